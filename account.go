@@ -36,6 +36,10 @@ import (
 type Account struct {
 	name       string
 	fullRescan bool
+	policy     *SpendPolicy
+	vault      *VaultRule
+	sweepRule  *SweepRule
+	reserved   map[btcwire.OutPoint]struct{}
 	*wallet.Wallet
 	TxStore *txstore.Store
 }
@@ -47,8 +51,9 @@ func (a *Account) Lock() error {
 		NotifyWalletLockStateChange(a.Name(), true)
 		return nil
 
-	case wallet.ErrWalletLocked:
+	case wallet.ErrWalletLocked, wallet.ErrWalletIsWatchingOnly:
 		// Do not pass wallet already locked errors to the caller.
+		// Watching-only wallets have no keys to lock.
 		return nil
 
 	default:
@@ -56,8 +61,13 @@ func (a *Account) Lock() error {
 	}
 }
 
-// Unlock unlocks the underlying wallet for an account.
+// Unlock unlocks the underlying wallet for an account.  Watching-only
+// wallets have no keys to unlock, so unlocking them always succeeds.
 func (a *Account) Unlock(passphrase []byte) error {
+	if a.Wallet.WatchingOnly() {
+		return nil
+	}
+
 	if err := a.Wallet.Unlock(passphrase); err != nil {
 		return err
 	}
@@ -304,7 +314,7 @@ func (a *Account) DumpPrivKeys() ([]string, error) {
 	for _, info := range a.Wallet.ActiveAddresses() {
 		// Only those addresses with keys needed.
 		pka, ok := info.(wallet.PubKeyAddress)
-		if !ok {
+		if !ok || a.IsWatchOnly(pka.Address()) {
 			continue
 		}
 		encKey, err := pka.ExportPrivKey()
@@ -348,16 +358,73 @@ func (a *Account) ImportPrivateKey(pk []byte, compressed bool,
 		return "", err
 	}
 
+	if err := a.trackImported(addr, rescan); err != nil {
+		return "", err
+	}
+
+	log.Infof("Imported payment address %s", addr.EncodeAddress())
+
+	// Return the payment address string of the imported private key.
+	return addr.EncodeAddress(), nil
+}
+
+// ImportWatchedAddress imports a pubkey hash address without a private key
+// to the account's wallet and writes the new wallet to disk.  The address
+// is watch-only: its balance and history are tracked, but its outputs are
+// never spent.
+func (a *Account) ImportWatchedAddress(addr btcutil.Address,
+	bs *wallet.BlockStamp, rescan bool) (string, error) {
+
+	addr, err := a.Wallet.ImportWatchedAddress(addr, bs)
+	if err != nil {
+		return "", err
+	}
+
+	if err := a.trackImported(addr, rescan); err != nil {
+		return "", err
+	}
+
+	log.Infof("Imported watch-only address %s", addr.EncodeAddress())
+	return addr.EncodeAddress(), nil
+}
+
+// ImportWatchedPubKey imports the pubkey hash address of a serialized
+// public key to the account's wallet without a private key, and writes
+// the new wallet to disk.  As with ImportWatchedAddress, the address is
+// watch-only.
+func (a *Account) ImportWatchedPubKey(pubkey []byte, bs *wallet.BlockStamp,
+	rescan bool) (string, error) {
+
+	addr, err := a.Wallet.ImportWatchedPubKey(pubkey, bs)
+	if err != nil {
+		return "", err
+	}
+
+	if err := a.trackImported(addr, rescan); err != nil {
+		return "", err
+	}
+
+	log.Infof("Imported watch-only address %s", addr.EncodeAddress())
+	return addr.EncodeAddress(), nil
+}
+
+// trackImported finishes the import of an address to the account's wallet
+// by immediately writing the wallet to disk, optionally rescanning the
+// blockchain for the address, and associating the address with the
+// account.
+func (a *Account) trackImported(addr btcutil.Address, rescan bool) error {
 	// Immediately write wallet to disk.
 	AcctMgr.ds.ScheduleWalletWrite(a)
 	if err := AcctMgr.ds.FlushAccount(a); err != nil {
-		return "", fmt.Errorf("cannot write account: %v", err)
+		return fmt.Errorf("cannot write account: %v", err)
 	}
 
 	addrStr := addr.EncodeAddress()
 
-	// Rescan blockchain for transactions with txout scripts paying to the
-	// imported address.
+	// Request notifications for new transactions paying to the address,
+	// and rescan the blockchain for older transactions with txout scripts
+	// paying to it.
+	a.ReqNewTxsForAddress(addr)
 	if rescan {
 		addrs := []btcutil.Address{addr}
 		job := &RescanJob{
@@ -377,11 +444,7 @@ func (a *Account) ImportPrivateKey(pk []byte, compressed bool,
 
 	// Associate the imported address with this account.
 	AcctMgr.MarkAddressForAccount(addr, a)
-
-	log.Infof("Imported payment address %s", addrStr)
-
-	// Return the payment address string of the imported private key.
-	return addrStr, nil
+	return nil
 }
 
 // ExportToDirectory writes an account to a special export directory.  Any
@@ -601,6 +664,7 @@ func (a *Account) NewChangeAddress() (btcutil.Address, error) {
 }
 
 // RecoverAddresses recovers the next n chained addresses of a wallet.
+// For HD wallets, the next n change addresses are recovered as well.
 func (a *Account) RecoverAddresses(n int) error {
 	// Get info on the last chained address.  The rescan starts at the
 	// earliest block height the last chained address might appear at.
@@ -614,10 +678,17 @@ func (a *Account) RecoverAddresses(n int) error {
 	if err != nil {
 		return err
 	}
+	changeAddrs, err := a.Wallet.ExtendActiveChangeAddresses(n, cfg.KeypoolSize)
+	if err != nil {
+		return err
+	}
+	addrs = append(addrs, changeAddrs...)
 	addrStrs := make([]string, 0, len(addrs))
 	for i := range addrs {
+		AcctMgr.MarkAddressForAccount(addrs[i], a)
 		addrStrs = append(addrStrs, addrs[i].EncodeAddress())
 	}
+	AcctMgr.ds.ScheduleWalletWrite(a)
 
 	// Run a goroutine to rescan blockchain for recovered addresses.
 	go func(addrs []string) {
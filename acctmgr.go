@@ -206,6 +206,33 @@ func openSavedAccount(name string, cfg *config) (*Account, error) {
 		return nil, &walletOpenError{msg}
 	}
 
+	// Read the spending policy, if any.  Accounts are not opened without
+	// a policy which exists but cannot be read, as any send would then
+	// be unrestricted.
+	if a.policy, err = readSpendPolicy(name); err != nil {
+		msg := fmt.Sprintf("cannot read spending policy: %s", err)
+		return nil, &walletOpenError{msg}
+	}
+
+	// Likewise, read the vault rule, if any.
+	if a.vault, err = readVaultRule(name); err != nil {
+		msg := fmt.Sprintf("cannot read vault rule: %s", err)
+		return nil, &walletOpenError{msg}
+	}
+
+	// Read the automatic sweep rule, if any.
+	if a.sweepRule, err = readSweepRule(name); err != nil {
+		msg := fmt.Sprintf("cannot read sweep rule: %s", err)
+		return nil, &walletOpenError{msg}
+	}
+
+	// Read the outputs reserved by held transactions, so they are not
+	// spent by other transactions.
+	if a.reserved, err = readReservedOutputs(name); err != nil {
+		msg := fmt.Sprintf("cannot read reserved outputs: %s", err)
+		return nil, &walletOpenError{msg}
+	}
+
 	// Read tx file.  If this fails, return a errNoTxs error and let
 	// the caller decide if a rescan is necessary.
 	var finalErr error
@@ -377,6 +404,11 @@ func (am *AccountManager) rescanListener() {
 
 		case *RescanProgressMsg:
 			for acct, addrs := range e.Addresses {
+				if acct == nil {
+					// Addresses of swept keys are not
+					// saved by any account.
+					continue
+				}
 				for i := range addrs {
 					err := acct.SetSyncStatus(addrs[i], wallet.PartialSync(e.Height))
 					if err != nil {
@@ -402,6 +434,9 @@ func (am *AccountManager) rescanListener() {
 			n := 0
 			for acct, addrs := range e.Addresses {
 				n += len(addrs)
+				if acct == nil {
+					continue
+				}
 				for i := range addrs {
 					err := acct.SetSyncStatus(addrs[i], wallet.FullSync{})
 					if err != nil {
@@ -546,8 +581,8 @@ func (am *AccountManager) Rollback(height int32, hash *btcwire.ShaHash) {
 }
 
 // BlockNotify notifies all frontends of any changes from the new block,
-// including changed balances.  Each account is then set to be synced
-// with the latest block.
+// including changed balances, and runs the automatic sweep rule of each
+// account.  Each account is then set to be synced with the latest block.
 func (am *AccountManager) BlockNotify(bs *wallet.BlockStamp) {
 	for _, a := range am.AllAccounts() {
 		// TODO: need a flag or check that the utxo store was actually
@@ -559,6 +594,9 @@ func (am *AccountManager) BlockNotify(bs *wallet.BlockStamp) {
 		NotifyWalletBalanceUnconfirmed(allClients, a.name,
 			unconfirmed)
 
+		// Sweep any excess balance to cold storage.
+		a.runSweepRule(bs)
+
 		// If this is the default account, update the block all accounts
 		// are synced with, and schedule a wallet write.
 		if a.Name() == "" {
@@ -603,21 +641,155 @@ func (am *AccountManager) CalculateBalance(account string, minconf int) (float64
 }
 
 // CreateEncryptedWallet creates a new default account with a wallet file
-// encrypted with passphrase.
-func (am *AccountManager) CreateEncryptedWallet(passphrase []byte) error {
+// encrypted with passphrase.  The wallet's keys are derived from a new
+// mnemonic, which is returned so it may be written down as a backup.  The
+// mnemonic is not saved, and can not be shown again.
+func (am *AccountManager) CreateEncryptedWallet(passphrase []byte) (string, error) {
 	if len(am.AllAccounts()) != 0 {
-		return ErrWalletExists
+		return "", ErrWalletExists
 	}
 
 	// Get current block's height and hash.
 	bs, err := GetCurBlock()
+	if err != nil {
+		return "", err
+	}
+
+	mnemonic, err := wallet.GenerateMnemonic()
+	if err != nil {
+		return "", err
+	}
+	a, err := am.createMnemonicAccount(mnemonic, passphrase, &bs)
+	if err != nil {
+		return "", err
+	}
+
+	// Begin tracking account against a connected btcd.
+	a.Track()
+
+	return mnemonic, nil
+}
+
+// RestoreWallet recreates the default account from the mnemonic shown
+// when its wallet was created, encrypting the new wallet file with
+// passphrase.  The wallet's previously used addresses are recovered and
+// the blockchain is rescanned for their transaction history.
+func (am *AccountManager) RestoreWallet(mnemonic string, passphrase []byte) error {
+	if len(am.AllAccounts()) != 0 {
+		return ErrWalletExists
+	}
+
+	// The wallet's addresses may have been used at any block, so the
+	// rescan must start at the genesis block.
+	a, err := am.createMnemonicAccount(mnemonic, passphrase,
+		&wallet.BlockStamp{})
 	if err != nil {
 		return err
 	}
 
+	// Begin tracking account against a connected btcd, and recover
+	// addresses.  Up to one keypool of addresses is recovered from each
+	// chain.
+	a.Track()
+	return a.RecoverAddresses(int(cfg.KeypoolSize))
+}
+
+// RestoreFromShares recreates the default account's wallet from shares of
+// its root key and chaincode created by backupshares, encrypted with a new
+// passphrase.  As with RestoreWallet, addresses are recovered and the
+// blockchain is rescanned for their history, and ErrWalletExists is
+// returned if any accounts already exist.
+func (am *AccountManager) RestoreFromShares(shares []string,
+	passphrase []byte) error {
+
+	if len(am.AllAccounts()) != 0 {
+		return ErrWalletExists
+	}
+
+	wlt, err := wallet.NewWalletFromShares("", "Default acccount", shares,
+		passphrase, cfg.Net(), &wallet.BlockStamp{})
+	if err != nil {
+		return err
+	}
+
+	a := &Account{
+		Wallet:  wlt,
+		TxStore: txstore.New(),
+	}
+	if err := am.RegisterNewAccount(a); err != nil {
+		return err
+	}
+
+	a.Track()
+	return a.RecoverAddresses(int(cfg.KeypoolSize))
+}
+
+// createMnemonicAccount creates and registers a new default account with a
+// wallet deriving keys from the seed of a mnemonic.  The wallet file is
+// encrypted with passphrase.
+func (am *AccountManager) createMnemonicAccount(mnemonic string,
+	passphrase []byte, bs *wallet.BlockStamp) (*Account, error) {
+
+	seed, err := wallet.MnemonicSeed(mnemonic, "")
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		for i := range seed {
+			seed[i] = 0
+		}
+	}()
+
 	// Create new wallet in memory.
-	wlt, err := wallet.NewWallet("", "Default acccount", passphrase,
-		cfg.Net(), &bs, cfg.KeypoolSize)
+	wlt, err := wallet.NewWalletFromSeed("", "Default acccount", seed,
+		passphrase, cfg.Net(), bs)
+	if err != nil {
+		return nil, err
+	}
+
+	// Create new account and begin managing with the global account
+	// manager.  Registering will fail if the new account can not be
+	// written immediately to disk.
+	a := &Account{
+		Wallet:  wlt,
+		TxStore: txstore.New(),
+	}
+	if err := am.RegisterNewAccount(a); err != nil {
+		return nil, err
+	}
+	return a, nil
+}
+
+// ImportWatchingChain creates and registers a new watching-only account
+// with the name account, deriving the same addresses as the account which
+// exported the watching chain.  The new account's addresses are tracked
+// like those of any other account.  ErrWalletExists is returned if the
+// account already exists.
+func (am *AccountManager) ImportWatchingChain(account, chain string) error {
+	switch _, err := am.Account(account); err {
+	case nil:
+		return ErrWalletExists
+
+	case ErrNotFound:
+		break
+
+	default:
+		return err
+	}
+
+	// Account names are used in account filenames.
+	if strings.ContainsAny(account, `/\`) {
+		return errors.New("account name may not contain path separators")
+	}
+
+	// Get current block's height and hash.
+	bs, err := GetCurBlock()
+	if err != nil {
+		return err
+	}
+
+	wlt, err := wallet.NewWatchingWallet(account, "Watching-only account",
+		chain, cfg.Net(), &bs)
 	if err != nil {
 		return err
 	}
@@ -626,6 +798,7 @@ func (am *AccountManager) CreateEncryptedWallet(passphrase []byte) error {
 	// manager.  Registering will fail if the new account can not be
 	// written immediately to disk.
 	a := &Account{
+		name:    account,
 		Wallet:  wlt,
 		TxStore: txstore.New(),
 	}
@@ -642,7 +815,13 @@ func (am *AccountManager) CreateEncryptedWallet(passphrase []byte) error {
 // ChangePassphrase unlocks all account wallets with the old
 // passphrase, and re-encrypts each using the new passphrase.
 func (am *AccountManager) ChangePassphrase(old, new []byte) error {
-	accts := am.AllAccounts()
+	// Watching-only wallets have no keys to re-encrypt.
+	var accts []*Account
+	for _, a := range am.AllAccounts() {
+		if !a.Wallet.WatchingOnly() {
+			accts = append(accts, a)
+		}
+	}
 
 	for _, a := range accts {
 		if locked := a.Wallet.IsLocked(); !locked {
@@ -704,6 +883,11 @@ func (am *AccountManager) UnlockWallets(passphrase string) error {
 func (am *AccountManager) DumpKeys() ([]string, error) {
 	var keys []string
 	for _, a := range am.AllAccounts() {
+		// Watching-only wallets have no keys to dump.
+		if a.Wallet.WatchingOnly() {
+			continue
+		}
+
 		switch walletKeys, err := a.DumpPrivKeys(); err {
 		case wallet.ErrWalletLocked:
 			return nil, err
@@ -821,6 +1005,14 @@ func (am *AccountManager) ListUnspent(minconf, maxconf int,
 			return nil, err
 		}
 		for _, credit := range unspent {
+			// Locked outputs are not spendable and are only
+			// reported by listlockunspent.  Outputs reserved by
+			// held transactions are not spendable either.
+			op := credit.OutPoint()
+			if credit.Locked() || a.outputReserved(op) {
+				continue
+			}
+
 			confs := credit.Confirmations(bs.Height)
 			if int(confs) < minconf || int(confs) > maxconf {
 				continue
@@ -860,6 +1052,88 @@ func (am *AccountManager) ListUnspent(minconf, maxconf int,
 	return results, nil
 }
 
+// LockUnspent locks or unlocks each unspent output referenced by ops,
+// searching through every account for the credit.  If unlock is true and
+// ops is empty, every locked output of every account is unlocked.  Changed
+// transaction stores are immediately written to disk so the locks are
+// kept across restarts.  ErrNotFound is returned, and no lock is changed,
+// if any outpoint does not reference an unspent output of some account.
+// Outputs reserved by held transactions are not locked outputs, and stay
+// reserved when they are unlocked.
+func (am *AccountManager) LockUnspent(unlock bool, ops []btcwire.OutPoint) error {
+	accts := am.AllAccounts()
+	modified := make(map[*Account]struct{})
+
+	if unlock && len(ops) == 0 {
+		for _, a := range accts {
+			if err := a.TxStore.UnlockAllOutputs(); err != nil {
+				return err
+			}
+			modified[a] = struct{}{}
+		}
+	}
+
+	// Find the account holding each referenced output before changing
+	// any locks, so that either every lock is changed or none are.
+	owners := make(map[btcwire.OutPoint]*Account, len(ops))
+	for _, a := range accts {
+		unspent, err := a.TxStore.UnspentOutputs()
+		if err != nil {
+			return err
+		}
+		for _, c := range unspent {
+			owners[*c.OutPoint()] = a
+		}
+	}
+	for i := range ops {
+		if _, ok := owners[ops[i]]; !ok {
+			return ErrNotFound
+		}
+	}
+
+	for i := range ops {
+		a := owners[ops[i]]
+		var err error
+		if unlock {
+			err = a.TxStore.UnlockOutput(&ops[i])
+		} else {
+			err = a.TxStore.LockOutput(&ops[i])
+		}
+		if err != nil {
+			return err
+		}
+		modified[a] = struct{}{}
+	}
+
+	for a := range modified {
+		am.ds.ScheduleTxStoreWrite(a)
+		if err := am.ds.FlushAccount(a); err != nil {
+			return fmt.Errorf("cannot write account: %v", err)
+		}
+	}
+	return nil
+}
+
+// ListLockUnspent returns the outpoints of all locked unspent outputs for
+// every account.
+func (am *AccountManager) ListLockUnspent() ([]btcjson.TransactionInput, error) {
+	results := []btcjson.TransactionInput{}
+	for _, a := range am.AllAccounts() {
+		locked, err := a.TxStore.LockedOutputs()
+		if err != nil {
+			return nil, err
+		}
+		for _, c := range locked {
+			op := c.OutPoint()
+			results = append(results, btcjson.TransactionInput{
+				Txid: op.Hash.String(),
+				Vout: int(op.Index),
+			})
+		}
+	}
+	return results, nil
+}
+
 // RescanActiveAddresses begins a rescan for all active addresses for
 // each account.
 func (am *AccountManager) RescanActiveAddresses() error {
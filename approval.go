@@ -0,0 +1,234 @@
+/*
+ * Copyright (c) 2014 Conformal Systems LLC <info@conformal.com>
+ *
+ * Permission to use, copy, modify, and distribute this software for any
+ * purpose with or without fee is hereby granted, provided that the above
+ * copyright notice and this permission notice appear in all copies.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+ * WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+ * MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ * ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+ * WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ * ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+ * OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ */
+
+package main
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"github.com/conformal/btcjson"
+	"io"
+	"os"
+	"time"
+)
+
+// ErrMethodNotPermitted describes an error where a client requests a method
+// which may not be called with the login it authenticated with.
+var ErrMethodNotPermitted = btcjson.Error{
+	Code:    btcjson.ErrWallet.Code,
+	Message: "method not permitted with this login",
+}
+
+// pendingSendsFilename is the name of the file in the network directory
+// holding sends waiting for approval.
+const pendingSendsFilename = "pendingsends.bin"
+
+// pendingSendsVersion is the current version of the serialized pending
+// sends.
+const pendingSendsVersion uint32 = 1
+
+// maxPendingSends is the maximum number of sends read from saved pending
+// sends.
+const maxPendingSends = 100000
+
+// approverMethods holds every method which may be called by clients
+// authenticated with the send approval login.
+var approverMethods = map[string]struct{}{
+	"approvesend":      {},
+	"cancelvaultsend":  {},
+	"listpendingsends": {},
+	"listvaultsends":   {},
+	"rejectsend":       {},
+}
+
+// userDeniedMethods holds every method which may not be called by clients
+// authenticated with the normal login while send approval is enabled.
+// These export private keys, or sign or broadcast transactions without
+// holding them for approval.
+var userDeniedMethods = map[string]struct{}{
+	"approvesend":        {},
+	"backupshares":       {},
+	"dumpprivkey":        {},
+	"dumpwallet":         {},
+	"sendrawtransaction": {},
+	"signpartialtx":      {},
+	"signrawtransaction": {},
+}
+
+// methodPermitted returns whether a client authenticated with the login
+// auth may call method.  Only the send approval login may approve sends,
+// and it may not call any method unrelated to approving, rejecting, or
+// cancelling sends, so neither login is able to move funds alone.
+func methodPermitted(method string, auth clientAuth) bool {
+	if auth == authApprover {
+		_, ok := approverMethods[method]
+		return ok
+	}
+	if !cfg.SendApproval() {
+		return method != "approvesend"
+	}
+	_, ok := userDeniedMethods[method]
+	return !ok
+}
+
+// pendingSend is a signed transaction held until it is approved or
+// rejected.
+type pendingSend struct {
+	id      string
+	created time.Time
+	tx      *heldTx
+}
+
+// PendingSends holds every send waiting for approval.
+var PendingSends = &PendingSendQueue{}
+
+// PendingSendQueue is a persistent queue of signed transactions created by
+// sends while send approval is enabled.  The inputs of each pending
+// transaction are reserved until it is approved and sent, or rejected.
+//
+// As the queue is only modified by RPC handlers, it is protected by the
+// account manager's semaphore rather than a mutex.
+type PendingSendQueue struct {
+	sends []*pendingSend
+}
+
+// Start loads the previously saved pending sends from disk, if any.  This
+// must be called before any requests are handled.
+func (q *PendingSendQueue) Start() {
+	err := loadNetworkFile(pendingSendsFilename, q.read)
+	if err != nil && !os.IsNotExist(err) {
+		log.Warnf("Cannot read sends waiting for approval: %v", err)
+	}
+}
+
+// Add holds a signed transaction created by account a until it is
+// approved or rejected, and returns the ID used to approve or reject it.
+// The transaction's inputs are reserved and the queue is saved.
+func (q *PendingSendQueue) Add(a *Account, createdTx *CreatedTx) (string, error) {
+	id, err := newRandomID()
+	if err != nil {
+		return "", err
+	}
+
+	h, err := a.holdCreatedTx(createdTx)
+	if err != nil {
+		return "", err
+	}
+	p := &pendingSend{
+		id:      id,
+		created: time.Now(),
+		tx:      h,
+	}
+	q.sends = append(q.sends, p)
+	if err := q.save(); err != nil {
+		return "", err
+	}
+
+	log.Infof("Holding transaction %v for approval with ID %s",
+		h.tx.Sha(), p.id)
+	return p.id, nil
+}
+
+// Find returns the pending send with ID id, or nil if there is no such
+// send.
+func (q *PendingSendQueue) Find(id string) *pendingSend {
+	for _, p := range q.sends {
+		if p.id == id {
+			return p
+		}
+	}
+	return nil
+}
+
+// Remove removes the pending send with ID id, if any, and saves the queue.
+// The inputs of the removed transaction are not released.
+func (q *PendingSendQueue) Remove(id string) error {
+	for i, p := range q.sends {
+		if p.id == id {
+			q.sends = append(q.sends[:i], q.sends[i+1:]...)
+			return q.save()
+		}
+	}
+	return nil
+}
+
+// save writes the queue to disk, replacing any previously saved queue.
+func (q *PendingSendQueue) save() error {
+	return saveNetworkFile(pendingSendsFilename, q.write)
+}
+
+// write serializes the queue to w.
+func (q *PendingSendQueue) write(w io.Writer) error {
+	if err := binary.Write(w, binary.LittleEndian, pendingSendsVersion); err != nil {
+		return err
+	}
+	if err := binary.Write(w, binary.LittleEndian, uint32(len(q.sends))); err != nil {
+		return err
+	}
+	for _, p := range q.sends {
+		if err := writeString(w, p.id); err != nil {
+			return err
+		}
+		if err := binary.Write(w, binary.LittleEndian, p.created.Unix()); err != nil {
+			return err
+		}
+		if err := writeHeldTx(w, p.tx); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// read deserializes a queue written by write, replacing all pending sends.
+func (q *PendingSendQueue) read(r io.Reader) error {
+	var vers, n uint32
+	if err := binary.Read(r, binary.LittleEndian, &vers); err != nil {
+		return err
+	}
+	if vers != pendingSendsVersion {
+		return fmt.Errorf("unknown pending sends version %d", vers)
+	}
+	if err := binary.Read(r, binary.LittleEndian, &n); err != nil {
+		return err
+	}
+	if n > maxPendingSends {
+		return errors.New("too many saved pending sends")
+	}
+
+	sends := make([]*pendingSend, 0, n)
+	for i := uint32(0); i < n; i++ {
+		id, err := readString(r, maxRandomIDLen)
+		if err != nil {
+			return err
+		}
+		var created int64
+		if err := binary.Read(r, binary.LittleEndian, &created); err != nil {
+			return err
+		}
+		h, err := readHeldTx(r)
+		if err != nil {
+			return err
+		}
+		sends = append(sends, &pendingSend{
+			id:      id,
+			created: time.Unix(created, 0),
+			tx:      h,
+		})
+	}
+	q.sends = sends
+	return nil
+}
@@ -0,0 +1,222 @@
+/*
+ * Copyright (c) 2014 Conformal Systems LLC <info@conformal.com>
+ *
+ * Permission to use, copy, modify, and distribute this software for any
+ * purpose with or without fee is hereby granted, provided that the above
+ * copyright notice and this permission notice appear in all copies.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+ * WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+ * MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ * ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+ * WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ * ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+ * OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ */
+
+package main
+
+import (
+	"encoding/binary"
+	"fmt"
+	"github.com/conformal/btcutil"
+	"github.com/conformal/btcwallet/wallet"
+	"io"
+	"os"
+)
+
+// sweepRuleFileSuffix is the filename suffix of the file holding an
+// account's automatic sweep rule.
+const sweepRuleFileSuffix = "sweeprule.bin"
+
+// sweepRuleVersion is the current version of serialized sweep rules.
+const sweepRuleVersion uint32 = 1
+
+// SweepRule moves excess funds out of an account once its balance grows
+// too large.  When the balance of outputs with at least MinConf
+// confirmations rises above HighWater, the excess down to LowWater is sent
+// to either Address, or the current address of the account named ToAccount
+// (for example, a watching-only account for cold storage), which only
+// changes once it has received funds.  The fee is deducted from the swept
+// amount.
+type SweepRule struct {
+	HighWater btcutil.Amount
+	LowWater  btcutil.Amount
+	MinConf   int32
+	Address   string
+	ToAccount string
+}
+
+// SetSweepRule replaces the automatic sweep rule of an account and writes
+// it to disk.  A nil rule removes any previous rule.  The wallet must be
+// unlocked to change the rule.
+func (a *Account) SetSweepRule(r *SweepRule) error {
+	if a.IsLocked() {
+		return wallet.ErrWalletLocked
+	}
+
+	if r == nil {
+		path := accountFilename(sweepRuleFileSuffix, a.name,
+			networkDir(cfg.Net()))
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	} else {
+		filename := accountFilename(sweepRuleFileSuffix, a.name, "")
+		if err := saveNetworkFile(filename, r.write); err != nil {
+			return err
+		}
+	}
+	a.sweepRule = r
+	return nil
+}
+
+// readSweepRule reads the sweep rule saved for the account named account.
+// If the account has no saved rule, nil is returned.
+func readSweepRule(account string) (*SweepRule, error) {
+	r := new(SweepRule)
+	filename := accountFilename(sweepRuleFileSuffix, account, "")
+	err := loadNetworkFile(filename, r.read)
+	switch {
+	case os.IsNotExist(err):
+		return nil, nil
+	case err != nil:
+		return nil, err
+	}
+	return r, nil
+}
+
+// sweepableBalance returns the balance of all outputs with at least
+// minConf confirmations which are neither locked nor reserved by held
+// transactions.
+func (a *Account) sweepableBalance(minConf int, bs *wallet.BlockStamp) (btcutil.Amount, error) {
+	bal, err := a.TxStore.Balance(minConf, bs.Height)
+	if err != nil {
+		return 0, err
+	}
+	unspent, err := a.TxStore.UnspentOutputs()
+	if err != nil {
+		return 0, err
+	}
+	for _, c := range unspent {
+		if !c.Locked() && !a.outputReserved(c.OutPoint()) {
+			continue
+		}
+		if c.Confirmed(minConf, bs.Height) {
+			bal -= c.Amount()
+		}
+	}
+	return bal, nil
+}
+
+// runSweepRule checks the account's sweep rule, if any, after the block
+// bs is connected, and sends the excess balance if it is above the high
+// water mark.  Sweeps are sent like any other send, and are subject to the
+// account's spending policy, send approval, and vault rule.  The result of
+// each sweep is only logged.
+func (a *Account) runSweepRule(bs *wallet.BlockStamp) {
+	r := a.sweepRule
+	if r == nil {
+		return
+	}
+
+	bal, err := a.sweepableBalance(int(r.MinConf), bs)
+	if err != nil {
+		log.Errorf("Cannot calculate balance of account %s to "+
+			"sweep: %v", a.name, err)
+		return
+	}
+	if bal <= r.HighWater {
+		return
+	}
+	excess := bal - r.LowWater
+	if a.IsLocked() {
+		log.Warnf("Cannot sweep %v from account %s: wallet is locked",
+			excess, a.name)
+		return
+	}
+
+	// Sweeps which fail are retried at every block, so the address of
+	// ToAccount is only advanced once it has been used, rather than
+	// creating a new address for each attempt.
+	addrStr := r.Address
+	if r.ToAccount != "" {
+		to, err := AcctMgr.Account(r.ToAccount)
+		if err != nil {
+			log.Errorf("Cannot sweep %v from account %s to "+
+				"account %s: %v", excess, a.name, r.ToAccount, err)
+			return
+		}
+		addr, err := to.CurrentAddress()
+		if err != nil {
+			log.Errorf("Cannot sweep %v from account %s to "+
+				"account %s: %v", excess, a.name, r.ToAccount, err)
+			return
+		}
+		addrStr = addr.EncodeAddress()
+	}
+
+	pairs := map[string]btcutil.Amount{addrStr: excess}
+	opts := &txOptions{subtractFeeFrom: []string{addrStr}}
+	reply, jsonErr := sendPairs(nil, a.name, pairs, int(r.MinConf), opts)
+	if jsonErr != nil {
+		log.Errorf("Cannot sweep %v from account %s to %s: %v",
+			excess, a.name, addrStr, jsonErr.Message)
+		return
+	}
+	log.Infof("Swept %v from account %s to %s (balance %v, high water "+
+		"mark %v): %v", excess, a.name, addrStr, bal, r.HighWater, reply)
+}
+
+// write serializes the rule to w.
+func (r *SweepRule) write(w io.Writer) error {
+	fields := []interface{}{
+		sweepRuleVersion,
+		int64(r.HighWater),
+		int64(r.LowWater),
+		r.MinConf,
+	}
+	for _, field := range fields {
+		if err := binary.Write(w, binary.LittleEndian, field); err != nil {
+			return err
+		}
+	}
+	if err := writeString(w, r.Address); err != nil {
+		return err
+	}
+	return writeString(w, r.ToAccount)
+}
+
+// read deserializes a rule written by write.
+func (r *SweepRule) read(rd io.Reader) error {
+	var vers uint32
+	var highWater, lowWater int64
+	var minConf int32
+	if err := binary.Read(rd, binary.LittleEndian, &vers); err != nil {
+		return err
+	}
+	if vers != sweepRuleVersion {
+		return fmt.Errorf("unknown sweep rule version %d", vers)
+	}
+	fields := []interface{}{&highWater, &lowWater, &minConf}
+	for _, field := range fields {
+		if err := binary.Read(rd, binary.LittleEndian, field); err != nil {
+			return err
+		}
+	}
+	addrStr, err := readString(rd, maxSpendPolicyAddressLen)
+	if err != nil {
+		return err
+	}
+	toAccount, err := readString(rd, maxSavedAccountNameLen)
+	if err != nil {
+		return err
+	}
+
+	r.HighWater = btcutil.Amount(highWater)
+	r.LowWater = btcutil.Amount(lowWater)
+	r.MinConf = minConf
+	r.Address = addrStr
+	r.ToAccount = toAccount
+	return nil
+}
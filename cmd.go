@@ -135,6 +135,24 @@ func main() {
 	// Start account manager and open accounts.
 	AcctMgr.Start()
 
+	// Begin estimating fee rates from connected blocks.
+	FeeEst.Start()
+
+	// Load transactions held until their lock times pass.
+	LockTimeTxs.Start()
+
+	// Load invoices to match against received transactions.
+	Invoices.Start()
+
+	// Load sends waiting for approval.
+	PendingSends.Start()
+
+	// Load sends delayed by vault rules.
+	VaultSends.Start()
+
+	// Load recurring payments.
+	ScheduledPayments.Start()
+
 	// Read CA file to verify a btcd TLS connection.
 	cafile, err := ioutil.ReadFile(cfg.CAFile)
 	if err != nil {
@@ -0,0 +1,4221 @@
+/*
+ * Copyright (c) 2014 Conformal Systems LLC <info@conformal.com>
+ *
+ * Permission to use, copy, modify, and distribute this software for any
+ * purpose with or without fee is hereby granted, provided that the above
+ * copyright notice and this permission notice appear in all copies.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+ * WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+ * MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ * ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+ * WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ * ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+ * OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ */
+
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"github.com/conformal/btcjson"
+)
+
+// This file implements btcwallet extension requests which are not yet
+// part of btcws.  Each is registered with btcjson as a custom command so
+// requests may be parsed from both HTTP POST and websocket clients.
+
+func init() {
+	btcjson.RegisterCustomCmd("addscheduledpayment",
+		parseAddScheduledPaymentCmd, nil,
+		`addscheduledpayment "account" "address" amount interval ("unit"="blocks")`)
+	btcjson.RegisterCustomCmd("approvescheduledpayment",
+		parseApproveScheduledPaymentCmd, nil,
+		`approvescheduledpayment "id" (approve=true)`)
+	btcjson.RegisterCustomCmd("approvesend", parseApproveSendCmd, nil,
+		`approvesend "id"`)
+	btcjson.RegisterCustomCmd("backupshares", parseBackupSharesCmd, nil,
+		`backupshares threshold count`)
+	btcjson.RegisterCustomCmd("bumpfee", parseBumpFeeCmd, nil,
+		`bumpfee "txid" targetrate`)
+	btcjson.RegisterCustomCmd("cancelvaultsend",
+		parseCancelVaultSendCmd, nil, `cancelvaultsend "id"`)
+	btcjson.RegisterCustomCmd("combinepartialtxs",
+		parseCombinePartialTxsCmd, nil, `combinepartialtxs ["hex",...]`)
+	btcjson.RegisterCustomCmd("consolidateutxos",
+		parseConsolidateUTXOsCmd, nil,
+		`consolidateutxos "account" maxinputs maxfeerate (minconf=1 dryrun=false)`)
+	btcjson.RegisterCustomCmd("createinvoice", parseCreateInvoiceCmd, nil,
+		`createinvoice amount expiry "memo" ("account"="")`)
+	btcjson.RegisterCustomCmd("createpartialtx",
+		parseCreatePartialTxCmd, nil,
+		`createpartialtx "fromaccount" {"address":amount,...} (minconf=1 {options})`)
+	btcjson.RegisterCustomCmd("finalizepartialtx",
+		parseFinalizePartialTxCmd, nil,
+		`finalizepartialtx "hex" (broadcast=true)`)
+	btcjson.RegisterCustomCmd("getpaymenturi", parseGetPaymentURICmd, nil,
+		`getpaymenturi "account" amount ("label")`)
+	btcjson.RegisterCustomCmd("getspendpolicy", parseGetSpendPolicyCmd, nil,
+		`getspendpolicy "account"`)
+	btcjson.RegisterCustomCmd("getsweeprules", parseGetSweepRulesCmd, nil,
+		`getsweeprules`)
+	btcjson.RegisterCustomCmd("importaddress", parseImportAddressCmd, nil,
+		`importaddress "address" ("account"="" rescan=true)`)
+	btcjson.RegisterCustomCmd("importpubkey", parseImportPubKeyCmd, nil,
+		`importpubkey "pubkey" ("account"="" rescan=true)`)
+	btcjson.RegisterCustomCmd("importwatchonlychain",
+		parseImportWatchOnlyChainCmd, nil,
+		`importwatchonlychain "account" "chain"`)
+	btcjson.RegisterCustomCmd("listpendingsends",
+		parseListPendingSendsCmd, nil, `listpendingsends`)
+	btcjson.RegisterCustomCmd("listscheduledpayments",
+		parseListScheduledPaymentsCmd, nil, `listscheduledpayments`)
+	btcjson.RegisterCustomCmd("listvaultsends",
+		parseListVaultSendsCmd, nil, `listvaultsends`)
+	btcjson.RegisterCustomCmd("rejectsend", parseRejectSendCmd, nil,
+		`rejectsend "id"`)
+	btcjson.RegisterCustomCmd("releasepartialtx",
+		parseReleasePartialTxCmd, nil, `releasepartialtx "hex"`)
+	btcjson.RegisterCustomCmd("removescheduledpayment",
+		parseRemoveScheduledPaymentCmd, nil, `removescheduledpayment "id"`)
+	btcjson.RegisterCustomCmd("restorefromshares",
+		parseRestoreFromSharesCmd, nil,
+		`restorefromshares ["share",...] "passphrase"`)
+	btcjson.RegisterCustomCmd("restorewallet", parseRestoreWalletCmd, nil,
+		`restorewallet "mnemonic" "passphrase"`)
+	btcjson.RegisterCustomCmd("sendall", parseSendAllCmd, nil,
+		`sendall "fromaccount" "toaddress" (minconf=1 {options})`)
+	btcjson.RegisterCustomCmd("senddata", parseSendDataCmd, nil,
+		`senddata "fromaccount" "hexdata" (minconf=1 {options})`)
+	btcjson.RegisterCustomCmd("sendfromwithoptions",
+		parseSendFromWithOptionsCmd, nil,
+		`sendfromwithoptions "fromaccount" "toaddress" amount (minconf=1 {options})`)
+	btcjson.RegisterCustomCmd("sendmanywithoptions",
+		parseSendManyWithOptionsCmd, nil,
+		`sendmanywithoptions "fromaccount" {"address":amount,...} (minconf=1 {options})`)
+	btcjson.RegisterCustomCmd("estimatefee", parseEstimateFeeCmd, nil,
+		`estimatefee nblocks`)
+	btcjson.RegisterCustomCmd("exportwatchonlychain",
+		parseExportWatchOnlyChainCmd, nil,
+		`exportwatchonlychain ("account"="")`)
+	btcjson.RegisterCustomCmd("sendwithinputs", parseSendWithInputsCmd, nil,
+		`sendwithinputs "fromaccount" [{"txid":"id","vout":n},...] {"address":amount,...} ({options})`)
+	btcjson.RegisterCustomCmd("sendtouri", parseSendToURICmd, nil,
+		`sendtouri "uri" ("fromaccount")`)
+	btcjson.RegisterCustomCmd("setspendpolicy", parseSetSpendPolicyCmd, nil,
+		`setspendpolicy "account" {"allowlist":["address",...],"maxpertx":amount,"dailylimit":amount}`)
+	btcjson.RegisterCustomCmd("setsweeprule", parseSetSweepRuleCmd, nil,
+		`setsweeprule "account" {"highwater":amount,"lowwater":amount,"address":"address","toaccount":"account","minconf":n}`)
+	btcjson.RegisterCustomCmd("settxfeerate", parseSetTxFeeRateCmd, nil,
+		`settxfeerate rate ("unit"="byte")`)
+	btcjson.RegisterCustomCmd("setvaultrule", parseSetVaultRuleCmd, nil,
+		`setvaultrule "account" threshold delay ("unit"="blocks")`)
+	btcjson.RegisterCustomCmd("signpartialtx", parseSignPartialTxCmd, nil,
+		`signpartialtx "hex"`)
+	btcjson.RegisterCustomCmd("sweepprivkey", parseSweepPrivKeyCmd, nil,
+		`sweepprivkey "privkey" ("account"="" startheight=0)`)
+}
+
+// SendOptions holds the optional settings, passed as a JSON object, which
+// change how a transaction is created by the sendfromwithoptions and
+// sendmanywithoptions extension requests.  Unset fields use the wallet's
+// defaults.
+type SendOptions struct {
+	// CoinSelect names the coin selection strategy used to choose
+	// transaction inputs.
+	CoinSelect string `json:"coinselect,omitempty"`
+
+	// FeeRate is the fee rate, in satoshis per byte, paid by the
+	// transaction instead of the rate set by settxfee.
+	FeeRate float64 `json:"feerate,omitempty"`
+
+	// SubtractFeeFrom holds output addresses the fee is deducted from,
+	// split evenly between them, instead of being paid in addition to
+	// the sent amounts.
+	SubtractFeeFrom []string `json:"subtractfeefrom,omitempty"`
+
+	// Data is hex encoded data carried by an additional zero-value null
+	// data output.
+	Data string `json:"data,omitempty"`
+
+	// SendAt is the lock time of the transaction, either a block height
+	// or, if at least 500000000, a Unix time.  The signed transaction is
+	// held by the wallet until the lock time passes.
+	SendAt int64 `json:"sendat,omitempty"`
+}
+
+// parseSendOptions parses the JSON object of a send options parameter.
+func parseSendOptions(raw json.RawMessage) (*SendOptions, error) {
+	opts := new(SendOptions)
+	if err := json.Unmarshal(raw, opts); err != nil {
+		return nil, errors.New("parameter 'options' must be an " +
+			"object: " + err.Error())
+	}
+	return opts, nil
+}
+
+// SendFromWithOptionsCmd is a type handling custom marshaling and
+// unmarshaling of sendfromwithoptions JSON extension commands.  It behaves
+// like the standard sendfrom request, with an additional options object.
+type SendFromWithOptionsCmd struct {
+	id          interface{}
+	FromAccount string
+	ToAddress   string
+	Amount      int64
+	MinConf     int
+	Options     *SendOptions
+}
+
+// Enforce that SendFromWithOptionsCmd satisifies the btcjson.Cmd
+// interface.
+var _ btcjson.Cmd = &SendFromWithOptionsCmd{}
+
+// NewSendFromWithOptionsCmd creates a new SendFromWithOptionsCmd.  Optional
+// arguments are the minconf (int) and send options (*SendOptions).
+func NewSendFromWithOptionsCmd(id interface{}, fromaccount, toaddress string,
+	amount int64, optArgs ...interface{}) (*SendFromWithOptionsCmd, error) {
+
+	if len(optArgs) > 2 {
+		return nil, btcjson.ErrTooManyOptArgs
+	}
+
+	minconf := 1
+	opts := new(SendOptions)
+	if len(optArgs) > 0 {
+		m, ok := optArgs[0].(int)
+		if !ok {
+			return nil, errors.New("first optional argument minconf is not an int")
+		}
+		minconf = m
+	}
+	if len(optArgs) > 1 {
+		o, ok := optArgs[1].(*SendOptions)
+		if !ok {
+			return nil, errors.New("second optional argument options is not a *SendOptions")
+		}
+		opts = o
+	}
+
+	return &SendFromWithOptionsCmd{
+		id:          id,
+		FromAccount: fromaccount,
+		ToAddress:   toaddress,
+		Amount:      amount,
+		MinConf:     minconf,
+		Options:     opts,
+	}, nil
+}
+
+// parseSendFromWithOptionsCmd parses a SendFromWithOptionsCmd into a
+// concrete type satisifying the btcjson.Cmd interface.  This is used when
+// registering the custom command with the btcjson parser.
+func parseSendFromWithOptionsCmd(r *btcjson.RawCmd) (btcjson.Cmd, error) {
+	if len(r.Params) < 3 || len(r.Params) > 5 {
+		return nil, btcjson.ErrWrongNumberOfParams
+	}
+
+	var fromaccount, toaddress string
+	if err := json.Unmarshal(r.Params[0], &fromaccount); err != nil {
+		return nil, errors.New("first parameter 'fromaccount' must be a string: " + err.Error())
+	}
+	if err := json.Unmarshal(r.Params[1], &toaddress); err != nil {
+		return nil, errors.New("second parameter 'toaddress' must be a string: " + err.Error())
+	}
+	var famount float64
+	if err := json.Unmarshal(r.Params[2], &famount); err != nil {
+		return nil, errors.New("third parameter 'amount' must be a number: " + err.Error())
+	}
+	amount, err := btcjson.JSONToAmount(famount)
+	if err != nil {
+		return nil, err
+	}
+
+	optArgs := make([]interface{}, 0, 2)
+	if len(r.Params) > 3 {
+		var minconf int
+		if err := json.Unmarshal(r.Params[3], &minconf); err != nil {
+			return nil, errors.New("fourth optional parameter 'minconf' must be an integer: " + err.Error())
+		}
+		optArgs = append(optArgs, minconf)
+	}
+	if len(r.Params) > 4 {
+		opts, err := parseSendOptions(r.Params[4])
+		if err != nil {
+			return nil, err
+		}
+		optArgs = append(optArgs, opts)
+	}
+
+	return NewSendFromWithOptionsCmd(r.Id, fromaccount, toaddress, amount,
+		optArgs...)
+}
+
+// Id satisifies the Cmd interface by returning the ID of the command.
+func (cmd *SendFromWithOptionsCmd) Id() interface{} {
+	return cmd.id
+}
+
+// Method satisifies the Cmd interface by returning the RPC method.
+func (cmd *SendFromWithOptionsCmd) Method() string {
+	return "sendfromwithoptions"
+}
+
+// MarshalJSON returns the JSON encoding of cmd.  Part of the Cmd interface.
+func (cmd *SendFromWithOptionsCmd) MarshalJSON() ([]byte, error) {
+	params := []interface{}{
+		cmd.FromAccount,
+		cmd.ToAddress,
+		float64(cmd.Amount) / 1e8,
+		cmd.MinConf,
+		cmd.Options,
+	}
+
+	raw, err := btcjson.NewRawCmd(cmd.id, cmd.Method(), params)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(raw)
+}
+
+// UnmarshalJSON unmarshals the JSON encoding of cmd into cmd.  Part of
+// the Cmd interface.
+func (cmd *SendFromWithOptionsCmd) UnmarshalJSON(b []byte) error {
+	// Unmarshal into a RawCmd.
+	var r btcjson.RawCmd
+	if err := json.Unmarshal(b, &r); err != nil {
+		return err
+	}
+
+	newCmd, err := parseSendFromWithOptionsCmd(&r)
+	if err != nil {
+		return err
+	}
+
+	concreteCmd, ok := newCmd.(*SendFromWithOptionsCmd)
+	if !ok {
+		return btcjson.ErrInternal
+	}
+	*cmd = *concreteCmd
+	return nil
+}
+
+// SendManyWithOptionsCmd is a type handling custom marshaling and
+// unmarshaling of sendmanywithoptions JSON extension commands.  It behaves
+// like the standard sendmany request, with an additional options object.
+type SendManyWithOptionsCmd struct {
+	id          interface{}
+	FromAccount string
+	Amounts     map[string]int64
+	MinConf     int
+	Options     *SendOptions
+}
+
+// Enforce that SendManyWithOptionsCmd satisifies the btcjson.Cmd
+// interface.
+var _ btcjson.Cmd = &SendManyWithOptionsCmd{}
+
+// NewSendManyWithOptionsCmd creates a new SendManyWithOptionsCmd.  Optional
+// arguments are the minconf (int) and send options (*SendOptions).
+func NewSendManyWithOptionsCmd(id interface{}, fromaccount string,
+	amounts map[string]int64, optArgs ...interface{}) (*SendManyWithOptionsCmd, error) {
+
+	if len(optArgs) > 2 {
+		return nil, btcjson.ErrTooManyOptArgs
+	}
+
+	minconf := 1
+	opts := new(SendOptions)
+	if len(optArgs) > 0 {
+		m, ok := optArgs[0].(int)
+		if !ok {
+			return nil, errors.New("first optional argument minconf is not an int")
+		}
+		minconf = m
+	}
+	if len(optArgs) > 1 {
+		o, ok := optArgs[1].(*SendOptions)
+		if !ok {
+			return nil, errors.New("second optional argument options is not a *SendOptions")
+		}
+		opts = o
+	}
+
+	return &SendManyWithOptionsCmd{
+		id:          id,
+		FromAccount: fromaccount,
+		Amounts:     amounts,
+		MinConf:     minconf,
+		Options:     opts,
+	}, nil
+}
+
+// parseSendManyWithOptionsCmd parses a SendManyWithOptionsCmd into a
+// concrete type satisifying the btcjson.Cmd interface.  This is used when
+// registering the custom command with the btcjson parser.
+func parseSendManyWithOptionsCmd(r *btcjson.RawCmd) (btcjson.Cmd, error) {
+	if len(r.Params) < 2 || len(r.Params) > 4 {
+		return nil, btcjson.ErrWrongNumberOfParams
+	}
+
+	var fromaccount string
+	if err := json.Unmarshal(r.Params[0], &fromaccount); err != nil {
+		return nil, errors.New("first parameter 'fromaccount' must be a string: " + err.Error())
+	}
+	var famounts map[string]float64
+	if err := json.Unmarshal(r.Params[1], &famounts); err != nil {
+		return nil, errors.New("second parameter 'amounts' must be a JSON object of address to amount mappings: " + err.Error())
+	}
+	amounts := make(map[string]int64, len(famounts))
+	for addr, famount := range famounts {
+		amount, err := btcjson.JSONToAmount(famount)
+		if err != nil {
+			return nil, err
+		}
+		amounts[addr] = amount
+	}
+
+	optArgs := make([]interface{}, 0, 2)
+	if len(r.Params) > 2 {
+		var minconf int
+		if err := json.Unmarshal(r.Params[2], &minconf); err != nil {
+			return nil, errors.New("third optional parameter 'minconf' must be an integer: " + err.Error())
+		}
+		optArgs = append(optArgs, minconf)
+	}
+	if len(r.Params) > 3 {
+		opts, err := parseSendOptions(r.Params[3])
+		if err != nil {
+			return nil, err
+		}
+		optArgs = append(optArgs, opts)
+	}
+
+	return NewSendManyWithOptionsCmd(r.Id, fromaccount, amounts, optArgs...)
+}
+
+// Id satisifies the Cmd interface by returning the ID of the command.
+func (cmd *SendManyWithOptionsCmd) Id() interface{} {
+	return cmd.id
+}
+
+// Method satisifies the Cmd interface by returning the RPC method.
+func (cmd *SendManyWithOptionsCmd) Method() string {
+	return "sendmanywithoptions"
+}
+
+// MarshalJSON returns the JSON encoding of cmd.  Part of the Cmd interface.
+func (cmd *SendManyWithOptionsCmd) MarshalJSON() ([]byte, error) {
+	amounts := make(map[string]float64, len(cmd.Amounts))
+	for addr, amount := range cmd.Amounts {
+		amounts[addr] = float64(amount) / 1e8
+	}
+	params := []interface{}{
+		cmd.FromAccount,
+		amounts,
+		cmd.MinConf,
+		cmd.Options,
+	}
+
+	raw, err := btcjson.NewRawCmd(cmd.id, cmd.Method(), params)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(raw)
+}
+
+// UnmarshalJSON unmarshals the JSON encoding of cmd into cmd.  Part of
+// the Cmd interface.
+func (cmd *SendManyWithOptionsCmd) UnmarshalJSON(b []byte) error {
+	// Unmarshal into a RawCmd.
+	var r btcjson.RawCmd
+	if err := json.Unmarshal(b, &r); err != nil {
+		return err
+	}
+
+	newCmd, err := parseSendManyWithOptionsCmd(&r)
+	if err != nil {
+		return err
+	}
+
+	concreteCmd, ok := newCmd.(*SendManyWithOptionsCmd)
+	if !ok {
+		return btcjson.ErrInternal
+	}
+	*cmd = *concreteCmd
+	return nil
+}
+
+// Units of the fee rate set by a settxfeerate request.
+const (
+	// FeeUnitPerByte is a rate in satoshis per byte.
+	FeeUnitPerByte = "byte"
+
+	// FeeUnitPerKB is a rate in BTC per kilobyte, as used by the
+	// standard settxfee request.
+	FeeUnitPerKB = "kb"
+)
+
+// SetTxFeeRateCmd is a type handling custom marshaling and unmarshaling of
+// settxfeerate JSON extension commands.
+type SetTxFeeRateCmd struct {
+	id   interface{}
+	Rate float64
+	Unit string
+}
+
+// Enforce that SetTxFeeRateCmd satisifies the btcjson.Cmd interface.
+var _ btcjson.Cmd = &SetTxFeeRateCmd{}
+
+// NewSetTxFeeRateCmd creates a new SetTxFeeRateCmd.  The optional argument
+// is the unit (string) of the rate, which defaults to FeeUnitPerByte.
+func NewSetTxFeeRateCmd(id interface{}, rate float64,
+	optArgs ...string) (*SetTxFeeRateCmd, error) {
+
+	if len(optArgs) > 1 {
+		return nil, btcjson.ErrTooManyOptArgs
+	}
+
+	unit := FeeUnitPerByte
+	if len(optArgs) > 0 {
+		unit = optArgs[0]
+	}
+
+	return &SetTxFeeRateCmd{
+		id:   id,
+		Rate: rate,
+		Unit: unit,
+	}, nil
+}
+
+// parseSetTxFeeRateCmd parses a SetTxFeeRateCmd into a concrete type
+// satisifying the btcjson.Cmd interface.  This is used when registering
+// the custom command with the btcjson parser.
+func parseSetTxFeeRateCmd(r *btcjson.RawCmd) (btcjson.Cmd, error) {
+	if len(r.Params) < 1 || len(r.Params) > 2 {
+		return nil, btcjson.ErrWrongNumberOfParams
+	}
+
+	var rate float64
+	if err := json.Unmarshal(r.Params[0], &rate); err != nil {
+		return nil, errors.New("first parameter 'rate' must be a number: " + err.Error())
+	}
+
+	optArgs := make([]string, 0, 1)
+	if len(r.Params) > 1 {
+		var unit string
+		if err := json.Unmarshal(r.Params[1], &unit); err != nil {
+			return nil, errors.New("second optional parameter 'unit' must be a string: " + err.Error())
+		}
+		optArgs = append(optArgs, unit)
+	}
+
+	return NewSetTxFeeRateCmd(r.Id, rate, optArgs...)
+}
+
+// Id satisifies the Cmd interface by returning the ID of the command.
+func (cmd *SetTxFeeRateCmd) Id() interface{} {
+	return cmd.id
+}
+
+// Method satisifies the Cmd interface by returning the RPC method.
+func (cmd *SetTxFeeRateCmd) Method() string {
+	return "settxfeerate"
+}
+
+// MarshalJSON returns the JSON encoding of cmd.  Part of the Cmd interface.
+func (cmd *SetTxFeeRateCmd) MarshalJSON() ([]byte, error) {
+	params := []interface{}{
+		cmd.Rate,
+		cmd.Unit,
+	}
+
+	raw, err := btcjson.NewRawCmd(cmd.id, cmd.Method(), params)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(raw)
+}
+
+// UnmarshalJSON unmarshals the JSON encoding of cmd into cmd.  Part of
+// the Cmd interface.
+func (cmd *SetTxFeeRateCmd) UnmarshalJSON(b []byte) error {
+	// Unmarshal into a RawCmd.
+	var r btcjson.RawCmd
+	if err := json.Unmarshal(b, &r); err != nil {
+		return err
+	}
+
+	newCmd, err := parseSetTxFeeRateCmd(&r)
+	if err != nil {
+		return err
+	}
+
+	concreteCmd, ok := newCmd.(*SetTxFeeRateCmd)
+	if !ok {
+		return btcjson.ErrInternal
+	}
+	*cmd = *concreteCmd
+	return nil
+}
+
+// EstimateFeeCmd is a type handling custom marshaling and unmarshaling of
+// estimatefee JSON extension commands.
+type EstimateFeeCmd struct {
+	id        interface{}
+	NumBlocks int64
+}
+
+// Enforce that EstimateFeeCmd satisifies the btcjson.Cmd interface.
+var _ btcjson.Cmd = &EstimateFeeCmd{}
+
+// NewEstimateFeeCmd creates a new EstimateFeeCmd.
+func NewEstimateFeeCmd(id interface{}, nblocks int64) *EstimateFeeCmd {
+	return &EstimateFeeCmd{
+		id:        id,
+		NumBlocks: nblocks,
+	}
+}
+
+// parseEstimateFeeCmd parses an EstimateFeeCmd into a concrete type
+// satisifying the btcjson.Cmd interface.  This is used when registering
+// the custom command with the btcjson parser.
+func parseEstimateFeeCmd(r *btcjson.RawCmd) (btcjson.Cmd, error) {
+	if len(r.Params) != 1 {
+		return nil, btcjson.ErrWrongNumberOfParams
+	}
+
+	var nblocks int64
+	if err := json.Unmarshal(r.Params[0], &nblocks); err != nil {
+		return nil, errors.New("first parameter 'nblocks' must be an integer: " + err.Error())
+	}
+
+	return NewEstimateFeeCmd(r.Id, nblocks), nil
+}
+
+// Id satisifies the Cmd interface by returning the ID of the command.
+func (cmd *EstimateFeeCmd) Id() interface{} {
+	return cmd.id
+}
+
+// Method satisifies the Cmd interface by returning the RPC method.
+func (cmd *EstimateFeeCmd) Method() string {
+	return "estimatefee"
+}
+
+// MarshalJSON returns the JSON encoding of cmd.  Part of the Cmd interface.
+func (cmd *EstimateFeeCmd) MarshalJSON() ([]byte, error) {
+	params := []interface{}{
+		cmd.NumBlocks,
+	}
+
+	raw, err := btcjson.NewRawCmd(cmd.id, cmd.Method(), params)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(raw)
+}
+
+// UnmarshalJSON unmarshals the JSON encoding of cmd into cmd.  Part of
+// the Cmd interface.
+func (cmd *EstimateFeeCmd) UnmarshalJSON(b []byte) error {
+	// Unmarshal into a RawCmd.
+	var r btcjson.RawCmd
+	if err := json.Unmarshal(b, &r); err != nil {
+		return err
+	}
+
+	newCmd, err := parseEstimateFeeCmd(&r)
+	if err != nil {
+		return err
+	}
+
+	concreteCmd, ok := newCmd.(*EstimateFeeCmd)
+	if !ok {
+		return btcjson.ErrInternal
+	}
+	*cmd = *concreteCmd
+	return nil
+}
+
+// BumpFeeCmd is a type handling custom marshaling and unmarshaling of
+// bumpfee JSON extension commands.  TargetRate is measured in satoshis per
+// byte.
+type BumpFeeCmd struct {
+	id         interface{}
+	TxID       string
+	TargetRate float64
+}
+
+// Enforce that BumpFeeCmd satisifies the btcjson.Cmd interface.
+var _ btcjson.Cmd = &BumpFeeCmd{}
+
+// NewBumpFeeCmd creates a new BumpFeeCmd.
+func NewBumpFeeCmd(id interface{}, txid string, targetrate float64) *BumpFeeCmd {
+	return &BumpFeeCmd{
+		id:         id,
+		TxID:       txid,
+		TargetRate: targetrate,
+	}
+}
+
+// parseBumpFeeCmd parses a BumpFeeCmd into a concrete type satisifying the
+// btcjson.Cmd interface.  This is used when registering the custom command
+// with the btcjson parser.
+func parseBumpFeeCmd(r *btcjson.RawCmd) (btcjson.Cmd, error) {
+	if len(r.Params) != 2 {
+		return nil, btcjson.ErrWrongNumberOfParams
+	}
+
+	var txid string
+	if err := json.Unmarshal(r.Params[0], &txid); err != nil {
+		return nil, errors.New("first parameter 'txid' must be a string: " + err.Error())
+	}
+	var targetrate float64
+	if err := json.Unmarshal(r.Params[1], &targetrate); err != nil {
+		return nil, errors.New("second parameter 'targetrate' must be a number: " + err.Error())
+	}
+
+	return NewBumpFeeCmd(r.Id, txid, targetrate), nil
+}
+
+// Id satisifies the Cmd interface by returning the ID of the command.
+func (cmd *BumpFeeCmd) Id() interface{} {
+	return cmd.id
+}
+
+// Method satisifies the Cmd interface by returning the RPC method.
+func (cmd *BumpFeeCmd) Method() string {
+	return "bumpfee"
+}
+
+// MarshalJSON returns the JSON encoding of cmd.  Part of the Cmd interface.
+func (cmd *BumpFeeCmd) MarshalJSON() ([]byte, error) {
+	params := []interface{}{
+		cmd.TxID,
+		cmd.TargetRate,
+	}
+
+	raw, err := btcjson.NewRawCmd(cmd.id, cmd.Method(), params)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(raw)
+}
+
+// UnmarshalJSON unmarshals the JSON encoding of cmd into cmd.  Part of
+// the Cmd interface.
+func (cmd *BumpFeeCmd) UnmarshalJSON(b []byte) error {
+	// Unmarshal into a RawCmd.
+	var r btcjson.RawCmd
+	if err := json.Unmarshal(b, &r); err != nil {
+		return err
+	}
+
+	newCmd, err := parseBumpFeeCmd(&r)
+	if err != nil {
+		return err
+	}
+
+	concreteCmd, ok := newCmd.(*BumpFeeCmd)
+	if !ok {
+		return btcjson.ErrInternal
+	}
+	*cmd = *concreteCmd
+	return nil
+}
+
+// SendWithInputsCmd is a type handling custom marshaling and unmarshaling
+// of sendwithinputs JSON extension commands.  The transaction created by
+// this request spends exactly the outputs referenced by Inputs.
+type SendWithInputsCmd struct {
+	id          interface{}
+	FromAccount string
+	Inputs      []btcjson.TransactionInput
+	Amounts     map[string]int64
+	Options     *SendOptions
+}
+
+// Enforce that SendWithInputsCmd satisifies the btcjson.Cmd interface.
+var _ btcjson.Cmd = &SendWithInputsCmd{}
+
+// NewSendWithInputsCmd creates a new SendWithInputsCmd.  The optional
+// argument is the send options (*SendOptions).
+func NewSendWithInputsCmd(id interface{}, fromaccount string,
+	inputs []btcjson.TransactionInput, amounts map[string]int64,
+	optArgs ...*SendOptions) (*SendWithInputsCmd, error) {
+
+	if len(optArgs) > 1 {
+		return nil, btcjson.ErrTooManyOptArgs
+	}
+
+	opts := new(SendOptions)
+	if len(optArgs) > 0 {
+		opts = optArgs[0]
+	}
+
+	return &SendWithInputsCmd{
+		id:          id,
+		FromAccount: fromaccount,
+		Inputs:      inputs,
+		Amounts:     amounts,
+		Options:     opts,
+	}, nil
+}
+
+// parseSendWithInputsCmd parses a SendWithInputsCmd into a concrete type
+// satisifying the btcjson.Cmd interface.  This is used when registering
+// the custom command with the btcjson parser.
+func parseSendWithInputsCmd(r *btcjson.RawCmd) (btcjson.Cmd, error) {
+	if len(r.Params) < 3 || len(r.Params) > 4 {
+		return nil, btcjson.ErrWrongNumberOfParams
+	}
+
+	var fromaccount string
+	if err := json.Unmarshal(r.Params[0], &fromaccount); err != nil {
+		return nil, errors.New("first parameter 'fromaccount' must be a string: " + err.Error())
+	}
+	var inputs []btcjson.TransactionInput
+	if err := json.Unmarshal(r.Params[1], &inputs); err != nil {
+		return nil, errors.New("second parameter 'inputs' must be an array of transaction inputs: " + err.Error())
+	}
+	var famounts map[string]float64
+	if err := json.Unmarshal(r.Params[2], &famounts); err != nil {
+		return nil, errors.New("third parameter 'amounts' must be a JSON object of address to amount mappings: " + err.Error())
+	}
+	amounts := make(map[string]int64, len(famounts))
+	for addr, famount := range famounts {
+		amount, err := btcjson.JSONToAmount(famount)
+		if err != nil {
+			return nil, err
+		}
+		amounts[addr] = amount
+	}
+
+	optArgs := make([]*SendOptions, 0, 1)
+	if len(r.Params) > 3 {
+		opts, err := parseSendOptions(r.Params[3])
+		if err != nil {
+			return nil, err
+		}
+		optArgs = append(optArgs, opts)
+	}
+
+	return NewSendWithInputsCmd(r.Id, fromaccount, inputs, amounts,
+		optArgs...)
+}
+
+// Id satisifies the Cmd interface by returning the ID of the command.
+func (cmd *SendWithInputsCmd) Id() interface{} {
+	return cmd.id
+}
+
+// Method satisifies the Cmd interface by returning the RPC method.
+func (cmd *SendWithInputsCmd) Method() string {
+	return "sendwithinputs"
+}
+
+// MarshalJSON returns the JSON encoding of cmd.  Part of the Cmd interface.
+func (cmd *SendWithInputsCmd) MarshalJSON() ([]byte, error) {
+	amounts := make(map[string]float64, len(cmd.Amounts))
+	for addr, amount := range cmd.Amounts {
+		amounts[addr] = float64(amount) / 1e8
+	}
+	params := []interface{}{
+		cmd.FromAccount,
+		cmd.Inputs,
+		amounts,
+		cmd.Options,
+	}
+
+	raw, err := btcjson.NewRawCmd(cmd.id, cmd.Method(), params)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(raw)
+}
+
+// UnmarshalJSON unmarshals the JSON encoding of cmd into cmd.  Part of
+// the Cmd interface.
+func (cmd *SendWithInputsCmd) UnmarshalJSON(b []byte) error {
+	// Unmarshal into a RawCmd.
+	var r btcjson.RawCmd
+	if err := json.Unmarshal(b, &r); err != nil {
+		return err
+	}
+
+	newCmd, err := parseSendWithInputsCmd(&r)
+	if err != nil {
+		return err
+	}
+
+	concreteCmd, ok := newCmd.(*SendWithInputsCmd)
+	if !ok {
+		return btcjson.ErrInternal
+	}
+	*cmd = *concreteCmd
+	return nil
+}
+
+// SweepPrivKeyCmd is a type handling custom marshaling and unmarshaling of
+// sweepprivkey JSON extension commands.  The private key is encoded in
+// wallet import format.  Outputs paying to the key are searched for in
+// blocks starting at StartHeight.
+type SweepPrivKeyCmd struct {
+	id          interface{}
+	PrivKey     string
+	Account     string
+	StartHeight int32
+}
+
+// Enforce that SweepPrivKeyCmd satisifies the btcjson.Cmd interface.
+var _ btcjson.Cmd = &SweepPrivKeyCmd{}
+
+// NewSweepPrivKeyCmd creates a new SweepPrivKeyCmd.  Optional arguments
+// are the account (string) to sweep funds to, which defaults to the
+// default account, and the height (int32) of the block to begin searching
+// for the key's outputs, which defaults to the genesis block.
+func NewSweepPrivKeyCmd(id interface{}, privkey string,
+	optArgs ...interface{}) (*SweepPrivKeyCmd, error) {
+
+	if len(optArgs) > 2 {
+		return nil, btcjson.ErrTooManyOptArgs
+	}
+
+	var account string
+	if len(optArgs) > 0 {
+		a, ok := optArgs[0].(string)
+		if !ok {
+			return nil, errors.New("first optional argument account is not a string")
+		}
+		account = a
+	}
+	var startheight int32
+	if len(optArgs) > 1 {
+		h, ok := optArgs[1].(int32)
+		if !ok {
+			return nil, errors.New("second optional argument startheight is not an int32")
+		}
+		startheight = h
+	}
+
+	return &SweepPrivKeyCmd{
+		id:          id,
+		PrivKey:     privkey,
+		Account:     account,
+		StartHeight: startheight,
+	}, nil
+}
+
+// parseSweepPrivKeyCmd parses a SweepPrivKeyCmd into a concrete type
+// satisifying the btcjson.Cmd interface.  This is used when registering
+// the custom command with the btcjson parser.
+func parseSweepPrivKeyCmd(r *btcjson.RawCmd) (btcjson.Cmd, error) {
+	if len(r.Params) < 1 || len(r.Params) > 3 {
+		return nil, btcjson.ErrWrongNumberOfParams
+	}
+
+	var privkey string
+	if err := json.Unmarshal(r.Params[0], &privkey); err != nil {
+		return nil, errors.New("first parameter 'privkey' must be a string: " + err.Error())
+	}
+
+	optArgs := make([]interface{}, 0, 2)
+	if len(r.Params) > 1 {
+		var account string
+		if err := json.Unmarshal(r.Params[1], &account); err != nil {
+			return nil, errors.New("second optional parameter 'account' must be a string: " + err.Error())
+		}
+		optArgs = append(optArgs, account)
+	}
+	if len(r.Params) > 2 {
+		var startheight int32
+		if err := json.Unmarshal(r.Params[2], &startheight); err != nil {
+			return nil, errors.New("third optional parameter 'startheight' must be an integer: " + err.Error())
+		}
+		optArgs = append(optArgs, startheight)
+	}
+
+	return NewSweepPrivKeyCmd(r.Id, privkey, optArgs...)
+}
+
+// Id satisifies the Cmd interface by returning the ID of the command.
+func (cmd *SweepPrivKeyCmd) Id() interface{} {
+	return cmd.id
+}
+
+// Method satisifies the Cmd interface by returning the RPC method.
+func (cmd *SweepPrivKeyCmd) Method() string {
+	return "sweepprivkey"
+}
+
+// MarshalJSON returns the JSON encoding of cmd.  Part of the Cmd interface.
+func (cmd *SweepPrivKeyCmd) MarshalJSON() ([]byte, error) {
+	params := []interface{}{
+		cmd.PrivKey,
+		cmd.Account,
+		cmd.StartHeight,
+	}
+
+	raw, err := btcjson.NewRawCmd(cmd.id, cmd.Method(), params)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(raw)
+}
+
+// UnmarshalJSON unmarshals the JSON encoding of cmd into cmd.  Part of
+// the Cmd interface.
+func (cmd *SweepPrivKeyCmd) UnmarshalJSON(b []byte) error {
+	// Unmarshal into a RawCmd.
+	var r btcjson.RawCmd
+	if err := json.Unmarshal(b, &r); err != nil {
+		return err
+	}
+
+	newCmd, err := parseSweepPrivKeyCmd(&r)
+	if err != nil {
+		return err
+	}
+
+	concreteCmd, ok := newCmd.(*SweepPrivKeyCmd)
+	if !ok {
+		return btcjson.ErrInternal
+	}
+	*cmd = *concreteCmd
+	return nil
+}
+
+// ConsolidateUTXOsCmd is a type handling custom marshaling and unmarshaling
+// of consolidateutxos JSON extension commands.  MaxFeeRate is measured in
+// satoshis per byte.  If DryRun is true, the consolidation is planned but
+// no transaction is created or sent.
+type ConsolidateUTXOsCmd struct {
+	id         interface{}
+	Account    string
+	MaxInputs  int
+	MaxFeeRate float64
+	MinConf    int
+	DryRun     bool
+}
+
+// Enforce that ConsolidateUTXOsCmd satisifies the btcjson.Cmd interface.
+var _ btcjson.Cmd = &ConsolidateUTXOsCmd{}
+
+// NewConsolidateUTXOsCmd creates a new ConsolidateUTXOsCmd.  Optional
+// arguments are the minimum number of confirmations (int) of consolidated
+// outputs, which defaults to 1, and whether to only plan the consolidation
+// (bool), which defaults to false.
+func NewConsolidateUTXOsCmd(id interface{}, account string, maxinputs int,
+	maxfeerate float64, optArgs ...interface{}) (*ConsolidateUTXOsCmd, error) {
+
+	if len(optArgs) > 2 {
+		return nil, btcjson.ErrTooManyOptArgs
+	}
+
+	minconf := 1
+	if len(optArgs) > 0 {
+		m, ok := optArgs[0].(int)
+		if !ok {
+			return nil, errors.New("first optional argument minconf is not an int")
+		}
+		minconf = m
+	}
+	var dryrun bool
+	if len(optArgs) > 1 {
+		d, ok := optArgs[1].(bool)
+		if !ok {
+			return nil, errors.New("second optional argument dryrun is not a bool")
+		}
+		dryrun = d
+	}
+
+	return &ConsolidateUTXOsCmd{
+		id:         id,
+		Account:    account,
+		MaxInputs:  maxinputs,
+		MaxFeeRate: maxfeerate,
+		MinConf:    minconf,
+		DryRun:     dryrun,
+	}, nil
+}
+
+// parseConsolidateUTXOsCmd parses a ConsolidateUTXOsCmd into a concrete
+// type satisifying the btcjson.Cmd interface.  This is used when
+// registering the custom command with the btcjson parser.
+func parseConsolidateUTXOsCmd(r *btcjson.RawCmd) (btcjson.Cmd, error) {
+	if len(r.Params) < 3 || len(r.Params) > 5 {
+		return nil, btcjson.ErrWrongNumberOfParams
+	}
+
+	var account string
+	if err := json.Unmarshal(r.Params[0], &account); err != nil {
+		return nil, errors.New("first parameter 'account' must be a string: " + err.Error())
+	}
+	var maxinputs int
+	if err := json.Unmarshal(r.Params[1], &maxinputs); err != nil {
+		return nil, errors.New("second parameter 'maxinputs' must be an integer: " + err.Error())
+	}
+	var maxfeerate float64
+	if err := json.Unmarshal(r.Params[2], &maxfeerate); err != nil {
+		return nil, errors.New("third parameter 'maxfeerate' must be a number: " + err.Error())
+	}
+
+	optArgs := make([]interface{}, 0, 2)
+	if len(r.Params) > 3 {
+		var minconf int
+		if err := json.Unmarshal(r.Params[3], &minconf); err != nil {
+			return nil, errors.New("fourth optional parameter 'minconf' must be an integer: " + err.Error())
+		}
+		optArgs = append(optArgs, minconf)
+	}
+	if len(r.Params) > 4 {
+		var dryrun bool
+		if err := json.Unmarshal(r.Params[4], &dryrun); err != nil {
+			return nil, errors.New("fifth optional parameter 'dryrun' must be a bool: " + err.Error())
+		}
+		optArgs = append(optArgs, dryrun)
+	}
+
+	return NewConsolidateUTXOsCmd(r.Id, account, maxinputs, maxfeerate,
+		optArgs...)
+}
+
+// Id satisifies the Cmd interface by returning the ID of the command.
+func (cmd *ConsolidateUTXOsCmd) Id() interface{} {
+	return cmd.id
+}
+
+// Method satisifies the Cmd interface by returning the RPC method.
+func (cmd *ConsolidateUTXOsCmd) Method() string {
+	return "consolidateutxos"
+}
+
+// MarshalJSON returns the JSON encoding of cmd.  Part of the Cmd interface.
+func (cmd *ConsolidateUTXOsCmd) MarshalJSON() ([]byte, error) {
+	params := []interface{}{
+		cmd.Account,
+		cmd.MaxInputs,
+		cmd.MaxFeeRate,
+		cmd.MinConf,
+		cmd.DryRun,
+	}
+
+	raw, err := btcjson.NewRawCmd(cmd.id, cmd.Method(), params)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(raw)
+}
+
+// UnmarshalJSON unmarshals the JSON encoding of cmd into cmd.  Part of
+// the Cmd interface.
+func (cmd *ConsolidateUTXOsCmd) UnmarshalJSON(b []byte) error {
+	// Unmarshal into a RawCmd.
+	var r btcjson.RawCmd
+	if err := json.Unmarshal(b, &r); err != nil {
+		return err
+	}
+
+	newCmd, err := parseConsolidateUTXOsCmd(&r)
+	if err != nil {
+		return err
+	}
+
+	concreteCmd, ok := newCmd.(*ConsolidateUTXOsCmd)
+	if !ok {
+		return btcjson.ErrInternal
+	}
+	*cmd = *concreteCmd
+	return nil
+}
+
+// ConsolidateUTXOsResult models the data returned by a consolidateutxos
+// request.  Amounts are in bitcoins and the fee rate is in satoshis per
+// byte.  TxID and Address are only set if the consolidation was sent.
+type ConsolidateUTXOsResult struct {
+	TxID    string  `json:"txid,omitempty"`
+	Address string  `json:"address,omitempty"`
+	Inputs  int     `json:"inputs"`
+	Amount  float64 `json:"amount"`
+	Fee     float64 `json:"fee"`
+	FeeRate float64 `json:"feerate"`
+	Size    int     `json:"size"`
+}
+
+// SendAllCmd is a type handling custom marshaling and unmarshaling of
+// sendall JSON extension commands.  Every eligible output of the account
+// is sent to a single address, with the fee subtracted from the sent
+// amount.
+type SendAllCmd struct {
+	id          interface{}
+	FromAccount string
+	ToAddress   string
+	MinConf     int
+	Options     *SendOptions
+}
+
+// Enforce that SendAllCmd satisifies the btcjson.Cmd interface.
+var _ btcjson.Cmd = &SendAllCmd{}
+
+// NewSendAllCmd creates a new SendAllCmd.  Optional arguments are the
+// minconf (int) and send options (*SendOptions).
+func NewSendAllCmd(id interface{}, fromaccount, toaddress string,
+	optArgs ...interface{}) (*SendAllCmd, error) {
+
+	if len(optArgs) > 2 {
+		return nil, btcjson.ErrTooManyOptArgs
+	}
+
+	minconf := 1
+	opts := new(SendOptions)
+	if len(optArgs) > 0 {
+		m, ok := optArgs[0].(int)
+		if !ok {
+			return nil, errors.New("first optional argument minconf is not an int")
+		}
+		minconf = m
+	}
+	if len(optArgs) > 1 {
+		o, ok := optArgs[1].(*SendOptions)
+		if !ok {
+			return nil, errors.New("second optional argument options is not a *SendOptions")
+		}
+		opts = o
+	}
+
+	return &SendAllCmd{
+		id:          id,
+		FromAccount: fromaccount,
+		ToAddress:   toaddress,
+		MinConf:     minconf,
+		Options:     opts,
+	}, nil
+}
+
+// parseSendAllCmd parses a SendAllCmd into a concrete type satisifying the
+// btcjson.Cmd interface.  This is used when registering the custom command
+// with the btcjson parser.
+func parseSendAllCmd(r *btcjson.RawCmd) (btcjson.Cmd, error) {
+	if len(r.Params) < 2 || len(r.Params) > 4 {
+		return nil, btcjson.ErrWrongNumberOfParams
+	}
+
+	var fromaccount, toaddress string
+	if err := json.Unmarshal(r.Params[0], &fromaccount); err != nil {
+		return nil, errors.New("first parameter 'fromaccount' must be a string: " + err.Error())
+	}
+	if err := json.Unmarshal(r.Params[1], &toaddress); err != nil {
+		return nil, errors.New("second parameter 'toaddress' must be a string: " + err.Error())
+	}
+
+	optArgs := make([]interface{}, 0, 2)
+	if len(r.Params) > 2 {
+		var minconf int
+		if err := json.Unmarshal(r.Params[2], &minconf); err != nil {
+			return nil, errors.New("third optional parameter 'minconf' must be an integer: " + err.Error())
+		}
+		optArgs = append(optArgs, minconf)
+	}
+	if len(r.Params) > 3 {
+		opts, err := parseSendOptions(r.Params[3])
+		if err != nil {
+			return nil, err
+		}
+		optArgs = append(optArgs, opts)
+	}
+
+	return NewSendAllCmd(r.Id, fromaccount, toaddress, optArgs...)
+}
+
+// Id satisifies the Cmd interface by returning the ID of the command.
+func (cmd *SendAllCmd) Id() interface{} {
+	return cmd.id
+}
+
+// Method satisifies the Cmd interface by returning the RPC method.
+func (cmd *SendAllCmd) Method() string {
+	return "sendall"
+}
+
+// MarshalJSON returns the JSON encoding of cmd.  Part of the Cmd interface.
+func (cmd *SendAllCmd) MarshalJSON() ([]byte, error) {
+	params := []interface{}{
+		cmd.FromAccount,
+		cmd.ToAddress,
+		cmd.MinConf,
+		cmd.Options,
+	}
+
+	raw, err := btcjson.NewRawCmd(cmd.id, cmd.Method(), params)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(raw)
+}
+
+// UnmarshalJSON unmarshals the JSON encoding of cmd into cmd.  Part of
+// the Cmd interface.
+func (cmd *SendAllCmd) UnmarshalJSON(b []byte) error {
+	// Unmarshal into a RawCmd.
+	var r btcjson.RawCmd
+	if err := json.Unmarshal(b, &r); err != nil {
+		return err
+	}
+
+	newCmd, err := parseSendAllCmd(&r)
+	if err != nil {
+		return err
+	}
+
+	concreteCmd, ok := newCmd.(*SendAllCmd)
+	if !ok {
+		return btcjson.ErrInternal
+	}
+	*cmd = *concreteCmd
+	return nil
+}
+
+// SendDataCmd is a type handling custom marshaling and unmarshaling of
+// senddata JSON extension commands.  The created transaction pays no
+// amounts other than any change, and carries the hex encoded Data in a
+// null data output.
+type SendDataCmd struct {
+	id          interface{}
+	FromAccount string
+	Data        string
+	MinConf     int
+	Options     *SendOptions
+}
+
+// Enforce that SendDataCmd satisifies the btcjson.Cmd interface.
+var _ btcjson.Cmd = &SendDataCmd{}
+
+// NewSendDataCmd creates a new SendDataCmd.  Optional arguments are the
+// minconf (int) and send options (*SendOptions).
+func NewSendDataCmd(id interface{}, fromaccount, data string,
+	optArgs ...interface{}) (*SendDataCmd, error) {
+
+	if len(optArgs) > 2 {
+		return nil, btcjson.ErrTooManyOptArgs
+	}
+
+	minconf := 1
+	opts := new(SendOptions)
+	if len(optArgs) > 0 {
+		m, ok := optArgs[0].(int)
+		if !ok {
+			return nil, errors.New("first optional argument minconf is not an int")
+		}
+		minconf = m
+	}
+	if len(optArgs) > 1 {
+		o, ok := optArgs[1].(*SendOptions)
+		if !ok {
+			return nil, errors.New("second optional argument options is not a *SendOptions")
+		}
+		opts = o
+	}
+
+	return &SendDataCmd{
+		id:          id,
+		FromAccount: fromaccount,
+		Data:        data,
+		MinConf:     minconf,
+		Options:     opts,
+	}, nil
+}
+
+// parseSendDataCmd parses a SendDataCmd into a concrete type satisifying
+// the btcjson.Cmd interface.  This is used when registering the custom
+// command with the btcjson parser.
+func parseSendDataCmd(r *btcjson.RawCmd) (btcjson.Cmd, error) {
+	if len(r.Params) < 2 || len(r.Params) > 4 {
+		return nil, btcjson.ErrWrongNumberOfParams
+	}
+
+	var fromaccount, data string
+	if err := json.Unmarshal(r.Params[0], &fromaccount); err != nil {
+		return nil, errors.New("first parameter 'fromaccount' must be a string: " + err.Error())
+	}
+	if err := json.Unmarshal(r.Params[1], &data); err != nil {
+		return nil, errors.New("second parameter 'hexdata' must be a string: " + err.Error())
+	}
+
+	optArgs := make([]interface{}, 0, 2)
+	if len(r.Params) > 2 {
+		var minconf int
+		if err := json.Unmarshal(r.Params[2], &minconf); err != nil {
+			return nil, errors.New("third optional parameter 'minconf' must be an integer: " + err.Error())
+		}
+		optArgs = append(optArgs, minconf)
+	}
+	if len(r.Params) > 3 {
+		opts, err := parseSendOptions(r.Params[3])
+		if err != nil {
+			return nil, err
+		}
+		optArgs = append(optArgs, opts)
+	}
+
+	return NewSendDataCmd(r.Id, fromaccount, data, optArgs...)
+}
+
+// Id satisifies the Cmd interface by returning the ID of the command.
+func (cmd *SendDataCmd) Id() interface{} {
+	return cmd.id
+}
+
+// Method satisifies the Cmd interface by returning the RPC method.
+func (cmd *SendDataCmd) Method() string {
+	return "senddata"
+}
+
+// MarshalJSON returns the JSON encoding of cmd.  Part of the Cmd interface.
+func (cmd *SendDataCmd) MarshalJSON() ([]byte, error) {
+	params := []interface{}{
+		cmd.FromAccount,
+		cmd.Data,
+		cmd.MinConf,
+		cmd.Options,
+	}
+
+	raw, err := btcjson.NewRawCmd(cmd.id, cmd.Method(), params)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(raw)
+}
+
+// UnmarshalJSON unmarshals the JSON encoding of cmd into cmd.  Part of
+// the Cmd interface.
+func (cmd *SendDataCmd) UnmarshalJSON(b []byte) error {
+	// Unmarshal into a RawCmd.
+	var r btcjson.RawCmd
+	if err := json.Unmarshal(b, &r); err != nil {
+		return err
+	}
+
+	newCmd, err := parseSendDataCmd(&r)
+	if err != nil {
+		return err
+	}
+
+	concreteCmd, ok := newCmd.(*SendDataCmd)
+	if !ok {
+		return btcjson.ErrInternal
+	}
+	*cmd = *concreteCmd
+	return nil
+}
+
+// SendToURICmd is a type handling custom marshaling and unmarshaling of
+// sendtouri JSON extension commands.  URI is a BIP0021 payment URI which
+// must request an amount.
+type SendToURICmd struct {
+	id          interface{}
+	URI         string
+	FromAccount string
+}
+
+// Enforce that SendToURICmd satisifies the btcjson.Cmd interface.
+var _ btcjson.Cmd = &SendToURICmd{}
+
+// NewSendToURICmd creates a new SendToURICmd.  The optional argument is
+// the account (string) to send from, which defaults to the default
+// account.
+func NewSendToURICmd(id interface{}, uri string,
+	optArgs ...string) (*SendToURICmd, error) {
+
+	if len(optArgs) > 1 {
+		return nil, btcjson.ErrTooManyOptArgs
+	}
+
+	var fromaccount string
+	if len(optArgs) > 0 {
+		fromaccount = optArgs[0]
+	}
+
+	return &SendToURICmd{
+		id:          id,
+		URI:         uri,
+		FromAccount: fromaccount,
+	}, nil
+}
+
+// parseSendToURICmd parses a SendToURICmd into a concrete type satisifying
+// the btcjson.Cmd interface.  This is used when registering the custom
+// command with the btcjson parser.
+func parseSendToURICmd(r *btcjson.RawCmd) (btcjson.Cmd, error) {
+	if len(r.Params) < 1 || len(r.Params) > 2 {
+		return nil, btcjson.ErrWrongNumberOfParams
+	}
+
+	var uri string
+	if err := json.Unmarshal(r.Params[0], &uri); err != nil {
+		return nil, errors.New("first parameter 'uri' must be a string: " + err.Error())
+	}
+
+	optArgs := make([]string, 0, 1)
+	if len(r.Params) > 1 {
+		var fromaccount string
+		if err := json.Unmarshal(r.Params[1], &fromaccount); err != nil {
+			return nil, errors.New("second optional parameter 'fromaccount' must be a string: " + err.Error())
+		}
+		optArgs = append(optArgs, fromaccount)
+	}
+
+	return NewSendToURICmd(r.Id, uri, optArgs...)
+}
+
+// Id satisifies the Cmd interface by returning the ID of the command.
+func (cmd *SendToURICmd) Id() interface{} {
+	return cmd.id
+}
+
+// Method satisifies the Cmd interface by returning the RPC method.
+func (cmd *SendToURICmd) Method() string {
+	return "sendtouri"
+}
+
+// MarshalJSON returns the JSON encoding of cmd.  Part of the Cmd interface.
+func (cmd *SendToURICmd) MarshalJSON() ([]byte, error) {
+	params := []interface{}{
+		cmd.URI,
+		cmd.FromAccount,
+	}
+
+	raw, err := btcjson.NewRawCmd(cmd.id, cmd.Method(), params)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(raw)
+}
+
+// UnmarshalJSON unmarshals the JSON encoding of cmd into cmd.  Part of
+// the Cmd interface.
+func (cmd *SendToURICmd) UnmarshalJSON(b []byte) error {
+	// Unmarshal into a RawCmd.
+	var r btcjson.RawCmd
+	if err := json.Unmarshal(b, &r); err != nil {
+		return err
+	}
+
+	newCmd, err := parseSendToURICmd(&r)
+	if err != nil {
+		return err
+	}
+
+	concreteCmd, ok := newCmd.(*SendToURICmd)
+	if !ok {
+		return btcjson.ErrInternal
+	}
+	*cmd = *concreteCmd
+	return nil
+}
+
+// GetPaymentURICmd is a type handling custom marshaling and unmarshaling
+// of getpaymenturi JSON extension commands.  A zero Amount requests no
+// specific amount.
+type GetPaymentURICmd struct {
+	id      interface{}
+	Account string
+	Amount  int64
+	Label   string
+}
+
+// Enforce that GetPaymentURICmd satisifies the btcjson.Cmd interface.
+var _ btcjson.Cmd = &GetPaymentURICmd{}
+
+// NewGetPaymentURICmd creates a new GetPaymentURICmd.  The optional
+// argument is the label (string) of the new payment address.
+func NewGetPaymentURICmd(id interface{}, account string, amount int64,
+	optArgs ...string) (*GetPaymentURICmd, error) {
+
+	if len(optArgs) > 1 {
+		return nil, btcjson.ErrTooManyOptArgs
+	}
+
+	var label string
+	if len(optArgs) > 0 {
+		label = optArgs[0]
+	}
+
+	return &GetPaymentURICmd{
+		id:      id,
+		Account: account,
+		Amount:  amount,
+		Label:   label,
+	}, nil
+}
+
+// parseGetPaymentURICmd parses a GetPaymentURICmd into a concrete type
+// satisifying the btcjson.Cmd interface.  This is used when registering
+// the custom command with the btcjson parser.
+func parseGetPaymentURICmd(r *btcjson.RawCmd) (btcjson.Cmd, error) {
+	if len(r.Params) < 2 || len(r.Params) > 3 {
+		return nil, btcjson.ErrWrongNumberOfParams
+	}
+
+	var account string
+	if err := json.Unmarshal(r.Params[0], &account); err != nil {
+		return nil, errors.New("first parameter 'account' must be a string: " + err.Error())
+	}
+	var famount float64
+	if err := json.Unmarshal(r.Params[1], &famount); err != nil {
+		return nil, errors.New("second parameter 'amount' must be a number: " + err.Error())
+	}
+	amount, err := btcjson.JSONToAmount(famount)
+	if err != nil {
+		return nil, err
+	}
+
+	optArgs := make([]string, 0, 1)
+	if len(r.Params) > 2 {
+		var label string
+		if err := json.Unmarshal(r.Params[2], &label); err != nil {
+			return nil, errors.New("third optional parameter 'label' must be a string: " + err.Error())
+		}
+		optArgs = append(optArgs, label)
+	}
+
+	return NewGetPaymentURICmd(r.Id, account, amount, optArgs...)
+}
+
+// Id satisifies the Cmd interface by returning the ID of the command.
+func (cmd *GetPaymentURICmd) Id() interface{} {
+	return cmd.id
+}
+
+// Method satisifies the Cmd interface by returning the RPC method.
+func (cmd *GetPaymentURICmd) Method() string {
+	return "getpaymenturi"
+}
+
+// MarshalJSON returns the JSON encoding of cmd.  Part of the Cmd interface.
+func (cmd *GetPaymentURICmd) MarshalJSON() ([]byte, error) {
+	params := []interface{}{
+		cmd.Account,
+		float64(cmd.Amount) / 1e8,
+		cmd.Label,
+	}
+
+	raw, err := btcjson.NewRawCmd(cmd.id, cmd.Method(), params)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(raw)
+}
+
+// UnmarshalJSON unmarshals the JSON encoding of cmd into cmd.  Part of
+// the Cmd interface.
+func (cmd *GetPaymentURICmd) UnmarshalJSON(b []byte) error {
+	// Unmarshal into a RawCmd.
+	var r btcjson.RawCmd
+	if err := json.Unmarshal(b, &r); err != nil {
+		return err
+	}
+
+	newCmd, err := parseGetPaymentURICmd(&r)
+	if err != nil {
+		return err
+	}
+
+	concreteCmd, ok := newCmd.(*GetPaymentURICmd)
+	if !ok {
+		return btcjson.ErrInternal
+	}
+	*cmd = *concreteCmd
+	return nil
+}
+
+// CreateInvoiceCmd is a type handling custom marshaling and unmarshaling of
+// createinvoice JSON extension commands.  Expiry is the number of seconds
+// the invoice may be paid for, or zero if the invoice never expires.
+type CreateInvoiceCmd struct {
+	id      interface{}
+	Amount  int64
+	Expiry  int64
+	Memo    string
+	Account string
+}
+
+// Enforce that CreateInvoiceCmd satisifies the btcjson.Cmd interface.
+var _ btcjson.Cmd = &CreateInvoiceCmd{}
+
+// NewCreateInvoiceCmd creates a new CreateInvoiceCmd.  The optional
+// argument is the account (string) paid by the invoice, which defaults to
+// the default account.
+func NewCreateInvoiceCmd(id interface{}, amount, expiry int64, memo string,
+	optArgs ...string) (*CreateInvoiceCmd, error) {
+
+	if len(optArgs) > 1 {
+		return nil, btcjson.ErrTooManyOptArgs
+	}
+
+	var account string
+	if len(optArgs) > 0 {
+		account = optArgs[0]
+	}
+
+	return &CreateInvoiceCmd{
+		id:      id,
+		Amount:  amount,
+		Expiry:  expiry,
+		Memo:    memo,
+		Account: account,
+	}, nil
+}
+
+// parseCreateInvoiceCmd parses a CreateInvoiceCmd into a concrete type
+// satisifying the btcjson.Cmd interface.  This is used when registering
+// the custom command with the btcjson parser.
+func parseCreateInvoiceCmd(r *btcjson.RawCmd) (btcjson.Cmd, error) {
+	if len(r.Params) < 3 || len(r.Params) > 4 {
+		return nil, btcjson.ErrWrongNumberOfParams
+	}
+
+	var famount float64
+	if err := json.Unmarshal(r.Params[0], &famount); err != nil {
+		return nil, errors.New("first parameter 'amount' must be a number: " + err.Error())
+	}
+	amount, err := btcjson.JSONToAmount(famount)
+	if err != nil {
+		return nil, err
+	}
+	var expiry int64
+	if err := json.Unmarshal(r.Params[1], &expiry); err != nil {
+		return nil, errors.New("second parameter 'expiry' must be an integer: " + err.Error())
+	}
+	var memo string
+	if err := json.Unmarshal(r.Params[2], &memo); err != nil {
+		return nil, errors.New("third parameter 'memo' must be a string: " + err.Error())
+	}
+
+	optArgs := make([]string, 0, 1)
+	if len(r.Params) > 3 {
+		var account string
+		if err := json.Unmarshal(r.Params[3], &account); err != nil {
+			return nil, errors.New("fourth optional parameter 'account' must be a string: " + err.Error())
+		}
+		optArgs = append(optArgs, account)
+	}
+
+	return NewCreateInvoiceCmd(r.Id, amount, expiry, memo, optArgs...)
+}
+
+// Id satisifies the Cmd interface by returning the ID of the command.
+func (cmd *CreateInvoiceCmd) Id() interface{} {
+	return cmd.id
+}
+
+// Method satisifies the Cmd interface by returning the RPC method.
+func (cmd *CreateInvoiceCmd) Method() string {
+	return "createinvoice"
+}
+
+// MarshalJSON returns the JSON encoding of cmd.  Part of the Cmd interface.
+func (cmd *CreateInvoiceCmd) MarshalJSON() ([]byte, error) {
+	params := []interface{}{
+		float64(cmd.Amount) / 1e8,
+		cmd.Expiry,
+		cmd.Memo,
+		cmd.Account,
+	}
+
+	raw, err := btcjson.NewRawCmd(cmd.id, cmd.Method(), params)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(raw)
+}
+
+// UnmarshalJSON unmarshals the JSON encoding of cmd into cmd.  Part of
+// the Cmd interface.
+func (cmd *CreateInvoiceCmd) UnmarshalJSON(b []byte) error {
+	// Unmarshal into a RawCmd.
+	var r btcjson.RawCmd
+	if err := json.Unmarshal(b, &r); err != nil {
+		return err
+	}
+
+	newCmd, err := parseCreateInvoiceCmd(&r)
+	if err != nil {
+		return err
+	}
+
+	concreteCmd, ok := newCmd.(*CreateInvoiceCmd)
+	if !ok {
+		return btcjson.ErrInternal
+	}
+	*cmd = *concreteCmd
+	return nil
+}
+
+// ApproveSendCmd is a type handling custom marshaling and unmarshaling of
+// approvesend JSON extension commands.
+type ApproveSendCmd struct {
+	id interface{}
+	ID string
+}
+
+// Enforce that ApproveSendCmd satisifies the btcjson.Cmd interface.
+var _ btcjson.Cmd = &ApproveSendCmd{}
+
+// NewApproveSendCmd creates a new ApproveSendCmd.
+func NewApproveSendCmd(id interface{}, sendID string) *ApproveSendCmd {
+	return &ApproveSendCmd{
+		id: id,
+		ID: sendID,
+	}
+}
+
+// parseApproveSendCmd parses a ApproveSendCmd into a concrete type satisifying
+// the btcjson.Cmd interface.  This is used when registering the custom
+// command with the btcjson parser.
+func parseApproveSendCmd(r *btcjson.RawCmd) (btcjson.Cmd, error) {
+	if len(r.Params) != 1 {
+		return nil, btcjson.ErrWrongNumberOfParams
+	}
+
+	var sendID string
+	if err := json.Unmarshal(r.Params[0], &sendID); err != nil {
+		return nil, errors.New("first parameter 'id' must be a string: " + err.Error())
+	}
+
+	return NewApproveSendCmd(r.Id, sendID), nil
+}
+
+// Id satisifies the Cmd interface by returning the ID of the command.
+func (cmd *ApproveSendCmd) Id() interface{} {
+	return cmd.id
+}
+
+// Method satisifies the Cmd interface by returning the RPC method.
+func (cmd *ApproveSendCmd) Method() string {
+	return "approvesend"
+}
+
+// MarshalJSON returns the JSON encoding of cmd.  Part of the Cmd interface.
+func (cmd *ApproveSendCmd) MarshalJSON() ([]byte, error) {
+	raw, err := btcjson.NewRawCmd(cmd.id, cmd.Method(), []interface{}{cmd.ID})
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(raw)
+}
+
+// UnmarshalJSON unmarshals the JSON encoding of cmd into cmd.  Part of
+// the Cmd interface.
+func (cmd *ApproveSendCmd) UnmarshalJSON(b []byte) error {
+	// Unmarshal into a RawCmd.
+	var r btcjson.RawCmd
+	if err := json.Unmarshal(b, &r); err != nil {
+		return err
+	}
+
+	newCmd, err := parseApproveSendCmd(&r)
+	if err != nil {
+		return err
+	}
+
+	concreteCmd, ok := newCmd.(*ApproveSendCmd)
+	if !ok {
+		return btcjson.ErrInternal
+	}
+	*cmd = *concreteCmd
+	return nil
+}
+
+// RejectSendCmd is a type handling custom marshaling and unmarshaling of
+// rejectsend JSON extension commands.
+type RejectSendCmd struct {
+	id interface{}
+	ID string
+}
+
+// Enforce that RejectSendCmd satisifies the btcjson.Cmd interface.
+var _ btcjson.Cmd = &RejectSendCmd{}
+
+// NewRejectSendCmd creates a new RejectSendCmd.
+func NewRejectSendCmd(id interface{}, sendID string) *RejectSendCmd {
+	return &RejectSendCmd{
+		id: id,
+		ID: sendID,
+	}
+}
+
+// parseRejectSendCmd parses a RejectSendCmd into a concrete type satisifying
+// the btcjson.Cmd interface.  This is used when registering the custom
+// command with the btcjson parser.
+func parseRejectSendCmd(r *btcjson.RawCmd) (btcjson.Cmd, error) {
+	if len(r.Params) != 1 {
+		return nil, btcjson.ErrWrongNumberOfParams
+	}
+
+	var sendID string
+	if err := json.Unmarshal(r.Params[0], &sendID); err != nil {
+		return nil, errors.New("first parameter 'id' must be a string: " + err.Error())
+	}
+
+	return NewRejectSendCmd(r.Id, sendID), nil
+}
+
+// Id satisifies the Cmd interface by returning the ID of the command.
+func (cmd *RejectSendCmd) Id() interface{} {
+	return cmd.id
+}
+
+// Method satisifies the Cmd interface by returning the RPC method.
+func (cmd *RejectSendCmd) Method() string {
+	return "rejectsend"
+}
+
+// MarshalJSON returns the JSON encoding of cmd.  Part of the Cmd interface.
+func (cmd *RejectSendCmd) MarshalJSON() ([]byte, error) {
+	raw, err := btcjson.NewRawCmd(cmd.id, cmd.Method(), []interface{}{cmd.ID})
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(raw)
+}
+
+// UnmarshalJSON unmarshals the JSON encoding of cmd into cmd.  Part of
+// the Cmd interface.
+func (cmd *RejectSendCmd) UnmarshalJSON(b []byte) error {
+	// Unmarshal into a RawCmd.
+	var r btcjson.RawCmd
+	if err := json.Unmarshal(b, &r); err != nil {
+		return err
+	}
+
+	newCmd, err := parseRejectSendCmd(&r)
+	if err != nil {
+		return err
+	}
+
+	concreteCmd, ok := newCmd.(*RejectSendCmd)
+	if !ok {
+		return btcjson.ErrInternal
+	}
+	*cmd = *concreteCmd
+	return nil
+}
+
+// ListPendingSendsCmd is a type handling custom marshaling and
+// unmarshaling of listpendingsends JSON extension commands.
+type ListPendingSendsCmd struct {
+	id interface{}
+}
+
+// Enforce that ListPendingSendsCmd satisifies the btcjson.Cmd interface.
+var _ btcjson.Cmd = &ListPendingSendsCmd{}
+
+// NewListPendingSendsCmd creates a new ListPendingSendsCmd.
+func NewListPendingSendsCmd(id interface{}) *ListPendingSendsCmd {
+	return &ListPendingSendsCmd{id: id}
+}
+
+// parseListPendingSendsCmd parses a ListPendingSendsCmd into a concrete
+// type satisifying the btcjson.Cmd interface.  This is used when
+// registering the custom command with the btcjson parser.
+func parseListPendingSendsCmd(r *btcjson.RawCmd) (btcjson.Cmd, error) {
+	if len(r.Params) != 0 {
+		return nil, btcjson.ErrWrongNumberOfParams
+	}
+	return NewListPendingSendsCmd(r.Id), nil
+}
+
+// Id satisifies the Cmd interface by returning the ID of the command.
+func (cmd *ListPendingSendsCmd) Id() interface{} {
+	return cmd.id
+}
+
+// Method satisifies the Cmd interface by returning the RPC method.
+func (cmd *ListPendingSendsCmd) Method() string {
+	return "listpendingsends"
+}
+
+// MarshalJSON returns the JSON encoding of cmd.  Part of the Cmd interface.
+func (cmd *ListPendingSendsCmd) MarshalJSON() ([]byte, error) {
+	raw, err := btcjson.NewRawCmd(cmd.id, cmd.Method(), []interface{}{})
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(raw)
+}
+
+// UnmarshalJSON unmarshals the JSON encoding of cmd into cmd.  Part of
+// the Cmd interface.
+func (cmd *ListPendingSendsCmd) UnmarshalJSON(b []byte) error {
+	// Unmarshal into a RawCmd.
+	var r btcjson.RawCmd
+	if err := json.Unmarshal(b, &r); err != nil {
+		return err
+	}
+
+	newCmd, err := parseListPendingSendsCmd(&r)
+	if err != nil {
+		return err
+	}
+
+	concreteCmd, ok := newCmd.(*ListPendingSendsCmd)
+	if !ok {
+		return btcjson.ErrInternal
+	}
+	*cmd = *concreteCmd
+	return nil
+}
+
+// PendingSendResult models the data returned for each transaction held
+// for approval by a listpendingsends request.  Amounts, keyed by the
+// addresses paid, and the fee are in bitcoins.  Change outputs are not
+// included.  The fee is zero if the inputs of the transaction are no
+// longer held by its account.
+type PendingSendResult struct {
+	ID      string             `json:"id"`
+	Account string             `json:"account"`
+	TxID    string             `json:"txid"`
+	Created int64              `json:"created"`
+	Amounts map[string]float64 `json:"amounts"`
+	Fee     float64            `json:"fee"`
+	Hex     string             `json:"hex"`
+}
+
+// SpendPolicyOptions holds the settings of an account spending policy,
+// passed as a JSON object.  Amounts are in bitcoins.  Omitted or zero
+// settings do not restrict sends.
+type SpendPolicyOptions struct {
+	// Allowlist holds every address the account may send to.
+	Allowlist []string `json:"allowlist,omitempty"`
+
+	// MaxPerTx is the maximum amount sent by a single transaction.
+	MaxPerTx float64 `json:"maxpertx,omitempty"`
+
+	// DailyLimit is the maximum total amount sent during the last 24
+	// hours.
+	DailyLimit float64 `json:"dailylimit,omitempty"`
+}
+
+// SpendPolicyResult models the data returned by a getspendpolicy request.
+// Amounts are in bitcoins, and Sent is the amount already sent by the
+// account during the last 24 hours.
+type SpendPolicyResult struct {
+	SpendPolicyOptions
+	Sent float64 `json:"sent24h"`
+}
+
+// GetSpendPolicyCmd is a type handling custom marshaling and unmarshaling
+// of getspendpolicy JSON extension commands.
+type GetSpendPolicyCmd struct {
+	id      interface{}
+	Account string
+}
+
+// Enforce that GetSpendPolicyCmd satisifies the btcjson.Cmd interface.
+var _ btcjson.Cmd = &GetSpendPolicyCmd{}
+
+// NewGetSpendPolicyCmd creates a new GetSpendPolicyCmd.
+func NewGetSpendPolicyCmd(id interface{}, account string) *GetSpendPolicyCmd {
+	return &GetSpendPolicyCmd{
+		id:      id,
+		Account: account,
+	}
+}
+
+// parseGetSpendPolicyCmd parses a GetSpendPolicyCmd into a concrete type
+// satisifying the btcjson.Cmd interface.  This is used when registering
+// the custom command with the btcjson parser.
+func parseGetSpendPolicyCmd(r *btcjson.RawCmd) (btcjson.Cmd, error) {
+	if len(r.Params) != 1 {
+		return nil, btcjson.ErrWrongNumberOfParams
+	}
+
+	var account string
+	if err := json.Unmarshal(r.Params[0], &account); err != nil {
+		return nil, errors.New("first parameter 'account' must be a string: " + err.Error())
+	}
+
+	return NewGetSpendPolicyCmd(r.Id, account), nil
+}
+
+// Id satisifies the Cmd interface by returning the ID of the command.
+func (cmd *GetSpendPolicyCmd) Id() interface{} {
+	return cmd.id
+}
+
+// Method satisifies the Cmd interface by returning the RPC method.
+func (cmd *GetSpendPolicyCmd) Method() string {
+	return "getspendpolicy"
+}
+
+// MarshalJSON returns the JSON encoding of cmd.  Part of the Cmd interface.
+func (cmd *GetSpendPolicyCmd) MarshalJSON() ([]byte, error) {
+	raw, err := btcjson.NewRawCmd(cmd.id, cmd.Method(), []interface{}{cmd.Account})
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(raw)
+}
+
+// UnmarshalJSON unmarshals the JSON encoding of cmd into cmd.  Part of
+// the Cmd interface.
+func (cmd *GetSpendPolicyCmd) UnmarshalJSON(b []byte) error {
+	// Unmarshal into a RawCmd.
+	var r btcjson.RawCmd
+	if err := json.Unmarshal(b, &r); err != nil {
+		return err
+	}
+
+	newCmd, err := parseGetSpendPolicyCmd(&r)
+	if err != nil {
+		return err
+	}
+
+	concreteCmd, ok := newCmd.(*GetSpendPolicyCmd)
+	if !ok {
+		return btcjson.ErrInternal
+	}
+	*cmd = *concreteCmd
+	return nil
+}
+
+// SetSpendPolicyCmd is a type handling custom marshaling and unmarshaling
+// of setspendpolicy JSON extension commands.  A policy without any
+// settings removes the account's policy.
+type SetSpendPolicyCmd struct {
+	id      interface{}
+	Account string
+	Policy  *SpendPolicyOptions
+}
+
+// Enforce that SetSpendPolicyCmd satisifies the btcjson.Cmd interface.
+var _ btcjson.Cmd = &SetSpendPolicyCmd{}
+
+// NewSetSpendPolicyCmd creates a new SetSpendPolicyCmd.
+func NewSetSpendPolicyCmd(id interface{}, account string,
+	policy *SpendPolicyOptions) *SetSpendPolicyCmd {
+
+	return &SetSpendPolicyCmd{
+		id:      id,
+		Account: account,
+		Policy:  policy,
+	}
+}
+
+// parseSetSpendPolicyCmd parses a SetSpendPolicyCmd into a concrete type
+// satisifying the btcjson.Cmd interface.  This is used when registering
+// the custom command with the btcjson parser.
+func parseSetSpendPolicyCmd(r *btcjson.RawCmd) (btcjson.Cmd, error) {
+	if len(r.Params) != 2 {
+		return nil, btcjson.ErrWrongNumberOfParams
+	}
+
+	var account string
+	if err := json.Unmarshal(r.Params[0], &account); err != nil {
+		return nil, errors.New("first parameter 'account' must be a string: " + err.Error())
+	}
+	policy := new(SpendPolicyOptions)
+	if err := json.Unmarshal(r.Params[1], policy); err != nil {
+		return nil, errors.New("second parameter 'policy' must be an object: " + err.Error())
+	}
+
+	return NewSetSpendPolicyCmd(r.Id, account, policy), nil
+}
+
+// Id satisifies the Cmd interface by returning the ID of the command.
+func (cmd *SetSpendPolicyCmd) Id() interface{} {
+	return cmd.id
+}
+
+// Method satisifies the Cmd interface by returning the RPC method.
+func (cmd *SetSpendPolicyCmd) Method() string {
+	return "setspendpolicy"
+}
+
+// MarshalJSON returns the JSON encoding of cmd.  Part of the Cmd interface.
+func (cmd *SetSpendPolicyCmd) MarshalJSON() ([]byte, error) {
+	params := []interface{}{
+		cmd.Account,
+		cmd.Policy,
+	}
+
+	raw, err := btcjson.NewRawCmd(cmd.id, cmd.Method(), params)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(raw)
+}
+
+// UnmarshalJSON unmarshals the JSON encoding of cmd into cmd.  Part of
+// the Cmd interface.
+func (cmd *SetSpendPolicyCmd) UnmarshalJSON(b []byte) error {
+	// Unmarshal into a RawCmd.
+	var r btcjson.RawCmd
+	if err := json.Unmarshal(b, &r); err != nil {
+		return err
+	}
+
+	newCmd, err := parseSetSpendPolicyCmd(&r)
+	if err != nil {
+		return err
+	}
+
+	concreteCmd, ok := newCmd.(*SetSpendPolicyCmd)
+	if !ok {
+		return btcjson.ErrInternal
+	}
+	*cmd = *concreteCmd
+	return nil
+}
+
+// Units of the delay set by a setvaultrule request.
+const (
+	// VaultDelayUnitBlocks is a delay in connected blocks.
+	VaultDelayUnitBlocks = "blocks"
+
+	// VaultDelayUnitHours is a delay in hours.
+	VaultDelayUnitHours = "hours"
+)
+
+// SetVaultRuleCmd is a type handling custom marshaling and unmarshaling of
+// setvaultrule JSON extension commands.  A zero delay removes the
+// account's vault rule.
+type SetVaultRuleCmd struct {
+	id        interface{}
+	Account   string
+	Threshold int64
+	Delay     int32
+	Unit      string
+}
+
+// Enforce that SetVaultRuleCmd satisifies the btcjson.Cmd interface.
+var _ btcjson.Cmd = &SetVaultRuleCmd{}
+
+// NewSetVaultRuleCmd creates a new SetVaultRuleCmd.  The optional argument
+// is the unit (string) of the delay, which defaults to VaultDelayUnitBlocks.
+func NewSetVaultRuleCmd(id interface{}, account string, threshold int64,
+	delay int32, optArgs ...string) (*SetVaultRuleCmd, error) {
+
+	if len(optArgs) > 1 {
+		return nil, btcjson.ErrTooManyOptArgs
+	}
+
+	unit := VaultDelayUnitBlocks
+	if len(optArgs) > 0 {
+		unit = optArgs[0]
+	}
+
+	return &SetVaultRuleCmd{
+		id:        id,
+		Account:   account,
+		Threshold: threshold,
+		Delay:     delay,
+		Unit:      unit,
+	}, nil
+}
+
+// parseSetVaultRuleCmd parses a SetVaultRuleCmd into a concrete type
+// satisifying the btcjson.Cmd interface.  This is used when registering
+// the custom command with the btcjson parser.
+func parseSetVaultRuleCmd(r *btcjson.RawCmd) (btcjson.Cmd, error) {
+	if len(r.Params) < 3 || len(r.Params) > 4 {
+		return nil, btcjson.ErrWrongNumberOfParams
+	}
+
+	var account string
+	if err := json.Unmarshal(r.Params[0], &account); err != nil {
+		return nil, errors.New("first parameter 'account' must be a string: " + err.Error())
+	}
+	var fthreshold float64
+	if err := json.Unmarshal(r.Params[1], &fthreshold); err != nil {
+		return nil, errors.New("second parameter 'threshold' must be a number: " + err.Error())
+	}
+	threshold, err := btcjson.JSONToAmount(fthreshold)
+	if err != nil {
+		return nil, err
+	}
+	var delay int32
+	if err := json.Unmarshal(r.Params[2], &delay); err != nil {
+		return nil, errors.New("third parameter 'delay' must be an integer: " + err.Error())
+	}
+
+	optArgs := make([]string, 0, 1)
+	if len(r.Params) > 3 {
+		var unit string
+		if err := json.Unmarshal(r.Params[3], &unit); err != nil {
+			return nil, errors.New("fourth optional parameter 'unit' must be a string: " + err.Error())
+		}
+		optArgs = append(optArgs, unit)
+	}
+
+	return NewSetVaultRuleCmd(r.Id, account, threshold, delay, optArgs...)
+}
+
+// Id satisifies the Cmd interface by returning the ID of the command.
+func (cmd *SetVaultRuleCmd) Id() interface{} {
+	return cmd.id
+}
+
+// Method satisifies the Cmd interface by returning the RPC method.
+func (cmd *SetVaultRuleCmd) Method() string {
+	return "setvaultrule"
+}
+
+// MarshalJSON returns the JSON encoding of cmd.  Part of the Cmd interface.
+func (cmd *SetVaultRuleCmd) MarshalJSON() ([]byte, error) {
+	params := []interface{}{
+		cmd.Account,
+		float64(cmd.Threshold) / 1e8,
+		cmd.Delay,
+		cmd.Unit,
+	}
+
+	raw, err := btcjson.NewRawCmd(cmd.id, cmd.Method(), params)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(raw)
+}
+
+// UnmarshalJSON unmarshals the JSON encoding of cmd into cmd.  Part of
+// the Cmd interface.
+func (cmd *SetVaultRuleCmd) UnmarshalJSON(b []byte) error {
+	// Unmarshal into a RawCmd.
+	var r btcjson.RawCmd
+	if err := json.Unmarshal(b, &r); err != nil {
+		return err
+	}
+
+	newCmd, err := parseSetVaultRuleCmd(&r)
+	if err != nil {
+		return err
+	}
+
+	concreteCmd, ok := newCmd.(*SetVaultRuleCmd)
+	if !ok {
+		return btcjson.ErrInternal
+	}
+	*cmd = *concreteCmd
+	return nil
+}
+
+// ListVaultSendsCmd is a type handling custom marshaling and unmarshaling
+// of listvaultsends JSON extension commands.
+type ListVaultSendsCmd struct {
+	id interface{}
+}
+
+// Enforce that ListVaultSendsCmd satisifies the btcjson.Cmd interface.
+var _ btcjson.Cmd = &ListVaultSendsCmd{}
+
+// NewListVaultSendsCmd creates a new ListVaultSendsCmd.
+func NewListVaultSendsCmd(id interface{}) *ListVaultSendsCmd {
+	return &ListVaultSendsCmd{id: id}
+}
+
+// parseListVaultSendsCmd parses a ListVaultSendsCmd into a concrete type
+// satisifying the btcjson.Cmd interface.  This is used when registering
+// the custom command with the btcjson parser.
+func parseListVaultSendsCmd(r *btcjson.RawCmd) (btcjson.Cmd, error) {
+	if len(r.Params) != 0 {
+		return nil, btcjson.ErrWrongNumberOfParams
+	}
+	return NewListVaultSendsCmd(r.Id), nil
+}
+
+// Id satisifies the Cmd interface by returning the ID of the command.
+func (cmd *ListVaultSendsCmd) Id() interface{} {
+	return cmd.id
+}
+
+// Method satisifies the Cmd interface by returning the RPC method.
+func (cmd *ListVaultSendsCmd) Method() string {
+	return "listvaultsends"
+}
+
+// MarshalJSON returns the JSON encoding of cmd.  Part of the Cmd interface.
+func (cmd *ListVaultSendsCmd) MarshalJSON() ([]byte, error) {
+	raw, err := btcjson.NewRawCmd(cmd.id, cmd.Method(), []interface{}{})
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(raw)
+}
+
+// UnmarshalJSON unmarshals the JSON encoding of cmd into cmd.  Part of
+// the Cmd interface.
+func (cmd *ListVaultSendsCmd) UnmarshalJSON(b []byte) error {
+	// Unmarshal into a RawCmd.
+	var r btcjson.RawCmd
+	if err := json.Unmarshal(b, &r); err != nil {
+		return err
+	}
+
+	newCmd, err := parseListVaultSendsCmd(&r)
+	if err != nil {
+		return err
+	}
+
+	concreteCmd, ok := newCmd.(*ListVaultSendsCmd)
+	if !ok {
+		return btcjson.ErrInternal
+	}
+	*cmd = *concreteCmd
+	return nil
+}
+
+// CancelVaultSendCmd is a type handling custom marshaling and unmarshaling
+// of cancelvaultsend JSON extension commands.
+type CancelVaultSendCmd struct {
+	id interface{}
+	ID string
+}
+
+// Enforce that CancelVaultSendCmd satisifies the btcjson.Cmd interface.
+var _ btcjson.Cmd = &CancelVaultSendCmd{}
+
+// NewCancelVaultSendCmd creates a new CancelVaultSendCmd.
+func NewCancelVaultSendCmd(id interface{}, sendID string) *CancelVaultSendCmd {
+	return &CancelVaultSendCmd{
+		id: id,
+		ID: sendID,
+	}
+}
+
+// parseCancelVaultSendCmd parses a CancelVaultSendCmd into a concrete type
+// satisifying the btcjson.Cmd interface.  This is used when registering
+// the custom command with the btcjson parser.
+func parseCancelVaultSendCmd(r *btcjson.RawCmd) (btcjson.Cmd, error) {
+	if len(r.Params) != 1 {
+		return nil, btcjson.ErrWrongNumberOfParams
+	}
+
+	var sendID string
+	if err := json.Unmarshal(r.Params[0], &sendID); err != nil {
+		return nil, errors.New("first parameter 'id' must be a string: " + err.Error())
+	}
+
+	return NewCancelVaultSendCmd(r.Id, sendID), nil
+}
+
+// Id satisifies the Cmd interface by returning the ID of the command.
+func (cmd *CancelVaultSendCmd) Id() interface{} {
+	return cmd.id
+}
+
+// Method satisifies the Cmd interface by returning the RPC method.
+func (cmd *CancelVaultSendCmd) Method() string {
+	return "cancelvaultsend"
+}
+
+// MarshalJSON returns the JSON encoding of cmd.  Part of the Cmd interface.
+func (cmd *CancelVaultSendCmd) MarshalJSON() ([]byte, error) {
+	raw, err := btcjson.NewRawCmd(cmd.id, cmd.Method(), []interface{}{cmd.ID})
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(raw)
+}
+
+// UnmarshalJSON unmarshals the JSON encoding of cmd into cmd.  Part of
+// the Cmd interface.
+func (cmd *CancelVaultSendCmd) UnmarshalJSON(b []byte) error {
+	// Unmarshal into a RawCmd.
+	var r btcjson.RawCmd
+	if err := json.Unmarshal(b, &r); err != nil {
+		return err
+	}
+
+	newCmd, err := parseCancelVaultSendCmd(&r)
+	if err != nil {
+		return err
+	}
+
+	concreteCmd, ok := newCmd.(*CancelVaultSendCmd)
+	if !ok {
+		return btcjson.ErrInternal
+	}
+	*cmd = *concreteCmd
+	return nil
+}
+
+// SweepRuleOptions holds the settings of an automatic sweep rule, passed
+// as a JSON object.  Amounts are in bitcoins.  Exactly one of Address and
+// ToAccount is set, so funds may not be swept to the default account by
+// name.  A rule without a high water mark removes the
+// account's rule.
+type SweepRuleOptions struct {
+	// HighWater is the balance above which excess funds are swept.
+	HighWater float64 `json:"highwater"`
+
+	// LowWater is the balance left in the account after a sweep, before
+	// fees.
+	LowWater float64 `json:"lowwater"`
+
+	// Address is the address excess funds are swept to.
+	Address string `json:"address,omitempty"`
+
+	// ToAccount is the name of the account excess funds are swept to.
+	ToAccount string `json:"toaccount,omitempty"`
+
+	// MinConf is the number of confirmations required for outputs to be
+	// included in the balance and swept.
+	MinConf int `json:"minconf"`
+}
+
+// SweepRuleResult models the data returned for each account with an
+// automatic sweep rule by a getsweeprules request.
+type SweepRuleResult struct {
+	Account string `json:"account"`
+	SweepRuleOptions
+}
+
+// GetSweepRulesCmd is a type handling custom marshaling and unmarshaling
+// of getsweeprules JSON extension commands.
+type GetSweepRulesCmd struct {
+	id interface{}
+}
+
+// Enforce that GetSweepRulesCmd satisifies the btcjson.Cmd interface.
+var _ btcjson.Cmd = &GetSweepRulesCmd{}
+
+// NewGetSweepRulesCmd creates a new GetSweepRulesCmd.
+func NewGetSweepRulesCmd(id interface{}) *GetSweepRulesCmd {
+	return &GetSweepRulesCmd{id: id}
+}
+
+// parseGetSweepRulesCmd parses a GetSweepRulesCmd into a concrete type
+// satisifying the btcjson.Cmd interface.  This is used when registering
+// the custom command with the btcjson parser.
+func parseGetSweepRulesCmd(r *btcjson.RawCmd) (btcjson.Cmd, error) {
+	if len(r.Params) != 0 {
+		return nil, btcjson.ErrWrongNumberOfParams
+	}
+	return NewGetSweepRulesCmd(r.Id), nil
+}
+
+// Id satisifies the Cmd interface by returning the ID of the command.
+func (cmd *GetSweepRulesCmd) Id() interface{} {
+	return cmd.id
+}
+
+// Method satisifies the Cmd interface by returning the RPC method.
+func (cmd *GetSweepRulesCmd) Method() string {
+	return "getsweeprules"
+}
+
+// MarshalJSON returns the JSON encoding of cmd.  Part of the Cmd interface.
+func (cmd *GetSweepRulesCmd) MarshalJSON() ([]byte, error) {
+	raw, err := btcjson.NewRawCmd(cmd.id, cmd.Method(), []interface{}{})
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(raw)
+}
+
+// UnmarshalJSON unmarshals the JSON encoding of cmd into cmd.  Part of
+// the Cmd interface.
+func (cmd *GetSweepRulesCmd) UnmarshalJSON(b []byte) error {
+	// Unmarshal into a RawCmd.
+	var r btcjson.RawCmd
+	if err := json.Unmarshal(b, &r); err != nil {
+		return err
+	}
+
+	newCmd, err := parseGetSweepRulesCmd(&r)
+	if err != nil {
+		return err
+	}
+
+	concreteCmd, ok := newCmd.(*GetSweepRulesCmd)
+	if !ok {
+		return btcjson.ErrInternal
+	}
+	*cmd = *concreteCmd
+	return nil
+}
+
+// SetSweepRuleCmd is a type handling custom marshaling and unmarshaling
+// of setsweeprule JSON extension commands.  If omitted, the minconf of the
+// rule defaults to 1.
+type SetSweepRuleCmd struct {
+	id      interface{}
+	Account string
+	Rule    *SweepRuleOptions
+}
+
+// Enforce that SetSweepRuleCmd satisifies the btcjson.Cmd interface.
+var _ btcjson.Cmd = &SetSweepRuleCmd{}
+
+// NewSetSweepRuleCmd creates a new SetSweepRuleCmd.
+func NewSetSweepRuleCmd(id interface{}, account string,
+	rule *SweepRuleOptions) *SetSweepRuleCmd {
+
+	return &SetSweepRuleCmd{
+		id:      id,
+		Account: account,
+		Rule:    rule,
+	}
+}
+
+// parseSetSweepRuleCmd parses a SetSweepRuleCmd into a concrete type
+// satisifying the btcjson.Cmd interface.  This is used when registering
+// the custom command with the btcjson parser.
+func parseSetSweepRuleCmd(r *btcjson.RawCmd) (btcjson.Cmd, error) {
+	if len(r.Params) != 2 {
+		return nil, btcjson.ErrWrongNumberOfParams
+	}
+
+	var account string
+	if err := json.Unmarshal(r.Params[0], &account); err != nil {
+		return nil, errors.New("first parameter 'account' must be a string: " + err.Error())
+	}
+	rule := &SweepRuleOptions{MinConf: 1}
+	if err := json.Unmarshal(r.Params[1], rule); err != nil {
+		return nil, errors.New("second parameter 'rule' must be an object: " + err.Error())
+	}
+
+	return NewSetSweepRuleCmd(r.Id, account, rule), nil
+}
+
+// Id satisifies the Cmd interface by returning the ID of the command.
+func (cmd *SetSweepRuleCmd) Id() interface{} {
+	return cmd.id
+}
+
+// Method satisifies the Cmd interface by returning the RPC method.
+func (cmd *SetSweepRuleCmd) Method() string {
+	return "setsweeprule"
+}
+
+// MarshalJSON returns the JSON encoding of cmd.  Part of the Cmd interface.
+func (cmd *SetSweepRuleCmd) MarshalJSON() ([]byte, error) {
+	params := []interface{}{
+		cmd.Account,
+		cmd.Rule,
+	}
+
+	raw, err := btcjson.NewRawCmd(cmd.id, cmd.Method(), params)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(raw)
+}
+
+// UnmarshalJSON unmarshals the JSON encoding of cmd into cmd.  Part of
+// the Cmd interface.
+func (cmd *SetSweepRuleCmd) UnmarshalJSON(b []byte) error {
+	// Unmarshal into a RawCmd.
+	var r btcjson.RawCmd
+	if err := json.Unmarshal(b, &r); err != nil {
+		return err
+	}
+
+	newCmd, err := parseSetSweepRuleCmd(&r)
+	if err != nil {
+		return err
+	}
+
+	concreteCmd, ok := newCmd.(*SetSweepRuleCmd)
+	if !ok {
+		return btcjson.ErrInternal
+	}
+	*cmd = *concreteCmd
+	return nil
+}
+
+// Units of the interval of an addscheduledpayment request.
+const (
+	// IntervalUnitBlocks is an interval in connected blocks.
+	IntervalUnitBlocks = "blocks"
+
+	// IntervalUnitHours is an interval in hours.
+	IntervalUnitHours = "hours"
+
+	// IntervalUnitDays is an interval in days.
+	IntervalUnitDays = "days"
+)
+
+// PaymentExecutionResult models the data returned for each execution in
+// the history of a recurring payment.  Result is the TxID of a sent
+// payment, or the ID returned if the payment was held for send approval or
+// by a vault rule.
+type PaymentExecutionResult struct {
+	Time   int64  `json:"time"`
+	Height int32  `json:"height"`
+	State  string `json:"state"`
+	Result string `json:"result,omitempty"`
+	Error  string `json:"error,omitempty"`
+}
+
+// ScheduledPaymentResult models the data returned for each recurring
+// payment by a listscheduledpayments request.  The amount is in bitcoins.
+// Exactly one of the block interval and the interval in seconds is set,
+// and likewise for the next due height and time.
+type ScheduledPaymentResult struct {
+	ID              string                   `json:"id"`
+	Account         string                   `json:"account"`
+	Address         string                   `json:"address"`
+	Amount          float64                  `json:"amount"`
+	IntervalBlocks  int32                    `json:"intervalblocks,omitempty"`
+	IntervalSeconds int64                    `json:"intervalseconds,omitempty"`
+	NextHeight      int32                    `json:"nextheight,omitempty"`
+	NextTime        int64                    `json:"nexttime,omitempty"`
+	Created         int64                    `json:"created"`
+	History         []PaymentExecutionResult `json:"history"`
+}
+
+// AddScheduledPaymentCmd is a type handling custom marshaling and
+// unmarshaling of addscheduledpayment JSON extension commands.
+type AddScheduledPaymentCmd struct {
+	id       interface{}
+	Account  string
+	Address  string
+	Amount   int64
+	Interval int32
+	Unit     string
+}
+
+// Enforce that AddScheduledPaymentCmd satisifies the btcjson.Cmd interface.
+var _ btcjson.Cmd = &AddScheduledPaymentCmd{}
+
+// NewAddScheduledPaymentCmd creates a new AddScheduledPaymentCmd.  The
+// optional argument is the unit (string) of the interval, which defaults
+// to IntervalUnitBlocks.
+func NewAddScheduledPaymentCmd(id interface{}, account, address string,
+	amount int64, interval int32, optArgs ...string) (*AddScheduledPaymentCmd, error) {
+
+	if len(optArgs) > 1 {
+		return nil, btcjson.ErrTooManyOptArgs
+	}
+
+	unit := IntervalUnitBlocks
+	if len(optArgs) > 0 {
+		unit = optArgs[0]
+	}
+
+	return &AddScheduledPaymentCmd{
+		id:       id,
+		Account:  account,
+		Address:  address,
+		Amount:   amount,
+		Interval: interval,
+		Unit:     unit,
+	}, nil
+}
+
+// parseAddScheduledPaymentCmd parses a AddScheduledPaymentCmd into a
+// concrete type satisifying the btcjson.Cmd interface.  This is used when
+// registering the custom command with the btcjson parser.
+func parseAddScheduledPaymentCmd(r *btcjson.RawCmd) (btcjson.Cmd, error) {
+	if len(r.Params) < 4 || len(r.Params) > 5 {
+		return nil, btcjson.ErrWrongNumberOfParams
+	}
+
+	var account string
+	if err := json.Unmarshal(r.Params[0], &account); err != nil {
+		return nil, errors.New("first parameter 'account' must be a string: " + err.Error())
+	}
+	var address string
+	if err := json.Unmarshal(r.Params[1], &address); err != nil {
+		return nil, errors.New("second parameter 'address' must be a string: " + err.Error())
+	}
+	var famount float64
+	if err := json.Unmarshal(r.Params[2], &famount); err != nil {
+		return nil, errors.New("third parameter 'amount' must be a number: " + err.Error())
+	}
+	amount, err := btcjson.JSONToAmount(famount)
+	if err != nil {
+		return nil, err
+	}
+	var interval int32
+	if err := json.Unmarshal(r.Params[3], &interval); err != nil {
+		return nil, errors.New("fourth parameter 'interval' must be an integer: " + err.Error())
+	}
+
+	optArgs := make([]string, 0, 1)
+	if len(r.Params) > 4 {
+		var unit string
+		if err := json.Unmarshal(r.Params[4], &unit); err != nil {
+			return nil, errors.New("fifth optional parameter 'unit' must be a string: " + err.Error())
+		}
+		optArgs = append(optArgs, unit)
+	}
+
+	return NewAddScheduledPaymentCmd(r.Id, account, address, amount,
+		interval, optArgs...)
+}
+
+// Id satisifies the Cmd interface by returning the ID of the command.
+func (cmd *AddScheduledPaymentCmd) Id() interface{} {
+	return cmd.id
+}
+
+// Method satisifies the Cmd interface by returning the RPC method.
+func (cmd *AddScheduledPaymentCmd) Method() string {
+	return "addscheduledpayment"
+}
+
+// MarshalJSON returns the JSON encoding of cmd.  Part of the Cmd interface.
+func (cmd *AddScheduledPaymentCmd) MarshalJSON() ([]byte, error) {
+	params := []interface{}{
+		cmd.Account,
+		cmd.Address,
+		float64(cmd.Amount) / 1e8,
+		cmd.Interval,
+		cmd.Unit,
+	}
+
+	raw, err := btcjson.NewRawCmd(cmd.id, cmd.Method(), params)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(raw)
+}
+
+// UnmarshalJSON unmarshals the JSON encoding of cmd into cmd.  Part of
+// the Cmd interface.
+func (cmd *AddScheduledPaymentCmd) UnmarshalJSON(b []byte) error {
+	// Unmarshal into a RawCmd.
+	var r btcjson.RawCmd
+	if err := json.Unmarshal(b, &r); err != nil {
+		return err
+	}
+
+	newCmd, err := parseAddScheduledPaymentCmd(&r)
+	if err != nil {
+		return err
+	}
+
+	concreteCmd, ok := newCmd.(*AddScheduledPaymentCmd)
+	if !ok {
+		return btcjson.ErrInternal
+	}
+	*cmd = *concreteCmd
+	return nil
+}
+
+// ListScheduledPaymentsCmd is a type handling custom marshaling and
+// unmarshaling of listscheduledpayments JSON extension commands.
+type ListScheduledPaymentsCmd struct {
+	id interface{}
+}
+
+// Enforce that ListScheduledPaymentsCmd satisifies the btcjson.Cmd
+// interface.
+var _ btcjson.Cmd = &ListScheduledPaymentsCmd{}
+
+// NewListScheduledPaymentsCmd creates a new ListScheduledPaymentsCmd.
+func NewListScheduledPaymentsCmd(id interface{}) *ListScheduledPaymentsCmd {
+	return &ListScheduledPaymentsCmd{id: id}
+}
+
+// parseListScheduledPaymentsCmd parses a ListScheduledPaymentsCmd into a
+// concrete type satisifying the btcjson.Cmd interface.  This is used when
+// registering the custom command with the btcjson parser.
+func parseListScheduledPaymentsCmd(r *btcjson.RawCmd) (btcjson.Cmd, error) {
+	if len(r.Params) != 0 {
+		return nil, btcjson.ErrWrongNumberOfParams
+	}
+	return NewListScheduledPaymentsCmd(r.Id), nil
+}
+
+// Id satisifies the Cmd interface by returning the ID of the command.
+func (cmd *ListScheduledPaymentsCmd) Id() interface{} {
+	return cmd.id
+}
+
+// Method satisifies the Cmd interface by returning the RPC method.
+func (cmd *ListScheduledPaymentsCmd) Method() string {
+	return "listscheduledpayments"
+}
+
+// MarshalJSON returns the JSON encoding of cmd.  Part of the Cmd interface.
+func (cmd *ListScheduledPaymentsCmd) MarshalJSON() ([]byte, error) {
+	raw, err := btcjson.NewRawCmd(cmd.id, cmd.Method(), []interface{}{})
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(raw)
+}
+
+// UnmarshalJSON unmarshals the JSON encoding of cmd into cmd.  Part of
+// the Cmd interface.
+func (cmd *ListScheduledPaymentsCmd) UnmarshalJSON(b []byte) error {
+	// Unmarshal into a RawCmd.
+	var r btcjson.RawCmd
+	if err := json.Unmarshal(b, &r); err != nil {
+		return err
+	}
+
+	newCmd, err := parseListScheduledPaymentsCmd(&r)
+	if err != nil {
+		return err
+	}
+
+	concreteCmd, ok := newCmd.(*ListScheduledPaymentsCmd)
+	if !ok {
+		return btcjson.ErrInternal
+	}
+	*cmd = *concreteCmd
+	return nil
+}
+
+// RemoveScheduledPaymentCmd is a type handling custom marshaling and
+// unmarshaling of removescheduledpayment JSON extension commands.
+type RemoveScheduledPaymentCmd struct {
+	id interface{}
+	ID string
+}
+
+// Enforce that RemoveScheduledPaymentCmd satisifies the btcjson.Cmd
+// interface.
+var _ btcjson.Cmd = &RemoveScheduledPaymentCmd{}
+
+// NewRemoveScheduledPaymentCmd creates a new RemoveScheduledPaymentCmd.
+func NewRemoveScheduledPaymentCmd(id interface{}, paymentID string) *RemoveScheduledPaymentCmd {
+	return &RemoveScheduledPaymentCmd{
+		id: id,
+		ID: paymentID,
+	}
+}
+
+// parseRemoveScheduledPaymentCmd parses a RemoveScheduledPaymentCmd into
+// a concrete type satisifying the btcjson.Cmd interface.  This is used
+// when registering the custom command with the btcjson parser.
+func parseRemoveScheduledPaymentCmd(r *btcjson.RawCmd) (btcjson.Cmd, error) {
+	if len(r.Params) != 1 {
+		return nil, btcjson.ErrWrongNumberOfParams
+	}
+
+	var paymentID string
+	if err := json.Unmarshal(r.Params[0], &paymentID); err != nil {
+		return nil, errors.New("first parameter 'id' must be a string: " + err.Error())
+	}
+
+	return NewRemoveScheduledPaymentCmd(r.Id, paymentID), nil
+}
+
+// Id satisifies the Cmd interface by returning the ID of the command.
+func (cmd *RemoveScheduledPaymentCmd) Id() interface{} {
+	return cmd.id
+}
+
+// Method satisifies the Cmd interface by returning the RPC method.
+func (cmd *RemoveScheduledPaymentCmd) Method() string {
+	return "removescheduledpayment"
+}
+
+// MarshalJSON returns the JSON encoding of cmd.  Part of the Cmd interface.
+func (cmd *RemoveScheduledPaymentCmd) MarshalJSON() ([]byte, error) {
+	raw, err := btcjson.NewRawCmd(cmd.id, cmd.Method(), []interface{}{cmd.ID})
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(raw)
+}
+
+// UnmarshalJSON unmarshals the JSON encoding of cmd into cmd.  Part of
+// the Cmd interface.
+func (cmd *RemoveScheduledPaymentCmd) UnmarshalJSON(b []byte) error {
+	// Unmarshal into a RawCmd.
+	var r btcjson.RawCmd
+	if err := json.Unmarshal(b, &r); err != nil {
+		return err
+	}
+
+	newCmd, err := parseRemoveScheduledPaymentCmd(&r)
+	if err != nil {
+		return err
+	}
+
+	concreteCmd, ok := newCmd.(*RemoveScheduledPaymentCmd)
+	if !ok {
+		return btcjson.ErrInternal
+	}
+	*cmd = *concreteCmd
+	return nil
+}
+
+// ApproveScheduledPaymentCmd is a type handling custom marshaling and
+// unmarshaling of approvescheduledpayment JSON extension commands.
+type ApproveScheduledPaymentCmd struct {
+	id      interface{}
+	ID      string
+	Approve bool
+}
+
+// Enforce that ApproveScheduledPaymentCmd satisifies the btcjson.Cmd
+// interface.
+var _ btcjson.Cmd = &ApproveScheduledPaymentCmd{}
+
+// NewApproveScheduledPaymentCmd creates a new ApproveScheduledPaymentCmd.
+// The optional argument is whether (bool) the pending executions are sent
+// rather than skipped, which defaults to true.
+func NewApproveScheduledPaymentCmd(id interface{}, paymentID string,
+	optArgs ...bool) (*ApproveScheduledPaymentCmd, error) {
+
+	if len(optArgs) > 1 {
+		return nil, btcjson.ErrTooManyOptArgs
+	}
+
+	approve := true
+	if len(optArgs) > 0 {
+		approve = optArgs[0]
+	}
+
+	return &ApproveScheduledPaymentCmd{
+		id:      id,
+		ID:      paymentID,
+		Approve: approve,
+	}, nil
+}
+
+// parseApproveScheduledPaymentCmd parses a ApproveScheduledPaymentCmd into
+// a concrete type satisifying the btcjson.Cmd interface.  This is used
+// when registering the custom command with the btcjson parser.
+func parseApproveScheduledPaymentCmd(r *btcjson.RawCmd) (btcjson.Cmd, error) {
+	if len(r.Params) < 1 || len(r.Params) > 2 {
+		return nil, btcjson.ErrWrongNumberOfParams
+	}
+
+	var paymentID string
+	if err := json.Unmarshal(r.Params[0], &paymentID); err != nil {
+		return nil, errors.New("first parameter 'id' must be a string: " + err.Error())
+	}
+
+	optArgs := make([]bool, 0, 1)
+	if len(r.Params) > 1 {
+		var approve bool
+		if err := json.Unmarshal(r.Params[1], &approve); err != nil {
+			return nil, errors.New("second optional parameter 'approve' must be a bool: " + err.Error())
+		}
+		optArgs = append(optArgs, approve)
+	}
+
+	return NewApproveScheduledPaymentCmd(r.Id, paymentID, optArgs...)
+}
+
+// Id satisifies the Cmd interface by returning the ID of the command.
+func (cmd *ApproveScheduledPaymentCmd) Id() interface{} {
+	return cmd.id
+}
+
+// Method satisifies the Cmd interface by returning the RPC method.
+func (cmd *ApproveScheduledPaymentCmd) Method() string {
+	return "approvescheduledpayment"
+}
+
+// MarshalJSON returns the JSON encoding of cmd.  Part of the Cmd interface.
+func (cmd *ApproveScheduledPaymentCmd) MarshalJSON() ([]byte, error) {
+	params := []interface{}{
+		cmd.ID,
+		cmd.Approve,
+	}
+
+	raw, err := btcjson.NewRawCmd(cmd.id, cmd.Method(), params)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(raw)
+}
+
+// UnmarshalJSON unmarshals the JSON encoding of cmd into cmd.  Part of
+// the Cmd interface.
+func (cmd *ApproveScheduledPaymentCmd) UnmarshalJSON(b []byte) error {
+	// Unmarshal into a RawCmd.
+	var r btcjson.RawCmd
+	if err := json.Unmarshal(b, &r); err != nil {
+		return err
+	}
+
+	newCmd, err := parseApproveScheduledPaymentCmd(&r)
+	if err != nil {
+		return err
+	}
+
+	concreteCmd, ok := newCmd.(*ApproveScheduledPaymentCmd)
+	if !ok {
+		return btcjson.ErrInternal
+	}
+	*cmd = *concreteCmd
+	return nil
+}
+
+// CreatePartialTxCmd is a type handling custom marshaling and
+// unmarshaling of createpartialtx JSON RPC commands.
+type CreatePartialTxCmd struct {
+	id          interface{}
+	FromAccount string
+	Amounts     map[string]int64
+	MinConf     int
+	Options     *SendOptions
+}
+
+// Enforce that CreatePartialTxCmd satisifies the btcjson.Cmd interface.
+var _ btcjson.Cmd = &CreatePartialTxCmd{}
+
+// NewCreatePartialTxCmd creates a new CreatePartialTxCmd.  Optional
+// arguments are the minconf (int) and send options (*SendOptions).
+func NewCreatePartialTxCmd(id interface{}, fromaccount string,
+	amounts map[string]int64, optArgs ...interface{}) (*CreatePartialTxCmd, error) {
+
+	if len(optArgs) > 2 {
+		return nil, btcjson.ErrTooManyOptArgs
+	}
+
+	minconf := 1
+	opts := new(SendOptions)
+	if len(optArgs) > 0 {
+		m, ok := optArgs[0].(int)
+		if !ok {
+			return nil, errors.New("first optional argument minconf is not an int")
+		}
+		minconf = m
+	}
+	if len(optArgs) > 1 {
+		o, ok := optArgs[1].(*SendOptions)
+		if !ok {
+			return nil, errors.New("second optional argument options is not a *SendOptions")
+		}
+		opts = o
+	}
+
+	return &CreatePartialTxCmd{
+		id:          id,
+		FromAccount: fromaccount,
+		Amounts:     amounts,
+		MinConf:     minconf,
+		Options:     opts,
+	}, nil
+}
+
+// parseCreatePartialTxCmd parses a CreatePartialTxCmd into a concrete type
+// satisifying the btcjson.Cmd interface.  This is used when registering
+// the custom command with the btcjson parser.
+func parseCreatePartialTxCmd(r *btcjson.RawCmd) (btcjson.Cmd, error) {
+	if len(r.Params) < 2 || len(r.Params) > 4 {
+		return nil, btcjson.ErrWrongNumberOfParams
+	}
+
+	var fromaccount string
+	if err := json.Unmarshal(r.Params[0], &fromaccount); err != nil {
+		return nil, errors.New("first parameter 'fromaccount' must be a string: " + err.Error())
+	}
+	var famounts map[string]float64
+	if err := json.Unmarshal(r.Params[1], &famounts); err != nil {
+		return nil, errors.New("second parameter 'amounts' must be a JSON object of address to amount mappings: " + err.Error())
+	}
+	amounts := make(map[string]int64, len(famounts))
+	for addr, famount := range famounts {
+		amount, err := btcjson.JSONToAmount(famount)
+		if err != nil {
+			return nil, err
+		}
+		amounts[addr] = amount
+	}
+
+	optArgs := make([]interface{}, 0, 2)
+	if len(r.Params) > 2 {
+		var minconf int
+		if err := json.Unmarshal(r.Params[2], &minconf); err != nil {
+			return nil, errors.New("third optional parameter 'minconf' must be an integer: " + err.Error())
+		}
+		optArgs = append(optArgs, minconf)
+	}
+	if len(r.Params) > 3 {
+		opts, err := parseSendOptions(r.Params[3])
+		if err != nil {
+			return nil, err
+		}
+		optArgs = append(optArgs, opts)
+	}
+
+	return NewCreatePartialTxCmd(r.Id, fromaccount, amounts, optArgs...)
+}
+
+// Id satisifies the Cmd interface by returning the ID of the command.
+func (cmd *CreatePartialTxCmd) Id() interface{} {
+	return cmd.id
+}
+
+// Method satisifies the Cmd interface by returning the RPC method.
+func (cmd *CreatePartialTxCmd) Method() string {
+	return "createpartialtx"
+}
+
+// MarshalJSON returns the JSON encoding of cmd.  Part of the Cmd interface.
+func (cmd *CreatePartialTxCmd) MarshalJSON() ([]byte, error) {
+	amounts := make(map[string]float64, len(cmd.Amounts))
+	for addr, amount := range cmd.Amounts {
+		amounts[addr] = float64(amount) / 1e8
+	}
+	params := []interface{}{
+		cmd.FromAccount,
+		amounts,
+		cmd.MinConf,
+		cmd.Options,
+	}
+
+	raw, err := btcjson.NewRawCmd(cmd.id, cmd.Method(), params)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(raw)
+}
+
+// UnmarshalJSON unmarshals the JSON encoding of cmd into cmd.  Part of
+// the Cmd interface.
+func (cmd *CreatePartialTxCmd) UnmarshalJSON(b []byte) error {
+	// Unmarshal into a RawCmd.
+	var r btcjson.RawCmd
+	if err := json.Unmarshal(b, &r); err != nil {
+		return err
+	}
+
+	newCmd, err := parseCreatePartialTxCmd(&r)
+	if err != nil {
+		return err
+	}
+
+	concreteCmd, ok := newCmd.(*CreatePartialTxCmd)
+	if !ok {
+		return btcjson.ErrInternal
+	}
+	*cmd = *concreteCmd
+	return nil
+}
+
+// SignPartialTxResult models the data returned by a signpartialtx request.
+type SignPartialTxResult struct {
+	Hex      string `json:"hex"`
+	Signed   int    `json:"signed"`
+	Complete bool   `json:"complete"`
+}
+
+// SignPartialTxCmd is a type handling custom marshaling and unmarshaling
+// of signpartialtx JSON RPC commands.
+type SignPartialTxCmd struct {
+	id  interface{}
+	Hex string
+}
+
+// Enforce that SignPartialTxCmd satisifies the btcjson.Cmd interface.
+var _ btcjson.Cmd = &SignPartialTxCmd{}
+
+// NewSignPartialTxCmd creates a new SignPartialTxCmd.
+func NewSignPartialTxCmd(id interface{}, hexPartialTx string) *SignPartialTxCmd {
+	return &SignPartialTxCmd{
+		id:  id,
+		Hex: hexPartialTx,
+	}
+}
+
+// parseSignPartialTxCmd parses a SignPartialTxCmd into a concrete type
+// satisifying the btcjson.Cmd interface.  This is used when registering
+// the custom command with the btcjson parser.
+func parseSignPartialTxCmd(r *btcjson.RawCmd) (btcjson.Cmd, error) {
+	if len(r.Params) != 1 {
+		return nil, btcjson.ErrWrongNumberOfParams
+	}
+
+	var hexPartialTx string
+	if err := json.Unmarshal(r.Params[0], &hexPartialTx); err != nil {
+		return nil, errors.New("first parameter 'hex' must be a string: " + err.Error())
+	}
+
+	return NewSignPartialTxCmd(r.Id, hexPartialTx), nil
+}
+
+// Id satisifies the Cmd interface by returning the ID of the command.
+func (cmd *SignPartialTxCmd) Id() interface{} {
+	return cmd.id
+}
+
+// Method satisifies the Cmd interface by returning the RPC method.
+func (cmd *SignPartialTxCmd) Method() string {
+	return "signpartialtx"
+}
+
+// MarshalJSON returns the JSON encoding of cmd.  Part of the Cmd interface.
+func (cmd *SignPartialTxCmd) MarshalJSON() ([]byte, error) {
+	params := []interface{}{
+		cmd.Hex,
+	}
+
+	raw, err := btcjson.NewRawCmd(cmd.id, cmd.Method(), params)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(raw)
+}
+
+// UnmarshalJSON unmarshals the JSON encoding of cmd into cmd.  Part of
+// the Cmd interface.
+func (cmd *SignPartialTxCmd) UnmarshalJSON(b []byte) error {
+	// Unmarshal into a RawCmd.
+	var r btcjson.RawCmd
+	if err := json.Unmarshal(b, &r); err != nil {
+		return err
+	}
+
+	newCmd, err := parseSignPartialTxCmd(&r)
+	if err != nil {
+		return err
+	}
+
+	concreteCmd, ok := newCmd.(*SignPartialTxCmd)
+	if !ok {
+		return btcjson.ErrInternal
+	}
+	*cmd = *concreteCmd
+	return nil
+}
+
+// ReleasePartialTxCmd is a type handling custom marshaling and
+// unmarshaling of releasepartialtx JSON RPC commands.
+type ReleasePartialTxCmd struct {
+	id  interface{}
+	Hex string
+}
+
+// Enforce that ReleasePartialTxCmd satisifies the btcjson.Cmd interface.
+var _ btcjson.Cmd = &ReleasePartialTxCmd{}
+
+// NewReleasePartialTxCmd creates a new ReleasePartialTxCmd.
+func NewReleasePartialTxCmd(id interface{}, hexPartialTx string) *ReleasePartialTxCmd {
+	return &ReleasePartialTxCmd{
+		id:  id,
+		Hex: hexPartialTx,
+	}
+}
+
+// parseReleasePartialTxCmd parses a ReleasePartialTxCmd into a concrete
+// type satisifying the btcjson.Cmd interface.  This is used when
+// registering the custom command with the btcjson parser.
+func parseReleasePartialTxCmd(r *btcjson.RawCmd) (btcjson.Cmd, error) {
+	if len(r.Params) != 1 {
+		return nil, btcjson.ErrWrongNumberOfParams
+	}
+
+	var hexPartialTx string
+	if err := json.Unmarshal(r.Params[0], &hexPartialTx); err != nil {
+		return nil, errors.New("first parameter 'hex' must be a string: " + err.Error())
+	}
+
+	return NewReleasePartialTxCmd(r.Id, hexPartialTx), nil
+}
+
+// Id satisifies the Cmd interface by returning the ID of the command.
+func (cmd *ReleasePartialTxCmd) Id() interface{} {
+	return cmd.id
+}
+
+// Method satisifies the Cmd interface by returning the RPC method.
+func (cmd *ReleasePartialTxCmd) Method() string {
+	return "releasepartialtx"
+}
+
+// MarshalJSON returns the JSON encoding of cmd.  Part of the Cmd interface.
+func (cmd *ReleasePartialTxCmd) MarshalJSON() ([]byte, error) {
+	params := []interface{}{
+		cmd.Hex,
+	}
+
+	raw, err := btcjson.NewRawCmd(cmd.id, cmd.Method(), params)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(raw)
+}
+
+// UnmarshalJSON unmarshals the JSON encoding of cmd into cmd.  Part of
+// the Cmd interface.
+func (cmd *ReleasePartialTxCmd) UnmarshalJSON(b []byte) error {
+	// Unmarshal into a RawCmd.
+	var r btcjson.RawCmd
+	if err := json.Unmarshal(b, &r); err != nil {
+		return err
+	}
+
+	newCmd, err := parseReleasePartialTxCmd(&r)
+	if err != nil {
+		return err
+	}
+
+	concreteCmd, ok := newCmd.(*ReleasePartialTxCmd)
+	if !ok {
+		return btcjson.ErrInternal
+	}
+	*cmd = *concreteCmd
+	return nil
+}
+
+// CombinePartialTxsCmd is a type handling custom marshaling and
+// unmarshaling of combinepartialtxs JSON RPC commands.
+type CombinePartialTxsCmd struct {
+	id  interface{}
+	Txs []string
+}
+
+// Enforce that CombinePartialTxsCmd satisifies the btcjson.Cmd interface.
+var _ btcjson.Cmd = &CombinePartialTxsCmd{}
+
+// NewCombinePartialTxsCmd creates a new CombinePartialTxsCmd.
+func NewCombinePartialTxsCmd(id interface{}, txs []string) *CombinePartialTxsCmd {
+	return &CombinePartialTxsCmd{
+		id:  id,
+		Txs: txs,
+	}
+}
+
+// parseCombinePartialTxsCmd parses a CombinePartialTxsCmd into a concrete
+// type satisifying the btcjson.Cmd interface.  This is used when
+// registering the custom command with the btcjson parser.
+func parseCombinePartialTxsCmd(r *btcjson.RawCmd) (btcjson.Cmd, error) {
+	if len(r.Params) != 1 {
+		return nil, btcjson.ErrWrongNumberOfParams
+	}
+
+	var txs []string
+	if err := json.Unmarshal(r.Params[0], &txs); err != nil {
+		return nil, errors.New("first parameter 'txs' must be an array of strings: " + err.Error())
+	}
+
+	return NewCombinePartialTxsCmd(r.Id, txs), nil
+}
+
+// Id satisifies the Cmd interface by returning the ID of the command.
+func (cmd *CombinePartialTxsCmd) Id() interface{} {
+	return cmd.id
+}
+
+// Method satisifies the Cmd interface by returning the RPC method.
+func (cmd *CombinePartialTxsCmd) Method() string {
+	return "combinepartialtxs"
+}
+
+// MarshalJSON returns the JSON encoding of cmd.  Part of the Cmd interface.
+func (cmd *CombinePartialTxsCmd) MarshalJSON() ([]byte, error) {
+	params := []interface{}{
+		cmd.Txs,
+	}
+
+	raw, err := btcjson.NewRawCmd(cmd.id, cmd.Method(), params)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(raw)
+}
+
+// UnmarshalJSON unmarshals the JSON encoding of cmd into cmd.  Part of
+// the Cmd interface.
+func (cmd *CombinePartialTxsCmd) UnmarshalJSON(b []byte) error {
+	// Unmarshal into a RawCmd.
+	var r btcjson.RawCmd
+	if err := json.Unmarshal(b, &r); err != nil {
+		return err
+	}
+
+	newCmd, err := parseCombinePartialTxsCmd(&r)
+	if err != nil {
+		return err
+	}
+
+	concreteCmd, ok := newCmd.(*CombinePartialTxsCmd)
+	if !ok {
+		return btcjson.ErrInternal
+	}
+	*cmd = *concreteCmd
+	return nil
+}
+
+// FinalizePartialTxCmd is a type handling custom marshaling and
+// unmarshaling of finalizepartialtx JSON RPC commands.
+type FinalizePartialTxCmd struct {
+	id        interface{}
+	Hex       string
+	Broadcast bool
+}
+
+// Enforce that FinalizePartialTxCmd satisifies the btcjson.Cmd interface.
+var _ btcjson.Cmd = &FinalizePartialTxCmd{}
+
+// NewFinalizePartialTxCmd creates a new FinalizePartialTxCmd.  The
+// optional argument is whether to broadcast the finalized transaction
+// (bool, default true).
+func NewFinalizePartialTxCmd(id interface{}, hexPartialTx string,
+	optArgs ...bool) (*FinalizePartialTxCmd, error) {
+
+	if len(optArgs) > 1 {
+		return nil, btcjson.ErrTooManyOptArgs
+	}
+
+	broadcast := true
+	if len(optArgs) > 0 {
+		broadcast = optArgs[0]
+	}
+
+	return &FinalizePartialTxCmd{
+		id:        id,
+		Hex:       hexPartialTx,
+		Broadcast: broadcast,
+	}, nil
+}
+
+// parseFinalizePartialTxCmd parses a FinalizePartialTxCmd into a concrete
+// type satisifying the btcjson.Cmd interface.  This is used when
+// registering the custom command with the btcjson parser.
+func parseFinalizePartialTxCmd(r *btcjson.RawCmd) (btcjson.Cmd, error) {
+	if len(r.Params) < 1 || len(r.Params) > 2 {
+		return nil, btcjson.ErrWrongNumberOfParams
+	}
+
+	var hexPartialTx string
+	if err := json.Unmarshal(r.Params[0], &hexPartialTx); err != nil {
+		return nil, errors.New("first parameter 'hex' must be a string: " + err.Error())
+	}
+
+	optArgs := make([]bool, 0, 1)
+	if len(r.Params) > 1 {
+		var broadcast bool
+		if err := json.Unmarshal(r.Params[1], &broadcast); err != nil {
+			return nil, errors.New("second optional parameter 'broadcast' must be a bool: " + err.Error())
+		}
+		optArgs = append(optArgs, broadcast)
+	}
+
+	return NewFinalizePartialTxCmd(r.Id, hexPartialTx, optArgs...)
+}
+
+// Id satisifies the Cmd interface by returning the ID of the command.
+func (cmd *FinalizePartialTxCmd) Id() interface{} {
+	return cmd.id
+}
+
+// Method satisifies the Cmd interface by returning the RPC method.
+func (cmd *FinalizePartialTxCmd) Method() string {
+	return "finalizepartialtx"
+}
+
+// MarshalJSON returns the JSON encoding of cmd.  Part of the Cmd interface.
+func (cmd *FinalizePartialTxCmd) MarshalJSON() ([]byte, error) {
+	params := []interface{}{
+		cmd.Hex,
+		cmd.Broadcast,
+	}
+
+	raw, err := btcjson.NewRawCmd(cmd.id, cmd.Method(), params)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(raw)
+}
+
+// UnmarshalJSON unmarshals the JSON encoding of cmd into cmd.  Part of
+// the Cmd interface.
+func (cmd *FinalizePartialTxCmd) UnmarshalJSON(b []byte) error {
+	// Unmarshal into a RawCmd.
+	var r btcjson.RawCmd
+	if err := json.Unmarshal(b, &r); err != nil {
+		return err
+	}
+
+	newCmd, err := parseFinalizePartialTxCmd(&r)
+	if err != nil {
+		return err
+	}
+
+	concreteCmd, ok := newCmd.(*FinalizePartialTxCmd)
+	if !ok {
+		return btcjson.ErrInternal
+	}
+	*cmd = *concreteCmd
+	return nil
+}
+
+// RestoreWalletCmd is a type handling custom marshaling and unmarshaling
+// of restorewallet JSON extension commands.
+type RestoreWalletCmd struct {
+	id         interface{}
+	Mnemonic   string
+	Passphrase string
+}
+
+// Enforce that RestoreWalletCmd satisifies the btcjson.Cmd interface.
+var _ btcjson.Cmd = &RestoreWalletCmd{}
+
+// NewRestoreWalletCmd creates a new RestoreWalletCmd.
+func NewRestoreWalletCmd(id interface{}, mnemonic, passphrase string) *RestoreWalletCmd {
+	return &RestoreWalletCmd{
+		id:         id,
+		Mnemonic:   mnemonic,
+		Passphrase: passphrase,
+	}
+}
+
+// parseRestoreWalletCmd parses a RestoreWalletCmd into a concrete type
+// satisifying the btcjson.Cmd interface.  This is used when registering
+// the custom command with the btcjson parser.
+func parseRestoreWalletCmd(r *btcjson.RawCmd) (btcjson.Cmd, error) {
+	if len(r.Params) != 2 {
+		return nil, btcjson.ErrWrongNumberOfParams
+	}
+
+	var mnemonic string
+	if err := json.Unmarshal(r.Params[0], &mnemonic); err != nil {
+		return nil, errors.New("first parameter 'mnemonic' must be a string: " + err.Error())
+	}
+
+	var passphrase string
+	if err := json.Unmarshal(r.Params[1], &passphrase); err != nil {
+		return nil, errors.New("second parameter 'passphrase' must be a string: " + err.Error())
+	}
+
+	return NewRestoreWalletCmd(r.Id, mnemonic, passphrase), nil
+}
+
+// Id satisifies the Cmd interface by returning the ID of the command.
+func (cmd *RestoreWalletCmd) Id() interface{} {
+	return cmd.id
+}
+
+// Method satisifies the Cmd interface by returning the RPC method.
+func (cmd *RestoreWalletCmd) Method() string {
+	return "restorewallet"
+}
+
+// MarshalJSON returns the JSON encoding of cmd.  Part of the Cmd interface.
+func (cmd *RestoreWalletCmd) MarshalJSON() ([]byte, error) {
+	raw, err := btcjson.NewRawCmd(cmd.id, cmd.Method(),
+		[]interface{}{cmd.Mnemonic, cmd.Passphrase})
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(raw)
+}
+
+// UnmarshalJSON unmarshals the JSON encoding of cmd into cmd.  Part of
+// the Cmd interface.
+func (cmd *RestoreWalletCmd) UnmarshalJSON(b []byte) error {
+	// Unmarshal into a RawCmd.
+	var r btcjson.RawCmd
+	if err := json.Unmarshal(b, &r); err != nil {
+		return err
+	}
+
+	newCmd, err := parseRestoreWalletCmd(&r)
+	if err != nil {
+		return err
+	}
+
+	concreteCmd, ok := newCmd.(*RestoreWalletCmd)
+	if !ok {
+		return btcjson.ErrInternal
+	}
+	*cmd = *concreteCmd
+	return nil
+}
+
+// ExportWatchOnlyChainCmd is a type handling custom marshaling and
+// unmarshaling of exportwatchonlychain JSON extension commands.
+type ExportWatchOnlyChainCmd struct {
+	id      interface{}
+	Account string
+}
+
+// Enforce that ExportWatchOnlyChainCmd satisifies the btcjson.Cmd
+// interface.
+var _ btcjson.Cmd = &ExportWatchOnlyChainCmd{}
+
+// NewExportWatchOnlyChainCmd creates a new ExportWatchOnlyChainCmd.  The
+// optional argument is the account (string) to export, which defaults to
+// the default account.
+func NewExportWatchOnlyChainCmd(id interface{},
+	optArgs ...string) (*ExportWatchOnlyChainCmd, error) {
+
+	if len(optArgs) > 1 {
+		return nil, btcjson.ErrTooManyOptArgs
+	}
+
+	var account string
+	if len(optArgs) > 0 {
+		account = optArgs[0]
+	}
+
+	return &ExportWatchOnlyChainCmd{
+		id:      id,
+		Account: account,
+	}, nil
+}
+
+// parseExportWatchOnlyChainCmd parses a ExportWatchOnlyChainCmd into a
+// concrete type satisifying the btcjson.Cmd interface.  This is used when
+// registering the custom command with the btcjson parser.
+func parseExportWatchOnlyChainCmd(r *btcjson.RawCmd) (btcjson.Cmd, error) {
+	if len(r.Params) > 1 {
+		return nil, btcjson.ErrWrongNumberOfParams
+	}
+
+	optArgs := make([]string, 0, 1)
+	if len(r.Params) > 0 {
+		var account string
+		if err := json.Unmarshal(r.Params[0], &account); err != nil {
+			return nil, errors.New("first optional parameter 'account' must be a string: " + err.Error())
+		}
+		optArgs = append(optArgs, account)
+	}
+
+	return NewExportWatchOnlyChainCmd(r.Id, optArgs...)
+}
+
+// Id satisifies the Cmd interface by returning the ID of the command.
+func (cmd *ExportWatchOnlyChainCmd) Id() interface{} {
+	return cmd.id
+}
+
+// Method satisifies the Cmd interface by returning the RPC method.
+func (cmd *ExportWatchOnlyChainCmd) Method() string {
+	return "exportwatchonlychain"
+}
+
+// MarshalJSON returns the JSON encoding of cmd.  Part of the Cmd interface.
+func (cmd *ExportWatchOnlyChainCmd) MarshalJSON() ([]byte, error) {
+	raw, err := btcjson.NewRawCmd(cmd.id, cmd.Method(),
+		[]interface{}{cmd.Account})
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(raw)
+}
+
+// UnmarshalJSON unmarshals the JSON encoding of cmd into cmd.  Part of
+// the Cmd interface.
+func (cmd *ExportWatchOnlyChainCmd) UnmarshalJSON(b []byte) error {
+	// Unmarshal into a RawCmd.
+	var r btcjson.RawCmd
+	if err := json.Unmarshal(b, &r); err != nil {
+		return err
+	}
+
+	newCmd, err := parseExportWatchOnlyChainCmd(&r)
+	if err != nil {
+		return err
+	}
+
+	concreteCmd, ok := newCmd.(*ExportWatchOnlyChainCmd)
+	if !ok {
+		return btcjson.ErrInternal
+	}
+	*cmd = *concreteCmd
+	return nil
+}
+
+// ImportWatchOnlyChainCmd is a type handling custom marshaling and
+// unmarshaling of importwatchonlychain JSON extension commands.
+type ImportWatchOnlyChainCmd struct {
+	id      interface{}
+	Account string
+	Chain   string
+}
+
+// Enforce that ImportWatchOnlyChainCmd satisifies the btcjson.Cmd
+// interface.
+var _ btcjson.Cmd = &ImportWatchOnlyChainCmd{}
+
+// NewImportWatchOnlyChainCmd creates a new ImportWatchOnlyChainCmd.
+func NewImportWatchOnlyChainCmd(id interface{}, account, chain string) *ImportWatchOnlyChainCmd {
+	return &ImportWatchOnlyChainCmd{
+		id:      id,
+		Account: account,
+		Chain:   chain,
+	}
+}
+
+// parseImportWatchOnlyChainCmd parses a ImportWatchOnlyChainCmd into a
+// concrete type satisifying the btcjson.Cmd interface.  This is used when
+// registering the custom command with the btcjson parser.
+func parseImportWatchOnlyChainCmd(r *btcjson.RawCmd) (btcjson.Cmd, error) {
+	if len(r.Params) != 2 {
+		return nil, btcjson.ErrWrongNumberOfParams
+	}
+
+	var account string
+	if err := json.Unmarshal(r.Params[0], &account); err != nil {
+		return nil, errors.New("first parameter 'account' must be a string: " + err.Error())
+	}
+
+	var chain string
+	if err := json.Unmarshal(r.Params[1], &chain); err != nil {
+		return nil, errors.New("second parameter 'chain' must be a string: " + err.Error())
+	}
+
+	return NewImportWatchOnlyChainCmd(r.Id, account, chain), nil
+}
+
+// Id satisifies the Cmd interface by returning the ID of the command.
+func (cmd *ImportWatchOnlyChainCmd) Id() interface{} {
+	return cmd.id
+}
+
+// Method satisifies the Cmd interface by returning the RPC method.
+func (cmd *ImportWatchOnlyChainCmd) Method() string {
+	return "importwatchonlychain"
+}
+
+// MarshalJSON returns the JSON encoding of cmd.  Part of the Cmd interface.
+func (cmd *ImportWatchOnlyChainCmd) MarshalJSON() ([]byte, error) {
+	raw, err := btcjson.NewRawCmd(cmd.id, cmd.Method(),
+		[]interface{}{cmd.Account, cmd.Chain})
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(raw)
+}
+
+// UnmarshalJSON unmarshals the JSON encoding of cmd into cmd.  Part of
+// the Cmd interface.
+func (cmd *ImportWatchOnlyChainCmd) UnmarshalJSON(b []byte) error {
+	// Unmarshal into a RawCmd.
+	var r btcjson.RawCmd
+	if err := json.Unmarshal(b, &r); err != nil {
+		return err
+	}
+
+	newCmd, err := parseImportWatchOnlyChainCmd(&r)
+	if err != nil {
+		return err
+	}
+
+	concreteCmd, ok := newCmd.(*ImportWatchOnlyChainCmd)
+	if !ok {
+		return btcjson.ErrInternal
+	}
+	*cmd = *concreteCmd
+	return nil
+}
+
+// ImportAddressCmd is a type handling custom marshaling and unmarshaling of
+// importaddress JSON extension commands.  The address is a pubkey hash
+// address imported without a private key.
+type ImportAddressCmd struct {
+	id      interface{}
+	Address string
+	Account string
+	Rescan  bool
+}
+
+// Enforce that ImportAddressCmd satisifies the btcjson.Cmd interface.
+var _ btcjson.Cmd = &ImportAddressCmd{}
+
+// NewImportAddressCmd creates a new ImportAddressCmd.  Optional arguments are
+// the account (string) to import the address to, which defaults to the
+// default account, and whether to rescan (bool) the blockchain for
+// transactions involving the address, which defaults to true.
+func NewImportAddressCmd(id interface{}, address string,
+	optArgs ...interface{}) (*ImportAddressCmd, error) {
+
+	if len(optArgs) > 2 {
+		return nil, btcjson.ErrTooManyOptArgs
+	}
+
+	var account string
+	if len(optArgs) > 0 {
+		a, ok := optArgs[0].(string)
+		if !ok {
+			return nil, errors.New("first optional argument account is not a string")
+		}
+		account = a
+	}
+	rescan := true
+	if len(optArgs) > 1 {
+		r, ok := optArgs[1].(bool)
+		if !ok {
+			return nil, errors.New("second optional argument rescan is not a bool")
+		}
+		rescan = r
+	}
+
+	return &ImportAddressCmd{
+		id:      id,
+		Address: address,
+		Account: account,
+		Rescan:  rescan,
+	}, nil
+}
+
+// parseImportAddressCmd parses a ImportAddressCmd into a concrete type
+// satisifying the btcjson.Cmd interface.  This is used when registering
+// the custom command with the btcjson parser.
+func parseImportAddressCmd(r *btcjson.RawCmd) (btcjson.Cmd, error) {
+	if len(r.Params) < 1 || len(r.Params) > 3 {
+		return nil, btcjson.ErrWrongNumberOfParams
+	}
+
+	var address string
+	if err := json.Unmarshal(r.Params[0], &address); err != nil {
+		return nil, errors.New("first parameter 'address' must be a string: " + err.Error())
+	}
+
+	optArgs := make([]interface{}, 0, 2)
+	if len(r.Params) > 1 {
+		var account string
+		if err := json.Unmarshal(r.Params[1], &account); err != nil {
+			return nil, errors.New("second optional parameter 'account' must be a string: " + err.Error())
+		}
+		optArgs = append(optArgs, account)
+	}
+	if len(r.Params) > 2 {
+		var rescan bool
+		if err := json.Unmarshal(r.Params[2], &rescan); err != nil {
+			return nil, errors.New("third optional parameter 'rescan' must be a bool: " + err.Error())
+		}
+		optArgs = append(optArgs, rescan)
+	}
+
+	return NewImportAddressCmd(r.Id, address, optArgs...)
+}
+
+// Id satisifies the Cmd interface by returning the ID of the command.
+func (cmd *ImportAddressCmd) Id() interface{} {
+	return cmd.id
+}
+
+// Method satisifies the Cmd interface by returning the RPC method.
+func (cmd *ImportAddressCmd) Method() string {
+	return "importaddress"
+}
+
+// MarshalJSON returns the JSON encoding of cmd.  Part of the Cmd interface.
+func (cmd *ImportAddressCmd) MarshalJSON() ([]byte, error) {
+	params := []interface{}{
+		cmd.Address,
+		cmd.Account,
+		cmd.Rescan,
+	}
+
+	raw, err := btcjson.NewRawCmd(cmd.id, cmd.Method(), params)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(raw)
+}
+
+// UnmarshalJSON unmarshals the JSON encoding of cmd into cmd.  Part of
+// the Cmd interface.
+func (cmd *ImportAddressCmd) UnmarshalJSON(b []byte) error {
+	// Unmarshal into a RawCmd.
+	var r btcjson.RawCmd
+	if err := json.Unmarshal(b, &r); err != nil {
+		return err
+	}
+
+	newCmd, err := parseImportAddressCmd(&r)
+	if err != nil {
+		return err
+	}
+
+	concreteCmd, ok := newCmd.(*ImportAddressCmd)
+	if !ok {
+		return btcjson.ErrInternal
+	}
+	*cmd = *concreteCmd
+	return nil
+}
+
+// ImportPubKeyCmd is a type handling custom marshaling and unmarshaling of
+// importpubkey JSON extension commands.  The public key is hex encoded,
+// and its pubkey hash address is imported without a private key.
+type ImportPubKeyCmd struct {
+	id      interface{}
+	PubKey  string
+	Account string
+	Rescan  bool
+}
+
+// Enforce that ImportPubKeyCmd satisifies the btcjson.Cmd interface.
+var _ btcjson.Cmd = &ImportPubKeyCmd{}
+
+// NewImportPubKeyCmd creates a new ImportPubKeyCmd.  Optional arguments
+// are the account (string) to import the address to, which defaults to the
+// default account, and whether to rescan (bool) the blockchain for
+// transactions involving the address, which defaults to true.
+func NewImportPubKeyCmd(id interface{}, pubkey string,
+	optArgs ...interface{}) (*ImportPubKeyCmd, error) {
+
+	if len(optArgs) > 2 {
+		return nil, btcjson.ErrTooManyOptArgs
+	}
+
+	var account string
+	if len(optArgs) > 0 {
+		a, ok := optArgs[0].(string)
+		if !ok {
+			return nil, errors.New("first optional argument account is not a string")
+		}
+		account = a
+	}
+	rescan := true
+	if len(optArgs) > 1 {
+		r, ok := optArgs[1].(bool)
+		if !ok {
+			return nil, errors.New("second optional argument rescan is not a bool")
+		}
+		rescan = r
+	}
+
+	return &ImportPubKeyCmd{
+		id:      id,
+		PubKey:  pubkey,
+		Account: account,
+		Rescan:  rescan,
+	}, nil
+}
+
+// parseImportPubKeyCmd parses a ImportPubKeyCmd into a concrete type
+// satisifying the btcjson.Cmd interface.  This is used when registering
+// the custom command with the btcjson parser.
+func parseImportPubKeyCmd(r *btcjson.RawCmd) (btcjson.Cmd, error) {
+	if len(r.Params) < 1 || len(r.Params) > 3 {
+		return nil, btcjson.ErrWrongNumberOfParams
+	}
+
+	var pubkey string
+	if err := json.Unmarshal(r.Params[0], &pubkey); err != nil {
+		return nil, errors.New("first parameter 'pubkey' must be a string: " + err.Error())
+	}
+
+	optArgs := make([]interface{}, 0, 2)
+	if len(r.Params) > 1 {
+		var account string
+		if err := json.Unmarshal(r.Params[1], &account); err != nil {
+			return nil, errors.New("second optional parameter 'account' must be a string: " + err.Error())
+		}
+		optArgs = append(optArgs, account)
+	}
+	if len(r.Params) > 2 {
+		var rescan bool
+		if err := json.Unmarshal(r.Params[2], &rescan); err != nil {
+			return nil, errors.New("third optional parameter 'rescan' must be a bool: " + err.Error())
+		}
+		optArgs = append(optArgs, rescan)
+	}
+
+	return NewImportPubKeyCmd(r.Id, pubkey, optArgs...)
+}
+
+// Id satisifies the Cmd interface by returning the ID of the command.
+func (cmd *ImportPubKeyCmd) Id() interface{} {
+	return cmd.id
+}
+
+// Method satisifies the Cmd interface by returning the RPC method.
+func (cmd *ImportPubKeyCmd) Method() string {
+	return "importpubkey"
+}
+
+// MarshalJSON returns the JSON encoding of cmd.  Part of the Cmd interface.
+func (cmd *ImportPubKeyCmd) MarshalJSON() ([]byte, error) {
+	params := []interface{}{
+		cmd.PubKey,
+		cmd.Account,
+		cmd.Rescan,
+	}
+
+	raw, err := btcjson.NewRawCmd(cmd.id, cmd.Method(), params)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(raw)
+}
+
+// UnmarshalJSON unmarshals the JSON encoding of cmd into cmd.  Part of
+// the Cmd interface.
+func (cmd *ImportPubKeyCmd) UnmarshalJSON(b []byte) error {
+	// Unmarshal into a RawCmd.
+	var r btcjson.RawCmd
+	if err := json.Unmarshal(b, &r); err != nil {
+		return err
+	}
+
+	newCmd, err := parseImportPubKeyCmd(&r)
+	if err != nil {
+		return err
+	}
+
+	concreteCmd, ok := newCmd.(*ImportPubKeyCmd)
+	if !ok {
+		return btcjson.ErrInternal
+	}
+	*cmd = *concreteCmd
+	return nil
+}
+
+// BackupSharesCmd is a type handling custom marshaling and unmarshaling of
+// backupshares JSON extension commands.
+type BackupSharesCmd struct {
+	id        interface{}
+	Threshold int
+	Count     int
+}
+
+// Enforce that BackupSharesCmd satisifies the btcjson.Cmd interface.
+var _ btcjson.Cmd = &BackupSharesCmd{}
+
+// NewBackupSharesCmd creates a new BackupSharesCmd.
+func NewBackupSharesCmd(id interface{}, threshold, count int) *BackupSharesCmd {
+	return &BackupSharesCmd{
+		id:        id,
+		Threshold: threshold,
+		Count:     count,
+	}
+}
+
+// parseBackupSharesCmd parses a BackupSharesCmd into a concrete type
+// satisifying the btcjson.Cmd interface.  This is used when registering
+// the custom command with the btcjson parser.
+func parseBackupSharesCmd(r *btcjson.RawCmd) (btcjson.Cmd, error) {
+	if len(r.Params) != 2 {
+		return nil, btcjson.ErrWrongNumberOfParams
+	}
+
+	var threshold int
+	if err := json.Unmarshal(r.Params[0], &threshold); err != nil {
+		return nil, errors.New("first parameter 'threshold' must be an integer: " + err.Error())
+	}
+
+	var count int
+	if err := json.Unmarshal(r.Params[1], &count); err != nil {
+		return nil, errors.New("second parameter 'count' must be an integer: " + err.Error())
+	}
+
+	return NewBackupSharesCmd(r.Id, threshold, count), nil
+}
+
+// Id satisifies the Cmd interface by returning the ID of the command.
+func (cmd *BackupSharesCmd) Id() interface{} {
+	return cmd.id
+}
+
+// Method satisifies the Cmd interface by returning the RPC method.
+func (cmd *BackupSharesCmd) Method() string {
+	return "backupshares"
+}
+
+// MarshalJSON returns the JSON encoding of cmd.  Part of the Cmd interface.
+func (cmd *BackupSharesCmd) MarshalJSON() ([]byte, error) {
+	raw, err := btcjson.NewRawCmd(cmd.id, cmd.Method(),
+		[]interface{}{cmd.Threshold, cmd.Count})
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(raw)
+}
+
+// UnmarshalJSON unmarshals the JSON encoding of cmd into cmd.  Part of
+// the Cmd interface.
+func (cmd *BackupSharesCmd) UnmarshalJSON(b []byte) error {
+	// Unmarshal into a RawCmd.
+	var r btcjson.RawCmd
+	if err := json.Unmarshal(b, &r); err != nil {
+		return err
+	}
+
+	newCmd, err := parseBackupSharesCmd(&r)
+	if err != nil {
+		return err
+	}
+
+	concreteCmd, ok := newCmd.(*BackupSharesCmd)
+	if !ok {
+		return btcjson.ErrInternal
+	}
+	*cmd = *concreteCmd
+	return nil
+}
+
+// RestoreFromSharesCmd is a type handling custom marshaling and
+// unmarshaling of restorefromshares JSON extension commands.
+type RestoreFromSharesCmd struct {
+	id         interface{}
+	Shares     []string
+	Passphrase string
+}
+
+// Enforce that RestoreFromSharesCmd satisifies the btcjson.Cmd interface.
+var _ btcjson.Cmd = &RestoreFromSharesCmd{}
+
+// NewRestoreFromSharesCmd creates a new RestoreFromSharesCmd.
+func NewRestoreFromSharesCmd(id interface{}, shares []string,
+	passphrase string) *RestoreFromSharesCmd {
+
+	return &RestoreFromSharesCmd{
+		id:         id,
+		Shares:     shares,
+		Passphrase: passphrase,
+	}
+}
+
+// parseRestoreFromSharesCmd parses a RestoreFromSharesCmd into a concrete
+// type satisifying the btcjson.Cmd interface.  This is used when
+// registering the custom command with the btcjson parser.
+func parseRestoreFromSharesCmd(r *btcjson.RawCmd) (btcjson.Cmd, error) {
+	if len(r.Params) != 2 {
+		return nil, btcjson.ErrWrongNumberOfParams
+	}
+
+	var shares []string
+	if err := json.Unmarshal(r.Params[0], &shares); err != nil {
+		return nil, errors.New("first parameter 'shares' must be an array of strings: " + err.Error())
+	}
+
+	var passphrase string
+	if err := json.Unmarshal(r.Params[1], &passphrase); err != nil {
+		return nil, errors.New("second parameter 'passphrase' must be a string: " + err.Error())
+	}
+
+	return NewRestoreFromSharesCmd(r.Id, shares, passphrase), nil
+}
+
+// Id satisifies the Cmd interface by returning the ID of the command.
+func (cmd *RestoreFromSharesCmd) Id() interface{} {
+	return cmd.id
+}
+
+// Method satisifies the Cmd interface by returning the RPC method.
+func (cmd *RestoreFromSharesCmd) Method() string {
+	return "restorefromshares"
+}
+
+// MarshalJSON returns the JSON encoding of cmd.  Part of the Cmd interface.
+func (cmd *RestoreFromSharesCmd) MarshalJSON() ([]byte, error) {
+	raw, err := btcjson.NewRawCmd(cmd.id, cmd.Method(),
+		[]interface{}{cmd.Shares, cmd.Passphrase})
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(raw)
+}
+
+// UnmarshalJSON unmarshals the JSON encoding of cmd into cmd.  Part of
+// the Cmd interface.
+func (cmd *RestoreFromSharesCmd) UnmarshalJSON(b []byte) error {
+	// Unmarshal into a RawCmd.
+	var r btcjson.RawCmd
+	if err := json.Unmarshal(b, &r); err != nil {
+		return err
+	}
+
+	newCmd, err := parseRestoreFromSharesCmd(&r)
+	if err != nil {
+		return err
+	}
+
+	concreteCmd, ok := newCmd.(*RestoreFromSharesCmd)
+	if !ok {
+		return btcjson.ErrInternal
+	}
+	*cmd = *concreteCmd
+	return nil
+}
@@ -0,0 +1,329 @@
+/*
+ * Copyright (c) 2014 Conformal Systems LLC <info@conformal.com>
+ *
+ * Permission to use, copy, modify, and distribute this software for any
+ * purpose with or without fee is hereby granted, provided that the above
+ * copyright notice and this permission notice appear in all copies.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+ * WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+ * MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ * ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+ * WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ * ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+ * OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ */
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"github.com/conformal/btcutil"
+	"github.com/conformal/btcwallet/txstore"
+	"sort"
+	"strings"
+)
+
+// ErrUnknownCoinSelector represents an error where a coin selection
+// strategy is requested by a name which does not match any known
+// strategy.
+var ErrUnknownCoinSelector = errors.New("unknown coin selection strategy")
+
+// Names of each coin selection strategy, used both by the coinselect
+// config option and per-request send options.
+const (
+	LargestFirstName   = "largestfirst"
+	OldestFirstName    = "oldestfirst"
+	BranchAndBoundName = "branchandbound"
+	SingleAddressName  = "singleaddress"
+)
+
+// defaultCoinSelectorName is the name of the coin selection strategy used
+// when none is configured.  This is the strategy btcwallet has always used.
+const defaultCoinSelectorName = LargestFirstName
+
+// CoinSelector is the interface implemented by each strategy for choosing
+// which unspent outputs to spend as inputs for a new transaction.
+//
+// SelectCoins is passed all eligible (confirmed, mature, and unlocked)
+// credits and must return a subset of them whose total value is at least
+// amt, along with that total.  ErrInsufficientFunds must be returned if no
+// such subset exists.  Implementations may reorder eligible.  feeRate is
+// the fee rate paid by the transaction being created, for strategies which
+// weigh the cost of change.
+type CoinSelector interface {
+	SelectCoins(eligible []*txstore.Credit, amt btcutil.Amount,
+		feeRate FeeRate) ([]*txstore.Credit, btcutil.Amount, error)
+}
+
+// coinSelectors maps each strategy name to its implementation.
+var coinSelectors = map[string]CoinSelector{
+	LargestFirstName:   LargestFirstSelector{},
+	OldestFirstName:    OldestFirstSelector{},
+	BranchAndBoundName: BranchAndBoundSelector{},
+	SingleAddressName:  SingleAddressSelector{},
+}
+
+// CoinSelectorByName returns the coin selection strategy for a name.  Names
+// are matched case-insensitively.  ErrUnknownCoinSelector is returned if no
+// strategy matches.
+func CoinSelectorByName(name string) (CoinSelector, error) {
+	s, ok := coinSelectors[strings.ToLower(name)]
+	if !ok {
+		return nil, ErrUnknownCoinSelector
+	}
+	return s, nil
+}
+
+// validCoinSelector returns whether name refers to a known coin selection
+// strategy.
+func validCoinSelector(name string) bool {
+	_, err := CoinSelectorByName(name)
+	return err == nil
+}
+
+// DefaultCoinSelector returns the coin selection strategy set by the
+// coinselect config option, or largest-first if it is unset.
+func DefaultCoinSelector() CoinSelector {
+	if cfg != nil && cfg.CoinSelect != "" {
+		if s, err := CoinSelectorByName(cfg.CoinSelect); err == nil {
+			return s
+		}
+	}
+	return coinSelectors[defaultCoinSelectorName]
+}
+
+// accumulateCoins appends credits, in order, until their total value
+// reaches amt.
+func accumulateCoins(credits []*txstore.Credit,
+	amt btcutil.Amount) ([]*txstore.Credit, btcutil.Amount, error) {
+
+	var selected []*txstore.Credit
+	var out btcutil.Amount
+	for _, c := range credits {
+		selected = append(selected, c)
+		out += c.Amount()
+		if out >= amt {
+			return selected, out, nil
+		}
+	}
+	return nil, 0, ErrInsufficientFunds
+}
+
+// LargestFirstSelector selects the largest valued outputs first so that a
+// minimum number of inputs are needed.
+type LargestFirstSelector struct{}
+
+// SelectCoins satisifies the CoinSelector interface.
+func (LargestFirstSelector) SelectCoins(eligible []*txstore.Credit,
+	amt btcutil.Amount, feeRate FeeRate) ([]*txstore.Credit,
+	btcutil.Amount, error) {
+
+	sort.Sort(sort.Reverse(ByAmount(eligible)))
+	return accumulateCoins(eligible, amt)
+}
+
+// ByAge defines the methods needed to satisify sort.Interface to sort a
+// slice of credits by the height of the block they were mined in, with
+// unconfirmed credits sorted last.
+type ByAge []*txstore.Credit
+
+func (u ByAge) Len() int {
+	return len(u)
+}
+
+func (u ByAge) Less(i, j int) bool {
+	hi, hj := u[i].BlockHeight, u[j].BlockHeight
+	switch {
+	case hi == -1:
+		return false
+	case hj == -1:
+		return true
+	}
+	return hi < hj
+}
+
+func (u ByAge) Swap(i, j int) {
+	u[i], u[j] = u[j], u[i]
+}
+
+// OldestFirstSelector selects the oldest outputs first.  This spends down
+// coins in the order they were received and keeps the set of unspent
+// outputs from growing with old, small outputs that are never chosen.
+type OldestFirstSelector struct{}
+
+// SelectCoins satisifies the CoinSelector interface.
+func (OldestFirstSelector) SelectCoins(eligible []*txstore.Credit,
+	amt btcutil.Amount, feeRate FeeRate) ([]*txstore.Credit,
+	btcutil.Amount, error) {
+
+	sort.Stable(ByAge(eligible))
+	return accumulateCoins(eligible, amt)
+}
+
+// maxBranchAndBoundTries is the maximum number of branches visited by
+// BranchAndBoundSelector before falling back to a simpler strategy.
+const maxBranchAndBoundTries = 100000
+
+// BranchAndBoundSelector searches for the combination of outputs whose total
+// value exceeds the spend amount by the smallest amount, so that either no
+// change output is needed, or the change output is as small as possible.
+// The search is a depth first branch and bound over outputs sorted by
+// decreasing value and ends early once a match within the cost of creating
+// a change output at the transaction's fee rate is found.  If the search is exhausted without finding any
+// solution, the smallest single output which covers the amount is chosen,
+// or if none exist, outputs are selected largest first.
+type BranchAndBoundSelector struct{}
+
+// SelectCoins satisifies the CoinSelector interface.
+func (BranchAndBoundSelector) SelectCoins(eligible []*txstore.Credit,
+	amt btcutil.Amount, feeRate FeeRate) ([]*txstore.Credit,
+	btcutil.Amount, error) {
+
+	sort.Sort(sort.Reverse(ByAmount(eligible)))
+
+	// remaining[i] holds the total value of all eligible outputs at
+	// index i and after, used to prune branches which can never reach
+	// amt.
+	remaining := make([]btcutil.Amount, len(eligible)+1)
+	for i := len(eligible) - 1; i >= 0; i-- {
+		remaining[i] = remaining[i+1] + eligible[i].Amount()
+	}
+	if remaining[0] < amt {
+		return nil, 0, ErrInsufficientFunds
+	}
+
+	// This must match the change txToPairs leaves for the miner.
+	tolerance := costOfChange(feeRate)
+	var best []int
+	var bestOut btcutil.Amount
+	current := make([]int, 0, len(eligible))
+	tries := 0
+
+	var search func(i int, out btcutil.Amount) bool
+	search = func(i int, out btcutil.Amount) bool {
+		tries++
+		if tries > maxBranchAndBoundTries {
+			return true
+		}
+		if out >= amt {
+			if best == nil || out < bestOut {
+				best = append(best[:0], current...)
+				bestOut = out
+			}
+			return out-amt <= tolerance
+		}
+		if i == len(eligible) || out+remaining[i] < amt {
+			return false
+		}
+		if best != nil && out >= bestOut {
+			return false
+		}
+
+		// Branch including the output first, then excluding it.
+		current = append(current, i)
+		if search(i+1, out+eligible[i].Amount()) {
+			return true
+		}
+		current = current[:len(current)-1]
+		return search(i+1, out)
+	}
+	search(0, 0)
+
+	if best != nil {
+		selected := make([]*txstore.Credit, 0, len(best))
+		for _, i := range best {
+			selected = append(selected, eligible[i])
+		}
+		return selected, bestOut, nil
+	}
+
+	// The search was cut short before any solution was found.  Use
+	// the smallest output which is sufficient by itself.
+	for i := len(eligible) - 1; i >= 0; i-- {
+		if eligible[i].Amount() >= amt {
+			return eligible[i : i+1], eligible[i].Amount(), nil
+		}
+	}
+	return accumulateCoins(eligible, amt)
+}
+
+// SingleAddressSelector avoids linking the unspent outputs of different
+// wallet addresses together in a single transaction.  If outputs paying a
+// single address are enough to cover the amount, the address with the
+// smallest sufficient balance is chosen and only its outputs are spent.
+// Otherwise, the outputs of as few addresses as possible are combined,
+// preferring addresses with the largest balances.
+type SingleAddressSelector struct{}
+
+// addressCoins holds all eligible outputs paying to a single address.
+type addressCoins struct {
+	credits []*txstore.Credit
+	total   btcutil.Amount
+}
+
+// byAddressTotal sorts groups of address outputs by their total value.
+type byAddressTotal []*addressCoins
+
+func (g byAddressTotal) Len() int {
+	return len(g)
+}
+
+func (g byAddressTotal) Less(i, j int) bool {
+	return g[i].total < g[j].total
+}
+
+func (g byAddressTotal) Swap(i, j int) {
+	g[i], g[j] = g[j], g[i]
+}
+
+// SelectCoins satisifies the CoinSelector interface.
+func (SingleAddressSelector) SelectCoins(eligible []*txstore.Credit,
+	amt btcutil.Amount, feeRate FeeRate) ([]*txstore.Credit,
+	btcutil.Amount, error) {
+
+	// Group outputs by the address they pay to.  Outputs without
+	// exactly one address are grouped by themselves.
+	sort.Sort(sort.Reverse(ByAmount(eligible)))
+	groups := make(map[string]*addressCoins)
+	var order []*addressCoins
+	for _, c := range eligible {
+		op := c.OutPoint()
+		key := fmt.Sprintf("%v:%d", op.Hash, op.Index)
+		_, addrs, _, _ := c.Addresses(cfg.Net())
+		if len(addrs) == 1 {
+			key = addrs[0].EncodeAddress()
+		}
+		g, ok := groups[key]
+		if !ok {
+			g = &addressCoins{}
+			groups[key] = g
+			order = append(order, g)
+		}
+		g.credits = append(g.credits, c)
+		g.total += c.Amount()
+	}
+	sort.Stable(byAddressTotal(order))
+
+	// Spend from the single address with the smallest total which is
+	// still sufficient.
+	for _, g := range order {
+		if g.total >= amt {
+			return accumulateCoins(g.credits, amt)
+		}
+	}
+
+	// No single address is enough, so combine the fewest addresses
+	// possible by spending every output of the largest addresses.
+	var selected []*txstore.Credit
+	var out btcutil.Amount
+	for i := len(order) - 1; i >= 0; i-- {
+		selected = append(selected, order[i].credits...)
+		out += order[i].total
+		if out >= amt {
+			return selected, out, nil
+		}
+	}
+	return nil, 0, ErrInsufficientFunds
+}
@@ -0,0 +1,180 @@
+/*
+ * Copyright (c) 2014 Conformal Systems LLC <info@conformal.com>
+ *
+ * Permission to use, copy, modify, and distribute this software for any
+ * purpose with or without fee is hereby granted, provided that the above
+ * copyright notice and this permission notice appear in all copies.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+ * WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+ * MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ * ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+ * WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ * ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+ * OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ */
+
+package main
+
+import (
+	"bytes"
+	"github.com/conformal/btcscript"
+	"github.com/conformal/btcutil"
+	"github.com/conformal/btcwallet/txstore"
+	"github.com/conformal/btcwire"
+	"reflect"
+	"sort"
+	"testing"
+)
+
+// testCoin describes a fake credit used to test coin selection.
+type testCoin struct {
+	amt    btcutil.Amount
+	height int32
+	addr   byte // repeated to create the paid pubkey hash
+}
+
+// testCoins are the credits each coin selection test chooses from.  The
+// cost of change at the default fee rate is 1830 satoshis.
+var testCoins = []testCoin{
+	{amt: 50000, height: 100, addr: 1},
+	{amt: 30000, height: 50, addr: 2},
+	{amt: 20000, height: 200, addr: 1},
+	{amt: 10000, height: 10, addr: 3},
+}
+
+type coinSelectTest struct {
+	name     string
+	selector CoinSelector
+	amt      btcutil.Amount
+	feeRate  FeeRate // default fee rate if zero
+	selected []int   // indexes of testCoins
+	err      error
+}
+
+var coinSelectTests = []coinSelectTest{
+	{
+		name:     "largest first",
+		selector: LargestFirstSelector{},
+		amt:      60000,
+		selected: []int{0, 1},
+	},
+	{
+		name:     "largest first insufficient",
+		selector: LargestFirstSelector{},
+		amt:      200000,
+		err:      ErrInsufficientFunds,
+	},
+	{
+		name:     "oldest first",
+		selector: OldestFirstSelector{},
+		amt:      35000,
+		selected: []int{1, 3},
+	},
+	{
+		name:     "branch and bound within cost of change",
+		selector: BranchAndBoundSelector{},
+		amt:      59000,
+		selected: []int{0, 3},
+	},
+	{
+		name:     "branch and bound exact match",
+		selector: BranchAndBoundSelector{},
+		amt:      40000,
+		selected: []int{1, 3},
+	},
+	{
+		// The cost of change is 18300 satoshis at ten times the
+		// default fee rate, so the first match is close enough.
+		name:     "branch and bound within cost of change at fee rate",
+		selector: BranchAndBoundSelector{},
+		amt:      40000,
+		feeRate:  10 * FeeRate(minTxFee),
+		selected: []int{0},
+	},
+	{
+		name:     "branch and bound smallest excess",
+		selector: BranchAndBoundSelector{},
+		amt:      31000,
+		selected: []int{1, 3},
+	},
+	{
+		name:     "branch and bound insufficient",
+		selector: BranchAndBoundSelector{},
+		amt:      200000,
+		err:      ErrInsufficientFunds,
+	},
+	{
+		name:     "single address",
+		selector: SingleAddressSelector{},
+		amt:      25000,
+		selected: []int{1},
+	},
+	{
+		name:     "single address combining addresses",
+		selector: SingleAddressSelector{},
+		amt:      75000,
+		selected: []int{0, 1, 2},
+	},
+	{
+		name:     "single address insufficient",
+		selector: SingleAddressSelector{},
+		amt:      200000,
+		err:      ErrInsufficientFunds,
+	},
+}
+
+func TestCoinSelectors(t *testing.T) {
+	for _, test := range coinSelectTests {
+		// Selectors may reorder the eligible credits, so new credits
+		// are created for each test.
+		s := txstore.New()
+		eligible := make([]*txstore.Credit, len(testCoins))
+		indexes := make(map[btcwire.OutPoint]int, len(testCoins))
+		for i, coin := range testCoins {
+			addr, err := btcutil.NewAddressPubKeyHash(
+				bytes.Repeat([]byte{coin.addr}, 20), btcwire.MainNet)
+			if err != nil {
+				t.Fatalf("Cannot create address: %v", err)
+			}
+			pkScript, err := btcscript.PayToAddrScript(addr)
+			if err != nil {
+				t.Fatalf("Cannot create PkScript: %v", err)
+			}
+			c := addTestCredit(t, s, coin.amt, coin.height, pkScript)
+			eligible[i] = c
+			indexes[*c.OutPoint()] = i
+		}
+
+		feeRate := test.feeRate
+		if feeRate == 0 {
+			feeRate = defaultFeeRate()
+		}
+		selected, out, err := test.selector.SelectCoins(eligible,
+			test.amt, feeRate)
+		if err != test.err {
+			t.Errorf("%s: unexpected error %v, expected %v",
+				test.name, err, test.err)
+			continue
+		}
+		if err != nil {
+			continue
+		}
+
+		got := make([]int, 0, len(selected))
+		var total btcutil.Amount
+		for _, c := range selected {
+			got = append(got, indexes[*c.OutPoint()])
+			total += c.Amount()
+		}
+		sort.Ints(got)
+		if !reflect.DeepEqual(got, test.selected) {
+			t.Errorf("%s: selected coins %v, expected %v",
+				test.name, got, test.selected)
+		}
+		if out != total {
+			t.Errorf("%s: selected total %v, expected %v",
+				test.name, out, total)
+		}
+	}
+}
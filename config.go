@@ -34,6 +34,7 @@ const (
 	defaultLogLevel       = "info"
 	defaultKeypoolSize    = 100
 	defaultDisallowFree   = false
+	defaultCoinSelect     = defaultCoinSelectorName
 )
 
 var (
@@ -62,9 +63,13 @@ type config struct {
 	MainNet      bool     `long:"mainnet" description:"Use the main Bitcoin network (default testnet3)"`
 	KeypoolSize  uint     `short:"k" long:"keypoolsize" description:"Maximum number of addresses in keypool"`
 	DisallowFree bool     `long:"disallowfree" description:"Force transactions to always include a fee"`
+	FeeTarget    uint     `long:"feetarget" description:"Pay fee rates estimated from recent blocks to confirm new transactions within this many blocks (0 uses the settxfee rate)"`
+	CoinSelect   string   `long:"coinselect" description:"Default strategy for choosing transaction inputs {largestfirst, oldestfirst, branchandbound, singleaddress}"`
 	Proxy        string   `long:"proxy" description:"Connect via SOCKS5 proxy (eg. 127.0.0.1:9050)"`
 	ProxyUser    string   `long:"proxyuser" description:"Username for proxy server"`
 	ProxyPass    string   `long:"proxypass" default-mask:"-" description:"Password for proxy server"`
+	ApproveUser  string   `long:"approveuser" description:"Username for approving sends -- when set, sends are held until approved with this login"`
+	ApprovePass  string   `long:"approvepass" default-mask:"-" description:"Password for approving sends"`
 	Profile      string   `long:"profile" description:"Enable HTTP profiling on given port -- NOTE port must be between 1024 and 65536"`
 }
 
@@ -148,6 +153,7 @@ func loadConfig() (*config, []string, error) {
 		RPCCert:      defaultRPCCertFile,
 		KeypoolSize:  defaultKeypoolSize,
 		DisallowFree: defaultDisallowFree,
+		CoinSelect:   defaultCoinSelect,
 	}
 
 	// A config file in the current directory takes precedence.
@@ -218,6 +224,33 @@ func loadConfig() (*config, []string, error) {
 		return nil, nil, err
 	}
 
+	// Validate the default coin selection strategy.
+	if !validCoinSelector(cfg.CoinSelect) {
+		str := "%s: The specified coin selection strategy [%v] is invalid"
+		err := fmt.Errorf(str, "loadConfig", cfg.CoinSelect)
+		fmt.Fprintln(os.Stderr, err)
+		parser.WriteHelp(os.Stderr)
+		return nil, nil, err
+	}
+
+	// Sends may only be approved with a login separate from the one
+	// used to create them.
+	if cfg.ApproveUser != "" || cfg.ApprovePass != "" {
+		var str string
+		switch {
+		case cfg.ApproveUser == "" || cfg.ApprovePass == "":
+			str = "%s: Both --approveuser and --approvepass must be set"
+		case cfg.ApproveUser == cfg.Username && cfg.ApprovePass == cfg.Password:
+			str = "%s: The send approval login must differ from the RPC login"
+		}
+		if str != "" {
+			err := fmt.Errorf(str, "loadConfig")
+			fmt.Fprintln(os.Stderr, err)
+			parser.WriteHelp(os.Stderr)
+			return nil, nil, err
+		}
+	}
+
 	if cfg.RPCConnect == "" {
 		cfg.RPCConnect = activeNetParams.connect
 	}
@@ -275,6 +308,12 @@ func loadConfig() (*config, []string, error) {
 	return &cfg, remainingArgs, nil
 }
 
+// SendApproval returns whether sends must be approved with the separate
+// approval login before they are sent.
+func (c *config) SendApproval() bool {
+	return c.ApproveUser != ""
+}
+
 func (c *config) Net() btcwire.BitcoinNet {
 	if cfg.MainNet {
 		return btcwire.MainNet
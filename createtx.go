@@ -18,6 +18,7 @@ package main
 
 import (
 	"bytes"
+	"crypto/rand"
 	"errors"
 	"fmt"
 	"github.com/conformal/btcchain"
@@ -26,6 +27,8 @@ import (
 	"github.com/conformal/btcwallet/txstore"
 	"github.com/conformal/btcwallet/wallet"
 	"github.com/conformal/btcwire"
+	"io"
+	"math/big"
 	"sort"
 	"sync"
 	"time"
@@ -47,13 +50,32 @@ var ErrNonPositiveAmount = errors.New("amount is not positive")
 // negative.
 var ErrNegativeFee = errors.New("fee is negative")
 
+// ErrNoUnspentChange represents an error where a transaction does not
+// have an unspent change output which may be spent to bump its fee.
+var ErrNoUnspentChange = errors.New("transaction has no unspent change")
+
+// ErrSubtractFeeFromUnknown represents an error where the fee of a
+// transaction is requested to be subtracted from an address which is not
+// paid by any of the transaction's outputs.
+var ErrSubtractFeeFromUnknown = errors.New("address to subtract fee from is not an output")
+
+// ErrDataTooLarge represents an error where the data carried by a null
+// data output is larger than the maximum size relayed by the network.
+var ErrDataTooLarge = errors.New("data is too large for a null data output")
+
+// ErrNothingToConsolidate represents an error where fewer than two unspent
+// outputs can be merged by a consolidation within its fee ceiling.
+var ErrNothingToConsolidate = errors.New("fewer than two outputs can be consolidated within the fee ceiling")
+
 // minTxFee is the default minimum transation fee (0.0001 BTC,
 // measured in satoshis) added to transactions requiring a fee.
 const minTxFee = 10000
 
-// TxFeeIncrement represents the global transaction fee per KB of Tx
-// added to newly-created transactions and sent as a reward to the block
-// miner.  i is measured in satoshis.
+// TxFeeIncrement represents the global transaction fee rate, per KB of Tx,
+// used to calculate fees added to newly-created transactions and sent as a
+// reward to the block miner.  i is measured in satoshis, and the fee is
+// charged in proportion to the size of the transaction rather than for each
+// whole kilobyte.
 var TxFeeIncrement = struct {
 	sync.Mutex
 	i btcutil.Amount
@@ -61,10 +83,38 @@ var TxFeeIncrement = struct {
 	i: minTxFee,
 }
 
+// CreatedTx holds a newly created and signed transaction, along with the
+// wallet credits it spends.  If a change address was generated while
+// creating the transaction, it is set as changeAddr.  changeIndex is the
+// output index of the change output, or -1 if the transaction does not pay
+// change back to the wallet.
 type CreatedTx struct {
-	tx         *btcutil.Tx
-	inputs     []*txstore.Credit
-	changeAddr btcutil.Address
+	tx          *btcutil.Tx
+	inputs      []*txstore.Credit
+	changeAddr  btcutil.Address
+	changeIndex int
+}
+
+// changeRandReader is the source of randomness used to choose the output
+// index of change outputs.  Tests may replace this with a deterministic
+// reader.
+var changeRandReader io.Reader = rand.Reader
+
+// randomChangeIndex returns a random output index, in the range [0, n], at
+// which to insert a change output into a transaction with n other outputs.
+func randomChangeIndex(n int) (int, error) {
+	i, err := rand.Int(changeRandReader, big.NewInt(int64(n+1)))
+	if err != nil {
+		return 0, err
+	}
+	return int(i.Int64()), nil
+}
+
+// insertTxOut inserts txout into the outputs of msgtx at index i.
+func insertTxOut(msgtx *btcwire.MsgTx, i int, txout *btcwire.TxOut) {
+	msgtx.TxOut = append(msgtx.TxOut, nil)
+	copy(msgtx.TxOut[i+1:], msgtx.TxOut[i:])
+	msgtx.TxOut[i] = txout
 }
 
 // ByAmount defines the methods needed to satisify sort.Interface to
@@ -83,25 +133,46 @@ func (u ByAmount) Swap(i, j int) {
 	u[i], u[j] = u[j], u[i]
 }
 
-// selectInputs selects the minimum number possible of unspent
-// outputs to use to create a new transaction that spends amt satoshis.
-// Previous outputs with less than minconf confirmations are ignored.  btcout
-// is the total number of satoshis which would be spent by the combination
-// of all selected previous outputs.  err will equal ErrInsufficientFunds if there
-// are not enough unspent outputs to spend amt.
-func selectInputs(credits []*txstore.Credit, amt btcutil.Amount,
-	minconf int) (selected []*txstore.Credit, out btcutil.Amount, err error) {
+// selectInputs selects unspent outputs to use to create a new transaction
+// that spends amt satoshis paying feeRate, using selector to choose among
+// all eligible outputs.  If selector is nil, the configured default
+// strategy is used.
+// Previous outputs with less than minconf confirmations, immature coinbase
+// outputs, locked or reserved outputs, and outputs to watch-only addresses
+// are ignored.  btcout is the total number of satoshis which would be
+// spent by the combination of all selected previous outputs.  err will
+// equal ErrInsufficientFunds if there are not enough unspent outputs to
+// spend amt.
+func (a *Account) selectInputs(credits []*txstore.Credit, amt btcutil.Amount,
+	minconf int, selector CoinSelector, feeRate FeeRate) (selected []*txstore.Credit, out btcutil.Amount, err error) {
 
-	bs, err := GetCurBlock()
+	eligible, err := a.eligibleCredits(credits, minconf)
 	if err != nil {
 		return nil, 0, err
 	}
 
-	// Create list of eligible unspent previous outputs to use as tx
-	// inputs, and sort by the amount in reverse order so a minimum number
-	// of inputs is needed.
+	if selector == nil {
+		selector = DefaultCoinSelector()
+	}
+	return selector.SelectCoins(eligible, amt, feeRate)
+}
+
+// eligibleCredits returns each credit which may be spent as a transaction
+// input.  Previous outputs with less than minconf confirmations, immature
+// coinbase outputs, locked outputs, outputs reserved by held transactions,
+// and outputs to watch-only addresses of the account are never eligible.
+func (a *Account) eligibleCredits(credits []*txstore.Credit, minconf int) ([]*txstore.Credit, error) {
+	bs, err := GetCurBlock()
+	if err != nil {
+		return nil, err
+	}
+
 	eligible := make([]*txstore.Credit, 0, len(credits))
 	for _, c := range credits {
+		if c.Locked() || a.outputReserved(c.OutPoint()) ||
+			a.watchOnlyCredit(c) {
+			continue
+		}
 		if c.Confirmed(minconf, bs.Height) {
 			// Coinbase transactions must have have reached maturity
 			// before their outputs may be spent.
@@ -114,23 +185,141 @@ func selectInputs(credits []*txstore.Credit, amt btcutil.Amount,
 			eligible = append(eligible, c)
 		}
 	}
-	sort.Sort(sort.Reverse(ByAmount(eligible)))
+	return eligible, nil
+}
 
-	// Iterate throguh eligible transactions, appending to outputs and
-	// increasing out.  This is finished when out is greater than the
-	// requested amt to spend.
-	for _, e := range eligible {
-		selected = append(selected, e)
-		out += e.Amount()
-		if out >= amt {
-			return selected, out, nil
-		}
+// watchOnlyCredit returns whether c pays to a watch-only address of the
+// account, which the wallet has no private key to spend.
+func (a *Account) watchOnlyCredit(c *txstore.Credit) bool {
+	_, addrs, _, _ := c.Addresses(cfg.Net())
+	return len(addrs) == 1 && a.IsWatchOnly(addrs[0])
+}
+
+// txOptions holds the optional settings used by txToPairs when creating a
+// transaction.  The zero value (or a nil *txOptions) uses the wallet's
+// configured defaults.
+type txOptions struct {
+	// selector chooses which eligible outputs are spent.  If nil, the
+	// configured default strategy is used.
+	selector CoinSelector
+
+	// feeRate overrides the global fee rate set by settxfee.  If zero,
+	// the global rate is used.
+	feeRate FeeRate
+
+	// inputs, if non-nil, are the exact credits spent by the transaction
+	// instead of selecting inputs from all eligible outputs.  The
+	// selector and minconf are ignored.
+	inputs []*txstore.Credit
+
+	// subtractFeeFrom holds the encoded addresses of outputs the fee is
+	// deducted from.  If empty, the fee is paid in addition to the
+	// output amounts.
+	subtractFeeFrom []string
+
+	// data, if non-nil, is carried by an additional zero-value null data
+	// output.
+	data []byte
+
+	// lockTime, if non-zero, is the lock time of the transaction.  Inputs
+	// use non-final sequence numbers so the lock time is enforced.
+	lockTime uint32
+
+	// unsigned, if true, leaves every input unsigned so the transaction
+	// may be signed later, possibly by other wallets.  The wallet does
+	// not need to be unlocked.
+	unsigned bool
+}
+
+// InsufficientInputsError describes an error where the inputs chosen to be
+// spent by a transaction are not enough to pay for its outputs and fee.
+type InsufficientInputsError struct {
+	Needed    btcutil.Amount
+	Available btcutil.Amount
+}
+
+// Error implements the error interface.
+func (e InsufficientInputsError) Error() string {
+	return fmt.Sprintf("selected inputs total %v, but %v is needed to "+
+		"pay for all outputs and the fee", e.Available, e.Needed)
+}
+
+// DustOutputError describes an error where subtracting the fee from an
+// output would leave it worth too little to be relayed.
+type DustOutputError struct {
+	Address string
+	Amount  btcutil.Amount
+}
+
+// Error implements the error interface.
+func (e DustOutputError) Error() string {
+	return fmt.Sprintf("output to %s would be dust (%v) after subtracting "+
+		"the fee", e.Address, e.Amount)
+}
+
+// InvalidInputError describes an error where an outpoint chosen to be spent
+// is not an unspent output which may be spent by the account.
+type InvalidInputError struct {
+	btcwire.OutPoint
+	Reason string
+}
+
+// Error implements the error interface.
+func (e InvalidInputError) Error() string {
+	return fmt.Sprintf("cannot spend %v:%d: %s", e.Hash, e.Index, e.Reason)
+}
+
+// unspentCredits looks up the unspent credit of the account referenced by
+// each outpoint of ops.  InvalidInputError is returned if any outpoint is
+// not an unspent credit, is repeated, is locked or reserved by a held
+// transaction, or spends an immature coinbase.  wallet.ErrWatchOnlyAddress
+// is returned if any outpoint pays to a watch-only address, which the
+// wallet can not sign for.
+func (a *Account) unspentCredits(ops []btcwire.OutPoint) ([]*txstore.Credit, error) {
+	bs, err := GetCurBlock()
+	if err != nil {
+		return nil, err
 	}
-	if out < amt {
-		return nil, 0, ErrInsufficientFunds
+
+	unspent, err := a.TxStore.UnspentOutputs()
+	if err != nil {
+		return nil, err
+	}
+	byOutPoint := make(map[btcwire.OutPoint]*txstore.Credit, len(unspent))
+	for _, c := range unspent {
+		byOutPoint[*c.OutPoint()] = c
 	}
 
-	return selected, out, nil
+	credits := make([]*txstore.Credit, 0, len(ops))
+	seen := make(map[btcwire.OutPoint]struct{}, len(ops))
+	for _, op := range ops {
+		if _, ok := seen[op]; ok {
+			return nil, InvalidInputError{op, "output is repeated"}
+		}
+		seen[op] = struct{}{}
+
+		c, ok := byOutPoint[op]
+		if !ok {
+			return nil, InvalidInputError{op, "not an unspent " +
+				"output of the account"}
+		}
+		if a.watchOnlyCredit(c) {
+			return nil, wallet.ErrWatchOnlyAddress
+		}
+		if c.Locked() {
+			return nil, InvalidInputError{op, "output is locked"}
+		}
+		if a.outputReserved(&op) {
+			return nil, InvalidInputError{op, "output is reserved " +
+				"by a held transaction"}
+		}
+		if c.IsCoinbase() && !c.Confirmed(btcchain.CoinbaseMaturity, bs.Height) {
+			return nil, InvalidInputError{op, "coinbase output " +
+				"has not reached maturity"}
+		}
+		credits = append(credits, c)
+	}
+	return credits, nil
 }
 
 // txToPairs creates a raw transaction sending the amounts for each
@@ -141,12 +330,25 @@ func selectInputs(credits []*txstore.Credit, amt btcutil.Amount,
 // address. If change is needed to return funds back to an owned
 // address, changeUtxo will point to a unconfirmed (height = -1, zeroed
 // block hash) Utxo.  ErrInsufficientFunds is returned if there are not
-// enough eligible unspent outputs to create the transaction.
+// enough eligible unspent outputs to create the transaction.  opts may be
+// nil to create the transaction using the wallet defaults.
+//
+// The fee is calculated from the fee rate and the estimated size of the
+// transaction once all inputs are signed, so inputs are only signed once
+// the final inputs and outputs are known.
 func (a *Account) txToPairs(pairs map[string]btcutil.Amount,
-	minconf int) (*CreatedTx, error) {
+	minconf int, opts *txOptions) (*CreatedTx, error) {
 
-	// Wallet must be unlocked to compose transaction.
-	if a.IsLocked() {
+	if opts == nil {
+		opts = &txOptions{}
+	}
+	feeRate := opts.feeRate
+	if feeRate == 0 {
+		feeRate = defaultFeeRate()
+	}
+
+	// Wallet must be unlocked to compose a signed transaction.
+	if !opts.unsigned && a.IsLocked() {
 		return nil, wallet.ErrWalletLocked
 	}
 
@@ -164,6 +366,7 @@ func (a *Account) txToPairs(pairs map[string]btcutil.Amount,
 	}
 
 	// Add outputs to new tx.
+	outputIdx := make(map[string]int, len(pairs))
 	for addrStr, amt := range pairs {
 		addr, err := btcutil.DecodeAddress(addrStr, cfg.Net())
 		if err != nil {
@@ -176,9 +379,33 @@ func (a *Account) txToPairs(pairs map[string]btcutil.Amount,
 			return nil, fmt.Errorf("cannot create txout script: %s", err)
 		}
 		txout := btcwire.NewTxOut(int64(amt), pkScript)
+		outputIdx[addrStr] = len(msgtx.TxOut)
 		msgtx.AddTxOut(txout)
 	}
 
+	// Find the outputs which pay the fee, if the fee is subtracted from
+	// the sent amounts rather than added to them.
+	subtractFrom := make([]int, 0, len(opts.subtractFeeFrom))
+	for _, addrStr := range opts.subtractFeeFrom {
+		i, ok := outputIdx[addrStr]
+		if !ok {
+			return nil, ErrSubtractFeeFromUnknown
+		}
+		subtractFrom = append(subtractFrom, i)
+	}
+
+	// Add an output carrying data.  This is the only output which may
+	// have a zero value.
+	if opts.data != nil {
+		pkScript, err := nullDataScript(opts.data)
+		if err != nil {
+			return nil, err
+		}
+		msgtx.AddTxOut(btcwire.NewTxOut(0, pkScript))
+	}
+
+	msgtx.LockTime = opts.lockTime
+
 	// Get current block's height and hash.
 	bs, err := GetCurBlock()
 	if err != nil {
@@ -195,31 +422,83 @@ func (a *Account) txToPairs(pairs map[string]btcutil.Amount,
 		return nil, err
 	}
 
+	// Branch and bound selection chooses inputs expecting change worth
+	// less than the fee needed to create and later spend it to be left
+	// for the miner.  Change is always kept with any other strategy.
+	selector := opts.selector
+	if selector == nil {
+		selector = DefaultCoinSelector()
+	}
+	var minChange btcutil.Amount
+	if _, ok := selector.(BranchAndBoundSelector); ok && opts.inputs == nil {
+		minChange = costOfChange(feeRate)
+	}
+
 	var selectedInputs []*txstore.Credit
 	// These are nil/zeroed until a change address is needed, and reused
 	// again in case a change utxo has already been chosen.
 	var changeAddr btcutil.Address
+	changeIdx := -1
 
-	// Get the number of satoshis to increment fee by when searching for
-	// the minimum tx fee needed.
+	// Search for the minimum fee needed.  Each time the fee is found to
+	// be too low, inputs are selected again to cover the higher fee.
 	fee := btcutil.Amount(0)
 	for {
 		msgtx = txNoInputs.Copy()
 
+		// When the fee is subtracted from outputs, it is split evenly
+		// between them, with any remainder paid by the first, and the
+		// inputs only need to cover the requested amounts.
+		needed := amt + fee
+		if len(subtractFrom) != 0 {
+			needed = amt
+			n := btcutil.Amount(len(subtractFrom))
+			for j, i := range subtractFrom {
+				txout := msgtx.TxOut[i]
+				share := fee / n
+				if j == 0 {
+					share += fee % n
+				}
+				txout.Value -= int64(share)
+				if isDust(txout) {
+					return nil, DustOutputError{
+						Address: opts.subtractFeeFrom[j],
+						Amount:  btcutil.Amount(txout.Value),
+					}
+				}
+			}
+		}
+
 		// Select unspent outputs to be used in transaction based on the amount
-		// neededing to sent, and the current fee estimation.
-		inputs, btcin, err := selectInputs(unspent, amt+fee, minconf)
-		if err != nil {
-			return nil, err
+		// neededing to sent, and the current fee estimation.  If the inputs
+		// were chosen by the caller, check that they are enough instead.
+		var inputs []*txstore.Credit
+		var btcin btcutil.Amount
+		if opts.inputs != nil {
+			inputs = opts.inputs
+			for _, c := range inputs {
+				btcin += c.Amount()
+			}
+			if btcin < needed {
+				return nil, InsufficientInputsError{
+					Needed:    needed,
+					Available: btcin,
+				}
+			}
+		} else {
+			inputs, btcin, err = a.selectInputs(unspent, needed,
+				minconf, selector, feeRate)
+			if err != nil {
+				return nil, err
+			}
 		}
 
 		// Check if there are leftover unspent outputs, and return coins back to
-		// a new address we own.
-		//
-		// TODO: change needs to be inserted into a random txout index, or else
-		// this is a privacy risk.
-		change := btcin - amt - fee
-		if change > 0 {
+		// a new address we own.  The change output is inserted at a random
+		// index so it can not be identified by its position.
+		changeIdx = -1
+		change := btcin - needed
+		if change > minChange {
 			// Get a new change address if one has not already been found.
 			if changeAddr == nil {
 				changeAddr, err = a.ChangeAddress(&bs, cfg.KeypoolSize)
@@ -236,51 +515,33 @@ func (a *Account) txToPairs(pairs map[string]btcutil.Amount,
 			if err != nil {
 				return nil, fmt.Errorf("cannot create txout script: %s", err)
 			}
-			msgtx.AddTxOut(btcwire.NewTxOut(int64(change), pkScript))
+			changeIdx, err = randomChangeIndex(len(msgtx.TxOut))
+			if err != nil {
+				return nil, fmt.Errorf("cannot choose change output index: %s", err)
+			}
+			insertTxOut(msgtx, changeIdx, btcwire.NewTxOut(int64(change), pkScript))
 		}
 
 		// Selected unspent outputs become new transaction's inputs.
 		for _, ip := range inputs {
-			msgtx.AddTxIn(btcwire.NewTxIn(ip.OutPoint(), nil))
-		}
-		for i, input := range inputs {
-			_, addrs, _, _ := input.Addresses(cfg.Net())
-			if len(addrs) != 1 {
-				continue
-			}
-			apkh, ok := addrs[0].(*btcutil.AddressPubKeyHash)
-			if !ok {
-				continue // don't handle inputs to this yes
-			}
-
-			ai, err := a.Address(apkh)
-			if err != nil {
-				return nil, fmt.Errorf("cannot get address info: %v", err)
-			}
-
-			pka := ai.(wallet.PubKeyAddress)
-
-			privkey, err := pka.PrivKey()
-			if err == wallet.ErrWalletLocked {
-				return nil, wallet.ErrWalletLocked
-			} else if err != nil {
-				return nil, fmt.Errorf("cannot get address key: %v", err)
+			txIn := btcwire.NewTxIn(ip.OutPoint(), nil)
+			if opts.lockTime != 0 {
+				txIn.Sequence = btcwire.MaxTxInSequenceNum - 1
 			}
-
-			sigscript, err := btcscript.SignatureScript(msgtx, i,
-				input.TxOut().PkScript, btcscript.SigHashAll, privkey,
-				ai.Compressed())
-			if err != nil {
-				return nil, fmt.Errorf("cannot create sigscript: %s", err)
-			}
-			msgtx.TxIn[i].SignatureScript = sigscript
+			msgtx.AddTxIn(txIn)
 		}
 
+		// Check the fee against the minimum fee needed for the
+		// transaction once all inputs are signed.
+		txSize, err := a.estimateSignedSize(msgtx, inputs)
+		if err != nil {
+			return nil, err
+		}
 		noFeeAllowed := false
 		if !cfg.DisallowFree {
-			noFeeAllowed = allowFree(bs.Height, inputs, msgtx.SerializeSize())
+			noFeeAllowed = allowFree(bs.Height, inputs, txSize)
 		}
-		if minFee := minimumFee(msgtx, noFeeAllowed); fee < minFee {
+		if minFee := minimumFee(msgtx, txSize, feeRate, noFeeAllowed); fee < minFee {
 			fee = minFee
 		} else {
 			selectedInputs = inputs
@@ -288,7 +549,76 @@ func (a *Account) txToPairs(pairs map[string]btcutil.Amount,
 		}
 	}
 
-	// Validate msgtx before returning the raw transaction.
+	// Check the final transaction against the account's spending
+	// policy before it is signed.
+	if err := a.checkPolicy(msgtx, changeIdx); err != nil {
+		return nil, err
+	}
+
+	if !opts.unsigned {
+		if err := a.signTx(msgtx, selectedInputs); err != nil {
+			return nil, err
+		}
+		if err := validateMsgTx(msgtx, selectedInputs); err != nil {
+			return nil, err
+		}
+	}
+
+	buf := bytes.NewBuffer(nil)
+	buf.Grow(msgtx.SerializeSize())
+	msgtx.BtcEncode(buf, btcwire.ProtocolVersion)
+	info := &CreatedTx{
+		tx:          btcutil.NewTx(msgtx),
+		inputs:      selectedInputs,
+		changeAddr:  changeAddr,
+		changeIndex: changeIdx,
+	}
+	return info, nil
+}
+
+// signTx signs each input of msgtx, which spends the credit at the same
+// index of inputs, with the private key of the wallet address the credit
+// pays to.  Inputs redeeming outputs to anything other than a single
+// pubkey hash address are skipped.
+func (a *Account) signTx(msgtx *btcwire.MsgTx, inputs []*txstore.Credit) error {
+	for i, input := range inputs {
+		_, addrs, _, _ := input.Addresses(cfg.Net())
+		if len(addrs) != 1 {
+			continue
+		}
+		apkh, ok := addrs[0].(*btcutil.AddressPubKeyHash)
+		if !ok {
+			continue // don't handle inputs to this yes
+		}
+
+		ai, err := a.Address(apkh)
+		if err != nil {
+			return fmt.Errorf("cannot get address info: %v", err)
+		}
+
+		pka := ai.(wallet.PubKeyAddress)
+
+		privkey, err := pka.PrivKey()
+		if err == wallet.ErrWalletLocked {
+			return wallet.ErrWalletLocked
+		} else if err != nil {
+			return fmt.Errorf("cannot get address key: %v", err)
+		}
+
+		sigscript, err := btcscript.SignatureScript(msgtx, i,
+			input.TxOut().PkScript, btcscript.SigHashAll, privkey,
+			ai.Compressed())
+		if err != nil {
+			return fmt.Errorf("cannot create sigscript: %s", err)
+		}
+		msgtx.TxIn[i].SignatureScript = sigscript
+	}
+	return nil
+}
+
+// validateMsgTx executes the script of each input of msgtx against the
+// output script of the credit it spends at the same index of inputs.
+func validateMsgTx(msgtx *btcwire.MsgTx, inputs []*txstore.Credit) error {
 	flags := btcscript.ScriptCanonicalSignatures
 	bip16 := time.Now().After(btcscript.Bip16Activation)
 	if bip16 {
@@ -296,47 +626,378 @@ func (a *Account) txToPairs(pairs map[string]btcutil.Amount,
 	}
 	for i, txin := range msgtx.TxIn {
 		engine, err := btcscript.NewScript(txin.SignatureScript,
-			selectedInputs[i].TxOut().PkScript, i, msgtx, flags)
+			inputs[i].TxOut().PkScript, i, msgtx, flags)
 		if err != nil {
-			return nil, fmt.Errorf("cannot create script engine: %s", err)
+			return fmt.Errorf("cannot create script engine: %s", err)
 		}
 		if err = engine.Execute(); err != nil {
-			return nil, fmt.Errorf("cannot validate transaction: %s", err)
+			return fmt.Errorf("cannot validate transaction: %s", err)
 		}
 	}
+	return nil
+}
+
+// txBumpingFee creates a transaction spending the unspent change credit of
+// the unmined transaction parent back to a new change address.  The child
+// pays a fee high enough so that the fee paid by both transactions together
+// reaches rate for their combined size, allowing miners to include the
+// parent by mining both (child pays for parent).  ErrNoUnspentChange is
+// returned if parent does not debit the wallet or has no spendable change,
+// and ErrInsufficientFunds is returned if the change is not enough to pay
+// the fee.
+func (a *Account) txBumpingFee(parent *txstore.TxRecord, rate FeeRate) (*CreatedTx, error) {
+	// Wallet must be unlocked to compose transaction.
+	if a.IsLocked() {
+		return nil, wallet.ErrWalletLocked
+	}
+
+	debits := parent.Debits()
+	if debits == nil {
+		return nil, ErrNoUnspentChange
+	}
+	var change *txstore.Credit
+	for _, c := range parent.Credits() {
+		if c.Change() && !c.Spent() && !c.Locked() &&
+			!a.outputReserved(c.OutPoint()) {
+			change = c
+			break
+		}
+	}
+	if change == nil {
+		return nil, ErrNoUnspentChange
+	}
+
+	bs, err := GetCurBlock()
+	if err != nil {
+		return nil, err
+	}
+	changeAddr, err := a.ChangeAddress(&bs, cfg.KeypoolSize)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get next address: %s", err)
+	}
+	AcctMgr.MarkAddressForAccount(changeAddr, a)
+	pkScript, err := btcscript.PayToAddrScript(changeAddr)
+	if err != nil {
+		return nil, fmt.Errorf("cannot create txout script: %s", err)
+	}
+
+	msgtx := btcwire.NewMsgTx()
+	msgtx.AddTxIn(btcwire.NewTxIn(change.OutPoint(), nil))
+	msgtx.AddTxOut(btcwire.NewTxOut(0, pkScript))
+	inputs := []*txstore.Credit{change}
+
+	// The child must pay for the size of both transactions, less the
+	// fee already paid by the parent, but never less than the rate for
+	// its own size.
+	childSize, err := a.estimateSignedSize(msgtx, inputs)
+	if err != nil {
+		return nil, err
+	}
+	parentSize := parent.Tx().MsgTx().SerializeSize()
+	fee := rate.Fee(parentSize+childSize) - debits.Fee()
+	if minFee := rate.Fee(childSize); fee < minFee {
+		fee = minFee
+	}
+	remaining := change.Amount() - fee
+	if remaining <= costOfChange(rate) {
+		return nil, ErrInsufficientFunds
+	}
+	msgtx.TxOut[0].Value = int64(remaining)
+
+	if err := a.checkPolicy(msgtx, 0); err != nil {
+		return nil, err
+	}
+	if err := a.signTx(msgtx, inputs); err != nil {
+		return nil, err
+	}
+	if err := validateMsgTx(msgtx, inputs); err != nil {
+		return nil, err
+	}
+
+	info := &CreatedTx{
+		tx:          btcutil.NewTx(msgtx),
+		inputs:      inputs,
+		changeAddr:  changeAddr,
+		changeIndex: 0,
+	}
+	return info, nil
+}
+
+// consolidation describes a transaction merging many small credits into a
+// single output.
+type consolidation struct {
+	inputs  []*txstore.Credit
+	total   btcutil.Amount
+	fee     btcutil.Amount
+	size    int
+	feeRate FeeRate
+}
+
+// planConsolidation chooses the credits spent by a transaction merging the
+// smallest eligible credits of an account into a single output, paying the
+// default fee rate.  Credits are added smallest first, up to maxInputs of
+// them, and adding stops early once the fee added by the next input would
+// be more than the input's size at fee rate ceiling, or would be more than
+// the input is worth.  ErrNothingToConsolidate is returned if fewer than
+// two credits can be merged.
+func (a *Account) planConsolidation(maxInputs int, ceiling FeeRate,
+	minconf int) (*consolidation, error) {
+
+	unspent, err := a.TxStore.UnspentOutputs()
+	if err != nil {
+		return nil, err
+	}
+	eligible, err := a.eligibleCredits(unspent, minconf)
+	if err != nil {
+		return nil, err
+	}
+	sort.Sort(ByAmount(eligible))
+
+	// The merged output pays to a new pubkey hash address, so a script
+	// of the same length is used to estimate the transaction size.
+	msgtx := btcwire.NewMsgTx()
+	msgtx.AddTxOut(btcwire.NewTxOut(0, make([]byte, p2pkhOutputSize-9)))
+
+	rate := defaultFeeRate()
+	plan := &consolidation{
+		size:    msgtx.SerializeSize(),
+		feeRate: rate,
+	}
+	plan.fee = rate.Fee(plan.size)
+	for _, c := range eligible {
+		if len(plan.inputs) == maxInputs {
+			break
+		}
+
+		inputs := append(plan.inputs, c)
+		msgtx.AddTxIn(btcwire.NewTxIn(c.OutPoint(), nil))
+		size, err := a.estimateSignedSize(msgtx, inputs)
+		if err != nil {
+			return nil, err
+		}
+		total := plan.total + c.Amount()
+		msgtx.TxOut[0].Value = int64(total)
+		fee := minimumFee(msgtx, size, rate, false)
+
+		addedFee := fee - plan.fee
+		if addedFee > ceiling.Fee(size-plan.size) || addedFee >= c.Amount() {
+			break
+		}
+		plan.inputs = inputs
+		plan.total = total
+		plan.fee = fee
+		plan.size = size
+	}
+	if len(plan.inputs) < 2 {
+		return nil, ErrNothingToConsolidate
+	}
+	return plan, nil
+}
+
+// txConsolidating creates and signs the transaction for a consolidation
+// plan, paying the merged value less the fee to a new change address.
+func (a *Account) txConsolidating(plan *consolidation) (*CreatedTx, error) {
+	// Wallet must be unlocked to compose transaction.
+	if a.IsLocked() {
+		return nil, wallet.ErrWalletLocked
+	}
+	if plan.total <= plan.fee {
+		return nil, ErrInsufficientFunds
+	}
+
+	changeAddr, err := a.NewChangeAddress()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get next address: %s", err)
+	}
+	pkScript, err := btcscript.PayToAddrScript(changeAddr)
+	if err != nil {
+		return nil, fmt.Errorf("cannot create txout script: %s", err)
+	}
+
+	msgtx := btcwire.NewMsgTx()
+	for _, c := range plan.inputs {
+		msgtx.AddTxIn(btcwire.NewTxIn(c.OutPoint(), nil))
+	}
+	msgtx.AddTxOut(btcwire.NewTxOut(int64(plan.total-plan.fee), pkScript))
+
+	if err := a.checkPolicy(msgtx, 0); err != nil {
+		return nil, err
+	}
+	if err := a.signTx(msgtx, plan.inputs); err != nil {
+		return nil, err
+	}
+	if err := validateMsgTx(msgtx, plan.inputs); err != nil {
+		return nil, err
+	}
 
-	buf := bytes.NewBuffer(nil)
-	buf.Grow(msgtx.SerializeSize())
-	msgtx.BtcEncode(buf, btcwire.ProtocolVersion)
 	info := &CreatedTx{
-		tx:         btcutil.NewTx(msgtx),
-		inputs:     selectedInputs,
-		changeAddr: changeAddr,
+		tx:          btcutil.NewTx(msgtx),
+		inputs:      plan.inputs,
+		changeAddr:  changeAddr,
+		changeIndex: 0,
 	}
 	return info, nil
 }
 
-// minimumFee calculates the minimum fee required for a transaction.
-// If allowFree is true, a fee may be zero so long as the entire
-// transaction has a serialized length less than 1 kilobyte
-// and none of the outputs contain a value less than 1 bitcent.
-// Otherwise, the fee will be calculated using TxFeeIncrement,
-// incrementing the fee for each kilobyte of transaction.
-func minimumFee(tx *btcwire.MsgTx, allowFree bool) btcutil.Amount {
-	txLen := tx.SerializeSize()
+// Estimated serialized sizes of a pay-to-pubkey-hash transaction output,
+// and the signature scripts redeeming pay-to-pubkey-hash and pay-to-pubkey
+// outputs.  Signatures are estimated at their maximum DER encoded length
+// plus the hash type byte.
+const (
+	p2pkhOutputSize                = 34
+	maxSigSize                     = 73
+	p2pkhCompressedSigScriptSize   = 1 + maxSigSize + 1 + 33
+	p2pkhUncompressedSigScriptSize = 1 + maxSigSize + 1 + 65
+	p2pkSigScriptSize              = 1 + maxSigSize
+
+	// p2pkhInputSize is the size of a transaction input redeeming a
+	// pay-to-pubkey-hash output with a compressed public key.  This is
+	// the outpoint, script length, signature script, and sequence.
+	p2pkhInputSize = 36 + 1 + p2pkhCompressedSigScriptSize + 4
+)
+
+// estimateSignedSize returns the estimated serialized size of msgtx once
+// each input, which redeems the credit at the same index of inputs, is
+// signed.  Input signature scripts of msgtx are expected to be empty.
+func (a *Account) estimateSignedSize(msgtx *btcwire.MsgTx,
+	inputs []*txstore.Credit) (int, error) {
+
+	// Signature scripts are all under 253 bytes, so the length of each
+	// continues to be serialized as a single byte, which is already
+	// included by the unsigned transaction's size.
+	size := msgtx.SerializeSize()
+	for _, input := range inputs {
+		class, addrs, _, _ := input.Addresses(cfg.Net())
+		switch {
+		case class == btcscript.PubKeyTy:
+			size += p2pkSigScriptSize
+
+		case class == btcscript.PubKeyHashTy && len(addrs) == 1:
+			ai, err := a.Address(addrs[0])
+			if err != nil {
+				return 0, fmt.Errorf("cannot get address info: %v", err)
+			}
+			if ai.Compressed() {
+				size += p2pkhCompressedSigScriptSize
+			} else {
+				size += p2pkhUncompressedSigScriptSize
+			}
+
+		default:
+			// Unknown script types are estimated with the
+			// largest script this wallet creates.
+			size += p2pkhUncompressedSigScriptSize
+		}
+	}
+	return size, nil
+}
+
+// FeeRate is a transaction fee rate measured in satoshis per 1000 bytes of
+// serialized transaction.
+type FeeRate btcutil.Amount
+
+// FeeRatePerByte returns the fee rate for a rate measured in satoshis per
+// byte.
+func FeeRatePerByte(satoshis float64) FeeRate {
+	return FeeRate(satoshis*1000 + 0.5)
+}
+
+// Fee returns the fee for a transaction of size bytes paying at rate r.
+// Fees for partial bytes are rounded up.
+func (r FeeRate) Fee(size int) btcutil.Amount {
+	return (btcutil.Amount(size)*btcutil.Amount(r) + 999) / 1000
+}
+
+// PerByte returns r measured in satoshis per byte.
+func (r FeeRate) PerByte() float64 {
+	return float64(r) / 1000
+}
+
+// defaultFeeRate returns the fee rate paid by new transactions when no rate
+// is specified.  If the feetarget option is set and enough blocks have been
+// seen by the fee estimator, this is the estimated rate, but never less than
+// the minimum relay fee rate.  Otherwise, the global rate set by settxfee is
+// used.
+func defaultFeeRate() FeeRate {
+	if cfg != nil && cfg.FeeTarget > 0 {
+		rate, err := FeeEst.EstimateFee(int(cfg.FeeTarget))
+		if err == nil {
+			if rate < minRelayFeeRate {
+				rate = minRelayFeeRate
+			}
+			return rate
+		}
+	}
+
 	TxFeeIncrement.Lock()
-	incr := TxFeeIncrement.i
-	TxFeeIncrement.Unlock()
-	fee := btcutil.Amount(int64(1+txLen/1000) * int64(incr))
+	defer TxFeeIncrement.Unlock()
+	return FeeRate(TxFeeIncrement.i)
+}
+
+// costOfChange returns the fee, at fee rate r, required to add a change
+// output to a transaction and to later spend it.  When inputs are chosen by
+// branch and bound selection, change outputs worth less than this are not
+// created.
+func costOfChange(r FeeRate) btcutil.Amount {
+	return r.Fee(p2pkhOutputSize + p2pkhInputSize)
+}
+
+// maxDataCarrierSize is the maximum number of bytes of data which may be
+// carried by a null data output for the transaction to remain standard.
+const maxDataCarrierSize = 80
+
+// nullDataScript returns a script for an unspendable output carrying data.
+// ErrDataTooLarge is returned if data is too large to be relayed.
+func nullDataScript(data []byte) ([]byte, error) {
+	if len(data) > maxDataCarrierSize {
+		return nil, ErrDataTooLarge
+	}
+
+	script := make([]byte, 0, len(data)+3)
+	script = append(script, btcscript.OP_RETURN)
+	switch {
+	case len(data) == 0:
+	case len(data) < btcscript.OP_PUSHDATA1:
+		script = append(script, byte(len(data)))
+	default:
+		script = append(script, btcscript.OP_PUSHDATA1, byte(len(data)))
+	}
+	return append(script, data...), nil
+}
+
+// isNullData returns whether pkScript is the script of an unspendable null
+// data output.
+func isNullData(pkScript []byte) bool {
+	return len(pkScript) > 0 && pkScript[0] == btcscript.OP_RETURN
+}
+
+// isDust returns whether txout is worth so little that spending it would
+// cost more than a third of its value at the minimum relay fee rate.  This
+// matches the dust check btcd applies before relaying transactions, which
+// estimates the input later spending txout to be 148 bytes.
+func isDust(txout *btcwire.TxOut) bool {
+	totalSize := txout.SerializeSize() + 148
+	return btcutil.Amount(txout.Value) < 3*minRelayFeeRate.Fee(totalSize)
+}
+
+// minimumFee calculates the minimum fee required for a transaction with
+// an estimated signed size of txSize bytes, paying at fee rate r.  If
+// allowFree is true, a fee may be zero so long as the entire transaction
+// has a serialized length less than 1 kilobyte and none of the outputs
+// contain a value less than 1 bitcent.  Transactions with outputs less
+// than 1 bitcent always pay at least the fee for a full kilobyte.
+func minimumFee(tx *btcwire.MsgTx, txSize int, r FeeRate, allowFree bool) btcutil.Amount {
+	fee := r.Fee(txSize)
 
-	if allowFree && txLen < 1000 {
+	if allowFree && txSize < 1000 {
 		fee = 0
 	}
 
-	if fee < incr {
+	if kbFee := btcutil.Amount(r); fee < kbFee {
 		for _, txOut := range tx.TxOut {
-			if txOut.Value < btcutil.SatoshiPerBitcent {
-				return incr
+			if txOut.Value < btcutil.SatoshiPerBitcent &&
+				!isNullData(txOut.PkScript) {
+				return kbFee
 			}
 		}
 	}
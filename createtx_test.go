@@ -1,18 +1,11 @@
-// TODO(jrick) Due to the extra encapsulation added during the switch
-// to the new txstore, structures can no longer be mocked due to private
-// members.  Since all members for RecvTxOut and SignedTx are private, the
-// simplist solution would be to make RecvTxOut an interface and create
-// our own types satisifying the interface for this test package.  Until
-// then, disable this test.
-//
-// +build ignore
-
 package main
 
 import (
+	"bytes"
+	"crypto/rand"
 	"github.com/conformal/btcscript"
 	"github.com/conformal/btcutil"
-	"github.com/conformal/btcwallet/tx"
+	"github.com/conformal/btcwallet/txstore"
 	"github.com/conformal/btcwallet/wallet"
 	"github.com/conformal/btcwire"
 	"testing"
@@ -21,12 +14,83 @@ import (
 func init() {
 	cfg = &config{
 		KeypoolSize: 100,
+		MainNet:     true,
+	}
+
+	// Fake our current block height so btcd doesn't need to be queried.
+	curBlock.BlockStamp.Height = 12346
+
+	// Change addresses are marked for their account by the account
+	// manager, which is not started for these tests.
+	go func() {
+		for _ = range AcctMgr.cmdChan {
+		}
+	}()
+}
+
+// testCreditIndex is incremented for each fake credit so that every fake
+// transaction spends a different outpoint and has a different hash.
+var testCreditIndex uint32
+
+// addTestCredit inserts a fake transaction mined at height into s, with a
+// single output of amt paying to pkScript, and returns the output's credit.
+//
+// This will pass validation because btcscript is unaware of invalid tx
+// inputs, however, transactions spending it would fail in btcd.
+func addTestCredit(t *testing.T, s *txstore.Store, amt btcutil.Amount,
+	height int32, pkScript []byte) *txstore.Credit {
+
+	testCreditIndex++
+	msgtx := btcwire.NewMsgTx()
+	op := btcwire.NewOutPoint(&btcwire.ShaHash{}, testCreditIndex)
+	msgtx.AddTxIn(btcwire.NewTxIn(op, nil))
+	msgtx.AddTxOut(btcwire.NewTxOut(int64(amt), pkScript))
+	tx := btcutil.NewTx(msgtx)
+	tx.SetIndex(int(testCreditIndex))
+
+	r, err := s.InsertTx(tx, &txstore.Block{Height: height})
+	if err != nil {
+		t.Fatalf("Cannot insert fake transaction: %v", err)
+	}
+	c, err := r.AddCredit(0, false)
+	if err != nil {
+		t.Fatalf("Cannot add fake credit: %v", err)
+	}
+	return c
+}
+
+// newTestAccount creates an account with an unlocked wallet holding a single
+// credit of amt to a wallet address.
+func newTestAccount(t *testing.T, amt btcutil.Amount) *Account {
+	w, err := wallet.NewWallet("banana wallet", "", []byte("banana"),
+		btcwire.MainNet, &wallet.BlockStamp{})
+	if err != nil {
+		t.Fatalf("Can not create encrypted wallet: %v", err)
+	}
+	if err := w.Unlock([]byte("banana")); err != nil {
+		t.Fatalf("Can not unlock wallet: %v", err)
+	}
+	a := &Account{
+		Wallet:  w,
+		TxStore: txstore.New(),
+	}
+
+	addr, err := w.NextChainedAddress(&wallet.BlockStamp{}, 100)
+	if err != nil {
+		t.Fatalf("Cannot get next address: %v", err)
+	}
+	pkScript, err := btcscript.PayToAddrScript(addr)
+	if err != nil {
+		t.Fatalf("Could not create credit PkScript: %v", err)
 	}
+	addTestCredit(t, a.TxStore, amt, 12345, pkScript)
+	return a
 }
 
 type allowFreeTest struct {
 	name      string
-	inputs    []*tx.Utxo
+	amt       btcutil.Amount
+	height    int32
 	curHeight int32
 	txSize    int
 	free      bool
@@ -34,37 +98,25 @@ type allowFreeTest struct {
 
 var allowFreeTests = []allowFreeTest{
 	{
-		name: "priority < 57,600,000",
-		inputs: []*tx.Utxo{
-			{
-				Amt:    uint64(btcutil.SatoshiPerBitcoin),
-				Height: 0,
-			},
-		},
+		name:      "priority < 57,600,000",
+		amt:       btcutil.SatoshiPerBitcoin,
+		height:    0,
 		curHeight: 142, // 143 confirmations
 		txSize:    250,
 		free:      false,
 	},
 	{
-		name: "priority == 57,600,000",
-		inputs: []*tx.Utxo{
-			{
-				Amt:    uint64(btcutil.SatoshiPerBitcoin),
-				Height: 0,
-			},
-		},
+		name:      "priority == 57,600,000",
+		amt:       btcutil.SatoshiPerBitcoin,
+		height:    0,
 		curHeight: 143, // 144 confirmations
 		txSize:    250,
 		free:      false,
 	},
 	{
-		name: "priority > 57,600,000",
-		inputs: []*tx.Utxo{
-			{
-				Amt:    uint64(btcutil.SatoshiPerBitcoin),
-				Height: 0,
-			},
-		},
+		name:      "priority > 57,600,000",
+		amt:       btcutil.SatoshiPerBitcoin,
+		height:    0,
 		curHeight: 144, // 145 confirmations
 		txSize:    250,
 		free:      true,
@@ -73,63 +125,99 @@ var allowFreeTests = []allowFreeTest{
 
 func TestAllowFree(t *testing.T) {
 	for _, test := range allowFreeTests {
-		calcFree := allowFree(test.curHeight, test.inputs, test.txSize)
+		s := txstore.New()
+		c := addTestCredit(t, s, test.amt, test.height, nil)
+		calcFree := allowFree(test.curHeight, []*txstore.Credit{c},
+			test.txSize)
 		if calcFree != test.free {
 			t.Errorf("Allow free test '%v' failed.", test.name)
 		}
 	}
 }
 
-func TestFakeTxs(t *testing.T) {
-	// First we need a wallet.
-	w, err := wallet.NewWallet("banana wallet", "", []byte("banana"),
-		btcwire.MainNet, &wallet.BlockStamp{}, 100)
-	if err != nil {
-		t.Errorf("Can not create encrypted wallet: %s", err)
-		return
-	}
-	a := &Account{
-		Wallet: w,
-	}
+type changeIndexTest struct {
+	name        string
+	randByte    byte
+	changeIndex int
+}
 
-	w.Unlock([]byte("banana"))
+var changeIndexTests = []changeIndexTest{
+	{
+		name:        "change before payment",
+		randByte:    0,
+		changeIndex: 0,
+	},
+	{
+		name:        "change after payment",
+		randByte:    1,
+		changeIndex: 1,
+	},
+}
 
-	// Create and add a fake Utxo so we have some funds to spend.
-	//
-	// This will pass validation because btcscript is unaware of invalid
-	// tx inputs, however, this example would fail in btcd.
-	utxo := &tx.Utxo{}
-	addr, err := w.NextChainedAddress(&wallet.BlockStamp{}, 100)
-	if err != nil {
-		t.Errorf("Cannot get next address: %s", err)
-		return
-	}
-	copy(utxo.AddrHash[:], addr.ScriptAddress())
-	ophash := (btcwire.ShaHash)([...]byte{1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11,
-		12, 13, 14, 15, 16, 17, 18, 19, 20, 21, 22, 23, 24, 25, 26, 27,
-		28, 29, 30, 31, 32})
-	out := btcwire.NewOutPoint(&ophash, 0)
-	utxo.Out = tx.OutPoint(*out)
-	ss, err := btcscript.PayToAddrScript(addr)
-	if err != nil {
-		t.Errorf("Could not create utxo PkScript: %s", err)
-		return
-	}
-	utxo.Subscript = tx.PkScript(ss)
-	utxo.Amt = 1000000
-	utxo.Height = 12345
-	a.UtxoStore = append(a.UtxoStore, utxo)
+func TestChangeIndex(t *testing.T) {
+	defer func() {
+		changeRandReader = rand.Reader
+	}()
 
-	// Fake our current block height so btcd doesn't need to be queried.
-	curBlock.BlockStamp.Height = 12346
+	const payAddr = "17XhEvq9Nahdj7Xe1nv6oRe1tEmaHUuynH"
+	const payAmt = 5000
+	for _, test := range changeIndexTests {
+		a := newTestAccount(t, 1000000)
+		changeRandReader = bytes.NewReader(bytes.Repeat(
+			[]byte{test.randByte}, 64))
 
-	// Create the transaction.
-	pairs := map[string]int64{
-		"17XhEvq9Nahdj7Xe1nv6oRe1tEmaHUuynH": 5000,
-	}
-	_, err = a.txToPairs(pairs, 1)
-	if err != nil {
-		t.Errorf("Tx creation failed: %s", err)
-		return
+		pairs := map[string]btcutil.Amount{payAddr: payAmt}
+		createdTx, err := a.txToPairs(pairs, 1, nil)
+		if err != nil {
+			t.Errorf("%s: Tx creation failed: %v", test.name, err)
+			continue
+		}
+		if createdTx.changeIndex != test.changeIndex {
+			t.Errorf("%s: change index is %d, expected %d",
+				test.name, createdTx.changeIndex,
+				test.changeIndex)
+			continue
+		}
+
+		txOuts := createdTx.tx.MsgTx().TxOut
+		if len(txOuts) != 2 {
+			t.Errorf("%s: transaction has %d outputs, expected 2",
+				test.name, len(txOuts))
+			continue
+		}
+		changeScript, err := btcscript.PayToAddrScript(createdTx.changeAddr)
+		if err != nil {
+			t.Errorf("%s: Cannot create change PkScript: %v",
+				test.name, err)
+			continue
+		}
+		change := txOuts[test.changeIndex]
+		if !bytes.Equal(change.PkScript, changeScript) {
+			t.Errorf("%s: output %d does not pay to the change "+
+				"address", test.name, test.changeIndex)
+		}
+		if payment := txOuts[1-test.changeIndex]; payment.Value != payAmt {
+			t.Errorf("%s: payment output has value %d, expected %d",
+				test.name, payment.Value, payAmt)
+		}
+
+		// The credit recorded for the change of a sent transaction
+		// must be the randomized change output.
+		r, err := txstore.New().InsertTx(createdTx.tx, nil)
+		if err != nil {
+			t.Errorf("%s: Cannot insert created transaction: %v",
+				test.name, err)
+			continue
+		}
+		c, err := r.AddCredit(uint32(createdTx.changeIndex), true)
+		if err != nil {
+			t.Errorf("%s: Cannot add change credit: %v", test.name,
+				err)
+			continue
+		}
+		if !c.Change() || c.Amount() != btcutil.Amount(change.Value) {
+			t.Errorf("%s: change credit does not match the change "+
+				"output", test.name)
+		}
 	}
 }
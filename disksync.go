@@ -332,6 +332,9 @@ func batchWriteAccounts(accts []*Account, tmpdir, netdir string) error {
 			return err
 		}
 	}
+	if err := moveOtherFiles(netdir, tmpdir); err != nil {
+		return err
+	}
 	// This is technically NOT an atomic operation, but at startup, if the
 	// network directory is missing but the temporary network directory
 	// exists, the temporary is moved before accounts are opened.
@@ -344,6 +347,36 @@ func batchWriteAccounts(accts []*Account, tmpdir, netdir string) error {
 	return nil
 }
 
+// moveOtherFiles moves every file in the network directory netdir which
+// was not rewritten to the temporary directory tmpdir, so files which are
+// not account files (such as the saved fee estimates) are kept when the
+// network directory is replaced.
+func moveOtherFiles(netdir, tmpdir string) error {
+	dir, err := os.Open(netdir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+	names, err := dir.Readdirnames(0)
+	dir.Close()
+	if err != nil {
+		return err
+	}
+
+	for _, name := range names {
+		if fileExists(filepath.Join(tmpdir, name)) {
+			continue
+		}
+		err := Rename(filepath.Join(netdir, name), filepath.Join(tmpdir, name))
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 func (a *Account) writeAll(dir string) error {
 	if err := a.writeTxStore(dir); err != nil {
 		return err
@@ -0,0 +1,457 @@
+/*
+ * Copyright (c) 2014 Conformal Systems LLC <info@conformal.com>
+ *
+ * Permission to use, copy, modify, and distribute this software for any
+ * purpose with or without fee is hereby granted, provided that the above
+ * copyright notice and this permission notice appear in all copies.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+ * WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+ * MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ * ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+ * WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ * ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+ * OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ */
+
+package main
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/conformal/btcjson"
+	"github.com/conformal/btcutil"
+	"github.com/conformal/btcwallet/wallet"
+	"github.com/conformal/btcwire"
+	"io"
+	"math"
+	"os"
+	"sort"
+	"sync"
+)
+
+const (
+	// feeEstimatorWindow is the number of most recently connected blocks
+	// used to estimate fee rates.
+	feeEstimatorWindow = 144
+
+	// minFeeEstimatorBlocks is the minimum number of blocks which must
+	// have been seen before any fee rate is estimated.
+	minFeeEstimatorBlocks = 6
+
+	// maxSampledTxs is the maximum number of transactions from each
+	// block for which fee rates are calculated.  Calculating the fee of
+	// a transaction requires fetching every previous transaction it
+	// spends, so only a sample of each block is used.
+	maxSampledTxs = 25
+
+	// blockFeeRatePercentile is the percentile of sampled transaction
+	// fee rates used as the fee rate needed to be included in a block.
+	blockFeeRatePercentile = 0.1
+
+	// feeEstimateConfidence is the desired probability of a transaction
+	// paying the estimated fee rate being mined within the requested
+	// number of blocks.
+	feeEstimateConfidence = 0.95
+
+	// minRelayFeeRate is the minimum fee rate used when fee rates are
+	// automatically chosen from estimates.  Transactions paying less are
+	// not relayed by btcd.
+	minRelayFeeRate FeeRate = 1000
+)
+
+// feeEstimatesFilename is the name of the file holding the fee estimator's
+// saved state in the network directory.
+const feeEstimatesFilename = "feeest.bin"
+
+// feeEstimatesVersion is the current version of the serialized fee
+// estimator state.
+const feeEstimatesVersion uint32 = 1
+
+// ErrNoFeeEstimate describes an error where not enough blocks have been
+// seen to estimate a fee rate.
+var ErrNoFeeEstimate = errors.New("not enough blocks to estimate fee rate")
+
+// FeeEst is the global fee estimator fed by all connected blocks.
+var FeeEst = NewFeeEstimator()
+
+// blockFeeRate records the fee rate needed for a transaction to be included
+// in a single block.
+type blockFeeRate struct {
+	height int32
+	hash   btcwire.ShaHash
+	rate   FeeRate
+}
+
+// FeeEstimator estimates the fee rate a transaction must pay to be mined
+// within some number of blocks.  For each block connected to the main
+// chain, a sample of its transactions are fetched from btcd and their fee
+// rates are calculated to find the rate needed to be included in the block.
+// Estimates are made from these rates over a sliding window of the most
+// recently connected blocks.
+type FeeEstimator struct {
+	sync.Mutex
+	blocks    []blockFeeRate // sorted by increasing height
+	connected chan wallet.BlockStamp
+}
+
+// NewFeeEstimator creates a new FeeEstimator with no seen blocks.
+func NewFeeEstimator() *FeeEstimator {
+	return &FeeEstimator{
+		connected: make(chan wallet.BlockStamp, feeEstimatorWindow),
+	}
+}
+
+// Start loads the previously saved fee estimator state from disk, if any,
+// and begins processing connected blocks.
+func (e *FeeEstimator) Start() {
+	if err := e.load(); err != nil && !os.IsNotExist(err) {
+		log.Warnf("Cannot read saved fee estimates: %v", err)
+	}
+	go e.blockHandler()
+}
+
+// BlockConnected queues a newly-connected block to be processed by the
+// estimator.  Blocks are processed in the background, and if too many are
+// waiting to be processed, bs is dropped.
+func (e *FeeEstimator) BlockConnected(bs *wallet.BlockStamp) {
+	select {
+	case e.connected <- *bs:
+	default:
+		log.Debugf("Fee estimator busy, skipping block %v", bs.Hash)
+	}
+}
+
+// Rollback removes the fee rates of all blocks at height onwards.
+func (e *FeeEstimator) Rollback(height int32) {
+	e.Lock()
+	i := len(e.blocks)
+	for i != 0 && e.blocks[i-1].height >= height {
+		i--
+	}
+	e.blocks = e.blocks[:i]
+	e.Unlock()
+}
+
+// EstimateFee returns the fee rate a transaction should pay to be mined
+// within nblocks blocks.  ErrNoFeeEstimate is returned if not enough blocks
+// have been seen.
+//
+// If the fee rate needed to be included in a block has been met for a
+// fraction p of recent blocks, the probability of a transaction paying
+// that rate being mined within nblocks is 1-(1-p)^nblocks.  The returned
+// rate is the lowest rate for which this probability reaches
+// feeEstimateConfidence.
+func (e *FeeEstimator) EstimateFee(nblocks int) (FeeRate, error) {
+	if nblocks < 1 {
+		nblocks = 1
+	}
+
+	e.Lock()
+	rates := make([]float64, 0, len(e.blocks))
+	for _, b := range e.blocks {
+		rates = append(rates, float64(b.rate))
+	}
+	e.Unlock()
+
+	if len(rates) < minFeeEstimatorBlocks {
+		return 0, ErrNoFeeEstimate
+	}
+	sort.Float64s(rates)
+
+	p := 1 - math.Pow(1-feeEstimateConfidence, 1/float64(nblocks))
+	i := int(math.Ceil(p*float64(len(rates)))) - 1
+	if i < 0 {
+		i = 0
+	}
+	return FeeRate(rates[i]), nil
+}
+
+// blockHandler calculates the fee rate needed to be included in each
+// connected block.  Fetching the sampled transactions from btcd is slow,
+// so this is done without holding the account manager's semaphore, and
+// each rate is queued as a wallet-internal notification to be added to the
+// estimator and saved by its handler.
+func (e *FeeEstimator) blockHandler() {
+	for bs := range e.connected {
+		rate, err := blockInclusionFeeRate(&bs.Hash)
+		if err != nil {
+			log.Warnf("Cannot estimate fee rates for block %v: %v",
+				bs.Hash, err)
+			continue
+		}
+		svrNtfns <- &blockFeeRateNtfn{
+			height: bs.Height,
+			hash:   bs.Hash,
+			rate:   rate,
+		}
+	}
+}
+
+// addBlock adds the fee rate needed to be included in a block, removing
+// any blocks which have been replaced by it and old blocks falling outside
+// the window.
+func (e *FeeEstimator) addBlock(b blockFeeRate) {
+	e.Lock()
+	i := len(e.blocks)
+	for i != 0 && e.blocks[i-1].height >= b.height {
+		i--
+	}
+	e.blocks = append(e.blocks[:i], b)
+	if len(e.blocks) > feeEstimatorWindow {
+		e.blocks = e.blocks[len(e.blocks)-feeEstimatorWindow:]
+	}
+	e.Unlock()
+}
+
+// blockFeeRateNtfnMethod is the method of the wallet-internal notification
+// queued after the fee rate of a connected block is calculated.
+const blockFeeRateNtfnMethod = "blockfeerate"
+
+// blockFeeRateNtfn is a wallet-internal notification which is queued with
+// btcd notifications so that the fee estimator state is only modified and
+// written to the network directory while the account manager's semaphore
+// is held.  It is never sent to or received from btcd.
+type blockFeeRateNtfn blockFeeRate
+
+// Enforce that blockFeeRateNtfn satisifies the btcjson.Cmd interface.
+var _ btcjson.Cmd = &blockFeeRateNtfn{}
+
+// Id satisifies the btcjson.Cmd interface by returning nil for a
+// notification ID.
+func (n *blockFeeRateNtfn) Id() interface{} {
+	return nil
+}
+
+// Method satisifies the btcjson.Cmd interface by returning the method of
+// the notification.
+func (n *blockFeeRateNtfn) Method() string {
+	return blockFeeRateNtfnMethod
+}
+
+// MarshalJSON returns the JSON encoding of n.  Required to satisify the
+// btcjson.Cmd interface.
+func (n *blockFeeRateNtfn) MarshalJSON() ([]byte, error) {
+	params := []interface{}{
+		n.height,
+		n.hash.String(),
+		int64(n.rate),
+	}
+	raw, err := btcjson.NewRawCmd(nil, n.Method(), params)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(raw)
+}
+
+// UnmarshalJSON unmarshals the JSON encoding of n into n.  Part of the
+// btcjson.Cmd interface.
+func (n *blockFeeRateNtfn) UnmarshalJSON(b []byte) error {
+	var r btcjson.RawCmd
+	if err := json.Unmarshal(b, &r); err != nil {
+		return err
+	}
+	if len(r.Params) != 3 {
+		return btcjson.ErrWrongNumberOfParams
+	}
+
+	var hashStr string
+	var rate int64
+	if err := json.Unmarshal(r.Params[0], &n.height); err != nil {
+		return err
+	}
+	if err := json.Unmarshal(r.Params[1], &hashStr); err != nil {
+		return err
+	}
+	if err := json.Unmarshal(r.Params[2], &rate); err != nil {
+		return err
+	}
+	hash, err := btcwire.NewShaHashFromStr(hashStr)
+	if err != nil {
+		return err
+	}
+	n.hash = *hash
+	n.rate = FeeRate(rate)
+	return nil
+}
+
+// NtfnBlockFeeRate handles the wallet-internal notification queued after
+// the fee rate of a connected block is calculated by adding it to the fee
+// estimator and saving the estimator state.
+func NtfnBlockFeeRate(n btcjson.Cmd) error {
+	fn, ok := n.(*blockFeeRateNtfn)
+	if !ok {
+		return fmt.Errorf("%v handler: unexpected type", n.Method())
+	}
+
+	FeeEst.addBlock(blockFeeRate(*fn))
+	if err := FeeEst.save(); err != nil {
+		log.Errorf("Cannot write fee estimates: %v", err)
+	}
+	return nil
+}
+
+// blockInclusionFeeRate fetches a block and a sample of its transactions
+// from btcd and returns the fee rate needed to be included in the block.
+func blockInclusionFeeRate(hash *btcwire.ShaHash) (FeeRate, error) {
+	rpc := CurrentServerConn()
+	block, jsonErr := GetBlock(rpc, hash.String())
+	if jsonErr != nil {
+		return 0, jsonErr
+	}
+
+	// The coinbase transaction pays no fee, so begin sampling with the
+	// second transaction, choosing sampled transactions evenly across
+	// the block.
+	txids := block.Tx
+	if len(txids) < 2 {
+		return 0, errors.New("block contains no fee-paying transactions")
+	}
+	txids = txids[1:]
+	step := 1
+	if len(txids) > maxSampledTxs {
+		step = len(txids) / maxSampledTxs
+	}
+
+	rates := make([]float64, 0, maxSampledTxs)
+	for i := 0; i < len(txids) && len(rates) < maxSampledTxs; i += step {
+		txSha, err := btcwire.NewShaHashFromStr(txids[i])
+		if err != nil {
+			return 0, err
+		}
+		rate, err := txFeeRate(rpc, txSha)
+		if err != nil {
+			return 0, err
+		}
+		rates = append(rates, float64(rate))
+	}
+	sort.Float64s(rates)
+
+	i := int(blockFeeRatePercentile * float64(len(rates)))
+	return FeeRate(rates[i]), nil
+}
+
+// txFeeRate fetches a transaction and every transaction it spends from
+// btcd and returns the fee rate paid by the transaction.
+func txFeeRate(rpc ServerConn, txSha *btcwire.ShaHash) (FeeRate, error) {
+	tx, jsonErr := GetRawTransaction(rpc, txSha)
+	if jsonErr != nil {
+		return 0, jsonErr
+	}
+	msgTx := tx.MsgTx()
+
+	// Request every previous transaction before waiting for any reply.
+	prevTxs := make([]chan RawRPCResponse, 0, len(msgTx.TxIn))
+	for _, txIn := range msgTx.TxIn {
+		op := &txIn.PreviousOutpoint
+		prevTxs = append(prevTxs, GetRawTransactionAsync(rpc, &op.Hash))
+	}
+
+	var fee btcutil.Amount
+	for i, txIn := range msgTx.TxIn {
+		prevTx, jsonErr := GetRawTransactionAsyncResult(prevTxs[i])
+		if jsonErr != nil {
+			return 0, jsonErr
+		}
+		prevOuts := prevTx.MsgTx().TxOut
+		index := txIn.PreviousOutpoint.Index
+		if int(index) >= len(prevOuts) {
+			return 0, fmt.Errorf("transaction %v spends missing "+
+				"output %v:%d", txSha, prevTx.Sha(), index)
+		}
+		fee += btcutil.Amount(prevOuts[index].Value)
+	}
+	for _, txOut := range msgTx.TxOut {
+		fee -= btcutil.Amount(txOut.Value)
+	}
+
+	size := msgTx.SerializeSize()
+	return FeeRate(fee * 1000 / btcutil.Amount(size)), nil
+}
+
+// load reads the estimator state saved to disk.
+func (e *FeeEstimator) load() error {
+	return loadNetworkFile(feeEstimatesFilename, func(r io.Reader) error {
+		blocks, err := readBlockFeeRates(r)
+		if err != nil {
+			return err
+		}
+
+		e.Lock()
+		e.blocks = blocks
+		e.Unlock()
+		return nil
+	})
+}
+
+// save writes the estimator state to disk, replacing any previously saved
+// state.  As the network directory may be replaced while accounts are
+// written, this must only be called while holding the account manager's
+// semaphore.
+func (e *FeeEstimator) save() error {
+	return saveNetworkFile(feeEstimatesFilename, func(w io.Writer) error {
+		e.Lock()
+		defer e.Unlock()
+		return writeBlockFeeRates(w, e.blocks)
+	})
+}
+
+// readBlockFeeRates deserializes block fee rates written by
+// writeBlockFeeRates.
+func readBlockFeeRates(r io.Reader) ([]blockFeeRate, error) {
+	var vers, n uint32
+	if err := binary.Read(r, binary.LittleEndian, &vers); err != nil {
+		return nil, err
+	}
+	if vers != feeEstimatesVersion {
+		return nil, fmt.Errorf("unknown fee estimates version %d", vers)
+	}
+	if err := binary.Read(r, binary.LittleEndian, &n); err != nil {
+		return nil, err
+	}
+	if n > feeEstimatorWindow {
+		return nil, errors.New("too many saved fee estimates")
+	}
+
+	blocks := make([]blockFeeRate, n)
+	for i := range blocks {
+		b := &blocks[i]
+		var rate int64
+		if err := binary.Read(r, binary.LittleEndian, &b.height); err != nil {
+			return nil, err
+		}
+		if _, err := io.ReadFull(r, b.hash[:]); err != nil {
+			return nil, err
+		}
+		if err := binary.Read(r, binary.LittleEndian, &rate); err != nil {
+			return nil, err
+		}
+		b.rate = FeeRate(rate)
+	}
+	return blocks, nil
+}
+
+// writeBlockFeeRates serializes blocks to w.
+func writeBlockFeeRates(w io.Writer, blocks []blockFeeRate) error {
+	if err := binary.Write(w, binary.LittleEndian, feeEstimatesVersion); err != nil {
+		return err
+	}
+	if err := binary.Write(w, binary.LittleEndian, uint32(len(blocks))); err != nil {
+		return err
+	}
+	for i := range blocks {
+		b := &blocks[i]
+		if err := binary.Write(w, binary.LittleEndian, b.height); err != nil {
+			return err
+		}
+		if _, err := w.Write(b.hash[:]); err != nil {
+			return err
+		}
+		if err := binary.Write(w, binary.LittleEndian, int64(b.rate)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,425 @@
+/*
+ * Copyright (c) 2014 Conformal Systems LLC <info@conformal.com>
+ *
+ * Permission to use, copy, modify, and distribute this software for any
+ * purpose with or without fee is hereby granted, provided that the above
+ * copyright notice and this permission notice appear in all copies.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+ * WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+ * MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ * ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+ * WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ * ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+ * OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ */
+
+package main
+
+import (
+	"crypto/rand"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"github.com/conformal/btcutil"
+	"github.com/conformal/btcwallet/txstore"
+	"github.com/conformal/btcwire"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+)
+
+// ErrHeldTxInputSpent describes an error where an input of a transaction
+// held by the wallet is no longer an unspent output of its account, so the
+// held transaction can never be sent.
+var ErrHeldTxInputSpent = errors.New("held transaction input is no longer unspent")
+
+// ErrHeldTxInputQueued describes an error where a transaction spends an
+// output reserved by a transaction waiting in one of the wallet's queues.
+// Such outputs are only released by sending or cancelling the queued
+// transaction.
+var ErrHeldTxInputQueued = errors.New("output is reserved by a queued " +
+	"transaction")
+
+// maxSavedAccountNameLen is the maximum length of an account name read
+// from a file saved in the network directory.
+const maxSavedAccountNameLen = 1024
+
+// reservedOutputsFileSuffix is the filename suffix of the file holding the
+// outputs of an account reserved by held transactions.
+const reservedOutputsFileSuffix = "reserved.bin"
+
+// reservedOutputsVersion is the current version of serialized reserved
+// outputs.
+const reservedOutputsVersion uint32 = 1
+
+// maxReservedOutputs is the maximum number of outputs read from a saved
+// reservation file.
+const maxReservedOutputs = 1000000
+
+// randomIDSize is the number of random bytes in the IDs used by clients to
+// refer to held transactions and scheduled payments.
+const randomIDSize = 16
+
+// maxRandomIDLen is the maximum length of a random ID read from a saved
+// file.
+const maxRandomIDLen = 2 * randomIDSize
+
+// newRandomID returns a new random hex encoded ID.
+func newRandomID() (string, error) {
+	var id [randomIDSize]byte
+	if _, err := rand.Read(id[:]); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(id[:]), nil
+}
+
+// heldTx is a signed transaction created by an account which the wallet
+// holds instead of sending immediately.  The inputs of a held transaction
+// remain reserved until it is either sent or released.
+//
+// Reserved outputs are kept apart from the outputs locked by lockunspent,
+// so unlocking outputs never releases the inputs of a held transaction.
+type heldTx struct {
+	account     string
+	tx          *btcutil.Tx
+	changeIndex int
+}
+
+// holdCreatedTx reserves the inputs of a newly created transaction so they
+// are not spent by any other transaction, and saves the reservations and
+// any change address so they are not lost.  The transaction is returned as
+// a heldTx so it may be created again for sending later.
+func (a *Account) holdCreatedTx(createdTx *CreatedTx) (*heldTx, error) {
+	ops := make([]btcwire.OutPoint, 0, len(createdTx.inputs))
+	for _, c := range createdTx.inputs {
+		ops = append(ops, *c.OutPoint())
+	}
+	if err := a.reserveOutputs(ops); err != nil {
+		return nil, fmt.Errorf("cannot write reserved outputs: %v", err)
+	}
+	if createdTx.changeAddr != nil {
+		AcctMgr.ds.ScheduleWalletWrite(a)
+		a.ReqNewTxsForAddress(createdTx.changeAddr)
+		if err := AcctMgr.ds.FlushAccount(a); err != nil {
+			return nil, fmt.Errorf("cannot write account: %v", err)
+		}
+	}
+
+	h := &heldTx{
+		account:     a.Name(),
+		tx:          createdTx.tx,
+		changeIndex: createdTx.changeIndex,
+	}
+	return h, nil
+}
+
+// heldCredits returns the account credits spent by each input of the held
+// transaction h.  ErrHeldTxInputSpent is returned if any input no longer
+// spends an unspent credit.
+func (a *Account) heldCredits(h *heldTx) ([]*txstore.Credit, error) {
+	unspent, err := a.TxStore.UnspentOutputs()
+	if err != nil {
+		return nil, err
+	}
+	byOutPoint := make(map[btcwire.OutPoint]*txstore.Credit, len(unspent))
+	for _, c := range unspent {
+		byOutPoint[*c.OutPoint()] = c
+	}
+
+	txIns := h.tx.MsgTx().TxIn
+	credits := make([]*txstore.Credit, 0, len(txIns))
+	for _, txIn := range txIns {
+		c, ok := byOutPoint[txIn.PreviousOutpoint]
+		if !ok {
+			return nil, ErrHeldTxInputSpent
+		}
+		credits = append(credits, c)
+	}
+	return credits, nil
+}
+
+// heldCreatedTx releases the inputs of the held transaction h and returns
+// it as a CreatedTx ready to be sent.  ErrHeldTxInputSpent is returned if
+// any input has already been spent, in which case the transaction can
+// never be sent and its remaining inputs are released as well.
+func (a *Account) heldCreatedTx(h *heldTx) (*CreatedTx, error) {
+	credits, err := a.heldCredits(h)
+	if err == ErrHeldTxInputSpent {
+		if err := a.releaseHeldTx(h); err != nil {
+			log.Errorf("Cannot release inputs of held transaction "+
+				"%v: %v", h.tx.Sha(), err)
+		}
+		return nil, ErrHeldTxInputSpent
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := a.releaseHeldTx(h); err != nil {
+		return nil, err
+	}
+
+	createdTx := &CreatedTx{
+		tx:          h.tx,
+		inputs:      credits,
+		changeIndex: h.changeIndex,
+	}
+	return createdTx, nil
+}
+
+// releaseHeldTx releases the reservation of every input of the held
+// transaction h, allowing them to be spent by other transactions.  The
+// remaining reservations are written to disk.
+func (a *Account) releaseHeldTx(h *heldTx) error {
+	txIns := h.tx.MsgTx().TxIn
+	ops := make([]btcwire.OutPoint, 0, len(txIns))
+	for _, txIn := range txIns {
+		ops = append(ops, txIn.PreviousOutpoint)
+	}
+	if err := a.unreserveOutputs(ops); err != nil {
+		return fmt.Errorf("cannot write reserved outputs: %v", err)
+	}
+	return nil
+}
+
+// spendsQueuedOutput returns whether any input of msgtx spends an output
+// reserved by a transaction waiting for its lock time, approval, or vault
+// delay.
+func spendsQueuedOutput(msgtx *btcwire.MsgTx) bool {
+	queued := make([]*heldTx, 0, len(LockTimeTxs.txs)+
+		len(PendingSends.sends)+len(VaultSends.sends))
+	queued = append(queued, LockTimeTxs.txs...)
+	for _, p := range PendingSends.sends {
+		queued = append(queued, p.tx)
+	}
+	for _, v := range VaultSends.sends {
+		queued = append(queued, v.tx)
+	}
+
+	ops := make(map[btcwire.OutPoint]struct{})
+	for _, h := range queued {
+		for _, txIn := range h.tx.MsgTx().TxIn {
+			ops[txIn.PreviousOutpoint] = struct{}{}
+		}
+	}
+	for _, txIn := range msgtx.TxIn {
+		if _, ok := ops[txIn.PreviousOutpoint]; ok {
+			return true
+		}
+	}
+	return false
+}
+
+// outputReserved returns whether the output referenced by op is reserved
+// by a transaction held by the account.
+func (a *Account) outputReserved(op *btcwire.OutPoint) bool {
+	_, ok := a.reserved[*op]
+	return ok
+}
+
+// reserveOutputs adds each outpoint of ops to the account's reserved
+// outputs and saves them.  If they cannot be saved, no output is reserved.
+func (a *Account) reserveOutputs(ops []btcwire.OutPoint) error {
+	reserved := make(map[btcwire.OutPoint]struct{}, len(a.reserved)+len(ops))
+	for op := range a.reserved {
+		reserved[op] = struct{}{}
+	}
+	for _, op := range ops {
+		reserved[op] = struct{}{}
+	}
+	return a.saveReservedOutputs(reserved)
+}
+
+// unreserveOutputs removes each outpoint of ops from the account's reserved
+// outputs and saves the remaining reservations.  If they cannot be saved,
+// no reservation is removed.
+func (a *Account) unreserveOutputs(ops []btcwire.OutPoint) error {
+	reserved := make(map[btcwire.OutPoint]struct{}, len(a.reserved))
+	for op := range a.reserved {
+		reserved[op] = struct{}{}
+	}
+	for _, op := range ops {
+		delete(reserved, op)
+	}
+	return a.saveReservedOutputs(reserved)
+}
+
+// saveReservedOutputs writes reserved to disk and, once written, replaces
+// the account's reserved outputs with it.
+func (a *Account) saveReservedOutputs(reserved map[btcwire.OutPoint]struct{}) error {
+	filename := accountFilename(reservedOutputsFileSuffix, a.name, "")
+	err := saveNetworkFile(filename, func(w io.Writer) error {
+		return writeReservedOutputs(w, reserved)
+	})
+	if err != nil {
+		return err
+	}
+	a.reserved = reserved
+	return nil
+}
+
+// readReservedOutputs reads the outputs reserved by transactions held by
+// the account named account.  If the account has no saved reservations,
+// nil is returned.
+func readReservedOutputs(account string) (map[btcwire.OutPoint]struct{}, error) {
+	var reserved map[btcwire.OutPoint]struct{}
+	filename := accountFilename(reservedOutputsFileSuffix, account, "")
+	err := loadNetworkFile(filename, func(r io.Reader) error {
+		var err error
+		reserved, err = readReservedOutputsFrom(r)
+		return err
+	})
+	switch {
+	case os.IsNotExist(err):
+		return nil, nil
+	case err != nil:
+		return nil, err
+	}
+	return reserved, nil
+}
+
+// writeReservedOutputs serializes the reserved outpoints to w.
+func writeReservedOutputs(w io.Writer, reserved map[btcwire.OutPoint]struct{}) error {
+	if err := binary.Write(w, binary.LittleEndian, reservedOutputsVersion); err != nil {
+		return err
+	}
+	if err := binary.Write(w, binary.LittleEndian, uint32(len(reserved))); err != nil {
+		return err
+	}
+	for op := range reserved {
+		if _, err := w.Write(op.Hash[:]); err != nil {
+			return err
+		}
+		if err := binary.Write(w, binary.LittleEndian, op.Index); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// readReservedOutputsFrom deserializes reserved outpoints written by
+// writeReservedOutputs.
+func readReservedOutputsFrom(r io.Reader) (map[btcwire.OutPoint]struct{}, error) {
+	var vers, n uint32
+	if err := binary.Read(r, binary.LittleEndian, &vers); err != nil {
+		return nil, err
+	}
+	if vers != reservedOutputsVersion {
+		return nil, fmt.Errorf("unknown reserved outputs version %d", vers)
+	}
+	if err := binary.Read(r, binary.LittleEndian, &n); err != nil {
+		return nil, err
+	}
+	if n > maxReservedOutputs {
+		return nil, fmt.Errorf("too many reserved outputs (%d)", n)
+	}
+	reserved := make(map[btcwire.OutPoint]struct{}, n)
+	for i := uint32(0); i < n; i++ {
+		var op btcwire.OutPoint
+		if _, err := io.ReadFull(r, op.Hash[:]); err != nil {
+			return nil, err
+		}
+		if err := binary.Read(r, binary.LittleEndian, &op.Index); err != nil {
+			return nil, err
+		}
+		reserved[op] = struct{}{}
+	}
+	return reserved, nil
+}
+
+// writeHeldTx serializes h to w.
+func writeHeldTx(w io.Writer, h *heldTx) error {
+	if err := writeString(w, h.account); err != nil {
+		return err
+	}
+	if err := binary.Write(w, binary.LittleEndian, int32(h.changeIndex)); err != nil {
+		return err
+	}
+	return h.tx.MsgTx().Serialize(w)
+}
+
+// readHeldTx deserializes a held transaction written by writeHeldTx.
+func readHeldTx(r io.Reader) (*heldTx, error) {
+	account, err := readString(r, maxSavedAccountNameLen)
+	if err != nil {
+		return nil, err
+	}
+	var changeIndex int32
+	if err := binary.Read(r, binary.LittleEndian, &changeIndex); err != nil {
+		return nil, err
+	}
+	msgtx := new(btcwire.MsgTx)
+	if err := msgtx.Deserialize(r); err != nil {
+		return nil, err
+	}
+
+	h := &heldTx{
+		account:     account,
+		tx:          btcutil.NewTx(msgtx),
+		changeIndex: int(changeIndex),
+	}
+	return h, nil
+}
+
+// writeString serializes a string to w, prefixed by its length.
+func writeString(w io.Writer, s string) error {
+	if err := binary.Write(w, binary.LittleEndian, uint32(len(s))); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, s)
+	return err
+}
+
+// readString deserializes a string written by writeString.  Strings longer
+// than max bytes are not read and an error is returned instead.
+func readString(r io.Reader, max uint32) (string, error) {
+	var n uint32
+	if err := binary.Read(r, binary.LittleEndian, &n); err != nil {
+		return "", err
+	}
+	if n > max {
+		return "", fmt.Errorf("string length %d exceeds maximum %d", n, max)
+	}
+	buf := make([]byte, n)
+	if _, err := io.ReadFull(r, buf); err != nil {
+		return "", err
+	}
+	return string(buf), nil
+}
+
+// saveNetworkFile atomically replaces the file named filename in the
+// network directory with the bytes written by write.
+func saveNetworkFile(filename string, write func(io.Writer) error) error {
+	dir := networkDir(cfg.Net())
+	if err := checkCreateDir(dir); err != nil {
+		return err
+	}
+	tmpfile, err := ioutil.TempFile(dir, filename)
+	if err != nil {
+		return err
+	}
+
+	err = write(tmpfile)
+	tmppath := tmpfile.Name()
+	tmpfile.Close()
+	if err != nil {
+		os.Remove(tmppath)
+		return err
+	}
+
+	return Rename(tmppath, filepath.Join(dir, filename))
+}
+
+// loadNetworkFile opens the file named filename in the network directory
+// and reads it with read.  If the file does not exist, an error for which
+// os.IsNotExist returns true is returned.
+func loadNetworkFile(filename string, read func(io.Reader) error) error {
+	f, err := os.Open(filepath.Join(networkDir(cfg.Net()), filename))
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	return read(f)
+}
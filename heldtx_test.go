@@ -0,0 +1,122 @@
+/*
+ * Copyright (c) 2014 Conformal Systems LLC <info@conformal.com>
+ *
+ * Permission to use, copy, modify, and distribute this software for any
+ * purpose with or without fee is hereby granted, provided that the above
+ * copyright notice and this permission notice appear in all copies.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+ * WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+ * MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ * ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+ * WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ * ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+ * OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ */
+
+package main
+
+import (
+	"bytes"
+	"github.com/conformal/btcutil"
+	"github.com/conformal/btcwallet/wallet"
+	"github.com/conformal/btcwire"
+	"reflect"
+	"testing"
+)
+
+func TestReservedOutputs(t *testing.T) {
+	a := newTestAccount(t, 1000000)
+	unspent, err := a.TxStore.UnspentOutputs()
+	if err != nil || len(unspent) != 1 {
+		t.Fatalf("Cannot get unspent outputs: %v", err)
+	}
+	op := *unspent[0].OutPoint()
+	a.reserved = map[btcwire.OutPoint]struct{}{op: struct{}{}}
+
+	// Unlocking every output, as lockunspent does, must not release
+	// outputs reserved by held transactions.
+	if err := a.TxStore.UnlockAllOutputs(); err != nil {
+		t.Fatalf("Cannot unlock outputs: %v", err)
+	}
+
+	eligible, err := a.eligibleCredits(unspent, 0)
+	if err != nil {
+		t.Fatalf("Cannot get eligible credits: %v", err)
+	}
+	if len(eligible) != 0 {
+		t.Errorf("Reserved output is eligible to be spent")
+	}
+	if _, err := a.unspentCredits([]btcwire.OutPoint{op}); err == nil {
+		t.Errorf("Reserved output was chosen to be spent")
+	} else if _, ok := err.(InvalidInputError); !ok {
+		t.Errorf("Unexpected error choosing reserved output: %v", err)
+	}
+	bal, err := a.sweepableBalance(0, &wallet.BlockStamp{Height: 12346})
+	if err != nil {
+		t.Fatalf("Cannot calculate sweepable balance: %v", err)
+	}
+	if bal != 0 {
+		t.Errorf("Sweepable balance is %v, expected 0", bal)
+	}
+
+	delete(a.reserved, op)
+	eligible, err = a.eligibleCredits(unspent, 0)
+	if err != nil {
+		t.Fatalf("Cannot get eligible credits: %v", err)
+	}
+	if len(eligible) != 1 {
+		t.Errorf("Released output is not eligible to be spent")
+	}
+}
+
+func TestSpendsQueuedOutput(t *testing.T) {
+	defer func(sends []*vaultSend) {
+		VaultSends.sends = sends
+	}(VaultSends.sends)
+
+	queuedOp := btcwire.NewOutPoint(&btcwire.ShaHash{1}, 0)
+	queued := btcwire.NewMsgTx()
+	queued.AddTxIn(btcwire.NewTxIn(queuedOp, nil))
+	VaultSends.sends = []*vaultSend{
+		{id: "vault", tx: &heldTx{tx: btcutil.NewTx(queued)}},
+	}
+
+	otherOp := btcwire.NewOutPoint(&btcwire.ShaHash{2}, 0)
+	other := btcwire.NewMsgTx()
+	other.AddTxIn(btcwire.NewTxIn(otherOp, nil))
+	if spendsQueuedOutput(other) {
+		t.Errorf("Transaction spending no queued output was rejected")
+	}
+	other.AddTxIn(btcwire.NewTxIn(queuedOp, nil))
+	if !spendsQueuedOutput(other) {
+		t.Errorf("Transaction spending a queued output was allowed")
+	}
+}
+
+func TestReservedOutputsSerialization(t *testing.T) {
+	reserved := map[btcwire.OutPoint]struct{}{
+		*btcwire.NewOutPoint(&btcwire.ShaHash{1}, 0): struct{}{},
+		*btcwire.NewOutPoint(&btcwire.ShaHash{1}, 1): struct{}{},
+		*btcwire.NewOutPoint(&btcwire.ShaHash{2}, 7): struct{}{},
+	}
+	var buf bytes.Buffer
+	if err := writeReservedOutputs(&buf, reserved); err != nil {
+		t.Fatalf("Cannot write reserved outputs: %v", err)
+	}
+	serialized := buf.Bytes()
+
+	read, err := readReservedOutputsFrom(bytes.NewReader(serialized))
+	if err != nil {
+		t.Fatalf("Cannot read reserved outputs: %v", err)
+	}
+	if !reflect.DeepEqual(read, reserved) {
+		t.Errorf("Read reserved outputs %v, expected %v", read,
+			reserved)
+	}
+
+	truncated := bytes.NewReader(serialized[:len(serialized)-1])
+	if _, err := readReservedOutputsFrom(truncated); err == nil {
+		t.Errorf("Truncated reserved outputs were read")
+	}
+}
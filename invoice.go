@@ -0,0 +1,546 @@
+/*
+ * Copyright (c) 2014 Conformal Systems LLC <info@conformal.com>
+ *
+ * Permission to use, copy, modify, and distribute this software for any
+ * purpose with or without fee is hereby granted, provided that the above
+ * copyright notice and this permission notice appear in all copies.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+ * WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+ * MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ * ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+ * WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ * ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+ * OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ */
+
+package main
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/conformal/btcjson"
+	"github.com/conformal/btcscript"
+	"github.com/conformal/btcutil"
+	"github.com/conformal/btcwallet/wallet"
+	"github.com/conformal/btcwire"
+	"io"
+	"os"
+	"time"
+)
+
+// ErrInvoiceMemoTooLong describes an error where an invoice is created
+// with a memo longer than maxInvoiceMemoLen bytes.
+var ErrInvoiceMemoTooLong = errors.New("invoice memo is too long")
+
+// invoicesFilename is the name of the file in the network directory
+// holding all invoices.
+const invoicesFilename = "invoices.bin"
+
+// invoicesVersion is the current version of the serialized invoices.
+const invoicesVersion uint32 = 1
+
+// Limits on the size of invoices.  Saved invoices exceeding these limits
+// are not read.
+const (
+	maxInvoiceMemoLen    = 4096
+	maxInvoiceAddressLen = 128
+	maxInvoices          = 1000000
+	maxInvoicePayments   = 100000
+)
+
+// invoiceConfirmations is the number of confirmations payments require
+// before they confirm an invoice.
+const invoiceConfirmations = 1
+
+// invoiceStatusNtfnMethod is the method of the websocket notification sent
+// to clients when the state of an invoice changes.
+const invoiceStatusNtfnMethod = "invoicestatus"
+
+// InvoiceState describes how far an invoice has been paid.
+type InvoiceState uint8
+
+// The possible states of an invoice.  Invoices begin unpaid, and are
+// partially paid once any payment is received, paid once the payments
+// (including unconfirmed payments) total the invoice amount, and confirmed
+// once payments with at least invoiceConfirmations confirmations total the
+// invoice amount.  Invoices not paid before their expiry time expire, and
+// remain expired even if paid later.
+const (
+	InvoiceUnpaid InvoiceState = iota
+	InvoicePartial
+	InvoicePaid
+	InvoiceConfirmed
+	InvoiceExpired
+)
+
+var invoiceStateStrings = [...]string{
+	InvoiceUnpaid:    "unpaid",
+	InvoicePartial:   "partial",
+	InvoicePaid:      "paid",
+	InvoiceConfirmed: "confirmed",
+	InvoiceExpired:   "expired",
+}
+
+// String returns the InvoiceState as a human-readable string.
+func (s InvoiceState) String() string {
+	if int(s) < len(invoiceStateStrings) {
+		return invoiceStateStrings[s]
+	}
+	return fmt.Sprintf("unknown invoice state (%d)", uint8(s))
+}
+
+// invoicePayment is an output paying to the address of an invoice.
+type invoicePayment struct {
+	amount btcutil.Amount
+	height int32
+}
+
+// Invoice is a request for an amount to be paid to a new address of an
+// account.  Each invoice is identified by its address.
+type Invoice struct {
+	Address  string
+	Account  string
+	Amount   btcutil.Amount
+	Memo     string
+	Created  time.Time
+	Expires  time.Time // zero if the invoice never expires
+	State    InvoiceState
+	payments map[btcwire.OutPoint]*invoicePayment
+}
+
+// received returns the total amount of all payments to the invoice with at
+// least minconf confirmations in the chain with best height curHeight.
+func (inv *Invoice) received(curHeight int32, minconf int32) btcutil.Amount {
+	var total btcutil.Amount
+	for _, p := range inv.payments {
+		if chainDepth(p.height, curHeight) >= minconf {
+			total += p.amount
+		}
+	}
+	return total
+}
+
+// update sets the state of the invoice from its payments in the chain with
+// best height curHeight, expiring the invoice if it is not fully paid by
+// now.  Expired invoices never change state.  Returns whether the state
+// changed.
+func (inv *Invoice) update(curHeight int32, now time.Time) bool {
+	if inv.State == InvoiceExpired {
+		return false
+	}
+
+	total := inv.received(curHeight, 0)
+	var state InvoiceState
+	switch {
+	case inv.received(curHeight, invoiceConfirmations) >= inv.Amount:
+		state = InvoiceConfirmed
+	case total >= inv.Amount:
+		state = InvoicePaid
+	case !inv.Expires.IsZero() && !now.Before(inv.Expires):
+		state = InvoiceExpired
+	case total > 0:
+		state = InvoicePartial
+	default:
+		state = InvoiceUnpaid
+	}
+
+	if state == inv.State {
+		return false
+	}
+	inv.State = state
+	return true
+}
+
+// InvoiceResult models the data returned for an invoice by a createinvoice
+// request and its invoicestatus notifications.  Amounts are in bitcoins
+// and times are Unix timestamps.  Expires is omitted if the invoice never
+// expires.
+type InvoiceResult struct {
+	Address  string  `json:"address"`
+	Account  string  `json:"account"`
+	Amount   float64 `json:"amount"`
+	Received float64 `json:"received"`
+	Memo     string  `json:"memo"`
+	Created  int64   `json:"created"`
+	Expires  int64   `json:"expires,omitempty"`
+	State    string  `json:"state"`
+}
+
+// result returns the JSON result of an invoice in a chain with best height
+// curHeight.
+func (inv *Invoice) result(curHeight int32) *InvoiceResult {
+	r := &InvoiceResult{
+		Address:  inv.Address,
+		Account:  inv.Account,
+		Amount:   inv.Amount.ToUnit(btcutil.AmountBTC),
+		Received: inv.received(curHeight, 0).ToUnit(btcutil.AmountBTC),
+		Memo:     inv.Memo,
+		Created:  inv.Created.Unix(),
+		State:    inv.State.String(),
+	}
+	if !inv.Expires.IsZero() {
+		r.Expires = inv.Expires.Unix()
+	}
+	return r
+}
+
+// Invoices holds every invoice created by the wallet.
+var Invoices = &InvoiceBook{}
+
+// InvoiceBook is a persistent collection of invoices, keyed by address.
+// Received transactions and connected blocks are matched against the
+// invoices, and websocket clients are notified of every invoice state
+// change.
+//
+// As the invoices are only modified by RPC and notification handlers,
+// they are protected by the account manager's semaphore rather than a
+// mutex.
+type InvoiceBook struct {
+	invoices map[string]*Invoice
+}
+
+// Start loads the previously saved invoices from disk, if any.  This must
+// be called before any requests or notifications are handled.
+func (b *InvoiceBook) Start() {
+	b.invoices = make(map[string]*Invoice)
+	err := loadNetworkFile(invoicesFilename, b.read)
+	if err != nil && !os.IsNotExist(err) {
+		log.Warnf("Cannot read invoices: %v", err)
+	}
+}
+
+// Add creates and saves an invoice for amount to be paid to a new address
+// of account a.  If expiry is non-zero, the invoice expires if it is not
+// paid within expiry.
+func (b *InvoiceBook) Add(a *Account, amount btcutil.Amount,
+	expiry time.Duration, memo string) (*Invoice, error) {
+
+	if len(memo) > maxInvoiceMemoLen {
+		return nil, ErrInvoiceMemoTooLong
+	}
+
+	addr, err := a.NewAddress()
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	inv := &Invoice{
+		Address:  addr.EncodeAddress(),
+		Account:  a.Name(),
+		Amount:   amount,
+		Memo:     memo,
+		Created:  now,
+		State:    InvoiceUnpaid,
+		payments: make(map[btcwire.OutPoint]*invoicePayment),
+	}
+	if expiry != 0 {
+		inv.Expires = now.Add(expiry)
+	}
+	b.invoices[inv.Address] = inv
+	if err := b.save(); err != nil {
+		return nil, err
+	}
+
+	log.Infof("Created invoice for %v to address %s", amount, inv.Address)
+	return inv, nil
+}
+
+// RecordTx records each output of tx, mined in a block at height (or -1
+// if unmined), paying to the address of an invoice.  curHeight is the
+// height of the current best block.
+func (b *InvoiceBook) RecordTx(tx *btcutil.Tx, height, curHeight int32) {
+	if len(b.invoices) == 0 {
+		return
+	}
+
+	now := time.Now()
+	modified := false
+	for i, txout := range tx.MsgTx().TxOut {
+		_, addrs, _, _ := btcscript.ExtractPkScriptAddrs(txout.PkScript,
+			cfg.Net())
+		for _, addr := range addrs {
+			inv, ok := b.invoices[addr.EncodeAddress()]
+			if !ok {
+				continue
+			}
+
+			// Expire the invoice first so payments arriving after
+			// the expiry time do not pay the invoice.
+			if inv.update(curHeight, now) {
+				b.notify(inv, curHeight)
+			}
+
+			op := btcwire.NewOutPoint(tx.Sha(), uint32(i))
+			inv.payments[*op] = &invoicePayment{
+				amount: btcutil.Amount(txout.Value),
+				height: height,
+			}
+			modified = true
+			if inv.update(curHeight, now) {
+				b.notify(inv, curHeight)
+			}
+		}
+	}
+
+	if modified {
+		if err := b.save(); err != nil {
+			log.Errorf("Cannot write invoices: %v", err)
+		}
+	}
+}
+
+// BlockConnected updates the state of every invoice after the block bs is
+// connected to the main chain, confirming or expiring invoices as needed.
+func (b *InvoiceBook) BlockConnected(bs *wallet.BlockStamp) {
+	b.updateAll(bs.Height)
+}
+
+// Rollback marks all invoice payments mined in blocks at or above height as
+// unmined after those blocks are disconnected from the main chain.
+func (b *InvoiceBook) Rollback(height int32) {
+	for _, inv := range b.invoices {
+		for _, p := range inv.payments {
+			if p.height >= height {
+				p.height = -1
+			}
+		}
+	}
+	b.updateAll(height - 1)
+}
+
+// updateAll updates the state of every invoice for a chain with best
+// height curHeight, and saves the invoices if any changed.
+func (b *InvoiceBook) updateAll(curHeight int32) {
+	now := time.Now()
+	modified := false
+	for _, inv := range b.invoices {
+		if inv.update(curHeight, now) {
+			b.notify(inv, curHeight)
+			modified = true
+		}
+	}
+
+	if modified {
+		if err := b.save(); err != nil {
+			log.Errorf("Cannot write invoices: %v", err)
+		}
+	}
+}
+
+// notify logs the new state of an invoice and sends it to all websocket
+// clients.
+func (b *InvoiceBook) notify(inv *Invoice, curHeight int32) {
+	log.Infof("Invoice for address %s is %v", inv.Address, inv.State)
+
+	ntfn := &invoiceStatusNtfn{invoice: inv.result(curHeight)}
+	mntfn, err := ntfn.MarshalJSON()
+	if err != nil {
+		log.Errorf("Cannot marshal invoice notification: %v", err)
+		return
+	}
+	allClients <- mntfn
+}
+
+// save writes the invoices to disk, replacing any previously saved
+// invoices.
+func (b *InvoiceBook) save() error {
+	return saveNetworkFile(invoicesFilename, b.write)
+}
+
+// write serializes the invoices to w.
+func (b *InvoiceBook) write(w io.Writer) error {
+	if err := binary.Write(w, binary.LittleEndian, invoicesVersion); err != nil {
+		return err
+	}
+	if err := binary.Write(w, binary.LittleEndian, uint32(len(b.invoices))); err != nil {
+		return err
+	}
+	for _, inv := range b.invoices {
+		if err := writeInvoice(w, inv); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// read deserializes invoices written by write, replacing all invoices.
+func (b *InvoiceBook) read(r io.Reader) error {
+	var vers, n uint32
+	if err := binary.Read(r, binary.LittleEndian, &vers); err != nil {
+		return err
+	}
+	if vers != invoicesVersion {
+		return fmt.Errorf("unknown invoices version %d", vers)
+	}
+	if err := binary.Read(r, binary.LittleEndian, &n); err != nil {
+		return err
+	}
+	if n > maxInvoices {
+		return errors.New("too many saved invoices")
+	}
+
+	invoices := make(map[string]*Invoice, n)
+	for i := uint32(0); i < n; i++ {
+		inv, err := readInvoice(r)
+		if err != nil {
+			return err
+		}
+		invoices[inv.Address] = inv
+	}
+	b.invoices = invoices
+	return nil
+}
+
+// invoiceHeader is the fixed size part of a serialized invoice.
+type invoiceHeader struct {
+	Amount   int64
+	Created  int64
+	Expires  int64
+	State    uint8
+	Payments uint32
+}
+
+// invoicePaymentRecord is a serialized invoice payment.
+type invoicePaymentRecord struct {
+	Hash   btcwire.ShaHash
+	Index  uint32
+	Amount int64
+	Height int32
+}
+
+// writeInvoice serializes inv to w.
+func writeInvoice(w io.Writer, inv *Invoice) error {
+	for _, s := range []string{inv.Address, inv.Account, inv.Memo} {
+		if err := writeString(w, s); err != nil {
+			return err
+		}
+	}
+
+	hdr := invoiceHeader{
+		Amount:   int64(inv.Amount),
+		Created:  inv.Created.Unix(),
+		State:    uint8(inv.State),
+		Payments: uint32(len(inv.payments)),
+	}
+	if !inv.Expires.IsZero() {
+		hdr.Expires = inv.Expires.Unix()
+	}
+	if err := binary.Write(w, binary.LittleEndian, &hdr); err != nil {
+		return err
+	}
+
+	for op, p := range inv.payments {
+		rec := invoicePaymentRecord{
+			Hash:   op.Hash,
+			Index:  op.Index,
+			Amount: int64(p.amount),
+			Height: p.height,
+		}
+		if err := binary.Write(w, binary.LittleEndian, &rec); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// readInvoice deserializes an invoice written by writeInvoice.
+func readInvoice(r io.Reader) (*Invoice, error) {
+	address, err := readString(r, maxInvoiceAddressLen)
+	if err != nil {
+		return nil, err
+	}
+	account, err := readString(r, maxSavedAccountNameLen)
+	if err != nil {
+		return nil, err
+	}
+	memo, err := readString(r, maxInvoiceMemoLen)
+	if err != nil {
+		return nil, err
+	}
+
+	var hdr invoiceHeader
+	if err := binary.Read(r, binary.LittleEndian, &hdr); err != nil {
+		return nil, err
+	}
+	if hdr.Payments > maxInvoicePayments {
+		return nil, errors.New("too many saved invoice payments")
+	}
+
+	inv := &Invoice{
+		Address:  address,
+		Account:  account,
+		Amount:   btcutil.Amount(hdr.Amount),
+		Memo:     memo,
+		Created:  time.Unix(hdr.Created, 0),
+		State:    InvoiceState(hdr.State),
+		payments: make(map[btcwire.OutPoint]*invoicePayment, hdr.Payments),
+	}
+	if hdr.Expires != 0 {
+		inv.Expires = time.Unix(hdr.Expires, 0)
+	}
+	for i := uint32(0); i < hdr.Payments; i++ {
+		var rec invoicePaymentRecord
+		if err := binary.Read(r, binary.LittleEndian, &rec); err != nil {
+			return nil, err
+		}
+		op := btcwire.OutPoint{Hash: rec.Hash, Index: rec.Index}
+		inv.payments[op] = &invoicePayment{
+			amount: btcutil.Amount(rec.Amount),
+			height: rec.Height,
+		}
+	}
+	return inv, nil
+}
+
+// invoiceStatusNtfn is a websocket notification sent to clients each time
+// the state of an invoice changes.
+type invoiceStatusNtfn struct {
+	invoice *InvoiceResult
+}
+
+// Enforce that invoiceStatusNtfn satisifies the btcjson.Cmd interface.
+var _ btcjson.Cmd = &invoiceStatusNtfn{}
+
+// Id satisifies the btcjson.Cmd interface by returning nil for a
+// notification ID.
+func (n *invoiceStatusNtfn) Id() interface{} {
+	return nil
+}
+
+// Method satisifies the btcjson.Cmd interface by returning the method of
+// the notification.
+func (n *invoiceStatusNtfn) Method() string {
+	return invoiceStatusNtfnMethod
+}
+
+// MarshalJSON returns the JSON encoding of n.  Required to satisify the
+// btcjson.Cmd interface.
+func (n *invoiceStatusNtfn) MarshalJSON() ([]byte, error) {
+	raw, err := btcjson.NewRawCmd(nil, n.Method(), []interface{}{n.invoice})
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(raw)
+}
+
+// UnmarshalJSON unmarshals the JSON encoding of n into n.  Part of the
+// btcjson.Cmd interface.
+func (n *invoiceStatusNtfn) UnmarshalJSON(b []byte) error {
+	var r btcjson.RawCmd
+	if err := json.Unmarshal(b, &r); err != nil {
+		return err
+	}
+	if len(r.Params) != 1 {
+		return btcjson.ErrWrongNumberOfParams
+	}
+	var invoice InvoiceResult
+	if err := json.Unmarshal(r.Params[0], &invoice); err != nil {
+		return err
+	}
+	n.invoice = &invoice
+	return nil
+}
@@ -0,0 +1,208 @@
+/*
+ * Copyright (c) 2014 Conformal Systems LLC <info@conformal.com>
+ *
+ * Permission to use, copy, modify, and distribute this software for any
+ * purpose with or without fee is hereby granted, provided that the above
+ * copyright notice and this permission notice appear in all copies.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+ * WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+ * MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ * ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+ * WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ * ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+ * OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ */
+
+package main
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"github.com/conformal/btcwallet/wallet"
+	"io"
+	"os"
+	"time"
+)
+
+// lockTimeThreshold is the value below which a transaction lock time is
+// interpreted as a block height.  Larger lock times are Unix timestamps.
+const lockTimeThreshold = 500000000
+
+// lockTimeTxsFilename is the name of the file in the network directory
+// holding transactions waiting for their lock times to pass.
+const lockTimeTxsFilename = "locktimetxs.bin"
+
+// lockTimeTxsVersion is the current version of the serialized lock time
+// queue.
+const lockTimeTxsVersion uint32 = 1
+
+// maxLockTimeTxs is the maximum number of transactions read from a saved
+// lock time queue.
+const maxLockTimeTxs = 100000
+
+// lockTimePassed returns whether a transaction with lock time lockTime may
+// be included in the block after the block at height, if that block is
+// mined at time now.
+func lockTimePassed(lockTime uint32, height int32, now time.Time) bool {
+	if lockTime < lockTimeThreshold {
+		return int64(lockTime) <= int64(height)
+	}
+	return int64(lockTime) < now.Unix()
+}
+
+// LockTimeTxs holds all transactions waiting for their lock times to pass.
+var LockTimeTxs = &LockTimeQueue{}
+
+// LockTimeQueue is a persistent queue of signed transactions which cannot
+// be mined until their lock time passes.  The inputs of each queued
+// transaction are reserved, and each transaction is sent once a newly
+// connected block shows that its lock time has passed.
+//
+// As the queue is only modified by RPC and notification handlers, it is
+// protected by the account manager's semaphore rather than a mutex.
+type LockTimeQueue struct {
+	txs []*heldTx
+}
+
+// Start loads the previously saved queue from disk, if any.  This must be
+// called before any requests or notifications are handled.
+func (q *LockTimeQueue) Start() {
+	err := loadNetworkFile(lockTimeTxsFilename, q.read)
+	if err != nil && !os.IsNotExist(err) {
+		log.Warnf("Cannot read transactions waiting for lock "+
+			"times: %v", err)
+	}
+}
+
+// Add holds a signed transaction created by account a until its lock time
+// passes.  The transaction's inputs are reserved and the queue is saved.
+func (q *LockTimeQueue) Add(a *Account, createdTx *CreatedTx) error {
+	h, err := a.holdCreatedTx(createdTx)
+	if err != nil {
+		return err
+	}
+	q.txs = append(q.txs, h)
+	if err := q.save(); err != nil {
+		return err
+	}
+
+	log.Infof("Holding transaction %v until lock time %d",
+		h.tx.Sha(), h.tx.MsgTx().LockTime)
+	return nil
+}
+
+// BlockConnected sends each queued transaction which may be included in
+// the block after bs.  Transactions which fail to send remain queued to be
+// retried with the next block, unless their inputs have been spent by
+// another transaction, in which case they are removed.
+func (q *LockTimeQueue) BlockConnected(bs *wallet.BlockStamp) {
+	now := time.Now()
+	remaining := q.txs[:0]
+	modified := false
+	for _, h := range q.txs {
+		if !lockTimePassed(h.tx.MsgTx().LockTime, bs.Height, now) {
+			remaining = append(remaining, h)
+			continue
+		}
+
+		err := sendHeldTx(h)
+		switch err {
+		case nil:
+			modified = true
+
+		case ErrHeldTxInputSpent, ErrNotFound:
+			log.Errorf("Dropping transaction %v waiting for lock "+
+				"time: %v", h.tx.Sha(), err)
+			modified = true
+
+		default:
+			log.Warnf("Cannot send transaction %v after lock "+
+				"time: %v", h.tx.Sha(), err)
+			remaining = append(remaining, h)
+		}
+	}
+	q.txs = remaining
+
+	if modified {
+		if err := q.save(); err != nil {
+			log.Errorf("Cannot write transactions waiting for "+
+				"lock times: %v", err)
+		}
+	}
+}
+
+// sendHeldTx releases the inputs of a held transaction and sends it, or
+// holds it until its lock time passes.  ErrNotFound is returned if the
+// account which created the transaction no longer exists.
+func sendHeldTx(h *heldTx) error {
+	a, err := AcctMgr.Account(h.account)
+	if err != nil {
+		return err
+	}
+	createdTx, err := a.heldCreatedTx(h)
+	if err != nil {
+		return err
+	}
+	if _, jsonErr := sendOrHoldCreatedTx(nil, a, createdTx); jsonErr != nil {
+		// Reserve the inputs again so they are not spent while the
+		// transaction is still held.
+		if _, err := a.holdCreatedTx(createdTx); err != nil {
+			log.Errorf("Cannot reserve held transaction inputs: %v",
+				err)
+		}
+		return errors.New(jsonErr.Message)
+	}
+	return nil
+}
+
+// save writes the queue to disk, replacing any previously saved queue.
+func (q *LockTimeQueue) save() error {
+	return saveNetworkFile(lockTimeTxsFilename, q.write)
+}
+
+// write serializes the queue to w.
+func (q *LockTimeQueue) write(w io.Writer) error {
+	if err := binary.Write(w, binary.LittleEndian, lockTimeTxsVersion); err != nil {
+		return err
+	}
+	if err := binary.Write(w, binary.LittleEndian, uint32(len(q.txs))); err != nil {
+		return err
+	}
+	for _, h := range q.txs {
+		if err := writeHeldTx(w, h); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// read deserializes a queue written by write, replacing all queued
+// transactions.
+func (q *LockTimeQueue) read(r io.Reader) error {
+	var vers, n uint32
+	if err := binary.Read(r, binary.LittleEndian, &vers); err != nil {
+		return err
+	}
+	if vers != lockTimeTxsVersion {
+		return fmt.Errorf("unknown lock time queue version %d", vers)
+	}
+	if err := binary.Read(r, binary.LittleEndian, &n); err != nil {
+		return err
+	}
+	if n > maxLockTimeTxs {
+		return errors.New("too many saved lock time transactions")
+	}
+
+	txs := make([]*heldTx, 0, n)
+	for i := uint32(0); i < n; i++ {
+		h, err := readHeldTx(r)
+		if err != nil {
+			return err
+		}
+		txs = append(txs, h)
+	}
+	q.txs = txs
+	return nil
+}
@@ -57,6 +57,8 @@ var notificationHandlers = map[string]notificationHandler{
 	btcws.RecvTxNtfnMethod:            NtfnRecvTx,
 	btcws.RedeemingTxNtfnMethod:       NtfnRedeemingTx,
 	btcws.RescanProgressNtfnMethod:    NtfnRescanProgress,
+	blockFeeRateNtfnMethod:            NtfnBlockFeeRate,
+	sweepFinishedNtfnMethod:           NtfnSweepFinished,
 }
 
 // NtfnRecvTx handles the btcws.RecvTxNtfn notification.
@@ -104,6 +106,14 @@ func NtfnRecvTx(n btcjson.Cmd) error {
 		SendTxHistSyncChans.remove <- *tx.Sha()
 	}
 
+	// Record outputs paying to any private key currently being swept.
+	height := int32(-1)
+	if block != nil {
+		height = block.Height
+	}
+	recordSweptOutputs(tx, height)
+	Invoices.RecordTx(tx, height, bs.Height)
+
 	// For every output, find all accounts handling that output address (if any)
 	// and record the received txout.
 	for outIdx, txout := range tx.MsgTx().TxOut {
@@ -203,6 +213,11 @@ func NtfnBlockConnected(n btcjson.Cmd) error {
 		NotifyBalanceSyncerChans.remove <- *hash
 	}
 	AcctMgr.BlockNotify(bs)
+	FeeEst.BlockConnected(bs)
+	LockTimeTxs.BlockConnected(bs)
+	VaultSends.BlockConnected(bs)
+	ScheduledPayments.BlockConnected(bs)
+	Invoices.BlockConnected(bs)
 
 	// Pass notification to frontends too.
 	marshaled, _ := n.MarshalJSON()
@@ -226,6 +241,8 @@ func NtfnBlockDisconnected(n btcjson.Cmd) error {
 
 	// Rollback Utxo and Tx data stores.
 	AcctMgr.Rollback(bdn.Height, hash)
+	FeeEst.Rollback(bdn.Height)
+	Invoices.Rollback(bdn.Height)
 
 	// Pass notification to frontends too.
 	marshaled, _ := n.MarshalJSON()
@@ -256,6 +273,7 @@ func NtfnRedeemingTx(n btcjson.Cmd) error {
 		return fmt.Errorf("%v handler: bad block: %v", n.Method(), err)
 	}
 	tx.SetIndex(txIdx)
+	removeSweptOutputs(tx)
 	return AcctMgr.RecordSpendingTx(tx, block)
 }
 
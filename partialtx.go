@@ -0,0 +1,543 @@
+/*
+ * Copyright (c) 2014 Conformal Systems LLC <info@conformal.com>
+ *
+ * Permission to use, copy, modify, and distribute this software for any
+ * purpose with or without fee is hereby granted, provided that the above
+ * copyright notice and this permission notice appear in all copies.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+ * WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+ * MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ * ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+ * WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ * ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+ * OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ */
+
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"github.com/conformal/btcec"
+	"github.com/conformal/btcscript"
+	"github.com/conformal/btcutil"
+	"github.com/conformal/btcwallet/wallet"
+	"github.com/conformal/btcwire"
+	"io"
+	"sort"
+)
+
+// partialTxMagic begins every serialized partially signed transaction.
+var partialTxMagic = [4]byte{'p', 't', 'x', 0}
+
+// partialTxVersion is the current version of serialized partially signed
+// transactions.
+const partialTxVersion uint32 = 1
+
+// Limits used when reading a serialized partially signed transaction.
+const (
+	maxPartialTxScriptLen = 10000
+	maxPartialTxSigs      = 20
+	maxPartialTxPubKeyLen = 65
+	maxPartialTxSigLen    = 73
+)
+
+// Errors returned when combining and finalizing partially signed
+// transactions.
+var (
+	ErrPartialTxMismatch = errors.New("partially signed transactions " +
+		"spend different inputs or create different outputs")
+	ErrPartialTxIncomplete = errors.New("partially signed transaction " +
+		"is missing signatures")
+	ErrPartialTxAccounts = errors.New("partially signed transaction " +
+		"spends outputs of more than one account")
+)
+
+// PartialTxInput holds everything needed to sign an input of a partially
+// signed transaction, and the signatures created so far.
+type PartialTxInput struct {
+	// PrevOut is the previous output spent by the input.
+	PrevOut *btcwire.TxOut
+
+	// RedeemScript is the script hashed by a pay-to-script-hash PrevOut,
+	// or nil if it is not yet known or PrevOut is not P2SH.
+	RedeemScript []byte
+
+	// Sigs maps each serialized public key which has signed the input to
+	// its signature, including the hash type byte.
+	Sigs map[string][]byte
+}
+
+// PartialTx is a self-describing transaction which may be signed by several
+// wallets in turn, or in parallel and then combined, before it is finalized
+// and sent.  Signatures are kept separate from the unsigned transaction
+// until the transaction is finalized, so inputs of any supported script
+// type may be signed in any order.  Every input is signed with
+// SigHashAll.
+type PartialTx struct {
+	Tx     *btcwire.MsgTx
+	Inputs []*PartialTxInput
+}
+
+// NewPartialTx creates a partially signed transaction with no signatures
+// for the unsigned transaction msgtx, where each input spends the previous
+// output at the same index of prevOuts.
+func NewPartialTx(msgtx *btcwire.MsgTx, prevOuts []*btcwire.TxOut) *PartialTx {
+	inputs := make([]*PartialTxInput, len(prevOuts))
+	for i, prevOut := range prevOuts {
+		inputs[i] = &PartialTxInput{
+			PrevOut: prevOut,
+			Sigs:    make(map[string][]byte),
+		}
+	}
+	return &PartialTx{Tx: msgtx, Inputs: inputs}
+}
+
+// signingScript returns the script signed by input i, which is either the
+// redeem script of a P2SH output or the previous output script, along with
+// its class, addresses, and number of required signatures.  If the redeem
+// script is unknown, it is looked up with getScript.
+func (p *PartialTx) signingScript(i int, getScript btcscript.ScriptClosure) ([]byte,
+	btcscript.ScriptClass, []btcutil.Address, int, error) {
+
+	in := p.Inputs[i]
+	script := in.PrevOut.PkScript
+	class, addrs, nRequired, err := btcscript.ExtractPkScriptAddrs(script,
+		cfg.Net())
+	if err != nil {
+		return nil, 0, nil, 0, err
+	}
+	if class != btcscript.ScriptHashTy {
+		return script, class, addrs, nRequired, nil
+	}
+
+	if in.RedeemScript == nil {
+		if getScript == nil {
+			return nil, 0, nil, 0, errors.New("unknown redeem script")
+		}
+		redeemScript, err := getScript(addrs[0])
+		if err != nil {
+			return nil, 0, nil, 0, err
+		}
+		scriptAddr, err := btcutil.NewAddressScriptHash(redeemScript,
+			cfg.Net())
+		if err != nil {
+			return nil, 0, nil, 0, err
+		}
+		if scriptAddr.EncodeAddress() != addrs[0].EncodeAddress() {
+			return nil, 0, nil, 0, errors.New("redeem script does " +
+				"not match script hash")
+		}
+		in.RedeemScript = redeemScript
+	}
+	script = in.RedeemScript
+	class, addrs, nRequired, err = btcscript.ExtractPkScriptAddrs(script,
+		cfg.Net())
+	if err != nil {
+		return nil, 0, nil, 0, err
+	}
+	return script, class, addrs, nRequired, nil
+}
+
+// Sign adds a signature to each input for every key which may sign it that
+// is found with getKey.  Missing redeem scripts are looked up with
+// getScript.  Inputs which can not be signed are skipped, as they may be
+// signed by another wallet.  The number of added signatures is returned.
+// If no signatures were added because a key is locked,
+// wallet.ErrWalletLocked is returned.
+func (p *PartialTx) Sign(getKey btcscript.KeyClosure,
+	getScript btcscript.ScriptClosure) (int, error) {
+
+	signed := 0
+	locked := false
+	for i, in := range p.Inputs {
+		script, class, addrs, _, err := p.signingScript(i, getScript)
+		if err != nil {
+			continue
+		}
+		switch class {
+		case btcscript.PubKeyHashTy, btcscript.PubKeyTy,
+			btcscript.MultiSigTy:
+		default:
+			continue
+		}
+
+		for _, addr := range addrs {
+			key, compressed, err := getKey(addr)
+			if err == wallet.ErrWalletLocked {
+				locked = true
+				continue
+			}
+			if err != nil {
+				continue
+			}
+
+			// Signatures are saved with the public key as it
+			// appears in the script, or with the serialized key
+			// which hashes to a pubkey hash.
+			pubKey := addr.ScriptAddress()
+			if class == btcscript.PubKeyHashTy {
+				pk := (*btcec.PublicKey)(&key.PublicKey)
+				if compressed {
+					pubKey = pk.SerializeCompressed()
+				} else {
+					pubKey = pk.SerializeUncompressed()
+				}
+				if !bytes.Equal(btcutil.Hash160(pubKey),
+					addr.ScriptAddress()) {
+					continue
+				}
+			}
+			if _, ok := in.Sigs[string(pubKey)]; ok {
+				continue
+			}
+
+			sig, err := btcscript.RawTxInSignature(p.Tx, i, script,
+				btcscript.SigHashAll, key)
+			if err != nil {
+				return signed, err
+			}
+			in.Sigs[string(pubKey)] = sig
+			signed++
+		}
+	}
+	if signed == 0 && locked {
+		return 0, wallet.ErrWalletLocked
+	}
+	return signed, nil
+}
+
+// Combine adds all signatures and redeem scripts of other to p.
+// ErrPartialTxMismatch is returned if other is not a copy of the same
+// unsigned transaction.
+func (p *PartialTx) Combine(other *PartialTx) error {
+	var buf, otherBuf bytes.Buffer
+	if err := p.Tx.Serialize(&buf); err != nil {
+		return err
+	}
+	if err := other.Tx.Serialize(&otherBuf); err != nil {
+		return err
+	}
+	if !bytes.Equal(buf.Bytes(), otherBuf.Bytes()) ||
+		len(p.Inputs) != len(other.Inputs) {
+		return ErrPartialTxMismatch
+	}
+	for i, in := range p.Inputs {
+		otherIn := other.Inputs[i]
+		if in.PrevOut.Value != otherIn.PrevOut.Value ||
+			!bytes.Equal(in.PrevOut.PkScript, otherIn.PrevOut.PkScript) {
+			return ErrPartialTxMismatch
+		}
+		if otherIn.RedeemScript != nil {
+			if in.RedeemScript != nil &&
+				!bytes.Equal(in.RedeemScript, otherIn.RedeemScript) {
+				return ErrPartialTxMismatch
+			}
+		}
+	}
+
+	for i, in := range p.Inputs {
+		otherIn := other.Inputs[i]
+		if in.RedeemScript == nil {
+			in.RedeemScript = otherIn.RedeemScript
+		}
+		for pubKey, sig := range otherIn.Sigs {
+			if _, ok := in.Sigs[pubKey]; !ok {
+				in.Sigs[pubKey] = sig
+			}
+		}
+	}
+	return nil
+}
+
+// appendPushData appends a canonical push of data to script.
+func appendPushData(script, data []byte) []byte {
+	switch {
+	case len(data) < btcscript.OP_PUSHDATA1:
+		script = append(script, byte(len(data)))
+	case len(data) <= 0xff:
+		script = append(script, btcscript.OP_PUSHDATA1, byte(len(data)))
+	default:
+		script = append(script, btcscript.OP_PUSHDATA2, byte(len(data)),
+			byte(len(data)>>8))
+	}
+	return append(script, data...)
+}
+
+// Finalize returns the transaction with the signature script of each input
+// built from the saved signatures.  ErrPartialTxIncomplete is returned if
+// any input does not have enough signatures, and an error is returned if
+// any completed input script fails to validate.
+func (p *PartialTx) Finalize() (*btcwire.MsgTx, error) {
+	msgtx := p.Tx.Copy()
+	for i, in := range p.Inputs {
+		script, class, addrs, nRequired, err := p.signingScript(i, nil)
+		if err != nil {
+			return nil, ErrPartialTxIncomplete
+		}
+
+		var sigScript []byte
+		switch class {
+		case btcscript.PubKeyHashTy:
+			for pubKey, sig := range in.Sigs {
+				if bytes.Equal(btcutil.Hash160([]byte(pubKey)),
+					addrs[0].ScriptAddress()) {
+					sigScript = appendPushData(sigScript, sig)
+					sigScript = appendPushData(sigScript,
+						[]byte(pubKey))
+					break
+				}
+			}
+			if sigScript == nil {
+				return nil, ErrPartialTxIncomplete
+			}
+
+		case btcscript.PubKeyTy:
+			sig, ok := in.Sigs[string(addrs[0].ScriptAddress())]
+			if !ok {
+				return nil, ErrPartialTxIncomplete
+			}
+			sigScript = appendPushData(sigScript, sig)
+
+		case btcscript.MultiSigTy:
+			// Signatures must be in the same order as the public
+			// keys of the script.  An extra item is consumed by
+			// OP_CHECKMULTISIG.
+			sigScript = append(sigScript, btcscript.OP_0)
+			n := 0
+			for _, addr := range addrs {
+				if n == nRequired {
+					break
+				}
+				sig, ok := in.Sigs[string(addr.ScriptAddress())]
+				if !ok {
+					continue
+				}
+				sigScript = appendPushData(sigScript, sig)
+				n++
+			}
+			if n < nRequired {
+				return nil, ErrPartialTxIncomplete
+			}
+
+		default:
+			return nil, fmt.Errorf("input %d: unsupported script", i)
+		}
+		if in.RedeemScript != nil {
+			sigScript = appendPushData(sigScript, script)
+		}
+		msgtx.TxIn[i].SignatureScript = sigScript
+	}
+
+	for i, txin := range msgtx.TxIn {
+		engine, err := btcscript.NewScript(txin.SignatureScript,
+			p.Inputs[i].PrevOut.PkScript, i, msgtx,
+			btcscript.ScriptBip16|btcscript.ScriptCanonicalSignatures)
+		if err != nil {
+			return nil, fmt.Errorf("cannot create script engine: %s", err)
+		}
+		if err = engine.Execute(); err != nil {
+			return nil, fmt.Errorf("cannot validate transaction: %s", err)
+		}
+	}
+	return msgtx, nil
+}
+
+// Hex returns the hex encoding of the serialized partially signed
+// transaction.
+func (p *PartialTx) Hex() (string, error) {
+	var buf bytes.Buffer
+	if err := p.write(&buf); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(buf.Bytes()), nil
+}
+
+// decodePartialTx decodes a partially signed transaction encoded by Hex.
+func decodePartialTx(s string) (*PartialTx, error) {
+	serialized, err := hex.DecodeString(s)
+	if err != nil {
+		return nil, err
+	}
+	p := new(PartialTx)
+	r := bytes.NewReader(serialized)
+	if err := p.read(r); err != nil {
+		return nil, err
+	}
+	if r.Len() != 0 {
+		return nil, errors.New("unexpected data after partially " +
+			"signed transaction")
+	}
+	return p, nil
+}
+
+// write serializes the partially signed transaction to w.  Signatures are
+// written sorted by public key, so equal transactions serialize equally.
+func (p *PartialTx) write(w io.Writer) error {
+	if _, err := w.Write(partialTxMagic[:]); err != nil {
+		return err
+	}
+	if err := binary.Write(w, binary.LittleEndian, partialTxVersion); err != nil {
+		return err
+	}
+	if err := p.Tx.Serialize(w); err != nil {
+		return err
+	}
+	for _, in := range p.Inputs {
+		if err := binary.Write(w, binary.LittleEndian, in.PrevOut.Value); err != nil {
+			return err
+		}
+		if err := writeString(w, string(in.PrevOut.PkScript)); err != nil {
+			return err
+		}
+		if err := writeString(w, string(in.RedeemScript)); err != nil {
+			return err
+		}
+
+		pubKeys := make([]string, 0, len(in.Sigs))
+		for pubKey := range in.Sigs {
+			pubKeys = append(pubKeys, pubKey)
+		}
+		sort.Strings(pubKeys)
+		if err := binary.Write(w, binary.LittleEndian, uint32(len(pubKeys))); err != nil {
+			return err
+		}
+		for _, pubKey := range pubKeys {
+			if err := writeString(w, pubKey); err != nil {
+				return err
+			}
+			if err := writeString(w, string(in.Sigs[pubKey])); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// read deserializes a partially signed transaction written by write.
+func (p *PartialTx) read(r io.Reader) error {
+	var magic [4]byte
+	var vers uint32
+	if _, err := io.ReadFull(r, magic[:]); err != nil {
+		return err
+	}
+	if magic != partialTxMagic {
+		return errors.New("not a partially signed transaction")
+	}
+	if err := binary.Read(r, binary.LittleEndian, &vers); err != nil {
+		return err
+	}
+	if vers != partialTxVersion {
+		return fmt.Errorf("unknown partially signed transaction "+
+			"version %d", vers)
+	}
+	msgtx := new(btcwire.MsgTx)
+	if err := msgtx.Deserialize(r); err != nil {
+		return err
+	}
+
+	inputs := make([]*PartialTxInput, len(msgtx.TxIn))
+	for i, txin := range msgtx.TxIn {
+		if len(txin.SignatureScript) != 0 {
+			return errors.New("partially signed transaction " +
+				"inputs must not have signature scripts")
+		}
+		var value int64
+		if err := binary.Read(r, binary.LittleEndian, &value); err != nil {
+			return err
+		}
+		pkScript, err := readString(r, maxPartialTxScriptLen)
+		if err != nil {
+			return err
+		}
+		redeemScript, err := readString(r, maxPartialTxScriptLen)
+		if err != nil {
+			return err
+		}
+
+		var n uint32
+		if err := binary.Read(r, binary.LittleEndian, &n); err != nil {
+			return err
+		}
+		if n > maxPartialTxSigs {
+			return errors.New("too many input signatures")
+		}
+		sigs := make(map[string][]byte, n)
+		for j := uint32(0); j < n; j++ {
+			pubKey, err := readString(r, maxPartialTxPubKeyLen)
+			if err != nil {
+				return err
+			}
+			sig, err := readString(r, maxPartialTxSigLen)
+			if err != nil {
+				return err
+			}
+			sigs[pubKey] = []byte(sig)
+		}
+
+		in := &PartialTxInput{
+			PrevOut: btcwire.NewTxOut(value, []byte(pkScript)),
+			Sigs:    sigs,
+		}
+		if redeemScript != "" {
+			in.RedeemScript = []byte(redeemScript)
+		}
+		inputs[i] = in
+	}
+
+	p.Tx = msgtx
+	p.Inputs = inputs
+	return nil
+}
+
+// partialTxHolds returns a heldTx for every account with unspent credits
+// spent by the inputs of msgtx, describing msgtx as if it were held by the
+// account.  The change index of each is the first output paying to an
+// address of the account, or -1 if no output does.
+func partialTxHolds(msgtx *btcwire.MsgTx) ([]*heldTx, error) {
+	ops := make(map[btcwire.OutPoint]struct{}, len(msgtx.TxIn))
+	for _, txIn := range msgtx.TxIn {
+		ops[txIn.PreviousOutpoint] = struct{}{}
+	}
+
+	var holds []*heldTx
+	tx := btcutil.NewTx(msgtx)
+	for _, a := range AcctMgr.AllAccounts() {
+		unspent, err := a.TxStore.UnspentOutputs()
+		if err != nil {
+			return nil, err
+		}
+		for _, c := range unspent {
+			if _, ok := ops[*c.OutPoint()]; ok {
+				h := &heldTx{
+					account:     a.Name(),
+					tx:          tx,
+					changeIndex: partialTxChangeIndex(a, msgtx),
+				}
+				holds = append(holds, h)
+				break
+			}
+		}
+	}
+	return holds, nil
+}
+
+// partialTxChangeIndex returns the index of the first output of msgtx
+// paying to an address of account a, or -1 if no output does.
+func partialTxChangeIndex(a *Account, msgtx *btcwire.MsgTx) int {
+	for i, txout := range msgtx.TxOut {
+		_, addrs, _, _ := btcscript.ExtractPkScriptAddrs(txout.PkScript,
+			cfg.Net())
+		if len(addrs) != 1 {
+			continue
+		}
+		if acct, err := AcctMgr.AccountByAddress(addrs[0]); err == nil && acct == a {
+			return i
+		}
+	}
+	return -1
+}
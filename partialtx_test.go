@@ -0,0 +1,201 @@
+/*
+ * Copyright (c) 2014 Conformal Systems LLC <info@conformal.com>
+ *
+ * Permission to use, copy, modify, and distribute this software for any
+ * purpose with or without fee is hereby granted, provided that the above
+ * copyright notice and this permission notice appear in all copies.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+ * WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+ * MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ * ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+ * WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ * ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+ * OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ */
+
+package main
+
+import (
+	"crypto/ecdsa"
+	"crypto/rand"
+	"github.com/conformal/btcec"
+	"github.com/conformal/btcscript"
+	"github.com/conformal/btcutil"
+	"github.com/conformal/btcwire"
+	"testing"
+)
+
+// newTestPartialTx creates a partially signed transaction spending a fake
+// 2-of-2 bare multisig output to the keys returned as the map used by
+// signingKeys.  The single output pays outAmt.
+func newTestPartialTx(t *testing.T, outAmt int64) (*PartialTx,
+	[]map[string]keyInfo) {
+
+	keys := make([]map[string]keyInfo, 2)
+	pubKeys := make([]*btcutil.AddressPubKey, 2)
+	for i := range keys {
+		pk, err := ecdsa.GenerateKey(btcec.S256(), rand.Reader)
+		if err != nil {
+			t.Fatalf("Cannot generate key: %v", err)
+		}
+		pub := (*btcec.PublicKey)(&pk.PublicKey)
+		addr, err := btcutil.NewAddressPubKey(pub.SerializeCompressed(),
+			cfg.Net())
+		if err != nil {
+			t.Fatalf("Cannot create pubkey address: %v", err)
+		}
+		keys[i] = map[string]keyInfo{
+			addr.EncodeAddress(): {key: pk, compressed: true},
+		}
+		pubKeys[i] = addr
+	}
+	pkScript, err := btcscript.MultiSigScript(pubKeys, 2)
+	if err != nil {
+		t.Fatalf("Cannot create multisig script: %v", err)
+	}
+
+	msgtx := btcwire.NewMsgTx()
+	op := btcwire.NewOutPoint(&btcwire.ShaHash{}, 0)
+	msgtx.AddTxIn(btcwire.NewTxIn(op, nil))
+	msgtx.AddTxOut(btcwire.NewTxOut(outAmt, []byte{btcscript.OP_TRUE}))
+	prevOut := btcwire.NewTxOut(100000, pkScript)
+	return NewPartialTx(msgtx, []*btcwire.TxOut{prevOut}), keys
+}
+
+type partialTxSignTest struct {
+	name    string
+	signers [][]int // keys signing each copy before the copies are combined
+	sigs    int
+	err     error
+}
+
+var partialTxSignTests = []partialTxSignTest{
+	{
+		name:    "unsigned",
+		signers: [][]int{{}},
+		err:     ErrPartialTxIncomplete,
+	},
+	{
+		name:    "one signature",
+		signers: [][]int{{1}},
+		sigs:    1,
+		err:     ErrPartialTxIncomplete,
+	},
+	{
+		name:    "signed in turn",
+		signers: [][]int{{0, 1}},
+		sigs:    2,
+	},
+	{
+		name:    "signed in parallel",
+		signers: [][]int{{0}, {1}},
+		sigs:    2,
+	},
+	{
+		name:    "repeated signature",
+		signers: [][]int{{0}, {0}},
+		sigs:    1,
+		err:     ErrPartialTxIncomplete,
+	},
+}
+
+func TestPartialTxSign(t *testing.T) {
+	for _, test := range partialTxSignTests {
+		p, keys := newTestPartialTx(t, 90000)
+		hexTx, err := p.Hex()
+		if err != nil {
+			t.Fatalf("%s: Cannot serialize transaction: %v",
+				test.name, err)
+		}
+
+		var combined *PartialTx
+		for _, signers := range test.signers {
+			// Each copy is signed as if by a different wallet,
+			// receiving the serialized transaction.
+			cp, err := decodePartialTx(hexTx)
+			if err != nil {
+				t.Fatalf("%s: Cannot decode transaction: %v",
+					test.name, err)
+			}
+			for _, i := range signers {
+				n, err := cp.Sign(signingKeys(keys[i]), nil)
+				if err != nil || n != 1 {
+					t.Fatalf("%s: Signing with key %d "+
+						"added %d signatures: %v",
+						test.name, i, n, err)
+				}
+			}
+			if combined == nil {
+				combined = cp
+				continue
+			}
+			if err := combined.Combine(cp); err != nil {
+				t.Fatalf("%s: Cannot combine transactions: %v",
+					test.name, err)
+			}
+		}
+
+		if n := len(combined.Inputs[0].Sigs); n != test.sigs {
+			t.Errorf("%s: input has %d signatures, expected %d",
+				test.name, n, test.sigs)
+		}
+		_, err = combined.Finalize()
+		if err != test.err {
+			t.Errorf("%s: unexpected finalize error %v, "+
+				"expected %v", test.name, err, test.err)
+		}
+	}
+}
+
+func TestPartialTxCombineMismatch(t *testing.T) {
+	p, _ := newTestPartialTx(t, 90000)
+	other, _ := newTestPartialTx(t, 80000)
+	if err := p.Combine(other); err != ErrPartialTxMismatch {
+		t.Errorf("Combining different transactions returned %v, "+
+			"expected %v", err, ErrPartialTxMismatch)
+	}
+}
+
+func TestPartialTxSerialization(t *testing.T) {
+	p, keys := newTestPartialTx(t, 90000)
+	if _, err := p.Sign(signingKeys(keys[0]), nil); err != nil {
+		t.Fatalf("Cannot sign transaction: %v", err)
+	}
+	hexTx, err := p.Hex()
+	if err != nil {
+		t.Fatalf("Cannot serialize transaction: %v", err)
+	}
+
+	decoded, err := decodePartialTx(hexTx)
+	if err != nil {
+		t.Fatalf("Cannot decode transaction: %v", err)
+	}
+	if len(decoded.Inputs) != 1 || len(decoded.Inputs[0].Sigs) != 1 {
+		t.Fatalf("Decoded transaction does not have the signed input")
+	}
+	reencoded, err := decoded.Hex()
+	if err != nil {
+		t.Fatalf("Cannot serialize decoded transaction: %v", err)
+	}
+	if reencoded != hexTx {
+		t.Errorf("Decoded transaction serializes differently")
+	}
+
+	invalid := []struct {
+		name  string
+		hexTx string
+	}{
+		{"empty", ""},
+		{"bad hex", hexTx[:len(hexTx)-1]},
+		{"bad magic", "00" + hexTx[2:]},
+		{"truncated", hexTx[:len(hexTx)-2]},
+		{"trailing data", hexTx + "00"},
+	}
+	for _, test := range invalid {
+		if _, err := decodePartialTx(test.hexTx); err == nil {
+			t.Errorf("%s: invalid transaction was decoded",
+				test.name)
+		}
+	}
+}
@@ -0,0 +1,266 @@
+/*
+ * Copyright (c) 2014 Conformal Systems LLC <info@conformal.com>
+ *
+ * Permission to use, copy, modify, and distribute this software for any
+ * purpose with or without fee is hereby granted, provided that the above
+ * copyright notice and this permission notice appear in all copies.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+ * WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+ * MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ * ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+ * WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ * ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+ * OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ */
+
+package main
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"github.com/conformal/btcjson"
+	"github.com/conformal/btcscript"
+	"github.com/conformal/btcutil"
+	"github.com/conformal/btcwallet/wallet"
+	"github.com/conformal/btcwire"
+	"io"
+	"os"
+	"time"
+)
+
+// ErrPolicyViolation is the JSON-RPC error returned when a send is rejected
+// by the spending policy of an account.  The message of a returned error is
+// replaced with the reason the send was rejected.
+var ErrPolicyViolation = btcjson.Error{
+	Code:    -40,
+	Message: "send violates account spending policy",
+}
+
+// PolicyViolationError describes an error where a transaction created by
+// an account violates the account's spending policy.
+type PolicyViolationError string
+
+// Error satisifies the builtin error interface.
+func (e PolicyViolationError) Error() string {
+	return string(e)
+}
+
+// spendPolicyFileSuffix is the filename suffix of the file holding an
+// account's spending policy.
+const spendPolicyFileSuffix = "policy.bin"
+
+// spendPolicyVersion is the current version of serialized spending
+// policies.
+const spendPolicyVersion uint32 = 1
+
+// maxSpendPolicyAddresses is the maximum number of allowed destination
+// addresses read from a saved spending policy.
+const maxSpendPolicyAddresses = 100000
+
+// maxSpendPolicyAddressLen is the maximum length of an encoded address
+// read from a saved spending policy.
+const maxSpendPolicyAddressLen = 128
+
+// spendPolicyPeriod is the length of the rolling period limited by a
+// spending policy's period limit.
+const spendPolicyPeriod = 24 * time.Hour
+
+// SpendPolicy restricts the transactions which may be created by an
+// account.  Amounts sent are the values of all outputs except change, and
+// do not include fees.  A zero limit and an empty allowlist do not
+// restrict sends.
+type SpendPolicy struct {
+	// Allowlist holds every encoded address an account may send to.
+	Allowlist map[string]struct{}
+
+	// MaxPerTx is the maximum amount sent by a single transaction.
+	MaxPerTx btcutil.Amount
+
+	// PeriodLimit is the maximum total amount sent by all transactions
+	// created during the last spendPolicyPeriod.
+	PeriodLimit btcutil.Amount
+}
+
+// sentAmount returns the total value of all outputs of msgtx, except for
+// the change output at changeIdx (or -1 if there is no change).
+func sentAmount(msgtx *btcwire.MsgTx, changeIdx int) btcutil.Amount {
+	var amt btcutil.Amount
+	for i, txout := range msgtx.TxOut {
+		if i != changeIdx {
+			amt += btcutil.Amount(txout.Value)
+		}
+	}
+	return amt
+}
+
+// sentSince returns the total amount sent by an account in all
+// transactions received by its transaction store since t.  Transactions
+// held by the wallet, which are not yet saved in the store, are always
+// included.
+func (a *Account) sentSince(t time.Time) btcutil.Amount {
+	var amt btcutil.Amount
+	for _, r := range a.TxStore.Records() {
+		if r.Debits() == nil || r.Received().Before(t) {
+			continue
+		}
+		amt += r.OutputAmount(true)
+	}
+
+	for _, h := range LockTimeTxs.txs {
+		if h.account == a.name {
+			amt += sentAmount(h.tx.MsgTx(), h.changeIndex)
+		}
+	}
+	for _, p := range PendingSends.sends {
+		if p.tx.account == a.name {
+			amt += sentAmount(p.tx.tx.MsgTx(), p.tx.changeIndex)
+		}
+	}
+	for _, v := range VaultSends.sends {
+		if v.tx.account == a.name {
+			amt += sentAmount(v.tx.tx.MsgTx(), v.tx.changeIndex)
+		}
+	}
+	return amt
+}
+
+// checkPolicy returns a PolicyViolationError if the unsigned transaction
+// msgtx, with change output at changeIdx (or -1 if there is no change),
+// may not be sent under the account's spending policy.
+func (a *Account) checkPolicy(msgtx *btcwire.MsgTx, changeIdx int) error {
+	p := a.policy
+	if p == nil {
+		return nil
+	}
+
+	if len(p.Allowlist) != 0 {
+		for i, txout := range msgtx.TxOut {
+			if i == changeIdx || txout.Value == 0 {
+				continue
+			}
+			_, addrs, _, _ := btcscript.ExtractPkScriptAddrs(
+				txout.PkScript, cfg.Net())
+			if len(addrs) != 1 {
+				return PolicyViolationError("output does not pay " +
+					"to a single address")
+			}
+			addrStr := addrs[0].EncodeAddress()
+			if _, ok := p.Allowlist[addrStr]; !ok {
+				return PolicyViolationError(fmt.Sprintf("address %s "+
+					"is not an allowed destination", addrStr))
+			}
+		}
+	}
+
+	amt := sentAmount(msgtx, changeIdx)
+	if p.MaxPerTx != 0 && amt > p.MaxPerTx {
+		return PolicyViolationError(fmt.Sprintf("sent amount %v exceeds "+
+			"the maximum of %v per transaction", amt, p.MaxPerTx))
+	}
+	if p.PeriodLimit != 0 {
+		sent := a.sentSince(time.Now().Add(-spendPolicyPeriod))
+		if sent+amt > p.PeriodLimit {
+			return PolicyViolationError(fmt.Sprintf("sent amount %v "+
+				"exceeds the remaining limit of %v in the last %v",
+				amt, p.PeriodLimit-sent, spendPolicyPeriod))
+		}
+	}
+	return nil
+}
+
+// SetPolicy replaces the spending policy of an account and writes it to
+// disk.  A nil policy removes any previous policy.  The wallet must be
+// unlocked to change the policy.
+func (a *Account) SetPolicy(p *SpendPolicy) error {
+	if a.IsLocked() {
+		return wallet.ErrWalletLocked
+	}
+
+	if p == nil {
+		path := accountFilename(spendPolicyFileSuffix, a.name,
+			networkDir(cfg.Net()))
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	} else {
+		filename := accountFilename(spendPolicyFileSuffix, a.name, "")
+		if err := saveNetworkFile(filename, p.write); err != nil {
+			return err
+		}
+	}
+	a.policy = p
+	return nil
+}
+
+// readSpendPolicy reads the spending policy saved for the account named
+// account.  If the account has no saved policy, nil is returned.
+func readSpendPolicy(account string) (*SpendPolicy, error) {
+	p := new(SpendPolicy)
+	filename := accountFilename(spendPolicyFileSuffix, account, "")
+	err := loadNetworkFile(filename, p.read)
+	switch {
+	case os.IsNotExist(err):
+		return nil, nil
+	case err != nil:
+		return nil, err
+	}
+	return p, nil
+}
+
+// write serializes the policy to w.
+func (p *SpendPolicy) write(w io.Writer) error {
+	fields := []interface{}{
+		spendPolicyVersion,
+		int64(p.MaxPerTx),
+		int64(p.PeriodLimit),
+		uint32(len(p.Allowlist)),
+	}
+	for _, field := range fields {
+		if err := binary.Write(w, binary.LittleEndian, field); err != nil {
+			return err
+		}
+	}
+	for addrStr := range p.Allowlist {
+		if err := writeString(w, addrStr); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// read deserializes a policy written by write.
+func (p *SpendPolicy) read(r io.Reader) error {
+	var vers, n uint32
+	var maxPerTx, periodLimit int64
+	if err := binary.Read(r, binary.LittleEndian, &vers); err != nil {
+		return err
+	}
+	if vers != spendPolicyVersion {
+		return fmt.Errorf("unknown spending policy version %d", vers)
+	}
+	fields := []interface{}{&maxPerTx, &periodLimit, &n}
+	for _, field := range fields {
+		if err := binary.Read(r, binary.LittleEndian, field); err != nil {
+			return err
+		}
+	}
+	if n > maxSpendPolicyAddresses {
+		return errors.New("too many saved allowed addresses")
+	}
+
+	allowlist := make(map[string]struct{}, n)
+	for i := uint32(0); i < n; i++ {
+		addrStr, err := readString(r, maxSpendPolicyAddressLen)
+		if err != nil {
+			return err
+		}
+		allowlist[addrStr] = struct{}{}
+	}
+
+	p.Allowlist = allowlist
+	p.MaxPerTx = btcutil.Amount(maxPerTx)
+	p.PeriodLimit = btcutil.Amount(periodLimit)
+	return nil
+}
@@ -230,7 +230,9 @@ func (m *RescanManager) rpcHandler() {
 
 // RescanJob is a job to be processed by the RescanManager.  The job includes
 // a set of account's addresses, a starting height to begin the rescan, and
-// outpoints spendable by the addresses thought to be unspent.
+// outpoints spendable by the addresses thought to be unspent.  Addresses
+// which are not saved by any account, such as those of swept private keys,
+// are keyed by a nil account.
 type RescanJob struct {
 	Addresses   map[*Account][]btcutil.Address
 	OutPoints   []*btcwire.OutPoint
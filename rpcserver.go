@@ -32,6 +32,8 @@ import (
 	"github.com/conformal/btcwallet/wallet"
 	"github.com/conformal/btcwire"
 	"github.com/conformal/btcws"
+	"math"
+	"sort"
 	"sync"
 	"time"
 )
@@ -55,9 +57,11 @@ var rpcHandlers = map[string]cmdHandler{
 	"importprivkey":          ImportPrivKey,
 	"keypoolrefill":          KeypoolRefill,
 	"listaccounts":           ListAccounts,
+	"listlockunspent":        ListLockUnspent,
 	"listsinceblock":         ListSinceBlock,
 	"listtransactions":       ListTransactions,
 	"listunspent":            ListUnspent,
+	"lockunspent":            LockUnspent,
 	"sendfrom":               SendFrom,
 	"sendmany":               SendMany,
 	"sendtoaddress":          SendToAddress,
@@ -80,10 +84,8 @@ var rpcHandlers = map[string]cmdHandler{
 	"getwork":               Unimplemented,
 	"importwallet":          Unimplemented,
 	"listaddressgroupings":  Unimplemented,
-	"listlockunspent":       Unimplemented,
 	"listreceivedbyaccount": Unimplemented,
 	"listreceivedbyaddress": Unimplemented,
-	"lockunspent":           Unimplemented,
 	"move":                  Unimplemented,
 	"setaccount":            Unimplemented,
 	"stop":                  Unimplemented,
@@ -92,7 +94,46 @@ var rpcHandlers = map[string]cmdHandler{
 	"encryptwallet": Unsupported,
 
 	// Extensions not exclusive to websocket connections.
-	"createencryptedwallet": CreateEncryptedWallet,
+	"addscheduledpayment":     AddScheduledPayment,
+	"approvescheduledpayment": ApproveScheduledPayment,
+	"approvesend":             ApproveSend,
+	"backupshares":            BackupShares,
+	"bumpfee":                 BumpFee,
+	"cancelvaultsend":         CancelVaultSend,
+	"combinepartialtxs":       CombinePartialTxs,
+	"consolidateutxos":        ConsolidateUTXOs,
+	"createencryptedwallet":   CreateEncryptedWallet,
+	"createinvoice":           CreateInvoice,
+	"createpartialtx":         CreatePartialTx,
+	"estimatefee":             EstimateFee,
+	"exportwatchonlychain":    ExportWatchOnlyChain,
+	"finalizepartialtx":       FinalizePartialTx,
+	"getpaymenturi":           GetPaymentURI,
+	"getspendpolicy":          GetSpendPolicy,
+	"getsweeprules":           GetSweepRules,
+	"importaddress":           ImportAddress,
+	"importpubkey":            ImportPubKey,
+	"importwatchonlychain":    ImportWatchOnlyChain,
+	"listpendingsends":        ListPendingSends,
+	"listscheduledpayments":   ListScheduledPayments,
+	"listvaultsends":          ListVaultSends,
+	"rejectsend":              RejectSend,
+	"releasepartialtx":        ReleasePartialTx,
+	"removescheduledpayment":  RemoveScheduledPayment,
+	"restorefromshares":       RestoreFromShares,
+	"restorewallet":           RestoreWallet,
+	"sendall":                 SendAll,
+	"senddata":                SendData,
+	"sendfromwithoptions":     SendFromWithOptions,
+	"sendmanywithoptions":     SendManyWithOptions,
+	"sendtouri":               SendToURI,
+	"sendwithinputs":          SendWithInputs,
+	"setspendpolicy":          SetSpendPolicy,
+	"setsweeprule":            SetSweepRule,
+	"settxfeerate":            SetTxFeeRate,
+	"setvaultrule":            SetVaultRule,
+	"signpartialtx":           SignPartialTx,
+	"sweepprivkey":            SweepPrivKey,
 }
 
 // Extensions exclusive to websocket connections.
@@ -299,6 +340,15 @@ func makeMultiSigScript(keys []string, nRequired int) ([]byte, *btcjson.Error) {
 
 			apkinfo := ainfo.(wallet.PubKeyAddress)
 
+			// Watch-only addresses imported without their public
+			// key can not be used.
+			if apkinfo.PubKey() == nil {
+				return nil, &btcjson.Error{
+					Code:    btcjson.ErrParse.Code,
+					Message: "no public key for address",
+				}
+			}
+
 			// This will be an addresspubkey
 			a, err := btcutil.DecodeAddress(apkinfo.ExportPubKey(),
 				cfg.Net())
@@ -460,6 +510,42 @@ func DumpWallet(icmd btcjson.Cmd) (interface{}, *btcjson.Error) {
 	}
 }
 
+// EstimateFee handles an estimatefee extension request by returning the
+// estimated fee rate, in BTC per kilobyte, needed for a transaction to begin
+// confirmation within the requested number of blocks.  As with bitcoind, -1
+// is returned if there is not yet enough data to make an estimate.
+func EstimateFee(icmd btcjson.Cmd) (interface{}, *btcjson.Error) {
+	// Type assert icmd to access parameters.
+	cmd, ok := icmd.(*EstimateFeeCmd)
+	if !ok {
+		return nil, &btcjson.ErrInternal
+	}
+
+	if cmd.NumBlocks < 1 {
+		e := btcjson.Error{
+			Code:    btcjson.ErrInvalidParameter.Code,
+			Message: "nblocks must be positive",
+		}
+		return nil, &e
+	}
+
+	rate, err := FeeEst.EstimateFee(int(cmd.NumBlocks))
+	switch err {
+	case nil:
+		return float64(rate) / float64(btcutil.SatoshiPerBitcoin), nil
+
+	case ErrNoFeeEstimate:
+		return -1, nil
+
+	default:
+		e := btcjson.Error{
+			Code:    btcjson.ErrWallet.Code,
+			Message: err.Error(),
+		}
+		return nil, &e
+	}
+}
+
 // ExportWatchingWallet handles an exportwatchingwallet request by exporting
 // the current account wallet as a watching wallet (with no private keys), and
 // either writing the exported wallet to disk, or base64-encoding serialized
@@ -522,6 +608,80 @@ func ExportWatchingWallet(icmd btcjson.Cmd) (interface{}, *btcjson.Error) {
 	return nil, nil
 }
 
+// ExportWatchOnlyChain handles an exportwatchonlychain extension request
+// by returning the watching chain of an account, the text encoding of the
+// public keys and chaincodes needed to derive the account's addresses
+// without any private keys.  The watching chain may be imported by another
+// wallet with importwatchonlychain.
+func ExportWatchOnlyChain(icmd btcjson.Cmd) (interface{}, *btcjson.Error) {
+	// Type assert icmd to access parameters.
+	cmd, ok := icmd.(*ExportWatchOnlyChainCmd)
+	if !ok {
+		return nil, &btcjson.ErrInternal
+	}
+
+	a, err := AcctMgr.Account(cmd.Account)
+	switch err {
+	case nil:
+		break
+
+	case ErrNotFound:
+		return nil, &btcjson.ErrWalletInvalidAccountName
+
+	default: // all other non-nil errors
+		e := btcjson.Error{
+			Code:    btcjson.ErrWallet.Code,
+			Message: err.Error(),
+		}
+		return nil, &e
+	}
+
+	chain, err := a.ExportWatchingChain()
+	if err != nil {
+		e := btcjson.Error{
+			Code:    btcjson.ErrWallet.Code,
+			Message: err.Error(),
+		}
+		return nil, &e
+	}
+	return chain, nil
+}
+
+// ImportWatchOnlyChain handles an importwatchonlychain extension request
+// by creating a new watching-only account from a watching chain returned
+// by exportwatchonlychain.  The new account derives and tracks the same
+// addresses as the exported account, but can not spend from them.
+func ImportWatchOnlyChain(icmd btcjson.Cmd) (interface{}, *btcjson.Error) {
+	// Type assert icmd to access parameters.
+	cmd, ok := icmd.(*ImportWatchOnlyChainCmd)
+	if !ok {
+		return nil, &btcjson.ErrInternal
+	}
+
+	err := AcctMgr.ImportWatchingChain(cmd.Account, cmd.Chain)
+	switch err {
+	case nil:
+		return nil, nil
+
+	case ErrWalletExists:
+		return nil, &btcjson.ErrWalletInvalidAccountName
+
+	case wallet.ErrInvalidWatchingChain:
+		e := btcjson.Error{
+			Code:    btcjson.ErrInvalidParameter.Code,
+			Message: err.Error(),
+		}
+		return nil, &e
+
+	default: // all other non-nil errors
+		e := btcjson.Error{
+			Code:    btcjson.ErrWallet.Code,
+			Message: err.Error(),
+		}
+		return nil, &e
+	}
+}
+
 // GetAddressesByAccount handles a getaddressesbyaccount request by returning
 // all addresses for an account, or an error if the requested account does
 // not exist.
@@ -800,6 +960,100 @@ func ImportPrivKey(icmd btcjson.Cmd) (interface{}, *btcjson.Error) {
 	}
 }
 
+// ImportAddress handles an importaddress extension request by importing a
+// pubkey hash address to an account as a watch-only address, without a
+// private key.
+func ImportAddress(icmd btcjson.Cmd) (interface{}, *btcjson.Error) {
+	// Type assert icmd to access parameters.
+	cmd, ok := icmd.(*ImportAddressCmd)
+	if !ok {
+		return nil, &btcjson.ErrInternal
+	}
+
+	a, err := AcctMgr.Account(cmd.Account)
+	switch err {
+	case nil:
+		break
+
+	case ErrNotFound:
+		return nil, &btcjson.ErrWalletInvalidAccountName
+
+	default:
+		e := btcjson.Error{
+			Code:    btcjson.ErrWallet.Code,
+			Message: err.Error(),
+		}
+		return nil, &e
+	}
+
+	addr, err := btcutil.DecodeAddress(cmd.Address, cfg.Net())
+	if err != nil || !addr.IsForNet(cfg.Net()) {
+		return nil, &btcjson.ErrInvalidAddressOrKey
+	}
+	if _, ok := addr.(*btcutil.AddressPubKeyHash); !ok {
+		return nil, &btcjson.ErrInvalidAddressOrKey
+	}
+
+	bs := &wallet.BlockStamp{}
+	_, err = a.ImportWatchedAddress(addr, bs, cmd.Rescan)
+	return nil, importWatchedError(err)
+}
+
+// ImportPubKey handles an importpubkey extension request by importing the
+// pubkey hash address of a hex encoded public key to an account as a
+// watch-only address, without a private key.
+func ImportPubKey(icmd btcjson.Cmd) (interface{}, *btcjson.Error) {
+	// Type assert icmd to access parameters.
+	cmd, ok := icmd.(*ImportPubKeyCmd)
+	if !ok {
+		return nil, &btcjson.ErrInternal
+	}
+
+	a, err := AcctMgr.Account(cmd.Account)
+	switch err {
+	case nil:
+		break
+
+	case ErrNotFound:
+		return nil, &btcjson.ErrWalletInvalidAccountName
+
+	default:
+		e := btcjson.Error{
+			Code:    btcjson.ErrWallet.Code,
+			Message: err.Error(),
+		}
+		return nil, &e
+	}
+
+	pubkey, err := hex.DecodeString(cmd.PubKey)
+	if err != nil {
+		return nil, &btcjson.ErrDecodeHexString
+	}
+
+	bs := &wallet.BlockStamp{}
+	_, err = a.ImportWatchedPubKey(pubkey, bs, cmd.Rescan)
+	return nil, importWatchedError(err)
+}
+
+// importWatchedError returns the JSON-RPC error for an error importing a
+// watch-only address, or nil if the import succeeded.
+func importWatchedError(err error) *btcjson.Error {
+	switch err {
+	case nil:
+		return nil
+
+	case wallet.ErrDuplicate:
+		// Do not return duplicate address errors to the client.
+		return nil
+
+	default:
+		return &btcjson.Error{
+			Code:    btcjson.ErrWallet.Code,
+			Message: err.Error(),
+		}
+	}
+}
+
 // KeypoolRefill handles the keypoolrefill command. Since we handle the keypool
 // automatically this does nothing since refilling is never manually required.
 func KeypoolRefill(icmd btcjson.Cmd) (interface{}, *btcjson.Error) {
@@ -858,6 +1112,86 @@ func GetNewAddress(icmd btcjson.Cmd) (interface{}, *btcjson.Error) {
 	return addr.EncodeAddress(), nil
 }
 
+// GetPaymentURI handles a getpaymenturi extension request by creating a
+// new address for an account and returning a BIP0021 payment URI
+// requesting the amount be paid to it.  The label is saved as the
+// address's comment in the wallet.
+func GetPaymentURI(icmd btcjson.Cmd) (interface{}, *btcjson.Error) {
+	// Type assert icmd to access parameters.
+	cmd, ok := icmd.(*GetPaymentURICmd)
+	if !ok {
+		return nil, &btcjson.ErrInternal
+	}
+
+	if cmd.Amount < 0 {
+		e := btcjson.Error{
+			Code:    btcjson.ErrInvalidParameter.Code,
+			Message: "amount must not be negative",
+		}
+		return nil, &e
+	}
+
+	a, err := AcctMgr.Account(cmd.Account)
+	switch err {
+	case nil:
+		break
+
+	case ErrNotFound:
+		return nil, &btcjson.ErrWalletInvalidAccountName
+
+	default: // all other non-nil errors
+		e := btcjson.Error{
+			Code:    btcjson.ErrWallet.Code,
+			Message: err.Error(),
+		}
+		return nil, &e
+	}
+
+	// Check the label before creating the address, so an invalid label
+	// does not use up an address.
+	if err := wallet.CheckComment(cmd.Label); err != nil {
+		e := btcjson.Error{
+			Code:    btcjson.ErrInvalidParameter.Code,
+			Message: err.Error(),
+		}
+		return nil, &e
+	}
+
+	addr, err := a.NewAddress()
+	if err != nil {
+		e := btcjson.Error{
+			Code:    btcjson.ErrWallet.Code,
+			Message: err.Error(),
+		}
+		return nil, &e
+	}
+
+	if cmd.Label != "" {
+		if err := a.SetAddressComment(addr, cmd.Label); err != nil {
+			e := btcjson.Error{
+				Code:    btcjson.ErrWallet.Code,
+				Message: err.Error(),
+			}
+			return nil, &e
+		}
+		AcctMgr.ds.ScheduleWalletWrite(a)
+		if err := AcctMgr.ds.FlushAccount(a); err != nil {
+			e := btcjson.Error{
+				Code:    btcjson.ErrWallet.Code,
+				Message: "cannot write account: " + err.Error(),
+			}
+			return nil, &e
+		}
+	}
+
+	uri := &PaymentURI{
+		Address: addr,
+		Amount:  btcutil.Amount(cmd.Amount),
+		Label:   cmd.Label,
+	}
+	return uri.String(), nil
+}
+
 // GetRawChangeAddress handles a getrawchangeaddress request by creating
 // and returning a new change address for an account.
 //
@@ -1079,6 +1413,24 @@ func ListAccounts(icmd btcjson.Cmd) (interface{}, *btcjson.Error) {
 	return AcctMgr.ListAccounts(cmd.MinConf), nil
 }
 
+// ListLockUnspent handles a listlockunspent request by returning an array
+// of all locked outpoints.
+func ListLockUnspent(icmd btcjson.Cmd) (interface{}, *btcjson.Error) {
+	_, ok := icmd.(*btcjson.ListLockUnspentCmd)
+	if !ok {
+		return nil, &btcjson.ErrInternal
+	}
+
+	results, err := AcctMgr.ListLockUnspent()
+	if err != nil {
+		return nil, &btcjson.Error{
+			Code:    btcjson.ErrWallet.Code,
+			Message: err.Error(),
+		}
+	}
+	return results, nil
+}
+
 // ListSinceBlock handles a listsinceblock request by returning an array of maps
 // with details of sent and received wallet transactions since the given block.
 func ListSinceBlock(icmd btcjson.Cmd) (interface{}, *btcjson.Error) {
@@ -1324,38 +1676,193 @@ func ListUnspent(icmd btcjson.Cmd) (interface{}, *btcjson.Error) {
 	return results, nil
 }
 
-// sendPairs is a helper routine to reduce duplicated code when creating and
-// sending payment transactions.
-func sendPairs(icmd btcjson.Cmd, account string, amounts map[string]btcutil.Amount,
-	minconf int) (interface{}, *btcjson.Error) {
-	// Check that the account specified in the request exists.
-	a, err := AcctMgr.Account(account)
-	if err != nil {
-		return nil, &btcjson.ErrWalletInvalidAccountName
-	}
-
-	// Create transaction, replying with an error if the creation
-	// was not successful.
-	createdTx, err := a.txToPairs(amounts, minconf)
-	switch {
-	case err == ErrNonPositiveAmount:
-		e := btcjson.Error{
-			Code:    btcjson.ErrInvalidParameter.Code,
-			Message: "amount must be positive",
+// parseOutPoints converts the transaction inputs of a JSON-RPC request to
+// the outpoints they reference.
+func parseOutPoints(inputs []btcjson.TransactionInput) ([]btcwire.OutPoint, *btcjson.Error) {
+	ops := make([]btcwire.OutPoint, 0, len(inputs))
+	for _, input := range inputs {
+		txSha, err := btcwire.NewShaHashFromStr(input.Txid)
+		if err != nil {
+			return nil, &btcjson.ErrDecodeHexString
 		}
-		return nil, &e
-
-	case err == wallet.ErrWalletLocked:
-		return nil, &btcjson.ErrWalletUnlockNeeded
-
-	case err != nil: // any other non-nil error
-		e := btcjson.Error{
-			Code:    btcjson.ErrInternal.Code,
-			Message: err.Error(),
+		if input.Vout < 0 {
+			return nil, &btcjson.ErrInvalidParameter
 		}
-		return nil, &e
+		ops = append(ops, *btcwire.NewOutPoint(txSha, uint32(input.Vout)))
 	}
+	return ops, nil
+}
+
+// LockUnspent handles the lockunspent command.  Locked outputs are not
+// chosen as inputs for newly-created transactions and are excluded from
+// listunspent results.  If unlocking with no outputs specified, every locked
+// output is unlocked.
+func LockUnspent(icmd btcjson.Cmd) (interface{}, *btcjson.Error) {
+	cmd, ok := icmd.(*btcjson.LockUnspentCmd)
+	if !ok {
+		return nil, &btcjson.ErrInternal
+	}
+
+	ops, jsonErr := parseOutPoints(cmd.Transactions)
+	if jsonErr != nil {
+		return nil, jsonErr
+	}
+
+	err := AcctMgr.LockUnspent(cmd.Unlock, ops)
+	switch err {
+	case nil:
+		return true, nil
+
+	case ErrNotFound:
+		return nil, &btcjson.Error{
+			Code:    btcjson.ErrInvalidParameter.Code,
+			Message: "Invalid parameter, unknown or spent output",
+		}
+
+	default:
+		return nil, &btcjson.Error{
+			Code:    btcjson.ErrWallet.Code,
+			Message: err.Error(),
+		}
+	}
+}
+
+// sendPairs is a helper routine to reduce duplicated code when creating and
+// sending payment transactions.  opts may be nil to create the transaction
+// using the wallet defaults.  If send approval is enabled, the transaction
+// is held until approved and the approval ID is returned instead of a TxID.
+// Likewise, the vault ID is returned for sends delayed by a vault rule.
+func sendPairs(icmd btcjson.Cmd, account string, amounts map[string]btcutil.Amount,
+	minconf int, opts *txOptions) (interface{}, *btcjson.Error) {
+	// Check that the account specified in the request exists.
+	a, err := AcctMgr.Account(account)
+	if err != nil {
+		return nil, &btcjson.ErrWalletInvalidAccountName
+	}
+
+	// Create transaction, replying with an error if the creation
+	// was not successful.
+	createdTx, err := a.txToPairs(amounts, minconf, opts)
+	if err != nil {
+		return nil, txToPairsError(err)
+	}
+
+	return sendOrQueueCreatedTx(icmd, a, createdTx)
+}
+
+// txToPairsError returns the JSON-RPC error replied when creating a
+// transaction with txToPairs fails with the non-nil error err.
+func txToPairsError(err error) *btcjson.Error {
+	switch {
+	case err == ErrNonPositiveAmount:
+		return &btcjson.Error{
+			Code:    btcjson.ErrInvalidParameter.Code,
+			Message: "amount must be positive",
+		}
+
+	case err == ErrSubtractFeeFromUnknown, err == ErrDataTooLarge:
+		return &btcjson.Error{
+			Code:    btcjson.ErrInvalidParameter.Code,
+			Message: err.Error(),
+		}
+
+	case err == wallet.ErrWalletLocked:
+		return &btcjson.ErrWalletUnlockNeeded
+	}
+
+	switch err.(type) {
+	case InsufficientInputsError, DustOutputError:
+		return &btcjson.Error{
+			Code:    btcjson.ErrWallet.Code,
+			Message: err.Error(),
+		}
+
+	case PolicyViolationError:
+		e := ErrPolicyViolation
+		e.Message = err.Error()
+		return &e
+	}
+
+	// any other non-nil error
+	return &btcjson.Error{
+		Code:    btcjson.ErrInternal.Code,
+		Message: err.Error(),
+	}
+}
+
+// sendOrQueueCreatedTx sends a transaction created by account a, unless
+// send approval is enabled, in which case it is held until approved and the
+// approval ID is returned.  Every transaction created by the wallet must be
+// sent with this, so that no send skips approval.
+func sendOrQueueCreatedTx(icmd btcjson.Cmd, a *Account, createdTx *CreatedTx) (interface{}, *btcjson.Error) {
+	if cfg.SendApproval() {
+		id, err := PendingSends.Add(a, createdTx)
+		if err != nil {
+			e := btcjson.Error{
+				Code:    btcjson.ErrWallet.Code,
+				Message: err.Error(),
+			}
+			return nil, &e
+		}
+		return id, nil
+	}
+
+	return sendOrDelayCreatedTx(icmd, a, createdTx)
+}
+
+// sendOrDelayCreatedTx sends a transaction created by account a, unless it
+// is delayed by the account's vault rule, in which case it is queued and
+// the vault ID is returned.
+func sendOrDelayCreatedTx(icmd btcjson.Cmd, a *Account, createdTx *CreatedTx) (interface{}, *btcjson.Error) {
+	if a.vault != nil && a.vault.delays(createdTx) {
+		id, err := VaultSends.Add(a, createdTx)
+		if err != nil {
+			e := btcjson.Error{
+				Code:    btcjson.ErrWallet.Code,
+				Message: err.Error(),
+			}
+			return nil, &e
+		}
+		return id, nil
+	}
+
+	return sendOrHoldCreatedTx(icmd, a, createdTx)
+}
+
+// sendOrHoldCreatedTx sends a transaction created by account a, unless its
+// lock time has not yet passed.  Transactions which can not yet be mined
+// are held by the wallet and sent once their lock time passes.  Either
+// way, the TxID is returned.
+func sendOrHoldCreatedTx(icmd btcjson.Cmd, a *Account, createdTx *CreatedTx) (interface{}, *btcjson.Error) {
+	if lockTime := createdTx.tx.MsgTx().LockTime; lockTime != 0 {
+		bs, err := GetCurBlock()
+		if err != nil {
+			e := btcjson.Error{
+				Code:    btcjson.ErrInternal.Code,
+				Message: err.Error(),
+			}
+			return nil, &e
+		}
+		if !lockTimePassed(lockTime, bs.Height, time.Now()) {
+			if err := LockTimeTxs.Add(a, createdTx); err != nil {
+				e := btcjson.Error{
+					Code:    btcjson.ErrWallet.Code,
+					Message: err.Error(),
+				}
+				return nil, &e
+			}
+			return createdTx.tx.Sha().String(), nil
+		}
+	}
+
+	return sendCreatedTx(icmd, a, createdTx)
+}
 
+// sendCreatedTx sends a transaction created by account a to btcd for relay
+// and records it in the account's transaction history.  If a change address
+// was generated while creating the transaction, the wallet is written to disk
+// and notifications for the address are requested.
+func sendCreatedTx(icmd btcjson.Cmd, a *Account, createdTx *CreatedTx) (interface{}, *btcjson.Error) {
 	// Mark txid as having send history so handlers adding receive history
 	// wait until all send history has been written.
 	SendTxHistSyncChans.add <- *createdTx.tx.Sha()
@@ -1419,7 +1926,7 @@ func SendFrom(icmd btcjson.Cmd) (interface{}, *btcjson.Error) {
 		cmd.ToAddress: btcutil.Amount(cmd.Amount),
 	}
 
-	return sendPairs(cmd, cmd.FromAccount, pairs, cmd.MinConf)
+	return sendPairs(cmd, cmd.FromAccount, pairs, cmd.MinConf, nil)
 }
 
 // SendMany handles a sendmany RPC request by creating a new transaction
@@ -1449,261 +1956,2066 @@ func SendMany(icmd btcjson.Cmd) (interface{}, *btcjson.Error) {
 		pairs[k] = btcutil.Amount(v)
 	}
 
-	return sendPairs(cmd, cmd.FromAccount, pairs, cmd.MinConf)
+	return sendPairs(cmd, cmd.FromAccount, pairs, cmd.MinConf, nil)
 }
 
-// SendToAddress handles a sendtoaddress RPC request by creating a new
-// transaction spending unspent transaction outputs for a wallet to another
-// payment address.  Leftover inputs not sent to the payment address or a fee
-// for the miner are sent back to a new address in the wallet.  Upon success,
-// the TxID for the created transaction is returned.
-func SendToAddress(icmd btcjson.Cmd) (interface{}, *btcjson.Error) {
-	// Type assert icmd to access parameters.
-	cmd, ok := icmd.(*btcjson.SendToAddressCmd)
-	if !ok {
-		return nil, &btcjson.ErrInternal
+// sendTxOptions returns the transaction creation options requested by
+// the options object of a send request.  Unset options use the wallet's
+// defaults.
+func sendTxOptions(opts *SendOptions) (*txOptions, *btcjson.Error) {
+	txOpts := new(txOptions)
+	if opts == nil {
+		return txOpts, nil
 	}
 
-	// Check that signed integer parameters are positive.
-	if cmd.Amount < 0 {
+	if opts.CoinSelect != "" {
+		selector, err := CoinSelectorByName(opts.CoinSelect)
+		if err != nil {
+			e := btcjson.Error{
+				Code:    btcjson.ErrInvalidParameter.Code,
+				Message: err.Error(),
+			}
+			return nil, &e
+		}
+		txOpts.selector = selector
+	}
+
+	if opts.FeeRate < 0 {
 		e := btcjson.Error{
 			Code:    btcjson.ErrInvalidParameter.Code,
-			Message: "amount must be positive",
+			Message: "feerate cannot be negative",
 		}
 		return nil, &e
 	}
+	txOpts.feeRate = FeeRatePerByte(opts.FeeRate)
+	txOpts.subtractFeeFrom = opts.SubtractFeeFrom
 
-	// Mock up map of address and amount pairs.
-	pairs := map[string]btcutil.Amount{
-		cmd.Address: btcutil.Amount(cmd.Amount),
+	if opts.Data != "" {
+		data, err := hex.DecodeString(opts.Data)
+		if err != nil {
+			return nil, &btcjson.ErrDecodeHexString
+		}
+		txOpts.data = data
 	}
 
-	return sendPairs(cmd, "", pairs, 1)
-}
-
-// Channels to manage SendBeforeReceiveHistorySync.
-var SendTxHistSyncChans = struct {
-	add, done, remove chan btcwire.ShaHash
-	access            chan SendTxHistSyncRequest
-}{
-	add:    make(chan btcwire.ShaHash),
-	remove: make(chan btcwire.ShaHash),
-	done:   make(chan btcwire.ShaHash),
-	access: make(chan SendTxHistSyncRequest),
-}
-
-// SendTxHistSyncRequest requests a SendTxHistSyncResponse from
-// SendBeforeReceiveHistorySync.
-type SendTxHistSyncRequest struct {
-	txsha    btcwire.ShaHash
-	response chan SendTxHistSyncResponse
-}
+	if opts.SendAt < 0 || opts.SendAt > math.MaxUint32 {
+		e := btcjson.Error{
+			Code:    btcjson.ErrInvalidParameter.Code,
+			Message: "sendat is out of range",
+		}
+		return nil, &e
+	}
+	txOpts.lockTime = uint32(opts.SendAt)
 
-// SendTxHistSyncResponse is the response
-type SendTxHistSyncResponse struct {
-	c  chan struct{}
-	ok bool
+	return txOpts, nil
 }
 
-// SendBeforeReceiveHistorySync manages a set of transaction hashes
-// created by this wallet.  For each newly added txsha, a channel is
-// created.  Once the send history has been recorded, the txsha should
-// be messaged across done, causing the internal channel to be closed.
-// Before receive history is recorded, access should be used to check
-// if there are or were any goroutines writing send history, and if
-// so, wait until the channel is closed after a done message.
-func SendBeforeReceiveHistorySync(add, done, remove chan btcwire.ShaHash,
-	access chan SendTxHistSyncRequest) {
-
-	m := make(map[btcwire.ShaHash]chan struct{})
-	for {
-		select {
-		case txsha := <-add:
-			m[txsha] = make(chan struct{})
-
-		case txsha := <-remove:
-			delete(m, txsha)
-
-		case txsha := <-done:
-			if c, ok := m[txsha]; ok {
-				close(c)
-			}
+// SendAll handles a sendall extension request by sending every eligible
+// unspent output of an account to a single address.  The fee is subtracted
+// from the sent amount, so no change is created.  Upon success, the TxID
+// for the created transaction is returned.
+func SendAll(icmd btcjson.Cmd) (interface{}, *btcjson.Error) {
+	// Type assert icmd to access parameters.
+	cmd, ok := icmd.(*SendAllCmd)
+	if !ok {
+		return nil, &btcjson.ErrInternal
+	}
 
-		case req := <-access:
-			c, ok := m[req.txsha]
-			req.response <- SendTxHistSyncResponse{c: c, ok: ok}
+	// Check that minconf is positive.
+	if cmd.MinConf < 0 {
+		e := btcjson.Error{
+			Code:    btcjson.ErrInvalidParameter.Code,
+			Message: "minconf must be positive",
 		}
+		return nil, &e
 	}
-}
 
-func handleSendRawTxReply(icmd btcjson.Cmd, txIDStr string, a *Account, txInfo *CreatedTx) (interface{}, *btcjson.Error) {
-	// Add to transaction store.
-	txr, err := a.TxStore.InsertTx(txInfo.tx, nil)
-	if err != nil {
-		log.Warnf("Error adding sent tx history: %v", err)
-		return nil, &btcjson.ErrInternal
+	txOpts, jsonErr := sendTxOptions(cmd.Options)
+	if jsonErr != nil {
+		return nil, jsonErr
 	}
-	debits, err := txr.AddDebits(txInfo.inputs)
+
+	a, err := AcctMgr.Account(cmd.FromAccount)
 	if err != nil {
-		log.Warnf("Error adding sent tx history: %v", err)
-		return nil, &btcjson.ErrInternal
+		return nil, &btcjson.ErrWalletInvalidAccountName
 	}
-	AcctMgr.ds.ScheduleTxStoreWrite(a)
 
-	// Notify frontends of new SendTx.
-	bs, err := GetCurBlock()
-	if err == nil {
-		ltr, err := debits.ToJSON(a.Name(), bs.Height, a.Net())
-		if err != nil {
-			log.Warnf("Error adding sent tx history: %v", err)
-			return nil, &btcjson.ErrInternal
+	// Spend every eligible output.
+	unspent, err := a.TxStore.UnspentOutputs()
+	if err != nil {
+		e := btcjson.Error{
+			Code:    btcjson.ErrInternal.Code,
+			Message: err.Error(),
 		}
-		for _, details := range ltr {
-			NotifyNewTxDetails(allClients, a.Name(), details)
+		return nil, &e
+	}
+	eligible, err := a.eligibleCredits(unspent, cmd.MinConf)
+	if err != nil {
+		e := btcjson.Error{
+			Code:    btcjson.ErrInternal.Code,
+			Message: err.Error(),
 		}
+		return nil, &e
 	}
-
-	// Signal that received notifiations are ok to add now.
-	SendTxHistSyncChans.done <- *txInfo.tx.Sha()
-
-	// Disk sync tx and utxo stores.
-	if err := AcctMgr.ds.FlushAccount(a); err != nil {
-		log.Errorf("cannot write account: %v", err)
+	if len(eligible) == 0 {
+		e := btcjson.Error{
+			Code:    btcjson.ErrWallet.Code,
+			Message: ErrInsufficientFunds.Error(),
+		}
+		return nil, &e
 	}
+	var total btcutil.Amount
+	for _, c := range eligible {
+		total += c.Amount()
+	}
+	txOpts.inputs = eligible
+	txOpts.subtractFeeFrom = []string{cmd.ToAddress}
 
-	// Notify all frontends of account's new unconfirmed and
-	// confirmed balance.
-	confirmed := a.CalculateBalance(1)
-	unconfirmed := a.CalculateBalance(0) - confirmed
-	NotifyWalletBalance(allClients, a.name, confirmed)
-	NotifyWalletBalanceUnconfirmed(allClients, a.name, unconfirmed)
+	pairs := map[string]btcutil.Amount{
+		cmd.ToAddress: total,
+	}
 
-	// The comments to be saved differ based on the underlying type
-	// of the cmd, so switch on the type to check whether it is a
-	// SendFromCmd or SendManyCmd.
-	//
-	// TODO(jrick): If message succeeded in being sent, save the
-	// transaction details with comments.
-	switch cmd := icmd.(type) {
-	case *btcjson.SendFromCmd:
-		_ = cmd.Comment
-		_ = cmd.CommentTo
+	return sendPairs(cmd, cmd.FromAccount, pairs, cmd.MinConf, txOpts)
+}
 
-	case *btcjson.SendManyCmd:
-		_ = cmd.Comment
-	case *btcjson.SendToAddressCmd:
-		_ = cmd.Comment
-		_ = cmd.CommentTo
+// SendData handles a senddata extension request by creating a transaction
+// with a zero-value null data output carrying the requested data.  The
+// transaction is funded like any other send, with leftover inputs returned
+// to a new change address.  Upon success, the TxID for the created
+// transaction is returned.
+func SendData(icmd btcjson.Cmd) (interface{}, *btcjson.Error) {
+	// Type assert icmd to access parameters.
+	cmd, ok := icmd.(*SendDataCmd)
+	if !ok {
+		return nil, &btcjson.ErrInternal
 	}
 
-	log.Infof("Successfully sent transaction %v", txIDStr)
-	return txIDStr, nil
+	// Check that minconf is positive.
+	if cmd.MinConf < 0 {
+		e := btcjson.Error{
+			Code:    btcjson.ErrInvalidParameter.Code,
+			Message: "minconf must be positive",
+		}
+		return nil, &e
+	}
+
+	if cmd.Options != nil && cmd.Options.Data != "" {
+		e := btcjson.Error{
+			Code:    btcjson.ErrInvalidParameter.Code,
+			Message: "data option cannot be used with senddata",
+		}
+		return nil, &e
+	}
+	txOpts, jsonErr := sendTxOptions(cmd.Options)
+	if jsonErr != nil {
+		return nil, jsonErr
+	}
+	data, err := hex.DecodeString(cmd.Data)
+	if err != nil {
+		return nil, &btcjson.ErrDecodeHexString
+	}
+	txOpts.data = data
+
+	pairs := map[string]btcutil.Amount{}
+	return sendPairs(cmd, cmd.FromAccount, pairs, cmd.MinConf, txOpts)
 }
 
-// SetTxFee sets the transaction fee per kilobyte added to transactions.
-func SetTxFee(icmd btcjson.Cmd) (interface{}, *btcjson.Error) {
+// SendFromWithOptions handles a sendfromwithoptions extension request.  It
+// is handled the same as sendfrom, but with additional options, such as the
+// coin selection strategy or fee rate, which control how the transaction
+// is created.
+func SendFromWithOptions(icmd btcjson.Cmd) (interface{}, *btcjson.Error) {
 	// Type assert icmd to access parameters.
-	cmd, ok := icmd.(*btcjson.SetTxFeeCmd)
+	cmd, ok := icmd.(*SendFromWithOptionsCmd)
 	if !ok {
 		return nil, &btcjson.ErrInternal
 	}
 
-	// Check that amount is not negative.
+	// Check that signed integer parameters are positive.
 	if cmd.Amount < 0 {
 		e := btcjson.Error{
-			Code:    btcjson.ErrInvalidParams.Code,
-			Message: "amount cannot be negative",
+			Code:    btcjson.ErrInvalidParameter.Code,
+			Message: "amount must be positive",
+		}
+		return nil, &e
+	}
+	if cmd.MinConf < 0 {
+		e := btcjson.Error{
+			Code:    btcjson.ErrInvalidParameter.Code,
+			Message: "minconf must be positive",
 		}
 		return nil, &e
 	}
 
-	// Set global tx fee.
-	TxFeeIncrement.Lock()
-	TxFeeIncrement.i = btcutil.Amount(cmd.Amount)
-	TxFeeIncrement.Unlock()
+	txOpts, jsonErr := sendTxOptions(cmd.Options)
+	if jsonErr != nil {
+		return nil, jsonErr
+	}
 
-	// A boolean true result is returned upon success.
-	return true, nil
+	// Create map of address and amount pairs.
+	pairs := map[string]btcutil.Amount{
+		cmd.ToAddress: btcutil.Amount(cmd.Amount),
+	}
+
+	return sendPairs(cmd, cmd.FromAccount, pairs, cmd.MinConf, txOpts)
 }
 
-// SignMessage signs the given message with the private key for the given
-// address
-func SignMessage(icmd btcjson.Cmd) (interface{}, *btcjson.Error) {
+// SendManyWithOptions handles a sendmanywithoptions extension request.  It
+// is handled the same as sendmany, but with additional options, such as the
+// coin selection strategy or fee rate, which control how the transaction
+// is created.
+func SendManyWithOptions(icmd btcjson.Cmd) (interface{}, *btcjson.Error) {
 	// Type assert icmd to access parameters.
-	cmd, ok := icmd.(*btcjson.SignMessageCmd)
+	cmd, ok := icmd.(*SendManyWithOptionsCmd)
 	if !ok {
 		return nil, &btcjson.ErrInternal
 	}
 
-	addr, err := btcutil.DecodeAddress(cmd.Address, cfg.Net())
+	// Check that minconf is positive.
+	if cmd.MinConf < 0 {
+		e := btcjson.Error{
+			Code:    btcjson.ErrInvalidParameter.Code,
+			Message: "minconf must be positive",
+		}
+		return nil, &e
+	}
+
+	txOpts, jsonErr := sendTxOptions(cmd.Options)
+	if jsonErr != nil {
+		return nil, jsonErr
+	}
+
+	// Recreate address/amount pairs, using btcutil.Amount.
+	pairs := make(map[string]btcutil.Amount, len(cmd.Amounts))
+	for k, v := range cmd.Amounts {
+		pairs[k] = btcutil.Amount(v)
+	}
+
+	return sendPairs(cmd, cmd.FromAccount, pairs, cmd.MinConf, txOpts)
+}
+
+// SendWithInputs handles a sendwithinputs extension request by creating a
+// new transaction spending exactly the chosen unspent outputs of an account
+// to any number of payment addresses.  Leftover inputs not sent to the
+// payment addresses or a fee for the miner are sent back to a new address in
+// the wallet.  Upon success, the TxID for the created transaction is returned.
+func SendWithInputs(icmd btcjson.Cmd) (interface{}, *btcjson.Error) {
+	// Type assert icmd to access parameters.
+	cmd, ok := icmd.(*SendWithInputsCmd)
+	if !ok {
+		return nil, &btcjson.ErrInternal
+	}
+
+	a, err := AcctMgr.Account(cmd.FromAccount)
 	if err != nil {
-		return nil, &btcjson.Error{
-			Code:    btcjson.ErrParse.Code,
+		return nil, &btcjson.ErrWalletInvalidAccountName
+	}
+
+	if len(cmd.Inputs) == 0 {
+		e := btcjson.Error{
+			Code:    btcjson.ErrInvalidParameter.Code,
+			Message: "no inputs specified",
+		}
+		return nil, &e
+	}
+	ops, jsonErr := parseOutPoints(cmd.Inputs)
+	if jsonErr != nil {
+		return nil, jsonErr
+	}
+	credits, err := a.unspentCredits(ops)
+	if err != nil {
+		// Inputs which can not be spent by the account are errors in
+		// the request rather than in the wallet.
+		code := btcjson.ErrWallet.Code
+		_, invalid := err.(InvalidInputError)
+		if invalid || err == wallet.ErrWatchOnlyAddress {
+			code = btcjson.ErrInvalidParameter.Code
+		}
+		e := btcjson.Error{
+			Code:    code,
+			Message: err.Error(),
+		}
+		return nil, &e
+	}
+
+	txOpts, jsonErr := sendTxOptions(cmd.Options)
+	if jsonErr != nil {
+		return nil, jsonErr
+	}
+	txOpts.inputs = credits
+
+	// Recreate address/amount pairs, using btcutil.Amount.
+	pairs := make(map[string]btcutil.Amount, len(cmd.Amounts))
+	for k, v := range cmd.Amounts {
+		pairs[k] = btcutil.Amount(v)
+	}
+
+	return sendPairs(cmd, cmd.FromAccount, pairs, 0, txOpts)
+}
+
+// SendToAddress handles a sendtoaddress RPC request by creating a new
+// transaction spending unspent transaction outputs for a wallet to another
+// payment address.  Leftover inputs not sent to the payment address or a fee
+// for the miner are sent back to a new address in the wallet.  Upon success,
+// the TxID for the created transaction is returned.
+func SendToAddress(icmd btcjson.Cmd) (interface{}, *btcjson.Error) {
+	// Type assert icmd to access parameters.
+	cmd, ok := icmd.(*btcjson.SendToAddressCmd)
+	if !ok {
+		return nil, &btcjson.ErrInternal
+	}
+
+	// Check that signed integer parameters are positive.
+	if cmd.Amount < 0 {
+		e := btcjson.Error{
+			Code:    btcjson.ErrInvalidParameter.Code,
+			Message: "amount must be positive",
+		}
+		return nil, &e
+	}
+
+	// Mock up map of address and amount pairs.
+	pairs := map[string]btcutil.Amount{
+		cmd.Address: btcutil.Amount(cmd.Amount),
+	}
+
+	return sendPairs(cmd, "", pairs, 1, nil)
+}
+
+// SendToURI handles a sendtouri extension request by paying the amount
+// requested by a BIP0021 payment URI to its address.  The URI must request
+// an amount, and any required parameters not understood by the wallet
+// cause the request to be rejected.  Upon success, the TxID for the created
+// transaction is returned.
+func SendToURI(icmd btcjson.Cmd) (interface{}, *btcjson.Error) {
+	// Type assert icmd to access parameters.
+	cmd, ok := icmd.(*SendToURICmd)
+	if !ok {
+		return nil, &btcjson.ErrInternal
+	}
+
+	uri, err := ParsePaymentURI(cmd.URI, cfg.Net())
+	if err != nil {
+		e := btcjson.Error{
+			Code:    btcjson.ErrInvalidParameter.Code,
 			Message: err.Error(),
 		}
+		return nil, &e
+	}
+	if uri.Amount == 0 {
+		e := btcjson.Error{
+			Code:    btcjson.ErrInvalidParameter.Code,
+			Message: "payment URI does not request an amount",
+		}
+		return nil, &e
+	}
+
+	pairs := map[string]btcutil.Amount{
+		uri.Address.EncodeAddress(): uri.Amount,
+	}
+	reply, jsonErr := sendPairs(cmd, cmd.FromAccount, pairs, 1, nil)
+	if jsonErr != nil {
+		return nil, jsonErr
+	}
+
+	if uri.Label != "" || uri.Message != "" {
+		log.Infof("Paid %v to %s (label %q, message %q)", uri.Amount,
+			uri.Address.EncodeAddress(), uri.Label, uri.Message)
+	}
+	return reply, nil
+}
+
+// Channels to manage SendBeforeReceiveHistorySync.
+var SendTxHistSyncChans = struct {
+	add, done, remove chan btcwire.ShaHash
+	access            chan SendTxHistSyncRequest
+}{
+	add:    make(chan btcwire.ShaHash),
+	remove: make(chan btcwire.ShaHash),
+	done:   make(chan btcwire.ShaHash),
+	access: make(chan SendTxHistSyncRequest),
+}
+
+// SendTxHistSyncRequest requests a SendTxHistSyncResponse from
+// SendBeforeReceiveHistorySync.
+type SendTxHistSyncRequest struct {
+	txsha    btcwire.ShaHash
+	response chan SendTxHistSyncResponse
+}
+
+// SendTxHistSyncResponse is the response
+type SendTxHistSyncResponse struct {
+	c  chan struct{}
+	ok bool
+}
+
+// SendBeforeReceiveHistorySync manages a set of transaction hashes
+// created by this wallet.  For each newly added txsha, a channel is
+// created.  Once the send history has been recorded, the txsha should
+// be messaged across done, causing the internal channel to be closed.
+// Before receive history is recorded, access should be used to check
+// if there are or were any goroutines writing send history, and if
+// so, wait until the channel is closed after a done message.
+func SendBeforeReceiveHistorySync(add, done, remove chan btcwire.ShaHash,
+	access chan SendTxHistSyncRequest) {
+
+	m := make(map[btcwire.ShaHash]chan struct{})
+	for {
+		select {
+		case txsha := <-add:
+			m[txsha] = make(chan struct{})
+
+		case txsha := <-remove:
+			delete(m, txsha)
+
+		case txsha := <-done:
+			if c, ok := m[txsha]; ok {
+				close(c)
+			}
+
+		case req := <-access:
+			c, ok := m[req.txsha]
+			req.response <- SendTxHistSyncResponse{c: c, ok: ok}
+		}
+	}
+}
+
+func handleSendRawTxReply(icmd btcjson.Cmd, txIDStr string, a *Account, txInfo *CreatedTx) (interface{}, *btcjson.Error) {
+	// Add to transaction store.
+	txr, err := a.TxStore.InsertTx(txInfo.tx, nil)
+	if err != nil {
+		log.Warnf("Error adding sent tx history: %v", err)
+		return nil, &btcjson.ErrInternal
+	}
+	debits, err := txr.AddDebits(txInfo.inputs)
+	if err != nil {
+		log.Warnf("Error adding sent tx history: %v", err)
+		return nil, &btcjson.ErrInternal
+	}
+	if txInfo.changeIndex >= 0 {
+		_, err := txr.AddCredit(uint32(txInfo.changeIndex), true)
+		if err != nil {
+			log.Warnf("Error adding change credit for sent "+
+				"tx: %v", err)
+			return nil, &btcjson.ErrInternal
+		}
+	}
+	AcctMgr.ds.ScheduleTxStoreWrite(a)
+
+	// Notify frontends of new SendTx.
+	bs, err := GetCurBlock()
+	if err == nil {
+		ltr, err := debits.ToJSON(a.Name(), bs.Height, a.Net())
+		if err != nil {
+			log.Warnf("Error adding sent tx history: %v", err)
+			return nil, &btcjson.ErrInternal
+		}
+		for _, details := range ltr {
+			NotifyNewTxDetails(allClients, a.Name(), details)
+		}
+	}
+
+	// Signal that received notifiations are ok to add now.
+	SendTxHistSyncChans.done <- *txInfo.tx.Sha()
+
+	// Disk sync tx and utxo stores.
+	if err := AcctMgr.ds.FlushAccount(a); err != nil {
+		log.Errorf("cannot write account: %v", err)
+	}
+
+	// Notify all frontends of account's new unconfirmed and
+	// confirmed balance.
+	confirmed := a.CalculateBalance(1)
+	unconfirmed := a.CalculateBalance(0) - confirmed
+	NotifyWalletBalance(allClients, a.name, confirmed)
+	NotifyWalletBalanceUnconfirmed(allClients, a.name, unconfirmed)
+
+	// The comments to be saved differ based on the underlying type
+	// of the cmd, so switch on the type to check whether it is a
+	// SendFromCmd or SendManyCmd.
+	//
+	// TODO(jrick): If message succeeded in being sent, save the
+	// transaction details with comments.
+	switch cmd := icmd.(type) {
+	case *btcjson.SendFromCmd:
+		_ = cmd.Comment
+		_ = cmd.CommentTo
+
+	case *btcjson.SendManyCmd:
+		_ = cmd.Comment
+	case *btcjson.SendToAddressCmd:
+		_ = cmd.Comment
+		_ = cmd.CommentTo
+	}
+
+	log.Infof("Successfully sent transaction %v", txIDStr)
+	return txIDStr, nil
+}
+
+// SetTxFee sets the transaction fee per kilobyte added to transactions.
+func SetTxFee(icmd btcjson.Cmd) (interface{}, *btcjson.Error) {
+	// Type assert icmd to access parameters.
+	cmd, ok := icmd.(*btcjson.SetTxFeeCmd)
+	if !ok {
+		return nil, &btcjson.ErrInternal
+	}
+
+	// Check that amount is not negative.
+	if cmd.Amount < 0 {
+		e := btcjson.Error{
+			Code:    btcjson.ErrInvalidParams.Code,
+			Message: "amount cannot be negative",
+		}
+		return nil, &e
+	}
+
+	// Set global tx fee.
+	TxFeeIncrement.Lock()
+	TxFeeIncrement.i = btcutil.Amount(cmd.Amount)
+	TxFeeIncrement.Unlock()
+
+	// A boolean true result is returned upon success.
+	return true, nil
+}
+
+// SetTxFeeRate handles a settxfeerate extension request by setting the
+// global transaction fee rate.  Unlike settxfee, which always sets a rate in
+// BTC per kilobyte, the rate may be given in satoshis per byte.
+func SetTxFeeRate(icmd btcjson.Cmd) (interface{}, *btcjson.Error) {
+	// Type assert icmd to access parameters.
+	cmd, ok := icmd.(*SetTxFeeRateCmd)
+	if !ok {
+		return nil, &btcjson.ErrInternal
+	}
+
+	// Check that rate is not negative.
+	if cmd.Rate < 0 {
+		e := btcjson.Error{
+			Code:    btcjson.ErrInvalidParams.Code,
+			Message: "rate cannot be negative",
+		}
+		return nil, &e
+	}
+
+	var rate FeeRate
+	switch cmd.Unit {
+	case FeeUnitPerByte:
+		rate = FeeRatePerByte(cmd.Rate)
+
+	case FeeUnitPerKB:
+		amt, err := btcjson.JSONToAmount(cmd.Rate)
+		if err != nil {
+			e := btcjson.Error{
+				Code:    btcjson.ErrInvalidParams.Code,
+				Message: err.Error(),
+			}
+			return nil, &e
+		}
+		rate = FeeRate(amt)
+
+	default:
+		e := btcjson.Error{
+			Code:    btcjson.ErrInvalidParams.Code,
+			Message: "unit must be one of {byte, kb}",
+		}
+		return nil, &e
+	}
+
+	// Set global tx fee.
+	TxFeeIncrement.Lock()
+	TxFeeIncrement.i = btcutil.Amount(rate)
+	TxFeeIncrement.Unlock()
+
+	// A boolean true result is returned upon success.
+	return true, nil
+}
+
+// SignMessage signs the given message with the private key for the given
+// address
+func SignMessage(icmd btcjson.Cmd) (interface{}, *btcjson.Error) {
+	// Type assert icmd to access parameters.
+	cmd, ok := icmd.(*btcjson.SignMessageCmd)
+	if !ok {
+		return nil, &btcjson.ErrInternal
+	}
+
+	addr, err := btcutil.DecodeAddress(cmd.Address, cfg.Net())
+	if err != nil {
+		return nil, &btcjson.Error{
+			Code:    btcjson.ErrParse.Code,
+			Message: err.Error(),
+		}
+	}
+
+	ainfo, err := AcctMgr.Address(addr)
+	if err != nil {
+		return nil, &btcjson.ErrInvalidAddressOrKey
+	}
+
+	pka := ainfo.(wallet.PubKeyAddress)
+	privkey, err := pka.PrivKey()
+	if err != nil {
+		return nil, &btcjson.Error{
+			Code:    btcjson.ErrWallet.Code,
+			Message: err.Error(),
+		}
+	}
+
+	fullmsg := "Bitcoin Signed Message:\n" + cmd.Message
+	sigbytes, err := btcec.SignCompact(btcec.S256(), privkey,
+		btcwire.DoubleSha256([]byte(fullmsg)), ainfo.Compressed())
+	if err != nil {
+		return nil, &btcjson.Error{
+			Code:    btcjson.ErrWallet.Code,
+			Message: err.Error(),
+		}
+	}
+
+	return base64.StdEncoding.EncodeToString(sigbytes), nil
+}
+
+// AddScheduledPayment handles an addscheduledpayment extension request by
+// scheduling a recurring payment of an amount to an address, repeated
+// every interval of blocks, hours, or days.  The wallet must be unlocked
+// to schedule payments.  Upon success, the ID of the payment is returned.
+func AddScheduledPayment(icmd btcjson.Cmd) (interface{}, *btcjson.Error) {
+	// Type assert icmd to access parameters.
+	cmd, ok := icmd.(*AddScheduledPaymentCmd)
+	if !ok {
+		return nil, &btcjson.ErrInternal
+	}
+
+	a, err := AcctMgr.Account(cmd.Account)
+	switch err {
+	case nil:
+		break
+
+	case ErrNotFound:
+		return nil, &btcjson.ErrWalletInvalidAccountName
+
+	default: // all other non-nil errors
+		e := btcjson.Error{
+			Code:    btcjson.ErrWallet.Code,
+			Message: err.Error(),
+		}
+		return nil, &e
+	}
+	if a.IsLocked() {
+		return nil, &btcjson.ErrWalletUnlockNeeded
+	}
+
+	addr, err := btcutil.DecodeAddress(cmd.Address, cfg.Net())
+	if err != nil || !addr.IsForNet(cfg.Net()) {
+		return nil, &btcjson.ErrInvalidAddressOrKey
+	}
+	if cmd.Amount <= 0 || cmd.Interval <= 0 {
+		e := btcjson.Error{
+			Code:    btcjson.ErrInvalidParameter.Code,
+			Message: "amount and interval must be positive",
+		}
+		return nil, &e
+	}
+
+	var intervalBlocks int32
+	var interval time.Duration
+	switch cmd.Unit {
+	case IntervalUnitBlocks:
+		intervalBlocks = cmd.Interval
+
+	case IntervalUnitHours:
+		interval = time.Duration(cmd.Interval) * time.Hour
+
+	case IntervalUnitDays:
+		interval = time.Duration(cmd.Interval) * 24 * time.Hour
+
+	default:
+		e := btcjson.Error{
+			Code:    btcjson.ErrInvalidParameter.Code,
+			Message: "unit must be one of {blocks, hours, days}",
+		}
+		return nil, &e
+	}
+
+	id, err := ScheduledPayments.Add(a.Name(), addr.EncodeAddress(),
+		btcutil.Amount(cmd.Amount), intervalBlocks, interval)
+	if err != nil {
+		e := btcjson.Error{
+			Code:    btcjson.ErrWallet.Code,
+			Message: err.Error(),
+		}
+		return nil, &e
+	}
+	return id, nil
+}
+
+// ApproveScheduledPayment handles an approvescheduledpayment extension
+// request by sending every execution of a recurring payment which became
+// due while the wallet was locked, or by skipping them if not approved.
+// Upon success, the TxIDs (or approval and vault IDs) of all sent payments
+// are returned.
+func ApproveScheduledPayment(icmd btcjson.Cmd) (interface{}, *btcjson.Error) {
+	// Type assert icmd to access parameters.
+	cmd, ok := icmd.(*ApproveScheduledPaymentCmd)
+	if !ok {
+		return nil, &btcjson.ErrInternal
+	}
+
+	results, err := ScheduledPayments.Approve(cmd.ID, cmd.Approve)
+	switch err {
+	case nil:
+		return results, nil
+
+	case ErrUnknownScheduledPayment:
+		e := btcjson.Error{
+			Code:    btcjson.ErrInvalidParameter.Code,
+			Message: "no scheduled payment with ID " + cmd.ID,
+		}
+		return nil, &e
+
+	case ErrNotFound:
+		return nil, &btcjson.ErrWalletInvalidAccountName
+
+	case wallet.ErrWalletLocked:
+		return nil, &btcjson.ErrWalletUnlockNeeded
+
+	default:
+		e := btcjson.Error{
+			Code:    btcjson.ErrWallet.Code,
+			Message: "cannot write scheduled payments: " + err.Error(),
+		}
+		return nil, &e
+	}
+}
+
+// ApproveSend handles an approvesend extension request by sending a
+// transaction held for approval.  This may only be requested by clients
+// authenticated with the send approval login.  Upon success, the TxID for
+// the sent transaction is returned.
+func ApproveSend(icmd btcjson.Cmd) (interface{}, *btcjson.Error) {
+	// Type assert icmd to access parameters.
+	cmd, ok := icmd.(*ApproveSendCmd)
+	if !ok {
+		return nil, &btcjson.ErrInternal
+	}
+
+	p := PendingSends.Find(cmd.ID)
+	if p == nil {
+		e := btcjson.Error{
+			Code:    btcjson.ErrInvalidParameter.Code,
+			Message: "no pending send with ID " + cmd.ID,
+		}
+		return nil, &e
+	}
+
+	a, err := AcctMgr.Account(p.tx.account)
+	if err != nil {
+		return nil, &btcjson.ErrWalletInvalidAccountName
+	}
+	createdTx, err := a.heldCreatedTx(p.tx)
+	switch err {
+	case nil:
+		break
+
+	case ErrHeldTxInputSpent:
+		// The transaction can never be sent, so remove it.
+		if err := PendingSends.Remove(p.id); err != nil {
+			log.Errorf("Cannot write pending sends: %v", err)
+		}
+		e := btcjson.Error{
+			Code:    btcjson.ErrWallet.Code,
+			Message: err.Error(),
+		}
+		return nil, &e
+
+	default:
+		e := btcjson.Error{
+			Code:    btcjson.ErrInternal.Code,
+			Message: err.Error(),
+		}
+		return nil, &e
+	}
+
+	reply, jsonErr := sendOrDelayCreatedTx(cmd, a, createdTx)
+	if jsonErr != nil {
+		// Reserve the inputs again so the send may be retried.
+		if _, err := a.holdCreatedTx(createdTx); err != nil {
+			log.Errorf("Cannot reserve pending send inputs: %v", err)
+		}
+		return nil, jsonErr
+	}
+	if err := PendingSends.Remove(p.id); err != nil {
+		log.Errorf("Cannot write pending sends: %v", err)
+	}
+
+	log.Infof("Approved send %s", p.id)
+	return reply, nil
+}
+
+// BumpFee handles a bumpfee extension request by creating and sending a
+// transaction spending the change of an unmined wallet transaction, paying
+// a fee high enough for both transactions to reach the target fee rate, in
+// satoshis per byte.  Upon success, the TxID of the new transaction is
+// returned, or the approval or vault ID if the send is held for approval
+// or delayed by a vault rule.
+func BumpFee(icmd btcjson.Cmd) (interface{}, *btcjson.Error) {
+	// Type assert icmd to access parameters.
+	cmd, ok := icmd.(*BumpFeeCmd)
+	if !ok {
+		return nil, &btcjson.ErrInternal
+	}
+
+	txSha, err := btcwire.NewShaHashFromStr(cmd.TxID)
+	if err != nil {
+		return nil, &btcjson.ErrDecodeHexString
+	}
+	if cmd.TargetRate <= 0 {
+		e := btcjson.Error{
+			Code:    btcjson.ErrInvalidParameter.Code,
+			Message: "targetrate must be positive",
+		}
+		return nil, &e
+	}
+
+	// Find the account which sent the unmined transaction.
+	var a *Account
+	var parent *txstore.TxRecord
+	for _, acct := range AcctMgr.AllAccounts() {
+		r, err := acct.TxStore.UnminedTx(txSha)
+		if err == nil {
+			a, parent = acct, r
+			break
+		}
+	}
+	if a == nil {
+		e := btcjson.Error{
+			Code:    btcjson.ErrInvalidAddressOrKey.Code,
+			Message: "Invalid or non-wallet unmined transaction id",
+		}
+		return nil, &e
+	}
+
+	createdTx, err := a.txBumpingFee(parent, FeeRatePerByte(cmd.TargetRate))
+	switch err {
+	case nil:
+		break
+
+	case wallet.ErrWalletLocked:
+		return nil, &btcjson.ErrWalletUnlockNeeded
+
+	case ErrNoUnspentChange, ErrInsufficientFunds:
+		e := btcjson.Error{
+			Code:    btcjson.ErrWallet.Code,
+			Message: err.Error(),
+		}
+		return nil, &e
+
+	default:
+		if _, ok := err.(PolicyViolationError); ok {
+			e := ErrPolicyViolation
+			e.Message = err.Error()
+			return nil, &e
+		}
+		e := btcjson.Error{
+			Code:    btcjson.ErrInternal.Code,
+			Message: err.Error(),
+		}
+		return nil, &e
+	}
+
+	return sendOrQueueCreatedTx(cmd, a, createdTx)
+}
+
+// CancelVaultSend handles a cancelvaultsend extension request by removing a
+// send delayed by a vault rule before it is broadcast, and releasing its
+// inputs so they may be spent by other transactions.
+func CancelVaultSend(icmd btcjson.Cmd) (interface{}, *btcjson.Error) {
+	// Type assert icmd to access parameters.
+	cmd, ok := icmd.(*CancelVaultSendCmd)
+	if !ok {
+		return nil, &btcjson.ErrInternal
+	}
+
+	v := VaultSends.Find(cmd.ID)
+	if v == nil {
+		e := btcjson.Error{
+			Code:    btcjson.ErrInvalidParameter.Code,
+			Message: "no vault send with ID " + cmd.ID,
+		}
+		return nil, &e
+	}
+	if err := VaultSends.Cancel(v); err != nil {
+		e := btcjson.Error{
+			Code:    btcjson.ErrWallet.Code,
+			Message: err.Error(),
+		}
+		return nil, &e
+	}
+	return nil, nil
+}
+
+// decodePartialTxParam decodes the hex encoded partially signed transaction
+// parameter s of an extension request.
+func decodePartialTxParam(s string) (*PartialTx, *btcjson.Error) {
+	p, err := decodePartialTx(s)
+	if err != nil {
+		return nil, &btcjson.Error{
+			Code:    btcjson.ErrDeserialization.Code,
+			Message: "partially signed transaction decode failed: " + err.Error(),
+		}
+	}
+	return p, nil
+}
+
+// CombinePartialTxs handles a combinepartialtxs extension request by merging
+// the signatures and redeem scripts of several copies of a partially signed
+// transaction, each signed by different wallets, into a single copy.
+func CombinePartialTxs(icmd btcjson.Cmd) (interface{}, *btcjson.Error) {
+	// Type assert icmd to access parameters.
+	cmd, ok := icmd.(*CombinePartialTxsCmd)
+	if !ok {
+		return nil, &btcjson.ErrInternal
+	}
+
+	if len(cmd.Txs) == 0 {
+		e := btcjson.Error{
+			Code:    btcjson.ErrInvalidParameter.Code,
+			Message: "no partially signed transactions specified",
+		}
+		return nil, &e
+	}
+
+	p, jsonErr := decodePartialTxParam(cmd.Txs[0])
+	if jsonErr != nil {
+		return nil, jsonErr
+	}
+	for _, s := range cmd.Txs[1:] {
+		other, jsonErr := decodePartialTxParam(s)
+		if jsonErr != nil {
+			return nil, jsonErr
+		}
+		if err := p.Combine(other); err != nil {
+			e := btcjson.Error{
+				Code:    btcjson.ErrInvalidParameter.Code,
+				Message: err.Error(),
+			}
+			return nil, &e
+		}
+	}
+
+	s, err := p.Hex()
+	if err != nil {
+		e := btcjson.Error{
+			Code:    btcjson.ErrInternal.Code,
+			Message: err.Error(),
+		}
+		return nil, &e
+	}
+	return s, nil
+}
+
+// ConsolidateUTXOs handles a consolidateutxos extension request by merging
+// the smallest unspent outputs of an account into a single output paying a
+// new change address.  At most maxinputs outputs are merged, and no more
+// are added once the fee to spend the next output would exceed the maximum
+// fee rate, in satoshis per byte.  The planned consolidation is returned,
+// and unless the request is a dry run, the transaction is also sent.  If
+// the send is held for approval or delayed by a vault rule, the approval
+// or vault ID replaces the TxID.
+func ConsolidateUTXOs(icmd btcjson.Cmd) (interface{}, *btcjson.Error) {
+	// Type assert icmd to access parameters.
+	cmd, ok := icmd.(*ConsolidateUTXOsCmd)
+	if !ok {
+		return nil, &btcjson.ErrInternal
+	}
+
+	if cmd.MaxInputs < 2 {
+		e := btcjson.Error{
+			Code:    btcjson.ErrInvalidParameter.Code,
+			Message: "maxinputs must be at least 2",
+		}
+		return nil, &e
+	}
+	if cmd.MaxFeeRate < 0 {
+		e := btcjson.Error{
+			Code:    btcjson.ErrInvalidParameter.Code,
+			Message: "maxfeerate cannot be negative",
+		}
+		return nil, &e
+	}
+	if cmd.MinConf < 0 {
+		e := btcjson.Error{
+			Code:    btcjson.ErrInvalidParameter.Code,
+			Message: "minconf must be positive",
+		}
+		return nil, &e
+	}
+
+	a, err := AcctMgr.Account(cmd.Account)
+	if err != nil {
+		return nil, &btcjson.ErrWalletInvalidAccountName
+	}
+
+	plan, err := a.planConsolidation(cmd.MaxInputs,
+		FeeRatePerByte(cmd.MaxFeeRate), cmd.MinConf)
+	switch err {
+	case nil:
+		break
+
+	case ErrNothingToConsolidate:
+		e := btcjson.Error{
+			Code:    btcjson.ErrWallet.Code,
+			Message: err.Error(),
+		}
+		return nil, &e
+
+	default:
+		e := btcjson.Error{
+			Code:    btcjson.ErrInternal.Code,
+			Message: err.Error(),
+		}
+		return nil, &e
+	}
+
+	result := &ConsolidateUTXOsResult{
+		Inputs:  len(plan.inputs),
+		Amount:  (plan.total - plan.fee).ToUnit(btcutil.AmountBTC),
+		Fee:     plan.fee.ToUnit(btcutil.AmountBTC),
+		FeeRate: plan.feeRate.PerByte(),
+		Size:    plan.size,
+	}
+	if cmd.DryRun {
+		return result, nil
+	}
+
+	createdTx, err := a.txConsolidating(plan)
+	switch err {
+	case nil:
+		break
+
+	case wallet.ErrWalletLocked:
+		return nil, &btcjson.ErrWalletUnlockNeeded
+
+	case ErrInsufficientFunds:
+		e := btcjson.Error{
+			Code:    btcjson.ErrWallet.Code,
+			Message: err.Error(),
+		}
+		return nil, &e
+
+	default:
+		if _, ok := err.(PolicyViolationError); ok {
+			e := ErrPolicyViolation
+			e.Message = err.Error()
+			return nil, &e
+		}
+		e := btcjson.Error{
+			Code:    btcjson.ErrInternal.Code,
+			Message: err.Error(),
+		}
+		return nil, &e
+	}
+
+	txID, jsonErr := sendOrQueueCreatedTx(cmd, a, createdTx)
+	if jsonErr != nil {
+		return nil, jsonErr
+	}
+	result.TxID, _ = txID.(string)
+	result.Address = createdTx.changeAddr.EncodeAddress()
+	return result, nil
+}
+
+// CreateEncryptedWallet creates a new account with an encrypted
+// wallet.  If an account with the same name as the requested account
+// name already exists, an invalid account name error is returned to
+// the client.  The reply is the mnemonic encoding of the new wallet's
+// seed, which is required to restore the wallet with restorewallet.
+//
+// Wallets will be created on TestNet3, or MainNet if btcwallet is run with
+// the --mainnet option.
+func CreateEncryptedWallet(icmd btcjson.Cmd) (interface{}, *btcjson.Error) {
+	// Type assert icmd to access parameters.
+	cmd, ok := icmd.(*btcws.CreateEncryptedWalletCmd)
+	if !ok {
+		return nil, &btcjson.ErrInternal
+	}
+
+	mnemonic, err := AcctMgr.CreateEncryptedWallet([]byte(cmd.Passphrase))
+	switch err {
+	case nil:
+		// The mnemonic backup of the new wallet is only shown once,
+		// as the reply upon successful wallet creation.
+		return mnemonic, nil
+
+	case ErrWalletExists:
+		return nil, &btcjson.ErrWalletInvalidAccountName
+
+	case ErrBtcdDisconnected:
+		return nil, &ErrBtcdDisconnected
+
+	default: // all other non-nil errors
+		return nil, &btcjson.ErrInternal
+	}
+}
+
+// RestoreWallet handles a restorewallet extension request by recreating
+// the default account's wallet from the mnemonic returned when it was
+// created, encrypted with a new passphrase.  Addresses are recovered and
+// the blockchain is rescanned for their history.  Restoring fails if any
+// account already exists.
+func RestoreWallet(icmd btcjson.Cmd) (interface{}, *btcjson.Error) {
+	// Type assert icmd to access parameters.
+	cmd, ok := icmd.(*RestoreWalletCmd)
+	if !ok {
+		return nil, &btcjson.ErrInternal
+	}
+
+	err := AcctMgr.RestoreWallet(cmd.Mnemonic, []byte(cmd.Passphrase))
+	switch err {
+	case nil:
+		return nil, nil
+
+	case ErrWalletExists:
+		return nil, &btcjson.ErrWalletInvalidAccountName
+
+	case wallet.ErrInvalidMnemonic:
+		e := btcjson.Error{
+			Code:    btcjson.ErrInvalidParameter.Code,
+			Message: err.Error(),
+		}
+		return nil, &e
+
+	default: // all other non-nil errors
+		e := btcjson.Error{
+			Code:    btcjson.ErrWallet.Code,
+			Message: err.Error(),
+		}
+		return nil, &e
+	}
+}
+
+// BackupShares handles a backupshares extension request by splitting the
+// root key and chaincode of the default account's wallet into shares, any
+// threshold of which restore the wallet with restorefromshares.  The
+// wallet must be unlocked.
+func BackupShares(icmd btcjson.Cmd) (interface{}, *btcjson.Error) {
+	// Type assert icmd to access parameters.
+	cmd, ok := icmd.(*BackupSharesCmd)
+	if !ok {
+		return nil, &btcjson.ErrInternal
+	}
+
+	a, err := AcctMgr.Account("")
+	switch err {
+	case nil:
+		break
+
+	case ErrNotFound:
+		return nil, &btcjson.ErrWalletInvalidAccountName
+
+	default:
+		e := btcjson.Error{
+			Code:    btcjson.ErrWallet.Code,
+			Message: err.Error(),
+		}
+		return nil, &e
+	}
+
+	switch shares, err := a.BackupShares(cmd.Threshold, cmd.Count); err {
+	case nil:
+		return shares, nil
+
+	case wallet.ErrWalletLocked:
+		return nil, &btcjson.ErrWalletUnlockNeeded
+
+	case wallet.ErrInvalidShareCount:
+		e := btcjson.Error{
+			Code:    btcjson.ErrInvalidParameter.Code,
+			Message: err.Error(),
+		}
+		return nil, &e
+
+	default: // all other non-nil errors
+		e := btcjson.Error{
+			Code:    btcjson.ErrWallet.Code,
+			Message: err.Error(),
+		}
+		return nil, &e
+	}
+}
+
+// RestoreFromShares handles a restorefromshares extension request by
+// recreating the default account's wallet from shares returned by
+// backupshares, encrypted with a new passphrase.  As with restorewallet,
+// addresses are recovered and the blockchain is rescanned for their
+// history, and restoring fails if any account already exists.
+func RestoreFromShares(icmd btcjson.Cmd) (interface{}, *btcjson.Error) {
+	// Type assert icmd to access parameters.
+	cmd, ok := icmd.(*RestoreFromSharesCmd)
+	if !ok {
+		return nil, &btcjson.ErrInternal
+	}
+
+	err := AcctMgr.RestoreFromShares(cmd.Shares, []byte(cmd.Passphrase))
+	switch err {
+	case nil:
+		return nil, nil
+
+	case ErrWalletExists:
+		return nil, &btcjson.ErrWalletInvalidAccountName
+
+	case wallet.ErrInvalidShare, wallet.ErrMismatchedShares,
+		wallet.ErrNotEnoughShares:
+		e := btcjson.Error{
+			Code:    btcjson.ErrInvalidParameter.Code,
+			Message: err.Error(),
+		}
+		return nil, &e
+
+	default: // all other non-nil errors
+		e := btcjson.Error{
+			Code:    btcjson.ErrWallet.Code,
+			Message: err.Error(),
+		}
+		return nil, &e
+	}
+}
+
+// CreateInvoice handles a createinvoice extension request by creating an
+// invoice for an amount to be paid to a new address of an account.  The
+// invoice is returned, and websocket clients are notified each time its
+// state changes.
+func CreateInvoice(icmd btcjson.Cmd) (interface{}, *btcjson.Error) {
+	// Type assert icmd to access parameters.
+	cmd, ok := icmd.(*CreateInvoiceCmd)
+	if !ok {
+		return nil, &btcjson.ErrInternal
+	}
+
+	if cmd.Amount <= 0 {
+		e := btcjson.Error{
+			Code:    btcjson.ErrInvalidParameter.Code,
+			Message: "amount must be positive",
+		}
+		return nil, &e
+	}
+	if cmd.Expiry < 0 {
+		e := btcjson.Error{
+			Code:    btcjson.ErrInvalidParameter.Code,
+			Message: "expiry must not be negative",
+		}
+		return nil, &e
+	}
+
+	a, err := AcctMgr.Account(cmd.Account)
+	switch err {
+	case nil:
+		break
+
+	case ErrNotFound:
+		return nil, &btcjson.ErrWalletInvalidAccountName
+
+	default: // all other non-nil errors
+		e := btcjson.Error{
+			Code:    btcjson.ErrWallet.Code,
+			Message: err.Error(),
+		}
+		return nil, &e
+	}
+
+	bs, err := GetCurBlock()
+	if err != nil {
+		e := btcjson.Error{
+			Code:    btcjson.ErrInternal.Code,
+			Message: err.Error(),
+		}
+		return nil, &e
+	}
+
+	expiry := time.Duration(cmd.Expiry) * time.Second
+	inv, err := Invoices.Add(a, btcutil.Amount(cmd.Amount), expiry, cmd.Memo)
+	switch err {
+	case nil:
+		return inv.result(bs.Height), nil
+
+	case ErrInvoiceMemoTooLong:
+		e := btcjson.Error{
+			Code:    btcjson.ErrInvalidParameter.Code,
+			Message: err.Error(),
+		}
+		return nil, &e
+
+	default:
+		e := btcjson.Error{
+			Code:    btcjson.ErrWallet.Code,
+			Message: err.Error(),
+		}
+		return nil, &e
+	}
+}
+
+// CreatePartialTx handles a createpartialtx extension request by creating
+// an unsigned transaction spending unspent outputs of an account to any
+// number of payment addresses, and returning it as a hex encoded partially
+// signed transaction which describes each spent output.  Leftover inputs
+// are sent back to a new change address of the account.  The spent outputs
+// are reserved so they are not chosen by other transactions, and may be
+// released with releasepartialtx if the transaction is abandoned.  The
+// wallet does not need to be unlocked.
+func CreatePartialTx(icmd btcjson.Cmd) (interface{}, *btcjson.Error) {
+	// Type assert icmd to access parameters.
+	cmd, ok := icmd.(*CreatePartialTxCmd)
+	if !ok {
+		return nil, &btcjson.ErrInternal
+	}
+
+	// Check that minconf is positive.
+	if cmd.MinConf < 0 {
+		e := btcjson.Error{
+			Code:    btcjson.ErrInvalidParameter.Code,
+			Message: "minconf must be positive",
+		}
+		return nil, &e
+	}
+
+	txOpts, jsonErr := sendTxOptions(cmd.Options)
+	if jsonErr != nil {
+		return nil, jsonErr
+	}
+	txOpts.unsigned = true
+
+	a, err := AcctMgr.Account(cmd.FromAccount)
+	switch err {
+	case nil:
+		break
+
+	case ErrNotFound:
+		return nil, &btcjson.ErrWalletInvalidAccountName
+
+	default: // all other non-nil errors
+		e := btcjson.Error{
+			Code:    btcjson.ErrWallet.Code,
+			Message: err.Error(),
+		}
+		return nil, &e
+	}
+
+	// Recreate address/amount pairs, using btcutil.Amount.
+	pairs := make(map[string]btcutil.Amount, len(cmd.Amounts))
+	for k, v := range cmd.Amounts {
+		pairs[k] = btcutil.Amount(v)
+	}
+
+	createdTx, err := a.txToPairs(pairs, cmd.MinConf, txOpts)
+	if err != nil {
+		return nil, txToPairsError(err)
+	}
+	if _, err := a.holdCreatedTx(createdTx); err != nil {
+		e := btcjson.Error{
+			Code:    btcjson.ErrWallet.Code,
+			Message: err.Error(),
+		}
+		return nil, &e
+	}
+
+	prevOuts := make([]*btcwire.TxOut, len(createdTx.inputs))
+	for i, c := range createdTx.inputs {
+		prevOuts[i] = c.TxOut()
+	}
+	p := NewPartialTx(createdTx.tx.MsgTx(), prevOuts)
+	s, err := p.Hex()
+	if err != nil {
+		e := btcjson.Error{
+			Code:    btcjson.ErrInternal.Code,
+			Message: err.Error(),
+		}
+		return nil, &e
+	}
+	return s, nil
+}
+
+// FinalizePartialTx handles a finalizepartialtx extension request by
+// building the signature scripts of a fully signed partially signed
+// transaction, and either broadcasting the transaction and returning its
+// ID, or returning the hex encoded transaction if broadcast is false.
+// Transactions spending outputs of an account are broadcast as if created
+// by the account, so they are checked against its spending policy and may
+// be held for approval or delayed by its vault rule, in which case the
+// approval or vault ID is returned instead.
+func FinalizePartialTx(icmd btcjson.Cmd) (interface{}, *btcjson.Error) {
+	// Type assert icmd to access parameters.
+	cmd, ok := icmd.(*FinalizePartialTxCmd)
+	if !ok {
+		return nil, &btcjson.ErrInternal
+	}
+
+	p, jsonErr := decodePartialTxParam(cmd.Hex)
+	if jsonErr != nil {
+		return nil, jsonErr
+	}
+	msgtx, err := p.Finalize()
+	if err != nil {
+		e := btcjson.Error{
+			Code:    btcjson.ErrWallet.Code,
+			Message: err.Error(),
+		}
+		return nil, &e
+	}
+
+	buf := bytes.NewBuffer(nil)
+	buf.Grow(msgtx.SerializeSize())
+	if err := msgtx.Serialize(buf); err != nil {
+		e := btcjson.Error{
+			Code:    btcjson.ErrInternal.Code,
+			Message: err.Error(),
+		}
+		return nil, &e
+	}
+	hextx := hex.EncodeToString(buf.Bytes())
+	if !cmd.Broadcast {
+		return hextx, nil
+	}
+
+	// Outputs reserved by queued transactions must not be spent by any
+	// other transaction sent by the wallet.
+	if spendsQueuedOutput(msgtx) {
+		e := btcjson.Error{
+			Code:    btcjson.ErrWallet.Code,
+			Message: ErrHeldTxInputQueued.Error(),
+		}
+		return nil, &e
+	}
+	holds, err := partialTxHolds(msgtx)
+	if err != nil {
+		e := btcjson.Error{
+			Code:    btcjson.ErrInternal.Code,
+			Message: err.Error(),
+		}
+		return nil, &e
+	}
+	switch len(holds) {
+	case 0:
+		// No wallet outputs are spent.
+		return SendRawTransaction(CurrentServerConn(), hextx)
+
+	case 1:
+		break
+
+	default:
+		e := btcjson.Error{
+			Code:    btcjson.ErrWallet.Code,
+			Message: ErrPartialTxAccounts.Error(),
+		}
+		return nil, &e
+	}
+
+	h := holds[0]
+	a, err := AcctMgr.Account(h.account)
+	if err != nil {
+		return nil, &btcjson.ErrWalletInvalidAccountName
+	}
+	if err := a.checkPolicy(msgtx, h.changeIndex); err != nil {
+		e := ErrPolicyViolation
+		e.Message = err.Error()
+		return nil, &e
+	}
+	createdTx, err := a.heldCreatedTx(h)
+	switch err {
+	case nil:
+		break
+
+	case ErrHeldTxInputSpent:
+		e := btcjson.Error{
+			Code:    btcjson.ErrWallet.Code,
+			Message: err.Error(),
+		}
+		return nil, &e
+
+	default:
+		e := btcjson.Error{
+			Code:    btcjson.ErrInternal.Code,
+			Message: err.Error(),
+		}
+		return nil, &e
+	}
+
+	reply, jsonErr := sendOrQueueCreatedTx(cmd, a, createdTx)
+	if jsonErr != nil {
+		// Reserve the inputs again so the send may be retried.
+		if _, err := a.holdCreatedTx(createdTx); err != nil {
+			log.Errorf("Cannot reserve partially signed transaction "+
+				"inputs: %v", err)
+		}
+		return nil, jsonErr
+	}
+	return reply, nil
+}
+
+// GetSpendPolicy handles a getspendpolicy extension request by returning
+// the spending policy of an account and the amount the account sent during
+// the last 24 hours.
+func GetSpendPolicy(icmd btcjson.Cmd) (interface{}, *btcjson.Error) {
+	// Type assert icmd to access parameters.
+	cmd, ok := icmd.(*GetSpendPolicyCmd)
+	if !ok {
+		return nil, &btcjson.ErrInternal
+	}
+
+	a, err := AcctMgr.Account(cmd.Account)
+	switch err {
+	case nil:
+		break
+
+	case ErrNotFound:
+		return nil, &btcjson.ErrWalletInvalidAccountName
+
+	default: // all other non-nil errors
+		e := btcjson.Error{
+			Code:    btcjson.ErrWallet.Code,
+			Message: err.Error(),
+		}
+		return nil, &e
+	}
+
+	sent := a.sentSince(time.Now().Add(-spendPolicyPeriod))
+	result := &SpendPolicyResult{
+		Sent: sent.ToUnit(btcutil.AmountBTC),
+	}
+	if p := a.policy; p != nil {
+		result.Allowlist = make([]string, 0, len(p.Allowlist))
+		for addrStr := range p.Allowlist {
+			result.Allowlist = append(result.Allowlist, addrStr)
+		}
+		sort.Strings(result.Allowlist)
+		result.MaxPerTx = p.MaxPerTx.ToUnit(btcutil.AmountBTC)
+		result.DailyLimit = p.PeriodLimit.ToUnit(btcutil.AmountBTC)
+	}
+	return result, nil
+}
+
+// GetSweepRules handles a getsweeprules extension request by returning
+// the automatic sweep rule of every account with one, ordered by account
+// name.
+func GetSweepRules(icmd btcjson.Cmd) (interface{}, *btcjson.Error) {
+	_, ok := icmd.(*GetSweepRulesCmd)
+	if !ok {
+		return nil, &btcjson.ErrInternal
+	}
+
+	results := []SweepRuleResult{}
+	for _, a := range AcctMgr.AllAccounts() {
+		r := a.sweepRule
+		if r == nil {
+			continue
+		}
+		results = append(results, SweepRuleResult{
+			Account: a.name,
+			SweepRuleOptions: SweepRuleOptions{
+				HighWater: r.HighWater.ToUnit(btcutil.AmountBTC),
+				LowWater:  r.LowWater.ToUnit(btcutil.AmountBTC),
+				Address:   r.Address,
+				ToAccount: r.ToAccount,
+				MinConf:   int(r.MinConf),
+			},
+		})
+	}
+	sort.Sort(sweepRuleResultsByAccount(results))
+	return results, nil
+}
+
+// sweepRuleResultsByAccount defines the methods needed to satisify
+// sort.Interface to sort sweep rule results by account name.
+type sweepRuleResultsByAccount []SweepRuleResult
+
+func (s sweepRuleResultsByAccount) Len() int {
+	return len(s)
+}
+
+func (s sweepRuleResultsByAccount) Less(i, j int) bool {
+	return s[i].Account < s[j].Account
+}
+
+func (s sweepRuleResultsByAccount) Swap(i, j int) {
+	s[i], s[j] = s[j], s[i]
+}
+
+// ListPendingSends handles a listpendingsends extension request by
+// returning the details of every transaction held for approval.
+func ListPendingSends(icmd btcjson.Cmd) (interface{}, *btcjson.Error) {
+	_, ok := icmd.(*ListPendingSendsCmd)
+	if !ok {
+		return nil, &btcjson.ErrInternal
+	}
+
+	results := make([]PendingSendResult, 0, len(PendingSends.sends))
+	for _, p := range PendingSends.sends {
+		msgtx := p.tx.tx.MsgTx()
+		serializedTx := bytes.NewBuffer(nil)
+		serializedTx.Grow(msgtx.SerializeSize())
+		if err := msgtx.Serialize(serializedTx); err != nil {
+			e := btcjson.Error{
+				Code:    btcjson.ErrInternal.Code,
+				Message: err.Error(),
+			}
+			return nil, &e
+		}
+
+		result := PendingSendResult{
+			ID:      p.id,
+			Account: p.tx.account,
+			TxID:    p.tx.tx.Sha().String(),
+			Created: p.created.Unix(),
+			Amounts: make(map[string]float64),
+			Hex:     hex.EncodeToString(serializedTx.Bytes()),
+		}
+		var outputTotal btcutil.Amount
+		for i, txout := range msgtx.TxOut {
+			outputTotal += btcutil.Amount(txout.Value)
+			if i == p.tx.changeIndex {
+				continue
+			}
+			_, addrs, _, _ := btcscript.ExtractPkScriptAddrs(
+				txout.PkScript, cfg.Net())
+			if len(addrs) != 1 {
+				continue
+			}
+			amt := btcutil.Amount(txout.Value).ToUnit(btcutil.AmountBTC)
+			result.Amounts[addrs[0].EncodeAddress()] += amt
+		}
+
+		// The fee is only known while the account still holds every
+		// input of the transaction.
+		if a, err := AcctMgr.Account(p.tx.account); err == nil {
+			if credits, err := a.heldCredits(p.tx); err == nil {
+				var inputTotal btcutil.Amount
+				for _, c := range credits {
+					inputTotal += c.Amount()
+				}
+				fee := inputTotal - outputTotal
+				result.Fee = fee.ToUnit(btcutil.AmountBTC)
+			}
+		}
+
+		results = append(results, result)
+	}
+	return results, nil
+}
+
+// RecoverAddresses recovers the next n addresses from an account's wallet.
+func RecoverAddresses(icmd btcjson.Cmd) (interface{}, *btcjson.Error) {
+	cmd, ok := icmd.(*btcws.RecoverAddressesCmd)
+	if !ok {
+		return nil, &btcjson.ErrInternal
+	}
+
+	a, err := AcctMgr.Account(cmd.Account)
+	switch err {
+	case nil:
+		break
+
+	case ErrNotFound:
+		return nil, &btcjson.ErrWalletInvalidAccountName
+
+	default: // all other non-nil errors
+		e := btcjson.Error{
+			Code:    btcjson.ErrWallet.Code,
+			Message: err.Error(),
+		}
+		return nil, &e
+	}
+
+	if err := a.RecoverAddresses(cmd.N); err != nil {
+		e := btcjson.Error{
+			Code:    btcjson.ErrWallet.Code,
+			Message: err.Error(),
+		}
+		return nil, &e
+	}
+
+	return nil, nil
+}
+
+// ListScheduledPayments handles a listscheduledpayments extension request
+// by returning every recurring payment and its execution history.
+func ListScheduledPayments(icmd btcjson.Cmd) (interface{}, *btcjson.Error) {
+	_, ok := icmd.(*ListScheduledPaymentsCmd)
+	if !ok {
+		return nil, &btcjson.ErrInternal
+	}
+
+	results := make([]ScheduledPaymentResult, 0, len(ScheduledPayments.payments))
+	for _, p := range ScheduledPayments.payments {
+		result := ScheduledPaymentResult{
+			ID:              p.id,
+			Account:         p.account,
+			Address:         p.address,
+			Amount:          p.amount.ToUnit(btcutil.AmountBTC),
+			IntervalBlocks:  p.intervalBlocks,
+			IntervalSeconds: int64(p.interval / time.Second),
+			NextHeight:      p.nextHeight,
+			Created:         p.created.Unix(),
+			History:         make([]PaymentExecutionResult, 0, len(p.history)),
+		}
+		if !p.nextTime.IsZero() {
+			result.NextTime = p.nextTime.Unix()
+		}
+		for _, e := range p.history {
+			result.History = append(result.History, PaymentExecutionResult{
+				Time:   e.time.Unix(),
+				Height: e.height,
+				State:  e.state.String(),
+				Result: e.result,
+				Error:  e.err,
+			})
+		}
+		results = append(results, result)
+	}
+	return results, nil
+}
+
+// ListVaultSends handles a listvaultsends extension request by returning
+// every send delayed by a vault rule which has not yet been broadcast.
+func ListVaultSends(icmd btcjson.Cmd) (interface{}, *btcjson.Error) {
+	_, ok := icmd.(*ListVaultSendsCmd)
+	if !ok {
+		return nil, &btcjson.ErrInternal
+	}
+
+	results := make([]*VaultSendResult, 0, len(VaultSends.sends))
+	for _, v := range VaultSends.sends {
+		state := VaultSendQueued
+		if v.due {
+			state = VaultSendDue
+		}
+		results = append(results, v.result(state))
+	}
+	return results, nil
+}
+
+// RejectSend handles a rejectsend extension request by removing a
+// transaction held for approval and releasing its inputs so they may be
+// spent by other transactions.
+func RejectSend(icmd btcjson.Cmd) (interface{}, *btcjson.Error) {
+	// Type assert icmd to access parameters.
+	cmd, ok := icmd.(*RejectSendCmd)
+	if !ok {
+		return nil, &btcjson.ErrInternal
+	}
+
+	p := PendingSends.Find(cmd.ID)
+	if p == nil {
+		e := btcjson.Error{
+			Code:    btcjson.ErrInvalidParameter.Code,
+			Message: "no pending send with ID " + cmd.ID,
+		}
+		return nil, &e
+	}
+
+	// If the account no longer exists, there are no inputs to release.
+	if a, err := AcctMgr.Account(p.tx.account); err == nil {
+		if err := a.releaseHeldTx(p.tx); err != nil {
+			e := btcjson.Error{
+				Code:    btcjson.ErrWallet.Code,
+				Message: err.Error(),
+			}
+			return nil, &e
+		}
+	}
+	if err := PendingSends.Remove(p.id); err != nil {
+		e := btcjson.Error{
+			Code:    btcjson.ErrWallet.Code,
+			Message: "cannot write pending sends: " + err.Error(),
+		}
+		return nil, &e
+	}
+
+	log.Infof("Rejected send %s", p.id)
+	return nil, nil
+}
+
+// ReleasePartialTx handles a releasepartialtx extension request by
+// releasing every wallet output spent by an abandoned partially signed
+// transaction, allowing them to be spent by other transactions.
+func ReleasePartialTx(icmd btcjson.Cmd) (interface{}, *btcjson.Error) {
+	// Type assert icmd to access parameters.
+	cmd, ok := icmd.(*ReleasePartialTxCmd)
+	if !ok {
+		return nil, &btcjson.ErrInternal
+	}
+
+	p, jsonErr := decodePartialTxParam(cmd.Hex)
+	if jsonErr != nil {
+		return nil, jsonErr
+	}
+
+	// Outputs reserved by queued transactions are only released by
+	// sending or cancelling the queued transaction.
+	if spendsQueuedOutput(p.Tx) {
+		e := btcjson.Error{
+			Code:    btcjson.ErrWallet.Code,
+			Message: ErrHeldTxInputQueued.Error(),
+		}
+		return nil, &e
+	}
+	holds, err := partialTxHolds(p.Tx)
+	if err != nil {
+		e := btcjson.Error{
+			Code:    btcjson.ErrInternal.Code,
+			Message: err.Error(),
+		}
+		return nil, &e
+	}
+	for _, h := range holds {
+		a, err := AcctMgr.Account(h.account)
+		if err != nil {
+			return nil, &btcjson.ErrWalletInvalidAccountName
+		}
+		if err := a.releaseHeldTx(h); err != nil {
+			e := btcjson.Error{
+				Code:    btcjson.ErrWallet.Code,
+				Message: err.Error(),
+			}
+			return nil, &e
+		}
+	}
+	return nil, nil
+}
+
+// RemoveScheduledPayment handles a removescheduledpayment extension
+// request by removing a recurring payment, including any executions
+// waiting for approval.
+func RemoveScheduledPayment(icmd btcjson.Cmd) (interface{}, *btcjson.Error) {
+	// Type assert icmd to access parameters.
+	cmd, ok := icmd.(*RemoveScheduledPaymentCmd)
+	if !ok {
+		return nil, &btcjson.ErrInternal
+	}
+
+	switch err := ScheduledPayments.Remove(cmd.ID); err {
+	case nil:
+		return nil, nil
+
+	case ErrUnknownScheduledPayment:
+		e := btcjson.Error{
+			Code:    btcjson.ErrInvalidParameter.Code,
+			Message: "no scheduled payment with ID " + cmd.ID,
+		}
+		return nil, &e
+
+	default:
+		e := btcjson.Error{
+			Code:    btcjson.ErrWallet.Code,
+			Message: "cannot write scheduled payments: " + err.Error(),
+		}
+		return nil, &e
+	}
+}
+
+// SetSpendPolicy handles a setspendpolicy extension request by replacing
+// the spending policy of an account.  Every transaction later created by
+// the account is checked against the policy before it is signed.  The
+// wallet must be unlocked to change a policy.
+func SetSpendPolicy(icmd btcjson.Cmd) (interface{}, *btcjson.Error) {
+	// Type assert icmd to access parameters.
+	cmd, ok := icmd.(*SetSpendPolicyCmd)
+	if !ok {
+		return nil, &btcjson.ErrInternal
+	}
+
+	a, err := AcctMgr.Account(cmd.Account)
+	switch err {
+	case nil:
+		break
+
+	case ErrNotFound:
+		return nil, &btcjson.ErrWalletInvalidAccountName
+
+	default: // all other non-nil errors
+		e := btcjson.Error{
+			Code:    btcjson.ErrWallet.Code,
+			Message: err.Error(),
+		}
+		return nil, &e
+	}
+
+	opts := cmd.Policy
+	if opts.MaxPerTx < 0 || opts.DailyLimit < 0 {
+		e := btcjson.Error{
+			Code:    btcjson.ErrInvalidParameter.Code,
+			Message: "policy limits must not be negative",
+		}
+		return nil, &e
+	}
+	maxPerTx, err := btcjson.JSONToAmount(opts.MaxPerTx)
+	if err != nil {
+		e := btcjson.Error{
+			Code:    btcjson.ErrInvalidParameter.Code,
+			Message: "invalid maxpertx: " + err.Error(),
+		}
+		return nil, &e
+	}
+	dailyLimit, err := btcjson.JSONToAmount(opts.DailyLimit)
+	if err != nil {
+		e := btcjson.Error{
+			Code:    btcjson.ErrInvalidParameter.Code,
+			Message: "invalid dailylimit: " + err.Error(),
+		}
+		return nil, &e
+	}
+
+	// A policy without any settings removes the account's policy.
+	var policy *SpendPolicy
+	if len(opts.Allowlist) != 0 || maxPerTx != 0 || dailyLimit != 0 {
+		policy = &SpendPolicy{
+			Allowlist:   make(map[string]struct{}, len(opts.Allowlist)),
+			MaxPerTx:    btcutil.Amount(maxPerTx),
+			PeriodLimit: btcutil.Amount(dailyLimit),
+		}
+		for _, addrStr := range opts.Allowlist {
+			addr, err := btcutil.DecodeAddress(addrStr, cfg.Net())
+			if err != nil || !addr.IsForNet(cfg.Net()) {
+				e := btcjson.Error{
+					Code:    btcjson.ErrInvalidAddressOrKey.Code,
+					Message: "invalid allowed address " + addrStr,
+				}
+				return nil, &e
+			}
+			policy.Allowlist[addr.EncodeAddress()] = struct{}{}
+		}
+	}
+
+	switch err := a.SetPolicy(policy); err {
+	case nil:
+		return nil, nil
+
+	case wallet.ErrWalletLocked:
+		return nil, &btcjson.ErrWalletUnlockNeeded
+
+	default:
+		e := btcjson.Error{
+			Code:    btcjson.ErrWallet.Code,
+			Message: "cannot write spending policy: " + err.Error(),
+		}
+		return nil, &e
+	}
+}
+
+// SetSweepRule handles a setsweeprule extension request by replacing the
+// automatic sweep rule of an account.  Each time a block is connected and
+// the account's balance is above the high water mark, the excess down to
+// the low water mark is sent to the rule's address or account.  A rule
+// without a high water mark removes the account's rule.
+func SetSweepRule(icmd btcjson.Cmd) (interface{}, *btcjson.Error) {
+	// Type assert icmd to access parameters.
+	cmd, ok := icmd.(*SetSweepRuleCmd)
+	if !ok {
+		return nil, &btcjson.ErrInternal
 	}
 
-	ainfo, err := AcctMgr.Address(addr)
-	if err != nil {
-		return nil, &btcjson.ErrInvalidAddressOrKey
-	}
+	a, err := AcctMgr.Account(cmd.Account)
+	switch err {
+	case nil:
+		break
 
-	pka := ainfo.(wallet.PubKeyAddress)
-	privkey, err := pka.PrivKey()
-	if err != nil {
-		return nil, &btcjson.Error{
+	case ErrNotFound:
+		return nil, &btcjson.ErrWalletInvalidAccountName
+
+	default: // all other non-nil errors
+		e := btcjson.Error{
 			Code:    btcjson.ErrWallet.Code,
 			Message: err.Error(),
 		}
+		return nil, &e
 	}
 
-	fullmsg := "Bitcoin Signed Message:\n" + cmd.Message
-	sigbytes, err := btcec.SignCompact(btcec.S256(), privkey,
-		btcwire.DoubleSha256([]byte(fullmsg)), ainfo.Compressed())
-	if err != nil {
-		return nil, &btcjson.Error{
-			Code:    btcjson.ErrWallet.Code,
-			Message: err.Error(),
+	opts := cmd.Rule
+	var rule *SweepRule
+	if opts.HighWater != 0 {
+		rule, err = sweepRuleFromOptions(a, opts)
+		if err != nil {
+			e := btcjson.Error{
+				Code:    btcjson.ErrInvalidParameter.Code,
+				Message: err.Error(),
+			}
+			return nil, &e
 		}
 	}
 
-	return base64.StdEncoding.EncodeToString(sigbytes), nil
+	switch err := a.SetSweepRule(rule); err {
+	case nil:
+		return nil, nil
+
+	case wallet.ErrWalletLocked:
+		return nil, &btcjson.ErrWalletUnlockNeeded
+
+	default:
+		e := btcjson.Error{
+			Code:    btcjson.ErrWallet.Code,
+			Message: "cannot write sweep rule: " + err.Error(),
+		}
+		return nil, &e
+	}
 }
 
-// CreateEncryptedWallet creates a new account with an encrypted
-// wallet.  If an account with the same name as the requested account
-// name already exists, an invalid account name error is returned to
-// the client.
-//
-// Wallets will be created on TestNet3, or MainNet if btcwallet is run with
-// the --mainnet option.
-func CreateEncryptedWallet(icmd btcjson.Cmd) (interface{}, *btcjson.Error) {
-	// Type assert icmd to access parameters.
-	cmd, ok := icmd.(*btcws.CreateEncryptedWalletCmd)
-	if !ok {
-		return nil, &btcjson.ErrInternal
+// sweepRuleFromOptions validates the options of a setsweeprule request
+// for account a and returns the described rule.
+func sweepRuleFromOptions(a *Account, opts *SweepRuleOptions) (*SweepRule, error) {
+	highWater, err := btcjson.JSONToAmount(opts.HighWater)
+	if err != nil {
+		return nil, fmt.Errorf("invalid highwater: %v", err)
+	}
+	lowWater, err := btcjson.JSONToAmount(opts.LowWater)
+	if err != nil {
+		return nil, fmt.Errorf("invalid lowwater: %v", err)
+	}
+	if lowWater < 0 || highWater <= lowWater {
+		return nil, errors.New("highwater must be greater than " +
+			"lowwater, and lowwater must not be negative")
+	}
+	if opts.MinConf < 0 {
+		return nil, errors.New("minconf must not be negative")
 	}
 
-	err := AcctMgr.CreateEncryptedWallet([]byte(cmd.Passphrase))
-	switch err {
-	case nil:
-		// A nil reply is sent upon successful wallet creation.
-		return nil, nil
+	rule := &SweepRule{
+		HighWater: btcutil.Amount(highWater),
+		LowWater:  btcutil.Amount(lowWater),
+		MinConf:   int32(opts.MinConf),
+	}
+	switch {
+	case opts.Address != "" && opts.ToAccount != "":
+		return nil, errors.New("only one of address and toaccount " +
+			"may be set")
+
+	case opts.Address != "":
+		addr, err := btcutil.DecodeAddress(opts.Address, cfg.Net())
+		if err != nil || !addr.IsForNet(cfg.Net()) {
+			return nil, errors.New("invalid address " + opts.Address)
+		}
+		rule.Address = addr.EncodeAddress()
 
-	case ErrWalletExists:
-		return nil, &btcjson.ErrWalletInvalidAccountName
+	case opts.ToAccount == "":
+		return nil, errors.New("one of address and toaccount must " +
+			"be set")
 
-	case ErrBtcdDisconnected:
-		return nil, &ErrBtcdDisconnected
+	case opts.ToAccount == a.name:
+		return nil, errors.New("cannot sweep to the same account")
 
-	default: // all other non-nil errors
-		return nil, &btcjson.ErrInternal
+	default:
+		if _, err := AcctMgr.Account(opts.ToAccount); err != nil {
+			return nil, errors.New("unknown account " + opts.ToAccount)
+		}
+		rule.ToAccount = opts.ToAccount
 	}
+	return rule, nil
 }
 
-// RecoverAddresses recovers the next n addresses from an account's wallet.
-func RecoverAddresses(icmd btcjson.Cmd) (interface{}, *btcjson.Error) {
-	cmd, ok := icmd.(*btcws.RecoverAddressesCmd)
+// SetVaultRule handles a setvaultrule extension request by replacing the
+// vault rule of an account.  Sends from the account of at least the
+// threshold amount are delayed by a number of blocks or hours before they
+// are broadcast, and may be cancelled until then.  A zero delay removes
+// the rule.
+func SetVaultRule(icmd btcjson.Cmd) (interface{}, *btcjson.Error) {
+	// Type assert icmd to access parameters.
+	cmd, ok := icmd.(*SetVaultRuleCmd)
 	if !ok {
 		return nil, &btcjson.ErrInternal
 	}
@@ -1724,15 +4036,47 @@ func RecoverAddresses(icmd btcjson.Cmd) (interface{}, *btcjson.Error) {
 		return nil, &e
 	}
 
-	if err := a.RecoverAddresses(cmd.N); err != nil {
+	if cmd.Threshold < 0 || cmd.Delay < 0 {
 		e := btcjson.Error{
-			Code:    btcjson.ErrWallet.Code,
-			Message: err.Error(),
+			Code:    btcjson.ErrInvalidParameter.Code,
+			Message: "threshold and delay must not be negative",
 		}
 		return nil, &e
 	}
 
-	return nil, nil
+	var rule *VaultRule
+	if cmd.Delay != 0 {
+		rule = &VaultRule{Threshold: btcutil.Amount(cmd.Threshold)}
+		switch cmd.Unit {
+		case VaultDelayUnitBlocks:
+			rule.DelayBlocks = cmd.Delay
+
+		case VaultDelayUnitHours:
+			rule.DelayTime = time.Duration(cmd.Delay) * time.Hour
+
+		default:
+			e := btcjson.Error{
+				Code:    btcjson.ErrInvalidParameter.Code,
+				Message: "unit must be one of {blocks, hours}",
+			}
+			return nil, &e
+		}
+	}
+
+	switch err := a.SetVaultRule(rule); err {
+	case nil:
+		return nil, nil
+
+	case wallet.ErrWalletLocked:
+		return nil, &btcjson.ErrWalletUnlockNeeded
+
+	default:
+		e := btcjson.Error{
+			Code:    btcjson.ErrWallet.Code,
+			Message: "cannot write vault rule: " + err.Error(),
+		}
+		return nil, &e
+	}
 }
 
 // pendingTx is used for async fetching of transaction dependancies in
@@ -1748,6 +4092,73 @@ type keyInfo struct {
 	compressed bool
 }
 
+// signingKeys returns the callback used by btcscript to look up the private
+// key for an address when signing transaction inputs.  If keys is not
+// empty, only those keys are used.  Otherwise, keys are looked up in the
+// wallet.
+func signingKeys(keys map[string]keyInfo) btcscript.KeyClosure {
+	return func(addr btcutil.Address) (*ecdsa.PrivateKey, bool, error) {
+		if len(keys) != 0 {
+			info, ok := keys[addr.EncodeAddress()]
+			if !ok {
+				return nil, false,
+					errors.New("no key for address")
+			}
+			return info.key, info.compressed, nil
+		}
+		address, err := AcctMgr.Address(addr)
+		if err != nil {
+			return nil, false, err
+		}
+
+		pka, ok := address.(wallet.PubKeyAddress)
+		if !ok {
+			return nil, false, errors.New("address is not " +
+				"a pubkey address")
+		}
+
+		key, err := pka.PrivKey()
+		if err != nil {
+			return nil, false, err
+		}
+
+		return key, pka.Compressed(), nil
+	}
+}
+
+// signingScripts returns the callback used by btcscript to look up the
+// redeem script for a P2SH address when signing transaction inputs.  If
+// keys is not empty, only the scripts in scripts are used.  Otherwise,
+// scripts are looked up in the wallet.
+func signingScripts(keys map[string]keyInfo, scripts map[string][]byte) btcscript.ScriptClosure {
+	return func(addr btcutil.Address) ([]byte, error) {
+		// If keys were provided then we can only use the
+		// scripts provided with our inputs, too.
+		if len(keys) != 0 {
+			script, ok := scripts[addr.EncodeAddress()]
+			if !ok {
+				return nil, errors.New("no script for " +
+					"address")
+			}
+			return script, nil
+		}
+		address, err := AcctMgr.Address(addr)
+		if err != nil {
+			return nil, err
+		}
+		sa, ok := address.(wallet.ScriptAddress)
+		if !ok {
+			return nil, errors.New("addres is not a script" +
+				" address")
+		}
+
+		// TODO(oga) we could possible speed things up further
+		// by returning the addresses, class and nrequired here
+		// thus avoiding recomputing them.
+		return sa.Script(), nil
+	}
+}
+
 // SignRawTransaction handles the signrawtransaction command.
 func SignRawTransaction(icmd btcjson.Cmd) (interface{}, *btcjson.Error) {
 	cmd, ok := icmd.(*btcjson.SignRawTransactionCmd)
@@ -1961,6 +4372,11 @@ func SignRawTransaction(icmd btcjson.Cmd) (interface{}, *btcjson.Error) {
 		}
 	}
 
+	// Set up our callbacks that we pass to btcscript so it can look up
+	// the appropriate keys and scripts by address.
+	getKey := signingKeys(keys)
+	getScript := signingScripts(keys, scripts)
+
 	// All args collected. Now we can sign all the inputs that we can.
 	// `complete' denotes that we successfully signed all outputs and that
 	// all scripts will run to completion. This is returned as part of the
@@ -1979,65 +4395,6 @@ func SignRawTransaction(icmd btcjson.Cmd) (interface{}, *btcjson.Error) {
 			}
 		}
 
-		// Set up our callbacks that we pass to btcscript so it can
-		// look up the appropriate keys and scripts by address.
-		getKey := btcscript.KeyClosure(func(addr btcutil.Address) (
-			*ecdsa.PrivateKey, bool, error) {
-			if len(keys) != 0 {
-				info, ok := keys[addr.EncodeAddress()]
-				if !ok {
-					return nil, false,
-						errors.New("no key for address")
-				}
-				return info.key, info.compressed, nil
-			}
-			address, err := AcctMgr.Address(addr)
-			if err != nil {
-				return nil, false, err
-			}
-
-			pka, ok := address.(wallet.PubKeyAddress)
-			if !ok {
-				return nil, false, errors.New("address is not " +
-					"a pubkey address")
-			}
-
-			key, err := pka.PrivKey()
-			if err != nil {
-				return nil, false, err
-			}
-
-			return key, pka.Compressed(), nil
-		})
-
-		getScript := btcscript.ScriptClosure(func(
-			addr btcutil.Address) ([]byte, error) {
-			// If keys were provided then we can only use the
-			// scripts provided with our inputs, too.
-			if len(keys) != 0 {
-				script, ok := scripts[addr.EncodeAddress()]
-				if !ok {
-					return nil, errors.New("no script for " +
-						"address")
-				}
-				return script, nil
-			}
-			address, err := AcctMgr.Address(addr)
-			if err != nil {
-				return nil, err
-			}
-			sa, ok := address.(wallet.ScriptAddress)
-			if !ok {
-				return nil, errors.New("addres is not a script" +
-					" address")
-			}
-
-			// TODO(oga) we could possible speed things up further
-			// by returning the addresses, class and nrequired here
-			// thus avoiding recomputing them.
-			return sa.Script(), nil
-		})
-
 		// SigHashSingle inputs can only be signed if there's a
 		// corresponding output. However this could be already signed,
 		// so we always verify the output.
@@ -2079,6 +4436,146 @@ func SignRawTransaction(icmd btcjson.Cmd) (interface{}, *btcjson.Error) {
 	}, nil
 }
 
+// SignPartialTx handles a signpartialtx extension request by adding a
+// signature to each input of a partially signed transaction for every
+// wallet key which may sign it, using the same key and script lookup as
+// signrawtransaction.  Inputs which can not be signed by the wallet are
+// left for other signers.  Transactions violating the spending policy of
+// an account whose outputs are spent are not signed.  The updated partially
+// signed transaction is returned along with the number of added signatures
+// and whether the transaction may be finalized.
+func SignPartialTx(icmd btcjson.Cmd) (interface{}, *btcjson.Error) {
+	// Type assert icmd to access parameters.
+	cmd, ok := icmd.(*SignPartialTxCmd)
+	if !ok {
+		return nil, &btcjson.ErrInternal
+	}
+
+	p, jsonErr := decodePartialTxParam(cmd.Hex)
+	if jsonErr != nil {
+		return nil, jsonErr
+	}
+
+	// Refuse to sign if the transaction violates the spending policy of
+	// any account whose outputs it spends.
+	holds, err := partialTxHolds(p.Tx)
+	if err != nil {
+		e := btcjson.Error{
+			Code:    btcjson.ErrInternal.Code,
+			Message: err.Error(),
+		}
+		return nil, &e
+	}
+	for _, h := range holds {
+		a, err := AcctMgr.Account(h.account)
+		if err != nil {
+			return nil, &btcjson.ErrWalletInvalidAccountName
+		}
+		if err := a.checkPolicy(p.Tx, h.changeIndex); err != nil {
+			e := ErrPolicyViolation
+			e.Message = err.Error()
+			return nil, &e
+		}
+	}
+
+	signed, err := p.Sign(signingKeys(nil), signingScripts(nil, nil))
+	switch err {
+	case nil:
+		break
+
+	case wallet.ErrWalletLocked:
+		return nil, &btcjson.ErrWalletUnlockNeeded
+
+	default:
+		e := btcjson.Error{
+			Code:    btcjson.ErrWallet.Code,
+			Message: err.Error(),
+		}
+		return nil, &e
+	}
+	_, err = p.Finalize()
+	complete := err == nil
+
+	s, err := p.Hex()
+	if err != nil {
+		e := btcjson.Error{
+			Code:    btcjson.ErrInternal.Code,
+			Message: err.Error(),
+		}
+		return nil, &e
+	}
+	return SignPartialTxResult{
+		Hex:      s,
+		Signed:   signed,
+		Complete: complete,
+	}, nil
+}
+
+// SweepPrivKey handles the sweepprivkey extension command by sending all
+// funds spendable by a private key which is not part of the wallet to a new
+// address of an account.  The key is never saved by the wallet.  Finding
+// the key's unspent outputs requires a rescan, so the sweep finishes in the
+// background, and the reply is the address being swept.
+func SweepPrivKey(icmd btcjson.Cmd) (interface{}, *btcjson.Error) {
+	// Type assert icmd to access parameters.
+	cmd, ok := icmd.(*SweepPrivKeyCmd)
+	if !ok {
+		return nil, &btcjson.ErrInternal
+	}
+
+	// Check that the account exists before searching for any outputs.
+	_, err := AcctMgr.Account(cmd.Account)
+	switch err {
+	case nil:
+		break
+
+	case ErrNotFound:
+		return nil, &btcjson.ErrWalletInvalidAccountName
+
+	default:
+		e := btcjson.Error{
+			Code:    btcjson.ErrWallet.Code,
+			Message: err.Error(),
+		}
+		return nil, &e
+	}
+
+	if cmd.StartHeight < 0 {
+		e := btcjson.Error{
+			Code:    btcjson.ErrInvalidParameter.Code,
+			Message: "startheight cannot be negative",
+		}
+		return nil, &e
+	}
+
+	pk, net, compressed, err := btcutil.DecodePrivateKey(cmd.PrivKey)
+	if err != nil || net != cfg.Net() {
+		return nil, &btcjson.ErrInvalidAddressOrKey
+	}
+
+	addr, err := StartSweep(cmd.Account, pk, compressed, cmd.StartHeight)
+	switch err {
+	case nil:
+		break
+
+	case ErrSweepWalletKey:
+		e := btcjson.Error{
+			Code:    btcjson.ErrInvalidParameter.Code,
+			Message: err.Error(),
+		}
+		return nil, &e
+
+	default:
+		e := btcjson.Error{
+			Code:    btcjson.ErrWallet.Code,
+			Message: err.Error(),
+		}
+		return nil, &e
+	}
+
+	return addr.EncodeAddress(), nil
+}
+
 // ValidateAddress handles the validateaddress command.
 func ValidateAddress(icmd btcjson.Cmd) (interface{}, *btcjson.Error) {
 	cmd, ok := icmd.(*btcjson.ValidateAddressCmd)
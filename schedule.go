@@ -0,0 +1,573 @@
+/*
+ * Copyright (c) 2014 Conformal Systems LLC <info@conformal.com>
+ *
+ * Permission to use, copy, modify, and distribute this software for any
+ * purpose with or without fee is hereby granted, provided that the above
+ * copyright notice and this permission notice appear in all copies.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+ * WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+ * MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ * ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+ * WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ * ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+ * OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ */
+
+package main
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"github.com/conformal/btcutil"
+	"github.com/conformal/btcwallet/wallet"
+	"io"
+	"os"
+	"time"
+)
+
+// scheduledPaymentsFilename is the name of the file in the network
+// directory holding recurring payments and their execution history.
+const scheduledPaymentsFilename = "scheduledpayments.bin"
+
+// scheduledPaymentsVersion is the current version of the serialized
+// scheduled payments.
+const scheduledPaymentsVersion uint32 = 1
+
+// maxScheduledPayments is the maximum number of recurring payments read
+// from saved scheduled payments.
+const maxScheduledPayments = 100000
+
+// maxPaymentExecutions is the maximum number of executions kept in the
+// history of each recurring payment.  Older executions are discarded,
+// except for those still waiting for approval.
+const maxPaymentExecutions = 100
+
+// maxSavedPaymentExecutions is the maximum number of executions read from
+// the history of a saved recurring payment.  This is larger than
+// maxPaymentExecutions, as executions waiting for approval are never
+// discarded.
+const maxSavedPaymentExecutions = 100000
+
+// maxPaymentResultLen is the maximum length of the result of an execution
+// read from the history of a saved recurring payment, which is the length
+// of a hex encoded TxID.
+const maxPaymentResultLen = 64
+
+// maxPaymentErrorLen is the maximum length of an error message read from
+// the history of a saved recurring payment.
+const maxPaymentErrorLen = 4096
+
+// ErrUnknownScheduledPayment describes an error where a client refers to a
+// recurring payment which does not exist.
+var ErrUnknownScheduledPayment = errors.New("no scheduled payment with ID")
+
+// PaymentExecutionState describes the outcome of a single execution of a
+// recurring payment.
+type PaymentExecutionState uint8
+
+// Possible states of a payment execution.
+const (
+	// PaymentSending describes an execution which is being sent.  It is
+	// saved before the payment is sent, so a payment interrupted by a
+	// restart is never sent a second time.
+	PaymentSending PaymentExecutionState = iota
+
+	// PaymentSent describes an execution which was sent, or held for
+	// send approval or by a vault rule.
+	PaymentSent
+
+	// PaymentPending describes an execution which became due while the
+	// wallet was locked, and which waits to be approved.
+	PaymentPending
+
+	// PaymentFailed describes an execution which could not be sent.
+	PaymentFailed
+
+	// PaymentSkipped describes an execution which was not approved.
+	PaymentSkipped
+
+	// PaymentUnknown describes an execution which was being sent when
+	// the wallet was stopped.  It may or may not have been sent.
+	PaymentUnknown
+)
+
+var paymentExecutionStateStrings = [...]string{
+	PaymentSending: "sending",
+	PaymentSent:    "sent",
+	PaymentPending: "pending",
+	PaymentFailed:  "failed",
+	PaymentSkipped: "skipped",
+	PaymentUnknown: "unknown",
+}
+
+// String returns the string used to describe the state in JSON results.
+func (s PaymentExecutionState) String() string {
+	if int(s) >= len(paymentExecutionStateStrings) {
+		return "invalid"
+	}
+	return paymentExecutionStateStrings[s]
+}
+
+// paymentExecution records a single execution of a recurring payment.
+// Result is the TxID of a sent payment, or the ID returned if the payment
+// was held for send approval or by a vault rule.
+type paymentExecution struct {
+	time   time.Time
+	height int32
+	state  PaymentExecutionState
+	result string
+	err    string
+}
+
+// scheduledPayment is a payment of amount to address from account, which
+// is repeated every intervalBlocks blocks or every interval.  Exactly one
+// of the intervals is non-zero.
+type scheduledPayment struct {
+	id             string
+	account        string
+	address        string
+	amount         btcutil.Amount
+	intervalBlocks int32
+	interval       time.Duration
+	nextHeight     int32
+	nextTime       time.Time
+	created        time.Time
+	history        []*paymentExecution
+}
+
+// isDue returns whether the payment is due once the block at height is
+// connected at time now.
+func (p *scheduledPayment) isDue(height int32, now time.Time) bool {
+	if p.intervalBlocks != 0 {
+		return height >= p.nextHeight
+	}
+	return !now.Before(p.nextTime)
+}
+
+// advance moves the next due height or time past height or now.  If more
+// than one interval has passed, as happens when the wallet was not running
+// for some time, the missed executions are skipped so a payment is made
+// only once.
+func (p *scheduledPayment) advance(height int32, now time.Time) {
+	if p.intervalBlocks != 0 {
+		for p.nextHeight <= height {
+			p.nextHeight += p.intervalBlocks
+		}
+		return
+	}
+	for !p.nextTime.After(now) {
+		p.nextTime = p.nextTime.Add(p.interval)
+	}
+}
+
+// addExecution appends e to the payment's history, discarding the oldest
+// executions which no longer wait for approval if the history is full.
+func (p *scheduledPayment) addExecution(e *paymentExecution) {
+	excess := len(p.history) + 1 - maxPaymentExecutions
+	if excess > 0 {
+		kept := p.history[:0]
+		for _, old := range p.history {
+			if excess > 0 && old.state != PaymentPending {
+				excess--
+				continue
+			}
+			kept = append(kept, old)
+		}
+		p.history = kept
+	}
+	p.history = append(p.history, e)
+}
+
+// ScheduledPayments holds every recurring payment.
+var ScheduledPayments = &PaymentScheduler{}
+
+// PaymentScheduler is a persistent set of recurring payments, which are
+// checked each time a block is connected.  Payments only fire while the
+// wallet is unlocked.  Payments which become due while the wallet is
+// locked are recorded as pending, and are sent once approved.
+//
+// As the scheduler is only modified by RPC and notification handlers, it
+// is protected by the account manager's semaphore rather than a mutex.
+type PaymentScheduler struct {
+	payments []*scheduledPayment
+}
+
+// Start loads the previously saved recurring payments from disk, if any.
+// Executions which were being sent when the wallet was stopped are marked
+// unknown, and are never sent again.  This must be called before any
+// requests or notifications are handled.
+func (s *PaymentScheduler) Start() {
+	err := loadNetworkFile(scheduledPaymentsFilename, s.read)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			log.Warnf("Cannot read scheduled payments: %v", err)
+		}
+		return
+	}
+
+	modified := false
+	for _, p := range s.payments {
+		for _, e := range p.history {
+			if e.state == PaymentSending {
+				log.Warnf("Scheduled payment %s was interrupted "+
+					"while sending and will not be retried",
+					p.id)
+				e.state = PaymentUnknown
+				modified = true
+			}
+		}
+	}
+	if modified {
+		if err := s.save(); err != nil {
+			log.Errorf("Cannot write scheduled payments: %v", err)
+		}
+	}
+}
+
+// Add schedules a new recurring payment of amount to address from the
+// account named account, first due one interval from now, and returns the
+// ID of the payment.  Exactly one of intervalBlocks and interval must be
+// non-zero.
+func (s *PaymentScheduler) Add(account, address string, amount btcutil.Amount,
+	intervalBlocks int32, interval time.Duration) (string, error) {
+
+	bs, err := GetCurBlock()
+	if err != nil {
+		return "", err
+	}
+	id, err := newRandomID()
+	if err != nil {
+		return "", err
+	}
+
+	now := time.Now()
+	p := &scheduledPayment{
+		id:             id,
+		account:        account,
+		address:        address,
+		amount:         amount,
+		intervalBlocks: intervalBlocks,
+		interval:       interval,
+		created:        now,
+	}
+	if intervalBlocks != 0 {
+		p.nextHeight = bs.Height + intervalBlocks
+	} else {
+		p.nextTime = now.Add(interval)
+	}
+	s.payments = append(s.payments, p)
+	if err := s.save(); err != nil {
+		return "", err
+	}
+
+	log.Infof("Scheduled recurring payment %s of %v to %s from account %s",
+		p.id, amount, address, account)
+	return p.id, nil
+}
+
+// Find returns the recurring payment with ID id, or nil if there is no
+// such payment.
+func (s *PaymentScheduler) Find(id string) *scheduledPayment {
+	for _, p := range s.payments {
+		if p.id == id {
+			return p
+		}
+	}
+	return nil
+}
+
+// Remove removes the recurring payment with ID id and saves the scheduler.
+// Any executions still waiting for approval are discarded.
+func (s *PaymentScheduler) Remove(id string) error {
+	for i, p := range s.payments {
+		if p.id == id {
+			s.payments = append(s.payments[:i], s.payments[i+1:]...)
+			log.Infof("Removed scheduled payment %s", id)
+			return s.save()
+		}
+	}
+	return ErrUnknownScheduledPayment
+}
+
+// Approve sends every execution of the recurring payment with ID id which
+// is waiting for approval, or, if approve is false, marks them skipped.
+// The wallet must be unlocked to send approved payments.  The results of
+// all sent executions are returned.
+func (s *PaymentScheduler) Approve(id string, approve bool) ([]string, error) {
+	p := s.Find(id)
+	if p == nil {
+		return nil, ErrUnknownScheduledPayment
+	}
+	if approve {
+		a, err := AcctMgr.Account(p.account)
+		if err != nil {
+			return nil, err
+		}
+		if a.IsLocked() {
+			return nil, wallet.ErrWalletLocked
+		}
+	}
+
+	results := []string{}
+	for _, e := range p.history {
+		if e.state != PaymentPending {
+			continue
+		}
+		if !approve {
+			e.state = PaymentSkipped
+			continue
+		}
+		if err := s.execute(p, e); err != nil {
+			return results, err
+		}
+		if e.state == PaymentSent {
+			results = append(results, e.result)
+		}
+	}
+	return results, s.save()
+}
+
+// BlockConnected fires each recurring payment which is due once bs is
+// connected.  If the wallet is locked, the execution is recorded as
+// pending instead.
+func (s *PaymentScheduler) BlockConnected(bs *wallet.BlockStamp) {
+	now := time.Now()
+	modified := false
+	for _, p := range s.payments {
+		if !p.isDue(bs.Height, now) {
+			continue
+		}
+		p.advance(bs.Height, now)
+		modified = true
+
+		e := &paymentExecution{time: now, height: bs.Height}
+		p.addExecution(e)
+		a, err := AcctMgr.Account(p.account)
+		switch {
+		case err != nil:
+			e.state = PaymentFailed
+			e.err = err.Error()
+			log.Errorf("Cannot send scheduled payment %s: %v",
+				p.id, err)
+
+		case a.IsLocked():
+			e.state = PaymentPending
+			log.Infof("Scheduled payment %s is waiting for approval "+
+				"while the wallet is locked", p.id)
+
+		default:
+			if err := s.execute(p, e); err != nil {
+				log.Errorf("Cannot write scheduled payments: %v",
+					err)
+				return
+			}
+		}
+	}
+
+	if modified {
+		if err := s.save(); err != nil {
+			log.Errorf("Cannot write scheduled payments: %v", err)
+		}
+	}
+}
+
+// execute sends a single execution of a recurring payment and records the
+// result.  The execution is saved as sending before the payment is sent,
+// and if it cannot be saved, the payment is not sent and the error is
+// returned.
+func (s *PaymentScheduler) execute(p *scheduledPayment, e *paymentExecution) error {
+	e.state = PaymentSending
+	if err := s.save(); err != nil {
+		e.state = PaymentFailed
+		e.err = err.Error()
+		return err
+	}
+
+	pairs := map[string]btcutil.Amount{p.address: p.amount}
+	reply, jsonErr := sendPairs(nil, p.account, pairs, 1, nil)
+	if jsonErr != nil {
+		e.state = PaymentFailed
+		e.err = jsonErr.Message
+		log.Errorf("Cannot send scheduled payment %s: %v", p.id,
+			jsonErr.Message)
+		return nil
+	}
+	e.state = PaymentSent
+	e.result, _ = reply.(string)
+	log.Infof("Sent scheduled payment %s of %v to %s: %s", p.id,
+		p.amount, p.address, e.result)
+	return nil
+}
+
+// save writes the scheduler to disk, replacing any previously saved
+// payments.
+func (s *PaymentScheduler) save() error {
+	return saveNetworkFile(scheduledPaymentsFilename, s.write)
+}
+
+// write serializes all recurring payments to w.
+func (s *PaymentScheduler) write(w io.Writer) error {
+	if err := binary.Write(w, binary.LittleEndian, scheduledPaymentsVersion); err != nil {
+		return err
+	}
+	if err := binary.Write(w, binary.LittleEndian, uint32(len(s.payments))); err != nil {
+		return err
+	}
+	for _, p := range s.payments {
+		if err := writeScheduledPayment(w, p); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// read deserializes recurring payments written by write, replacing all
+// payments.
+func (s *PaymentScheduler) read(r io.Reader) error {
+	var vers, n uint32
+	if err := binary.Read(r, binary.LittleEndian, &vers); err != nil {
+		return err
+	}
+	if vers != scheduledPaymentsVersion {
+		return fmt.Errorf("unknown scheduled payments version %d", vers)
+	}
+	if err := binary.Read(r, binary.LittleEndian, &n); err != nil {
+		return err
+	}
+	if n > maxScheduledPayments {
+		return errors.New("too many saved scheduled payments")
+	}
+
+	payments := make([]*scheduledPayment, 0, n)
+	for i := uint32(0); i < n; i++ {
+		p, err := readScheduledPayment(r)
+		if err != nil {
+			return err
+		}
+		payments = append(payments, p)
+	}
+	s.payments = payments
+	return nil
+}
+
+// writeScheduledPayment serializes a recurring payment and its history to
+// w.
+func writeScheduledPayment(w io.Writer, p *scheduledPayment) error {
+	for _, s := range []string{p.id, p.account, p.address} {
+		if err := writeString(w, s); err != nil {
+			return err
+		}
+	}
+	var nextTime int64
+	if !p.nextTime.IsZero() {
+		nextTime = p.nextTime.Unix()
+	}
+	fields := []interface{}{
+		int64(p.amount),
+		p.intervalBlocks,
+		int64(p.interval),
+		p.nextHeight,
+		nextTime,
+		p.created.Unix(),
+		uint32(len(p.history)),
+	}
+	for _, field := range fields {
+		if err := binary.Write(w, binary.LittleEndian, field); err != nil {
+			return err
+		}
+	}
+
+	for _, e := range p.history {
+		fields := []interface{}{e.time.Unix(), e.height, e.state}
+		for _, field := range fields {
+			if err := binary.Write(w, binary.LittleEndian, field); err != nil {
+				return err
+			}
+		}
+		if err := writeString(w, e.result); err != nil {
+			return err
+		}
+		if err := writeString(w, e.err); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// readScheduledPayment deserializes a recurring payment written by
+// writeScheduledPayment.
+func readScheduledPayment(r io.Reader) (*scheduledPayment, error) {
+	id, err := readString(r, maxRandomIDLen)
+	if err != nil {
+		return nil, err
+	}
+	account, err := readString(r, maxSavedAccountNameLen)
+	if err != nil {
+		return nil, err
+	}
+	address, err := readString(r, maxSpendPolicyAddressLen)
+	if err != nil {
+		return nil, err
+	}
+
+	var amount, interval, nextTime, created int64
+	var intervalBlocks, nextHeight int32
+	var n uint32
+	fields := []interface{}{
+		&amount,
+		&intervalBlocks,
+		&interval,
+		&nextHeight,
+		&nextTime,
+		&created,
+		&n,
+	}
+	for _, field := range fields {
+		if err := binary.Read(r, binary.LittleEndian, field); err != nil {
+			return nil, err
+		}
+	}
+	if (intervalBlocks <= 0) == (interval <= 0) {
+		return nil, errors.New("scheduled payment has invalid interval")
+	}
+	if n > maxSavedPaymentExecutions {
+		return nil, errors.New("too many saved payment executions")
+	}
+
+	p := &scheduledPayment{
+		id:             id,
+		account:        account,
+		address:        address,
+		amount:         btcutil.Amount(amount),
+		intervalBlocks: intervalBlocks,
+		interval:       time.Duration(interval),
+		nextHeight:     nextHeight,
+		created:        time.Unix(created, 0),
+		history:        make([]*paymentExecution, 0, n),
+	}
+	if nextTime != 0 {
+		p.nextTime = time.Unix(nextTime, 0)
+	}
+
+	for i := uint32(0); i < n; i++ {
+		var t int64
+		e := new(paymentExecution)
+		fields := []interface{}{&t, &e.height, &e.state}
+		for _, field := range fields {
+			if err := binary.Read(r, binary.LittleEndian, field); err != nil {
+				return nil, err
+			}
+		}
+		e.time = time.Unix(t, 0)
+		if e.result, err = readString(r, maxPaymentResultLen); err != nil {
+			return nil, err
+		}
+		if e.err, err = readString(r, maxPaymentErrorLen); err != nil {
+			return nil, err
+		}
+		p.history = append(p.history, e)
+	}
+	return p, nil
+}
@@ -69,11 +69,23 @@ var (
 // server holds the items the RPC server may need to access (auth,
 // config, shutdown, etc.)
 type server struct {
-	wg        sync.WaitGroup
-	listeners []net.Listener
-	authsha   [sha256.Size]byte
+	wg         sync.WaitGroup
+	listeners  []net.Listener
+	authsha    [sha256.Size]byte
+	approvesha [sha256.Size]byte
 }
 
+// clientAuth describes the login a client has authenticated with, if any.
+type clientAuth int
+
+// Each login a client may authenticate with.  Clients authenticated with
+// the send approval login may only approve or reject held sends.
+const (
+	authNone clientAuth = iota
+	authUser
+	authApprover
+)
+
 type clientContext struct {
 	send chan []byte
 	quit chan struct{} // closed on disconnect
@@ -126,6 +138,11 @@ func newServer(listenAddrs []string) (*server, error) {
 	s := server{
 		authsha: sha256.Sum256([]byte(auth)),
 	}
+	if cfg.SendApproval() {
+		login := cfg.ApproveUser + ":" + cfg.ApprovePass
+		auth := "Basic " + base64.StdEncoding.EncodeToString([]byte(login))
+		s.approvesha = sha256.Sum256([]byte(auth))
+	}
 
 	// Check for existence of cert file and key file
 	if !fileExists(cfg.RPCKey) && !fileExists(cfg.RPCCert) {
@@ -224,8 +241,9 @@ func ParseRequest(msg []byte) (btcjson.Cmd, *btcjson.Error) {
 // marshaled JSON-RPC response for both standard and extension
 // (websocket) clients.  The returned error is ErrBadAuth if a
 // missing, incorrect, or duplicate authentication request is
-// received.
-func (s *server) ReplyToFrontend(msg []byte, ws, authenticated bool) ([]byte, error) {
+// received.  auth is set to the client's login after a successful
+// authentication request.
+func (s *server) ReplyToFrontend(msg []byte, ws bool, auth *clientAuth) ([]byte, error) {
 	cmd, jsonErr := ParseRequest(msg)
 	var id interface{}
 	if cmd != nil {
@@ -235,7 +253,7 @@ func (s *server) ReplyToFrontend(msg []byte, ws, authenticated bool) ([]byte, er
 	// If client is not already authenticated, the parsed request must
 	// be for authentication.
 	authCmd, ok := cmd.(*btcws.AuthenticateCmd)
-	if authenticated {
+	if *auth != authNone {
 		if ok {
 			// Duplicate auth request.
 			return nil, ErrBadAuth
@@ -248,15 +266,21 @@ func (s *server) ReplyToFrontend(msg []byte, ws, authenticated bool) ([]byte, er
 
 		// Check credentials.
 		login := authCmd.Username + ":" + authCmd.Passphrase
-		auth := "Basic " + base64.StdEncoding.EncodeToString([]byte(login))
-		authSha := sha256.Sum256([]byte(auth))
-		cmp := subtle.ConstantTimeCompare(authSha[:], s.authsha[:])
-		if cmp != 1 {
+		*auth = s.checkLogin("Basic " +
+			base64.StdEncoding.EncodeToString([]byte(login)))
+		if *auth == authNone {
 			return nil, ErrBadAuth
 		}
 		return nil, nil
 	}
 
+	// Clients authenticated with the send approval login may only
+	// call the methods needed to approve sends, and only they may
+	// approve them.
+	if jsonErr == nil && !methodPermitted(cmd.Method(), *auth) {
+		jsonErr = &ErrMethodNotPermitted
+	}
+
 	if jsonErr != nil {
 		response := btcjson.Reply{
 			Id:    &id,
@@ -293,14 +317,15 @@ func (s *server) ReplyToFrontend(msg []byte, ws, authenticated bool) ([]byte, er
 	return mresponse, nil
 }
 
-// ServeRPCRequest processes and replies to a JSON-RPC client request.
-func (s *server) ServeRPCRequest(w http.ResponseWriter, r *http.Request) {
+// ServeRPCRequest processes and replies to a JSON-RPC client request from
+// a client authenticated with the login auth.
+func (s *server) ServeRPCRequest(w http.ResponseWriter, r *http.Request, auth clientAuth) {
 	body, err := btcjson.GetRaw(r.Body)
 	if err != nil {
 		log.Errorf("RPCS: Error getting JSON message: %v", err)
 	}
 
-	resp, err := s.ReplyToFrontend(body, false, true)
+	resp, err := s.ReplyToFrontend(body, false, &auth)
 	if err == ErrBadAuth {
 		http.Error(w, "401 Unauthorized.", http.StatusUnauthorized)
 		return
@@ -406,7 +431,7 @@ out:
 // WSSendRecv is the handler for websocket client connections.  It loops
 // forever (until disconnected), reading JSON-RPC requests and sending
 // sending responses and notifications.
-func (s *server) WSSendRecv(ws *websocket.Conn, remoteAddr string, authenticated bool) {
+func (s *server) WSSendRecv(ws *websocket.Conn, remoteAddr string, auth clientAuth) {
 	// Clear the read deadline set before the websocket hijacked
 	// the connection.
 	ws.SetReadDeadline(time.Time{})
@@ -471,7 +496,7 @@ func (s *server) WSSendRecv(ws *websocket.Conn, remoteAddr string, authenticated
 	go func() {
 	out:
 		for m := range recvQueueOut {
-			resp, err := s.ReplyToFrontend([]byte(m), true, authenticated)
+			resp, err := s.ReplyToFrontend([]byte(m), true, &auth)
 			if err == ErrBadAuth {
 				select {
 				case badAuth <- struct{}{}:
@@ -480,9 +505,6 @@ func (s *server) WSSendRecv(ws *websocket.Conn, remoteAddr string, authenticated
 				break out
 			}
 
-			// Authentication passed.
-			authenticated = true
-
 			select {
 			case sendResp <- resp:
 			case <-cc.quit:
@@ -563,16 +585,17 @@ func (s *server) Start() {
 		ReadTimeout: time.Second * rpcAuthTimeoutSeconds,
 	}
 	serveMux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
-		if err := s.checkAuth(r); err != nil {
+		auth, err := s.checkAuth(r)
+		if err != nil {
 			log.Warnf("Unauthorized client connection attempt")
 			http.Error(w, "401 Unauthorized.", http.StatusUnauthorized)
 			return
 		}
-		s.ServeRPCRequest(w, r)
+		s.ServeRPCRequest(w, r, auth)
 	})
 	serveMux.HandleFunc("/frontend", func(w http.ResponseWriter, r *http.Request) {
-		authenticated := false
-		if err := s.checkAuth(r); err != nil {
+		auth, err := s.checkAuth(r)
+		if err != nil {
 			// If auth was supplied but incorrect, rather than simply being
 			// missing, immediately terminate the connection.
 			if err != ErrNoAuth {
@@ -580,8 +603,6 @@ func (s *server) Start() {
 				http.Error(w, "401 Unauthorized.", http.StatusUnauthorized)
 				return
 			}
-		} else {
-			authenticated = true
 		}
 
 		// A new Server instance is created rather than just creating the
@@ -589,7 +610,7 @@ func (s *server) Start() {
 		// client if the origin is unset.
 		wsServer := websocket.Server{
 			Handler: websocket.Handler(func(ws *websocket.Conn) {
-				s.WSSendRecv(ws, r.RemoteAddr, authenticated)
+				s.WSSendRecv(ws, r.RemoteAddr, auth)
 			}),
 		}
 		wsServer.ServeHTTP(w, r)
@@ -606,23 +627,38 @@ func (s *server) Start() {
 }
 
 // checkAuth checks the HTTP Basic authentication supplied by a frontend
-// in the HTTP request r.  If the frontend's supplied authentication does
-// not match the username and password expected, a non-nil error is
-// returned.
+// in the HTTP request r, returning the login the frontend authenticated
+// with.  If the frontend's supplied authentication does not match any
+// username and password expected, a non-nil error is returned.
 //
 // This check is time-constant.
-func (s *server) checkAuth(r *http.Request) error {
+func (s *server) checkAuth(r *http.Request) (clientAuth, error) {
 	authhdr := r.Header["Authorization"]
 	if len(authhdr) == 0 {
-		return ErrNoAuth
+		return authNone, ErrNoAuth
 	}
 
-	authsha := sha256.Sum256([]byte(authhdr[0]))
-	cmp := subtle.ConstantTimeCompare(authsha[:], s.authsha[:])
-	if cmp != 1 {
-		return ErrBadAuth
+	auth := s.checkLogin(authhdr[0])
+	if auth == authNone {
+		return authNone, ErrBadAuth
 	}
-	return nil
+	return auth, nil
+}
+
+// checkLogin returns the login matching the HTTP Basic authorization
+// string auth, or authNone if it matches no login.
+//
+// This check is time-constant.
+func (s *server) checkLogin(auth string) clientAuth {
+	authsha := sha256.Sum256([]byte(auth))
+	if subtle.ConstantTimeCompare(authsha[:], s.authsha[:]) == 1 {
+		return authUser
+	}
+	if cfg.SendApproval() &&
+		subtle.ConstantTimeCompare(authsha[:], s.approvesha[:]) == 1 {
+		return authApprover
+	}
+	return authNone
 }
 
 // BtcdWS opens a websocket connection to a btcd instance.
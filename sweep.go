@@ -0,0 +1,329 @@
+/*
+ * Copyright (c) 2014 Conformal Systems LLC <info@conformal.com>
+ *
+ * Permission to use, copy, modify, and distribute this software for any
+ * purpose with or without fee is hereby granted, provided that the above
+ * copyright notice and this permission notice appear in all copies.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+ * WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+ * MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ * ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+ * WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ * ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+ * OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ */
+
+package main
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/conformal/btcchain"
+	"github.com/conformal/btcec"
+	"github.com/conformal/btcjson"
+	"github.com/conformal/btcscript"
+	"github.com/conformal/btcutil"
+	"github.com/conformal/btcwire"
+)
+
+// ErrAlreadySweeping describes an error where a key is requested to be
+// swept while a previous sweep of the same key has not yet finished.
+var ErrAlreadySweeping = errors.New("key is already being swept")
+
+// ErrSweepWalletKey describes an error where a key requested to be swept
+// is already saved by the wallet.
+var ErrSweepWalletKey = errors.New("key is already saved by the wallet")
+
+// ErrNothingToSweep describes an error where no unspent outputs were found
+// for a swept key, or their total value does not cover the sweep fee.
+var ErrNothingToSweep = errors.New("no spendable funds found for key")
+
+// sweptOutput is an output found by a rescan paying to a swept key.
+type sweptOutput struct {
+	txOut    *btcwire.TxOut
+	height   int32
+	coinbase bool
+}
+
+// sweepJob holds the state of a single private key sweep while its rescan
+// is running.  The private key is only ever held in memory and is cleared
+// once the sweep finishes.
+type sweepJob struct {
+	account    string
+	addr       *btcutil.AddressPubKeyHash
+	privKey    *ecdsa.PrivateKey
+	compressed bool
+	outputs    map[btcwire.OutPoint]*sweptOutput
+}
+
+// sweeps holds every running sweep, keyed by the encoded address of the
+// swept key.  As it is only accessed by RPC and notification handlers, it
+// is protected by the account manager's semaphore rather than a mutex.
+var sweeps = make(map[string]*sweepJob)
+
+// StartSweep begins sweeping all funds spendable by a private key to a new
+// address of the account named account.  A rescan starting at startHeight
+// is submitted for the key's address, and once the rescan finishes, a
+// transaction spending every unspent output found is created, signed, and
+// sent.  As rescans may take a long time to finish, this does not block on
+// the sweep, and the sweep's result is only logged.
+//
+// This must be called while holding the account manager's semaphore.
+func StartSweep(account string, privKey []byte, compressed bool,
+	startHeight int32) (*btcutil.AddressPubKeyHash, error) {
+
+	privk, pubk := btcec.PrivKeyFromBytes(btcec.S256(), privKey)
+	var serializedPubKey []byte
+	if compressed {
+		serializedPubKey = pubk.SerializeCompressed()
+	} else {
+		serializedPubKey = pubk.SerializeUncompressed()
+	}
+	addr, err := btcutil.NewAddressPubKeyHash(
+		btcutil.Hash160(serializedPubKey), cfg.Net())
+	if err != nil {
+		return nil, err
+	}
+	if _, err := AcctMgr.AccountByAddress(addr); err == nil {
+		return nil, ErrSweepWalletKey
+	}
+	addrStr := addr.EncodeAddress()
+	if _, ok := sweeps[addrStr]; ok {
+		return nil, ErrAlreadySweeping
+	}
+
+	job := &sweepJob{
+		account:    account,
+		addr:       addr,
+		privKey:    privk,
+		compressed: compressed,
+		outputs:    make(map[btcwire.OutPoint]*sweptOutput),
+	}
+	sweeps[addrStr] = job
+
+	// The swept address does not belong to any account, so it is
+	// rescanned under the nil account.  The rescan's notifications are
+	// all queued before the rescan completes, so once it is done, a
+	// notification to finish the sweep is queued after them to be
+	// handled only after every found output has been recorded.
+	rescan := &RescanJob{
+		Addresses:   map[*Account][]btcutil.Address{nil: {addr}},
+		OutPoints:   nil,
+		StartHeight: startHeight,
+	}
+	doneChan := AcctMgr.rm.SubmitJob(rescan)
+	go func() {
+		<-doneChan
+		svrNtfns <- &sweepFinishedNtfn{addr: addrStr}
+	}()
+
+	log.Infof("Started sweep of address %s", addrStr)
+	return addr, nil
+}
+
+// recordSweptOutputs records each output of tx paying to an address which
+// is currently being swept.
+func recordSweptOutputs(tx *btcutil.Tx, height int32) {
+	if len(sweeps) == 0 {
+		return
+	}
+	coinbase := btcchain.IsCoinBase(tx)
+	for i, txout := range tx.MsgTx().TxOut {
+		_, addrs, _, _ := btcscript.ExtractPkScriptAddrs(txout.PkScript,
+			cfg.Net())
+		for _, addr := range addrs {
+			job, ok := sweeps[addr.EncodeAddress()]
+			if !ok {
+				continue
+			}
+			op := btcwire.NewOutPoint(tx.Sha(), uint32(i))
+			job.outputs[*op] = &sweptOutput{
+				txOut:    txout,
+				height:   height,
+				coinbase: coinbase,
+			}
+		}
+	}
+}
+
+// removeSweptOutputs removes each previously found output of a running
+// sweep which is spent by tx.
+func removeSweptOutputs(tx *btcutil.Tx) {
+	for _, job := range sweeps {
+		for _, txin := range tx.MsgTx().TxIn {
+			delete(job.outputs, txin.PreviousOutpoint)
+		}
+	}
+}
+
+// finishSweep creates, signs, and sends the transaction sweeping the
+// outputs found for the sweep of address addrStr, and removes the sweep.
+func finishSweep(addrStr string) (*btcwire.ShaHash, error) {
+	job, ok := sweeps[addrStr]
+	if !ok {
+		return nil, fmt.Errorf("no sweep for address %s", addrStr)
+	}
+	defer func() {
+		delete(sweeps, addrStr)
+		job.privKey.D.SetInt64(0)
+	}()
+
+	a, err := AcctMgr.Account(job.account)
+	if err != nil {
+		return nil, err
+	}
+
+	bs, err := GetCurBlock()
+	if err != nil {
+		return nil, err
+	}
+
+	// Spend every found output, skipping any immature coinbase outputs.
+	msgtx := btcwire.NewMsgTx()
+	var prevOuts []*btcwire.TxOut
+	var total btcutil.Amount
+	for op, out := range job.outputs {
+		if out.coinbase {
+			depth := chainDepth(out.height, bs.Height)
+			if depth < btcchain.CoinbaseMaturity {
+				continue
+			}
+		}
+		op := op
+		msgtx.AddTxIn(btcwire.NewTxIn(&op, nil))
+		prevOuts = append(prevOuts, out.txOut)
+		total += btcutil.Amount(out.txOut.Value)
+	}
+	if len(prevOuts) == 0 {
+		return nil, ErrNothingToSweep
+	}
+
+	addr, err := a.NewAddress()
+	if err != nil {
+		return nil, err
+	}
+	pkScript, err := btcscript.PayToAddrScript(addr)
+	if err != nil {
+		return nil, fmt.Errorf("cannot create txout script: %s", err)
+	}
+	txout := btcwire.NewTxOut(0, pkScript)
+	msgtx.AddTxOut(txout)
+
+	// Pay the fee for the estimated signed size from the swept value.
+	sigScriptSize := p2pkhUncompressedSigScriptSize
+	if job.compressed {
+		sigScriptSize = p2pkhCompressedSigScriptSize
+	}
+	txSize := msgtx.SerializeSize() + len(prevOuts)*sigScriptSize
+	feeRate := defaultFeeRate()
+	fee := feeRate.Fee(txSize)
+	txout.Value = int64(total - fee)
+	if minFee := minimumFee(msgtx, txSize, feeRate, false); fee < minFee {
+		// Outputs worth less than a bitcent require the fee for a
+		// full kilobyte.
+		fee = minFee
+	}
+	if total <= fee {
+		return nil, ErrNothingToSweep
+	}
+	txout.Value = int64(total - fee)
+
+	for i, prevOut := range prevOuts {
+		sigscript, err := btcscript.SignatureScript(msgtx, i,
+			prevOut.PkScript, btcscript.SigHashAll, job.privKey,
+			job.compressed)
+		if err != nil {
+			return nil, fmt.Errorf("cannot create sigscript: %s", err)
+		}
+		msgtx.TxIn[i].SignatureScript = sigscript
+	}
+
+	buf := bytes.NewBuffer(nil)
+	buf.Grow(msgtx.SerializeSize())
+	if err := msgtx.BtcEncode(buf, btcwire.ProtocolVersion); err != nil {
+		return nil, err
+	}
+	_, jsonErr := SendRawTransaction(CurrentServerConn(),
+		hex.EncodeToString(buf.Bytes()))
+	if jsonErr != nil {
+		return nil, jsonErr
+	}
+
+	txSha, err := msgtx.TxSha()
+	if err != nil {
+		return nil, err
+	}
+	log.Infof("Swept %v from address %s to %s (fee %v) in transaction %v",
+		btcutil.Amount(txout.Value), addrStr, addr.EncodeAddress(), fee,
+		txSha)
+	return &txSha, nil
+}
+
+// sweepFinishedNtfnMethod is the method of the wallet-internal notification
+// queued after the rescan for a swept key completes.
+const sweepFinishedNtfnMethod = "sweepfinished"
+
+// sweepFinishedNtfn is a wallet-internal notification which is queued with
+// btcd notifications to finish a sweep only after all notifications from
+// the sweep's rescan have been handled.  It is never sent to or received
+// from btcd.
+type sweepFinishedNtfn struct {
+	addr string
+}
+
+// Enforce that sweepFinishedNtfn satisifies the btcjson.Cmd interface.
+var _ btcjson.Cmd = &sweepFinishedNtfn{}
+
+// Id satisifies the btcjson.Cmd interface by returning nil for a
+// notification ID.
+func (n *sweepFinishedNtfn) Id() interface{} {
+	return nil
+}
+
+// Method satisifies the btcjson.Cmd interface by returning the method of
+// the notification.
+func (n *sweepFinishedNtfn) Method() string {
+	return sweepFinishedNtfnMethod
+}
+
+// MarshalJSON returns the JSON encoding of n.  Required to satisify the
+// btcjson.Cmd interface.
+func (n *sweepFinishedNtfn) MarshalJSON() ([]byte, error) {
+	raw, err := btcjson.NewRawCmd(nil, n.Method(), []interface{}{n.addr})
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(raw)
+}
+
+// UnmarshalJSON unmarshals the JSON encoding of n into n.  Part of the
+// btcjson.Cmd interface.
+func (n *sweepFinishedNtfn) UnmarshalJSON(b []byte) error {
+	var r btcjson.RawCmd
+	if err := json.Unmarshal(b, &r); err != nil {
+		return err
+	}
+	if len(r.Params) != 1 {
+		return btcjson.ErrWrongNumberOfParams
+	}
+	return json.Unmarshal(r.Params[0], &n.addr)
+}
+
+// NtfnSweepFinished handles the wallet-internal notification queued after
+// a sweep's rescan completes by sending the sweep transaction.
+func NtfnSweepFinished(n btcjson.Cmd) error {
+	sn, ok := n.(*sweepFinishedNtfn)
+	if !ok {
+		return fmt.Errorf("%v handler: unexpected type", n.Method())
+	}
+
+	if _, err := finishSweep(sn.addr); err != nil {
+		log.Errorf("Cannot sweep address %s: %v", sn.addr, err)
+	}
+	return nil
+}
@@ -693,7 +693,7 @@ func (t *txRecord) WriteTo(w io.Writer) (int64, error) {
 			// Write a single byte to specify whether this credit
 			// is locked.
 			lockByte := falseByte
-			if c.change {
+			if c.locked {
 				lockByte = trueByte
 			}
 			n, err = w.Write([]byte{lockByte})
@@ -114,6 +114,38 @@ func (e MissingCreditError) MissingValueError() error {
 	return e
 }
 
+// MissingUnminedTxError describes an error where an unmined transaction
+// could not be found in the transaction store.  The value is the hash of
+// the missing transaction.
+type MissingUnminedTxError btcwire.ShaHash
+
+// Error implements the error interface.
+func (e MissingUnminedTxError) Error() string {
+	return fmt.Sprintf("missing record for unmined transaction %v",
+		btcwire.ShaHash(e))
+}
+
+// MissingValueError implements the MissingValueError interface.
+func (e MissingUnminedTxError) MissingValueError() error {
+	return e
+}
+
+// MissingUnspentError describes an error where an unspent credit could not
+// be found in the transaction store.  The value is the outpoint of the
+// missing credit.
+type MissingUnspentError btcwire.OutPoint
+
+// Error implements the error interface.
+func (e MissingUnspentError) Error() string {
+	return fmt.Sprintf("missing record for unspent output %v:%d", e.Hash,
+		e.Index)
+}
+
+// MissingValueError implements the MissingValueError interface.
+func (e MissingUnspentError) MissingValueError() error {
+	return e
+}
+
 // TxRecord is the record type for all transactions in the store.  If the
 // transaction is mined, BlockTxKey will be the lookup key for the transaction.
 // Otherwise, the embedded BlockHeight will be -1.
@@ -991,6 +1023,17 @@ func (s *Store) UnminedDebitTxs() []*btcutil.Tx {
 	return unmined
 }
 
+// UnminedTx returns the record for the unmined transaction with the given
+// hash.  MissingUnminedTxError is returned if the store does not contain an
+// unmined transaction with this hash.
+func (s *Store) UnminedTx(hash *btcwire.ShaHash) (*TxRecord, error) {
+	r, ok := s.unconfirmed.txs[*hash]
+	if !ok {
+		return nil, MissingUnminedTxError(*hash)
+	}
+	return &TxRecord{BlockTxKey{BlockHeight: -1}, r, s}, nil
+}
+
 // removeDoubleSpends checks for any unconfirmed transactions which would
 // introduce a double spend if tx was added to the store (either as a confirmed
 // or unconfirmed transaction).  If one is found, it and all transactions which
@@ -1108,6 +1151,98 @@ func (s *Store) UnspentOutputs() ([]*Credit, error) {
 	return unspent, nil
 }
 
+// lookupUnspentCredit searches for the unspent credit referenced by op.
+// MissingUnspentError is returned if op does not reference an unspent
+// credit.
+func (s *Store) lookupUnspentCredit(op *btcwire.OutPoint) (*Credit, error) {
+	if r, ok := s.unconfirmed.txs[op.Hash]; ok {
+		if r.unspentCredit(op.Index) {
+			key := BlockTxKey{BlockHeight: -1}
+			return &Credit{&TxRecord{key, r, s}, op.Index}, nil
+		}
+		return nil, MissingUnspentError(*op)
+	}
+	for height := range s.unspent {
+		b, err := s.lookupBlock(height)
+		if err != nil {
+			return nil, err
+		}
+		for blockIndex, index := range b.unspent {
+			r := b.txs[index]
+			if *r.Tx().Sha() != op.Hash {
+				continue
+			}
+			if !r.unspentCredit(op.Index) {
+				return nil, MissingUnspentError(*op)
+			}
+			key := BlockTxKey{blockIndex, b.Height}
+			return &Credit{&TxRecord{key, r, s}, op.Index}, nil
+		}
+	}
+	return nil, MissingUnspentError(*op)
+}
+
+// unspentCredit returns whether the output at index is an unspent credit.
+func (r *txRecord) unspentCredit(index uint32) bool {
+	if len(r.credits) <= int(index) {
+		return false
+	}
+	c := r.credits[index]
+	return c != nil && c.spentBy == nil
+}
+
+// LockOutput marks the unspent credit referenced by op as locked.  Locked
+// credits are still returned by UnspentOutputs and included in the balance,
+// but should not be chosen as inputs for new transactions until unlocked.
+// MissingUnspentError is returned if op does not reference an unspent credit.
+func (s *Store) LockOutput(op *btcwire.OutPoint) error {
+	c, err := s.lookupUnspentCredit(op)
+	if err != nil {
+		return err
+	}
+	c.txRecord.credits[c.OutputIndex].locked = true
+	return nil
+}
+
+// UnlockOutput removes the lock from the unspent credit referenced by op.
+// MissingUnspentError is returned if op does not reference an unspent credit.
+func (s *Store) UnlockOutput(op *btcwire.OutPoint) error {
+	c, err := s.lookupUnspentCredit(op)
+	if err != nil {
+		return err
+	}
+	c.txRecord.credits[c.OutputIndex].locked = false
+	return nil
+}
+
+// UnlockAllOutputs removes the lock from every unspent credit.
+func (s *Store) UnlockAllOutputs() error {
+	unspent, err := s.UnspentOutputs()
+	if err != nil {
+		return err
+	}
+	for _, c := range unspent {
+		c.txRecord.credits[c.OutputIndex].locked = false
+	}
+	return nil
+}
+
+// LockedOutputs returns all unspent credits which are currently locked.
+// The order is undefined.
+func (s *Store) LockedOutputs() ([]*Credit, error) {
+	unspent, err := s.UnspentOutputs()
+	if err != nil {
+		return nil, err
+	}
+	var locked []*Credit
+	for _, c := range unspent {
+		if c.Locked() {
+			locked = append(locked, c)
+		}
+	}
+	return locked, nil
+}
+
 // confirmed checks whether a transaction at height txHeight has met
 // minconf confirmations for a blockchain at height curHeight.
 func confirmed(minconf int, txHeight, curHeight int32) bool {
@@ -1214,10 +1349,10 @@ func (s *Store) Records() (records []*TxRecord) {
 
 	// Unconfirmed records are saved unsorted, and must be sorted by
 	// received date on the fly.
-	unconfirmed := make([]*TxRecord, len(s.unconfirmed.txs))
+	unconfirmed := make([]*TxRecord, 0, len(s.unconfirmed.txs))
 	for _, r := range s.unconfirmed.txs {
 		key := BlockTxKey{BlockHeight: -1}
-		unconfirmed = append(records, &TxRecord{key, r, s})
+		unconfirmed = append(unconfirmed, &TxRecord{key, r, s})
 	}
 	sort.Sort(byReceiveDate(unconfirmed))
 	records = append(records, unconfirmed...)
@@ -1295,6 +1430,12 @@ func (c *Credit) Change() bool {
 	return c.txRecord.credits[c.OutputIndex].change
 }
 
+// Locked returns whether the credit has been locked to prevent it from
+// being spent by newly-created transactions.
+func (c *Credit) Locked() bool {
+	return c.txRecord.credits[c.OutputIndex].locked
+}
+
 // Confirmed returns whether a transaction has reached some target number of
 // confirmations, given the current best chain height.
 func (t *TxRecord) Confirmed(target int, chainHeight int32) bool {
@@ -593,3 +593,139 @@ func TestFindingSpentCredits(t *testing.T) {
 		t.Fatal("has more than one unspent credit")
 	}
 }
+
+func TestLockingOutputs(t *testing.T) {
+	s := New()
+
+	r, err := s.InsertTx(TstRecvTx, TstRecvTxBlockDetails)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c, err := r.AddCredit(0, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.Locked() {
+		t.Fatal("new credit is locked")
+	}
+
+	// Outputs which are not unspent credits cannot be locked.
+	missing := btcwire.NewOutPoint(TstRecvTx.Sha(), 1)
+	if _, ok := s.LockOutput(missing).(MissingUnspentError); !ok {
+		t.Fatal("expected MissingUnspentError locking a non-credit")
+	}
+
+	if err := s.LockOutput(c.OutPoint()); err != nil {
+		t.Fatal(err)
+	}
+	if !c.Locked() {
+		t.Fatal("credit not locked")
+	}
+
+	// Locks must survive serialization.
+	buf := new(bytes.Buffer)
+	if _, err := s.WriteTo(buf); err != nil {
+		t.Fatal(err)
+	}
+	s = New()
+	if _, err := s.ReadFrom(buf); err != nil {
+		t.Fatal(err)
+	}
+	locked, err := s.LockedOutputs()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(locked) != 1 || *locked[0].OutPoint() != *c.OutPoint() {
+		t.Fatal("locked credit not restored after deserialization")
+	}
+	if locked[0].Change() {
+		t.Fatal("locked credit deserialized as change")
+	}
+
+	if err := s.UnlockOutput(c.OutPoint()); err != nil {
+		t.Fatal(err)
+	}
+	locked, err = s.LockedOutputs()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(locked) != 0 {
+		t.Fatal("credit still locked after unlock")
+	}
+}
+
+func TestUnminedTx(t *testing.T) {
+	s := New()
+	tx, err := btcutil.NewTxFromBytes(TstRecvSerializedTx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := s.UnminedTx(tx.Sha()); err == nil {
+		t.Fatal("found unmined tx in empty store")
+	}
+
+	r, err := s.InsertTx(tx, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := r.AddCredit(1, true); err != nil {
+		t.Fatal(err)
+	}
+	r, err = s.UnminedTx(tx.Sha())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.BlockHeight != -1 {
+		t.Fatal("unmined tx record has a block height")
+	}
+	credits := r.Credits()
+	if len(credits) != 1 || !credits[0].Change() {
+		t.Fatal("unmined tx record missing change credit")
+	}
+
+	// Once mined, the record is no longer returned.
+	tx.SetIndex(TstRecvIndex)
+	if _, err := s.InsertTx(tx, TstRecvTxBlockDetails); err != nil {
+		t.Fatal(err)
+	}
+	_, err = s.UnminedTx(tx.Sha())
+	if _, ok := err.(MissingUnminedTxError); !ok {
+		t.Fatalf("expected MissingUnminedTxError, got %v", err)
+	}
+}
+
+func TestRecords(t *testing.T) {
+	s := New()
+
+	recvTx, err := btcutil.NewTxFromBytes(TstRecvSerializedTx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	recvTx.SetIndex(TstRecvIndex)
+	if _, err := s.InsertTx(recvTx, TstRecvTxBlockDetails); err != nil {
+		t.Fatal(err)
+	}
+	spendingTx, err := btcutil.NewTxFromBytes(TstSpendingSerializedTx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.InsertTx(spendingTx, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	// Mined records are returned before unmined records, and each
+	// record is returned exactly once.
+	records := s.Records()
+	if len(records) != 2 {
+		t.Fatalf("expected 2 records, got %d", len(records))
+	}
+	if *records[0].Tx().Sha() != *recvTx.Sha() ||
+		records[0].BlockHeight != TstRecvTxBlockDetails.Height {
+		t.Fatal("first record is not the mined transaction")
+	}
+	if *records[1].Tx().Sha() != *spendingTx.Sha() ||
+		records[1].BlockHeight != -1 {
+		t.Fatal("second record is not the unmined transaction")
+	}
+}
@@ -0,0 +1,165 @@
+/*
+ * Copyright (c) 2014 Conformal Systems LLC <info@conformal.com>
+ *
+ * Permission to use, copy, modify, and distribute this software for any
+ * purpose with or without fee is hereby granted, provided that the above
+ * copyright notice and this permission notice appear in all copies.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+ * WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+ * MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ * ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+ * WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ * ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+ * OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ */
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"github.com/conformal/btcutil"
+	"github.com/conformal/btcwire"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// paymentURIScheme is the URI scheme of BIP0021 payment URIs.
+const paymentURIScheme = "bitcoin"
+
+// ErrInvalidPaymentURI describes an error where a payment URI does not
+// follow the syntax of BIP0021.
+var ErrInvalidPaymentURI = errors.New("invalid payment URI")
+
+// ErrInvalidURIAmount describes an error where the amount of a payment URI
+// is not a valid decimal number of bitcoins.
+var ErrInvalidURIAmount = errors.New("invalid payment URI amount")
+
+// PaymentURI is a BIP0021 payment request for an amount to be paid to an
+// address.  A zero Amount requests no specific amount.
+type PaymentURI struct {
+	Address btcutil.Address
+	Amount  btcutil.Amount
+	Label   string
+	Message string
+}
+
+// ParsePaymentURI parses and validates a BIP0021 payment URI for the
+// bitcoin network net.  Unknown parameters are ignored, except for those
+// beginning with "req-", which must be understood for the request to be
+// valid and therefore result in an error.
+func ParsePaymentURI(uri string, net btcwire.BitcoinNet) (*PaymentURI, error) {
+	u, err := url.Parse(uri)
+	if err != nil {
+		return nil, ErrInvalidPaymentURI
+	}
+	if strings.ToLower(u.Scheme) != paymentURIScheme || u.Opaque == "" {
+		return nil, ErrInvalidPaymentURI
+	}
+
+	addr, err := btcutil.DecodeAddress(u.Opaque, net)
+	if err != nil {
+		return nil, fmt.Errorf("invalid payment URI address: %v", err)
+	}
+	if !addr.IsForNet(net) {
+		return nil, errors.New("payment URI address is for the wrong network")
+	}
+
+	params, err := url.ParseQuery(u.RawQuery)
+	if err != nil {
+		return nil, ErrInvalidPaymentURI
+	}
+	p := &PaymentURI{Address: addr}
+	for key, values := range params {
+		if len(values) != 1 {
+			return nil, fmt.Errorf("payment URI parameter %s is "+
+				"repeated", key)
+		}
+		value := values[0]
+
+		switch key {
+		case "amount":
+			p.Amount, err = parseURIAmount(value)
+			if err != nil {
+				return nil, err
+			}
+
+		case "label":
+			p.Label = value
+
+		case "message":
+			p.Message = value
+
+		default:
+			if strings.HasPrefix(key, "req-") {
+				return nil, fmt.Errorf("unsupported required "+
+					"payment URI parameter %s", key)
+			}
+		}
+	}
+	return p, nil
+}
+
+// String returns the BIP0021 encoding of p.
+func (p *PaymentURI) String() string {
+	var params []string
+	if p.Amount != 0 {
+		params = append(params, "amount="+formatURIAmount(p.Amount))
+	}
+	if p.Label != "" {
+		params = append(params, "label="+escapeURIParam(p.Label))
+	}
+	if p.Message != "" {
+		params = append(params, "message="+escapeURIParam(p.Message))
+	}
+
+	uri := paymentURIScheme + ":" + p.Address.EncodeAddress()
+	if len(params) != 0 {
+		uri += "?" + strings.Join(params, "&")
+	}
+	return uri
+}
+
+// parseURIAmount parses a decimal number of bitcoins with at most eight
+// digits after the decimal point, as required by BIP0021.  Exponents,
+// signs, and grouping separators are not allowed.
+func parseURIAmount(s string) (btcutil.Amount, error) {
+	whole, frac := s, ""
+	if i := strings.IndexByte(s, '.'); i != -1 {
+		whole, frac = s[:i], s[i+1:]
+	}
+	if whole == "" && frac == "" || len(frac) > 8 {
+		return 0, ErrInvalidURIAmount
+	}
+	for _, r := range whole + frac {
+		if r < '0' || r > '9' {
+			return 0, ErrInvalidURIAmount
+		}
+	}
+
+	// Pad the fractional part to a whole number of satoshis.
+	frac += strings.Repeat("0", 8-len(frac))
+	satoshis, err := strconv.ParseInt(whole+frac, 10, 64)
+	if err != nil || satoshis > btcutil.MaxSatoshi {
+		return 0, ErrInvalidURIAmount
+	}
+	return btcutil.Amount(satoshis), nil
+}
+
+// formatURIAmount formats amt as a decimal number of bitcoins without any
+// trailing zeros.
+func formatURIAmount(amt btcutil.Amount) string {
+	satoshis := int64(amt)
+	s := fmt.Sprintf("%d.%08d", satoshis/btcutil.SatoshiPerBitcoin,
+		satoshis%btcutil.SatoshiPerBitcoin)
+	return strings.TrimSuffix(strings.TrimRight(s, "0"), ".")
+}
+
+// escapeURIParam percent-encodes a payment URI parameter value.  Spaces
+// are encoded as %20 rather than '+', which not all URI parsers decode as
+// a space.
+func escapeURIParam(s string) string {
+	return strings.Replace(url.QueryEscape(s), "+", "%20", -1)
+}
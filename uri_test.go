@@ -0,0 +1,195 @@
+/*
+ * Copyright (c) 2014 Conformal Systems LLC <info@conformal.com>
+ *
+ * Permission to use, copy, modify, and distribute this software for any
+ * purpose with or without fee is hereby granted, provided that the above
+ * copyright notice and this permission notice appear in all copies.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+ * WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+ * MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ * ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+ * WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ * ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+ * OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ */
+
+package main
+
+import (
+	"github.com/conformal/btcutil"
+	"github.com/conformal/btcwire"
+	"testing"
+)
+
+const testURIAddr = "17XhEvq9Nahdj7Xe1nv6oRe1tEmaHUuynH"
+
+type paymentURITest struct {
+	name    string
+	uri     string
+	valid   bool
+	amount  btcutil.Amount
+	label   string
+	message string
+	str     string // String of the parsed URI, if it differs from uri
+}
+
+var paymentURITests = []paymentURITest{
+	{
+		name:  "address only",
+		uri:   "bitcoin:" + testURIAddr,
+		valid: true,
+	},
+	{
+		name:    "all parameters",
+		uri:     "bitcoin:" + testURIAddr + "?amount=0.5&label=Luke-Jr&message=Donation%20for%20project%20xyz",
+		valid:   true,
+		amount:  50000000,
+		label:   "Luke-Jr",
+		message: "Donation for project xyz",
+	},
+	{
+		name:   "whole amount",
+		uri:    "bitcoin:" + testURIAddr + "?amount=20",
+		valid:  true,
+		amount: 2000000000,
+	},
+	{
+		name:   "single satoshi",
+		uri:    "bitcoin:" + testURIAddr + "?amount=0.00000001",
+		valid:  true,
+		amount: 1,
+	},
+	{
+		name:   "trailing zeros",
+		uri:    "bitcoin:" + testURIAddr + "?amount=20.30",
+		valid:  true,
+		amount: 2030000000,
+		str:    "bitcoin:" + testURIAddr + "?amount=20.3",
+	},
+	{
+		name:  "uppercase scheme",
+		uri:   "BITCOIN:" + testURIAddr,
+		valid: true,
+		str:   "bitcoin:" + testURIAddr,
+	},
+	{
+		name:  "unknown parameter",
+		uri:   "bitcoin:" + testURIAddr + "?somethingyoudontunderstand=50",
+		valid: true,
+		str:   "bitcoin:" + testURIAddr,
+	},
+	{
+		name: "unknown required parameter",
+		uri:  "bitcoin:" + testURIAddr + "?req-somethingyoudontunderstand=50",
+	},
+	{
+		name: "repeated parameter",
+		uri:  "bitcoin:" + testURIAddr + "?amount=1&amount=2",
+	},
+	{
+		name: "bad amount",
+		uri:  "bitcoin:" + testURIAddr + "?amount=1e8",
+	},
+	{
+		name: "wrong scheme",
+		uri:  "litecoin:" + testURIAddr,
+	},
+	{
+		name: "missing address",
+		uri:  "bitcoin:",
+	},
+	{
+		name: "bad address",
+		uri:  "bitcoin:17XhEvq9Nahdj7Xe1nv6oRe1tEmaHUuynJ",
+	},
+}
+
+func TestPaymentURIs(t *testing.T) {
+	for _, test := range paymentURITests {
+		p, err := ParsePaymentURI(test.uri, btcwire.MainNet)
+		if !test.valid {
+			if err == nil {
+				t.Errorf("%s: invalid URI was parsed", test.name)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: cannot parse URI: %v", test.name, err)
+			continue
+		}
+
+		if p.Address.EncodeAddress() != testURIAddr {
+			t.Errorf("%s: address is %v, expected %v", test.name,
+				p.Address, testURIAddr)
+		}
+		if p.Amount != test.amount {
+			t.Errorf("%s: amount is %v, expected %v", test.name,
+				p.Amount, test.amount)
+		}
+		if p.Label != test.label {
+			t.Errorf("%s: label is %q, expected %q", test.name,
+				p.Label, test.label)
+		}
+		if p.Message != test.message {
+			t.Errorf("%s: message is %q, expected %q", test.name,
+				p.Message, test.message)
+		}
+
+		str := test.str
+		if str == "" {
+			str = test.uri
+		}
+		if s := p.String(); s != str {
+			t.Errorf("%s: URI string is %q, expected %q", test.name,
+				s, str)
+		}
+	}
+}
+
+type uriAmountTest struct {
+	s     string
+	amt   btcutil.Amount
+	valid bool
+}
+
+var uriAmountTests = []uriAmountTest{
+	{s: "1", amt: 100000000, valid: true},
+	{s: "1.", amt: 100000000, valid: true},
+	{s: ".5", amt: 50000000, valid: true},
+	{s: "0.12345678", amt: 12345678, valid: true},
+	{s: "21000000", amt: 2100000000000000, valid: true},
+	{s: ""},
+	{s: "."},
+	{s: "-1"},
+	{s: "+1"},
+	{s: "1e8"},
+	{s: "1,000"},
+	{s: "0x10"},
+	{s: "0.123456789"},
+	{s: "1.2.3"},
+	{s: "21000000.00000001"},
+	{s: "99999999999999999999"},
+}
+
+func TestParseURIAmount(t *testing.T) {
+	for _, test := range uriAmountTests {
+		amt, err := parseURIAmount(test.s)
+		if !test.valid {
+			if err != ErrInvalidURIAmount {
+				t.Errorf("amount %q: unexpected error %v, "+
+					"expected %v", test.s, err,
+					ErrInvalidURIAmount)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("amount %q: cannot parse: %v", test.s, err)
+			continue
+		}
+		if amt != test.amt {
+			t.Errorf("amount %q: parsed %v, expected %v", test.s,
+				amt, test.amt)
+		}
+	}
+}
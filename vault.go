@@ -0,0 +1,539 @@
+/*
+ * Copyright (c) 2014 Conformal Systems LLC <info@conformal.com>
+ *
+ * Permission to use, copy, modify, and distribute this software for any
+ * purpose with or without fee is hereby granted, provided that the above
+ * copyright notice and this permission notice appear in all copies.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+ * WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+ * MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ * ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+ * WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ * ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+ * OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ */
+
+package main
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/conformal/btcjson"
+	"github.com/conformal/btcutil"
+	"github.com/conformal/btcwallet/wallet"
+	"io"
+	"os"
+	"time"
+)
+
+// vaultRuleFileSuffix is the filename suffix of the file holding an
+// account's vault rule.
+const vaultRuleFileSuffix = "vault.bin"
+
+// vaultRuleVersion is the current version of serialized vault rules.
+const vaultRuleVersion uint32 = 1
+
+// vaultSendsFilename is the name of the file in the network directory
+// holding sends delayed by vault rules.
+const vaultSendsFilename = "vaultsends.bin"
+
+// vaultSendsVersion is the current version of the serialized vault sends.
+const vaultSendsVersion uint32 = 1
+
+// maxVaultSends is the maximum number of sends read from saved vault sends.
+const maxVaultSends = 100000
+
+// vaultSendNtfnMethod is the method of the websocket notification sent
+// each time the state of a vault send changes.
+const vaultSendNtfnMethod = "vaultsend"
+
+// VaultSendState describes the progress of a send delayed by a vault rule.
+type VaultSendState int
+
+// Possible states of a vault send.
+const (
+	// VaultSendQueued describes a send waiting for its delay to pass.
+	VaultSendQueued VaultSendState = iota
+
+	// VaultSendDue describes a send whose delay has passed, but which
+	// has not yet been broadcast.
+	VaultSendDue
+
+	// VaultSendBroadcast describes a send which has been broadcast, or
+	// which is held until its lock time passes.
+	VaultSendBroadcast
+
+	// VaultSendCancelled describes a send which was cancelled, or which
+	// can no longer be sent.
+	VaultSendCancelled
+)
+
+var vaultSendStateStrings = [...]string{
+	VaultSendQueued:    "queued",
+	VaultSendDue:       "due",
+	VaultSendBroadcast: "broadcast",
+	VaultSendCancelled: "cancelled",
+}
+
+// String returns the string used to describe the state in JSON results
+// and notifications.
+func (s VaultSendState) String() string {
+	if s < 0 || int(s) >= len(vaultSendStateStrings) {
+		return "unknown"
+	}
+	return vaultSendStateStrings[s]
+}
+
+// VaultRule delays sends of large amounts from an account, so they may be
+// cancelled before they are broadcast.  Amounts sent are the values of all
+// outputs except change, and do not include fees.  Exactly one of the block
+// and time delays is non-zero.
+type VaultRule struct {
+	// Threshold is the smallest amount sent by a transaction which is
+	// delayed.
+	Threshold btcutil.Amount
+
+	// DelayBlocks is the number of blocks which must be connected after
+	// a send is requested before it is broadcast.
+	DelayBlocks int32
+
+	// DelayTime is the time which must pass after a send is requested
+	// before it is broadcast.  As delays are only checked when blocks
+	// are connected, sends may be broadcast some time after their delay
+	// passes.
+	DelayTime time.Duration
+}
+
+// delays returns whether the rule delays the send of a transaction created
+// by an account.
+func (r *VaultRule) delays(createdTx *CreatedTx) bool {
+	amt := sentAmount(createdTx.tx.MsgTx(), createdTx.changeIndex)
+	return amt >= r.Threshold
+}
+
+// SetVaultRule replaces the vault rule of an account and writes it to
+// disk.  A nil rule removes any previous rule.  Sends already delayed are
+// not affected.  The wallet must be unlocked to change the rule.
+func (a *Account) SetVaultRule(r *VaultRule) error {
+	if a.IsLocked() {
+		return wallet.ErrWalletLocked
+	}
+
+	if r == nil {
+		path := accountFilename(vaultRuleFileSuffix, a.name,
+			networkDir(cfg.Net()))
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	} else {
+		filename := accountFilename(vaultRuleFileSuffix, a.name, "")
+		if err := saveNetworkFile(filename, r.write); err != nil {
+			return err
+		}
+	}
+	a.vault = r
+	return nil
+}
+
+// readVaultRule reads the vault rule saved for the account named account.
+// If the account has no saved rule, nil is returned.
+func readVaultRule(account string) (*VaultRule, error) {
+	r := new(VaultRule)
+	filename := accountFilename(vaultRuleFileSuffix, account, "")
+	err := loadNetworkFile(filename, r.read)
+	switch {
+	case os.IsNotExist(err):
+		return nil, nil
+	case err != nil:
+		return nil, err
+	}
+	return r, nil
+}
+
+// write serializes the rule to w.
+func (r *VaultRule) write(w io.Writer) error {
+	fields := []interface{}{
+		vaultRuleVersion,
+		int64(r.Threshold),
+		r.DelayBlocks,
+		int64(r.DelayTime),
+	}
+	for _, field := range fields {
+		if err := binary.Write(w, binary.LittleEndian, field); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// read deserializes a rule written by write.
+func (r *VaultRule) read(rd io.Reader) error {
+	var vers uint32
+	var threshold, delayTime int64
+	var delayBlocks int32
+	if err := binary.Read(rd, binary.LittleEndian, &vers); err != nil {
+		return err
+	}
+	if vers != vaultRuleVersion {
+		return fmt.Errorf("unknown vault rule version %d", vers)
+	}
+	fields := []interface{}{&threshold, &delayBlocks, &delayTime}
+	for _, field := range fields {
+		if err := binary.Read(rd, binary.LittleEndian, field); err != nil {
+			return err
+		}
+	}
+
+	r.Threshold = btcutil.Amount(threshold)
+	r.DelayBlocks = delayBlocks
+	r.DelayTime = time.Duration(delayTime)
+	return nil
+}
+
+// vaultSend is a signed transaction held until its vault delay passes or
+// it is cancelled.  Sends delayed by blocks become due once the block at
+// dueHeight is connected, and sends delayed by time become due once a
+// block is connected at or after dueTime.
+type vaultSend struct {
+	id        string
+	created   time.Time
+	dueHeight int32
+	dueTime   time.Time
+	due       bool
+	tx        *heldTx
+}
+
+// isDue returns whether the delay of the send has passed once the block at
+// height is connected at time now.
+func (v *vaultSend) isDue(height int32, now time.Time) bool {
+	if v.dueTime.IsZero() {
+		return height >= v.dueHeight
+	}
+	return !now.Before(v.dueTime)
+}
+
+// result returns the JSON result describing the send in state.
+func (v *vaultSend) result(state VaultSendState) *VaultSendResult {
+	amt := sentAmount(v.tx.tx.MsgTx(), v.tx.changeIndex)
+	r := &VaultSendResult{
+		ID:        v.id,
+		Account:   v.tx.account,
+		TxID:      v.tx.tx.Sha().String(),
+		State:     state.String(),
+		Amount:    amt.ToUnit(btcutil.AmountBTC),
+		Created:   v.created.Unix(),
+		DueHeight: v.dueHeight,
+	}
+	if !v.dueTime.IsZero() {
+		r.DueTime = v.dueTime.Unix()
+	}
+	return r
+}
+
+// VaultSendResult models the data returned for each send delayed by a
+// vault rule, both in listvaultsends results and vaultsend notifications.
+// The amount, which does not include change or fees, is in bitcoins.
+// Exactly one of the due height and due time is set.
+type VaultSendResult struct {
+	ID        string  `json:"id"`
+	Account   string  `json:"account"`
+	TxID      string  `json:"txid"`
+	State     string  `json:"state"`
+	Amount    float64 `json:"amount"`
+	Created   int64   `json:"created"`
+	DueHeight int32   `json:"dueheight,omitempty"`
+	DueTime   int64   `json:"duetime,omitempty"`
+}
+
+// VaultSends holds every send delayed by a vault rule.
+var VaultSends = &VaultQueue{}
+
+// VaultQueue is a persistent queue of signed transactions created by sends
+// from accounts with vault rules.  The inputs of each queued transaction
+// are reserved until it is broadcast or cancelled.  Clients are notified each
+// time a send is queued, becomes due, and is broadcast or cancelled.
+//
+// As the queue is only modified by RPC and notification handlers, it is
+// protected by the account manager's semaphore rather than a mutex.
+type VaultQueue struct {
+	sends []*vaultSend
+}
+
+// Start loads the previously saved vault sends from disk, if any.  This
+// must be called before any requests or notifications are handled.
+func (q *VaultQueue) Start() {
+	err := loadNetworkFile(vaultSendsFilename, q.read)
+	if err != nil && !os.IsNotExist(err) {
+		log.Warnf("Cannot read sends delayed by vault rules: %v", err)
+	}
+}
+
+// Add holds a signed transaction created by account a until the delay of
+// the account's vault rule passes, and returns the ID used to cancel it.
+// The transaction's inputs are reserved and the queue is saved.
+func (q *VaultQueue) Add(a *Account, createdTx *CreatedTx) (string, error) {
+	bs, err := GetCurBlock()
+	if err != nil {
+		return "", err
+	}
+	id, err := newRandomID()
+	if err != nil {
+		return "", err
+	}
+
+	h, err := a.holdCreatedTx(createdTx)
+	if err != nil {
+		return "", err
+	}
+	v := &vaultSend{
+		id:      id,
+		created: time.Now(),
+		tx:      h,
+	}
+	if a.vault.DelayTime != 0 {
+		v.dueTime = v.created.Add(a.vault.DelayTime)
+	} else {
+		v.dueHeight = bs.Height + a.vault.DelayBlocks
+	}
+	q.sends = append(q.sends, v)
+	if err := q.save(); err != nil {
+		return "", err
+	}
+
+	log.Infof("Delaying transaction %v from account %s with vault ID %s",
+		h.tx.Sha(), h.account, v.id)
+	q.notify(v, VaultSendQueued)
+	return v.id, nil
+}
+
+// Find returns the vault send with ID id, or nil if there is no such send.
+func (q *VaultQueue) Find(id string) *vaultSend {
+	for _, v := range q.sends {
+		if v.id == id {
+			return v
+		}
+	}
+	return nil
+}
+
+// Cancel removes a vault send, releases its inputs, and saves the queue.
+func (q *VaultQueue) Cancel(v *vaultSend) error {
+	// If the account no longer exists, there are no inputs to release.
+	if a, err := AcctMgr.Account(v.tx.account); err == nil {
+		if err := a.releaseHeldTx(v.tx); err != nil {
+			return err
+		}
+	}
+	for i, s := range q.sends {
+		if s == v {
+			q.sends = append(q.sends[:i], q.sends[i+1:]...)
+			break
+		}
+	}
+	if err := q.save(); err != nil {
+		return err
+	}
+
+	log.Infof("Cancelled vault send %s", v.id)
+	q.notify(v, VaultSendCancelled)
+	return nil
+}
+
+// BlockConnected broadcasts each queued send whose delay has passed once
+// bs is connected.  Sends which fail to broadcast remain due and are
+// retried with the next block, unless their inputs have been spent by
+// another transaction, in which case they are cancelled.
+func (q *VaultQueue) BlockConnected(bs *wallet.BlockStamp) {
+	now := time.Now()
+	remaining := q.sends[:0]
+	modified := false
+	for _, v := range q.sends {
+		if !v.due {
+			if !v.isDue(bs.Height, now) {
+				remaining = append(remaining, v)
+				continue
+			}
+			v.due = true
+			modified = true
+			q.notify(v, VaultSendDue)
+		}
+
+		err := sendHeldTx(v.tx)
+		switch err {
+		case nil:
+			log.Infof("Sent vault send %s", v.id)
+			q.notify(v, VaultSendBroadcast)
+
+		case ErrHeldTxInputSpent, ErrNotFound:
+			log.Errorf("Dropping vault send %s: %v", v.id, err)
+			q.notify(v, VaultSendCancelled)
+
+		default:
+			log.Warnf("Cannot send vault send %s: %v", v.id, err)
+			remaining = append(remaining, v)
+			continue
+		}
+		modified = true
+	}
+	q.sends = remaining
+
+	if modified {
+		if err := q.save(); err != nil {
+			log.Errorf("Cannot write vault sends: %v", err)
+		}
+	}
+}
+
+// notify sends a vaultsend notification describing v in state to all
+// connected clients.
+func (q *VaultQueue) notify(v *vaultSend, state VaultSendState) {
+	ntfn := &vaultSendNtfn{send: v.result(state)}
+	mntfn, err := ntfn.MarshalJSON()
+	if err != nil {
+		log.Errorf("Cannot marshal vault send notification: %v", err)
+		return
+	}
+	allClients <- mntfn
+}
+
+// save writes the queue to disk, replacing any previously saved queue.
+func (q *VaultQueue) save() error {
+	return saveNetworkFile(vaultSendsFilename, q.write)
+}
+
+// write serializes the queue to w.
+func (q *VaultQueue) write(w io.Writer) error {
+	if err := binary.Write(w, binary.LittleEndian, vaultSendsVersion); err != nil {
+		return err
+	}
+	if err := binary.Write(w, binary.LittleEndian, uint32(len(q.sends))); err != nil {
+		return err
+	}
+	for _, v := range q.sends {
+		if err := writeString(w, v.id); err != nil {
+			return err
+		}
+		var dueTime int64
+		if !v.dueTime.IsZero() {
+			dueTime = v.dueTime.Unix()
+		}
+		fields := []interface{}{
+			v.created.Unix(),
+			v.dueHeight,
+			dueTime,
+			v.due,
+		}
+		for _, field := range fields {
+			if err := binary.Write(w, binary.LittleEndian, field); err != nil {
+				return err
+			}
+		}
+		if err := writeHeldTx(w, v.tx); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// read deserializes a queue written by write, replacing all vault sends.
+func (q *VaultQueue) read(r io.Reader) error {
+	var vers, n uint32
+	if err := binary.Read(r, binary.LittleEndian, &vers); err != nil {
+		return err
+	}
+	if vers != vaultSendsVersion {
+		return fmt.Errorf("unknown vault sends version %d", vers)
+	}
+	if err := binary.Read(r, binary.LittleEndian, &n); err != nil {
+		return err
+	}
+	if n > maxVaultSends {
+		return errors.New("too many saved vault sends")
+	}
+
+	sends := make([]*vaultSend, 0, n)
+	for i := uint32(0); i < n; i++ {
+		id, err := readString(r, maxRandomIDLen)
+		if err != nil {
+			return err
+		}
+		var created, dueTime int64
+		var dueHeight int32
+		var due bool
+		fields := []interface{}{&created, &dueHeight, &dueTime, &due}
+		for _, field := range fields {
+			if err := binary.Read(r, binary.LittleEndian, field); err != nil {
+				return err
+			}
+		}
+		h, err := readHeldTx(r)
+		if err != nil {
+			return err
+		}
+		v := &vaultSend{
+			id:        id,
+			created:   time.Unix(created, 0),
+			dueHeight: dueHeight,
+			due:       due,
+			tx:        h,
+		}
+		if dueTime != 0 {
+			v.dueTime = time.Unix(dueTime, 0)
+		}
+		sends = append(sends, v)
+	}
+	q.sends = sends
+	return nil
+}
+
+// vaultSendNtfn is a websocket notification sent to clients each time the
+// state of a vault send changes.
+type vaultSendNtfn struct {
+	send *VaultSendResult
+}
+
+// Enforce that vaultSendNtfn satisifies the btcjson.Cmd interface.
+var _ btcjson.Cmd = &vaultSendNtfn{}
+
+// Id satisifies the btcjson.Cmd interface by returning nil for a
+// notification ID.
+func (n *vaultSendNtfn) Id() interface{} {
+	return nil
+}
+
+// Method satisifies the btcjson.Cmd interface by returning the method of
+// the notification.
+func (n *vaultSendNtfn) Method() string {
+	return vaultSendNtfnMethod
+}
+
+// MarshalJSON returns the JSON encoding of n.  Required to satisify the
+// btcjson.Cmd interface.
+func (n *vaultSendNtfn) MarshalJSON() ([]byte, error) {
+	raw, err := btcjson.NewRawCmd(nil, n.Method(), []interface{}{n.send})
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(raw)
+}
+
+// UnmarshalJSON unmarshals the JSON encoding of n into n.  Part of the
+// btcjson.Cmd interface.
+func (n *vaultSendNtfn) UnmarshalJSON(b []byte) error {
+	var r btcjson.RawCmd
+	if err := json.Unmarshal(b, &r); err != nil {
+		return err
+	}
+	if len(r.Params) != 1 {
+		return btcjson.ErrWrongNumberOfParams
+	}
+	var send VaultSendResult
+	if err := json.Unmarshal(r.Params[0], &send); err != nil {
+		return err
+	}
+	n.send = &send
+	return nil
+}
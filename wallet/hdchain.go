@@ -0,0 +1,447 @@
+/*
+ * Copyright (c) 2014 Conformal Systems LLC <info@conformal.com>
+ *
+ * Permission to use, copy, modify, and distribute this software for any
+ * purpose with or without fee is hereby granted, provided that the above
+ * copyright notice and this permission notice appear in all copies.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+ * WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+ * MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ * ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+ * WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ * ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+ * OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ */
+
+package wallet
+
+import (
+	"crypto/hmac"
+	"crypto/sha512"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"github.com/conformal/btcec"
+	"io"
+	"math/big"
+)
+
+// HardenedKeyStart is the index of the first hardened BIP32 child key.
+// Hardened child keys can only be derived from a private key.
+const HardenedKeyStart = 0x80000000
+
+// ErrInvalidChild describes an error where a BIP32 child key can not be
+// derived at some index.  This happens with a probability lower than 1 in
+// 2^127, and the key at the next index should be used instead.
+var ErrInvalidChild = errors.New("invalid child key at index")
+
+// masterKeySeed is the HMAC key used to derive a BIP32 master key from a
+// seed.
+var masterKeySeed = []byte("Bitcoin seed")
+
+// Indexes of the BIP32 chains of an HD wallet.  Addresses given out for
+// payments are derived from the external chain, and change addresses from
+// the internal chain.
+const (
+	hdExternalChain = 0
+	hdInternalChain = 1
+)
+
+// hdAccount is the index of the hardened BIP32 account key, derived from
+// the master key, which both chains of an HD wallet are derived from.
+// Following the BIP32 wallet layout, external addresses use the path
+// m/0'/0/k and internal addresses use m/0'/1/k.
+const hdAccount = HardenedKeyStart + 0
+
+// hmacSHA512 splits the HMAC-SHA512 of data keyed by key into its left and
+// right 32 byte halves.
+func hmacSHA512(key, data []byte) (il, ir []byte) {
+	mac := hmac.New(sha512.New, key)
+	mac.Write(data)
+	sum := mac.Sum(nil)
+	return sum[:32], sum[32:]
+}
+
+// NewMasterKey derives the BIP32 master private key and chaincode from a
+// seed, which must be between 16 and 64 bytes long.
+func NewMasterKey(seed []byte) (privkey, chaincode []byte, err error) {
+	if len(seed) < 16 || len(seed) > 64 {
+		return nil, nil, fmt.Errorf("invalid seed length %d (must be "+
+			"between 16 and 64)", len(seed))
+	}
+
+	il, ir := hmacSHA512(masterKeySeed, seed)
+	k := new(big.Int).SetBytes(il)
+	if k.Sign() == 0 || k.Cmp(btcec.S256().N) >= 0 {
+		return nil, nil, errors.New("invalid seed")
+	}
+	return il, ir, nil
+}
+
+// ChildPrivKey derives the BIP32 child private key and chaincode at index i
+// from a parent private key and chaincode, which must both be 32 bytes
+// long.  ErrInvalidChild is returned if there is no valid key at index i.
+func ChildPrivKey(privkey, chaincode []byte, i uint32) ([]byte, []byte, error) {
+	if len(privkey) != 32 {
+		return nil, nil, fmt.Errorf("invalid privkey length %d (must be 32)",
+			len(privkey))
+	}
+	if len(chaincode) != 32 {
+		return nil, nil, fmt.Errorf("invalid chaincode length %d (must be 32)",
+			len(chaincode))
+	}
+
+	data := make([]byte, 0, 37)
+	if i >= HardenedKeyStart {
+		data = append(data, 0x00)
+		data = append(data, privkey...)
+	} else {
+		data = append(data, pubkeyFromPrivkey(privkey, true)...)
+	}
+	var index [4]byte
+	binary.BigEndian.PutUint32(index[:], i)
+	data = append(data, index[:]...)
+
+	il, ir := hmacSHA512(chaincode, data)
+	n := btcec.S256().N
+	k := new(big.Int).SetBytes(il)
+	if k.Cmp(n) >= 0 {
+		return nil, nil, ErrInvalidChild
+	}
+	k.Add(k, new(big.Int).SetBytes(privkey))
+	k.Mod(k, n)
+	if k.Sign() == 0 {
+		return nil, nil, ErrInvalidChild
+	}
+	return pad(32, k.Bytes()), ir, nil
+}
+
+// ChildPubKey derives the BIP32 child public key and chaincode at the
+// non-hardened index i from a parent public key and chaincode.  pubkey must
+// be 33 or 65 bytes, and chaincode must be 32 bytes long.  The child public
+// key is always compressed.  ErrInvalidChild is returned if there is no
+// valid key at index i.
+func ChildPubKey(pubkey, chaincode []byte, i uint32) ([]byte, []byte, error) {
+	if i >= HardenedKeyStart {
+		return nil, nil, errors.New("hardened child keys can not be " +
+			"derived from a public key")
+	}
+	if !(len(pubkey) == 65 || len(pubkey) == 33) {
+		return nil, nil, fmt.Errorf("invalid pubkey length %d", len(pubkey))
+	}
+	if len(chaincode) != 32 {
+		return nil, nil, fmt.Errorf("invalid chaincode length %d (must be 32)",
+			len(chaincode))
+	}
+
+	pk, err := btcec.ParsePubKey(pubkey, btcec.S256())
+	if err != nil {
+		return nil, nil, err
+	}
+	data := make([]byte, 0, 37)
+	data = append(data, pk.SerializeCompressed()...)
+	var index [4]byte
+	binary.BigEndian.PutUint32(index[:], i)
+	data = append(data, index[:]...)
+
+	il, ir := hmacSHA512(chaincode, data)
+	curve := btcec.S256()
+	if new(big.Int).SetBytes(il).Cmp(curve.N) >= 0 {
+		return nil, nil, ErrInvalidChild
+	}
+	x, y := curve.ScalarBaseMult(il)
+	x, y = curve.Add(x, y, pk.X, pk.Y)
+	if x.Sign() == 0 && y.Sign() == 0 {
+		return nil, nil, ErrInvalidChild
+	}
+	childPk := &btcec.PublicKey{
+		Curve: curve,
+		X:     x,
+		Y:     y,
+	}
+	return childPk.SerializeCompressed(), ir, nil
+}
+
+// hdChain is one BIP32 chain of an HD wallet.  The extended public key of
+// the chain is saved unencrypted so addresses may be derived from locked
+// and watching-only wallets, in the same way Armory chained addresses are
+// derived from the previous public key and chaincode.
+type hdChain struct {
+	pubKey    [33]byte
+	chaincode [32]byte
+	next      uint32 // index of the next child key to derive
+}
+
+// hdChains holds the external and internal chains of an HD wallet.  The
+// BIP32 master key is the wallet's root address, and is encrypted with
+// every other private key.
+type hdChains struct {
+	chains [2]hdChain
+}
+
+// newHDChains derives the external and internal chains of an HD wallet
+// from the BIP32 master private key and chaincode.
+func newHDChains(masterKey, masterChaincode []byte) (*hdChains, error) {
+	hd := new(hdChains)
+	for branch := range hd.chains {
+		privkey, chaincode, err := hdChainPrivKey(masterKey,
+			masterChaincode, uint32(branch))
+		if err != nil {
+			return nil, err
+		}
+		c := &hd.chains[branch]
+		copy(c.pubKey[:], pubkeyFromPrivkey(privkey, true))
+		copy(c.chaincode[:], chaincode)
+		zero(privkey)
+	}
+	return hd, nil
+}
+
+// hdChainPrivKey derives the extended private key of the chain branch from
+// the BIP32 master private key and chaincode.
+func hdChainPrivKey(masterKey, masterChaincode []byte, branch uint32) ([]byte, []byte, error) {
+	acctKey, acctChaincode, err := ChildPrivKey(masterKey,
+		masterChaincode, hdAccount)
+	if err != nil {
+		return nil, nil, err
+	}
+	privkey, chaincode, err := ChildPrivKey(acctKey, acctChaincode, branch)
+	zero(acctKey)
+	return privkey, chaincode, err
+}
+
+// hdChainDepth returns the value of the chainDepth field of an address
+// derived from an HD wallet chain, which records the BIP32 chain and child
+// index of the address.  Armory does not use this field.
+func hdChainDepth(branch, child uint32) int64 {
+	return int64(branch)<<32 | int64(child)
+}
+
+// hdPath returns the chain and child index of an address derived from an
+// HD wallet chain.
+func (a *btcAddress) hdPath() (branch, child uint32) {
+	return uint32(a.chainDepth >> 32), uint32(a.chainDepth)
+}
+
+// nextHDAddress derives the next address of an HD wallet chain and adds it
+// to the end of the wallet's address chain.  If the wallet is unlocked,
+// the private key is derived and encrypted.  Otherwise, the address is
+// derived from the chain's public key, and the private key is created on
+// the next unlock.
+func (w *Wallet) nextHDAddress(branch uint32, bs *BlockStamp) (*btcAddress, error) {
+	c := &w.hd.chains[branch]
+
+	child := c.next
+	var pubkey []byte
+	for {
+		var err error
+		pubkey, _, err = ChildPubKey(c.pubKey[:], c.chaincode[:], child)
+		if err == ErrInvalidChild {
+			child++
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		break
+	}
+
+	var newaddr *btcAddress
+	if w.IsLocked() {
+		var err error
+		newaddr, err = newBtcAddressWithoutPrivkey(w, pubkey, nil, bs)
+		if err != nil {
+			return nil, err
+		}
+	} else {
+		masterKey, err := w.keyGenerator.unlock(w.secret)
+		if err != nil {
+			return nil, err
+		}
+		chainKey, chainCode, err := hdChainPrivKey(masterKey,
+			w.keyGenerator.chaincode[:], branch)
+		zero(masterKey)
+		if err != nil {
+			return nil, err
+		}
+		privkey, _, err := ChildPrivKey(chainKey, chainCode, child)
+		zero(chainKey)
+		if err != nil {
+			return nil, err
+		}
+		newaddr, err = newBtcAddress(w, privkey, nil, bs, true)
+		if err != nil {
+			return nil, err
+		}
+		if err := newaddr.verifyKeypairs(); err != nil {
+			return nil, err
+		}
+		if err := newaddr.encrypt(w.secret); err != nil {
+			return nil, err
+		}
+	}
+
+	a := newaddr.Address()
+	if _, ok := w.addrMap[getAddressKey(a)]; ok {
+		return nil, ErrDuplicate
+	}
+	newaddr.chainIndex = w.lastChainIdx + 1
+	newaddr.chainDepth = hdChainDepth(branch, child)
+	w.addrMap[getAddressKey(a)] = newaddr
+	w.chainIdxMap[newaddr.chainIndex] = a
+	w.lastChainIdx++
+	c.next = child + 1
+
+	if newaddr.flags.createPrivKeyNextUnlock && w.missingKeysStart == 0 {
+		w.missingKeysStart = newaddr.chainIndex
+	}
+
+	return newaddr, nil
+}
+
+// nextChainedHDAddress returns the next address of an HD wallet chain and
+// marks it used.  HD wallets do not use a keypool, as every address can be
+// recreated from the master key, so a new address is always derived.
+func (w *Wallet) nextChainedHDAddress(branch uint32, bs *BlockStamp) (*btcAddress, error) {
+	addr, err := w.nextHDAddress(branch, bs)
+	if err != nil {
+		return nil, err
+	}
+	w.highestUsed = addr.chainIndex
+	return addr, nil
+}
+
+// createMissingHDPrivateKeys derives and encrypts the private keys of all
+// HD wallet addresses which were created while the wallet was locked.
+func (w *Wallet) createMissingHDPrivateKeys() error {
+	if w.IsLocked() {
+		return ErrWalletLocked
+	}
+
+	var chainKeys, chainCodes [2][]byte
+	defer func() {
+		for _, k := range chainKeys {
+			zero(k)
+		}
+	}()
+
+	for i := int64(0); ; i++ {
+		apkh, ok := w.chainIdxMap[i]
+		if !ok {
+			// Finished.
+			break
+		}
+		addr, ok := w.addrMap[getAddressKey(apkh)].(*btcAddress)
+		if !ok {
+			return errors.New("found non-pubkey chained address")
+		}
+		if !addr.flags.createPrivKeyNextUnlock {
+			continue
+		}
+
+		branch, child := addr.hdPath()
+		if branch != hdExternalChain && branch != hdInternalChain {
+			return errors.New("address has an unknown HD chain")
+		}
+		if chainKeys[branch] == nil {
+			masterKey, err := w.keyGenerator.unlock(w.secret)
+			if err != nil {
+				return err
+			}
+			chainKeys[branch], chainCodes[branch], err = hdChainPrivKey(
+				masterKey, w.keyGenerator.chaincode[:], branch)
+			zero(masterKey)
+			if err != nil {
+				return err
+			}
+		}
+		privkey, _, err := ChildPrivKey(chainKeys[branch],
+			chainCodes[branch], child)
+		if err != nil {
+			return err
+		}
+
+		addr.privKeyCT = privkey
+		if err := addr.verifyKeypairs(); err != nil {
+			return err
+		}
+		if err := addr.encrypt(w.secret); err != nil {
+			return err
+		}
+		addr.flags.createPrivKeyNextUnlock = false
+	}
+
+	w.missingKeysStart = 0
+	return nil
+}
+
+// hdChainsEntry is the entry type for the chains of an HD wallet.
+type hdChainsEntry struct {
+	hd hdChains
+}
+
+// WriteTo implements io.WriterTo by writing the entry to w.
+func (e *hdChainsEntry) WriteTo(w io.Writer) (n int64, err error) {
+	var written int64
+
+	// Write header
+	if written, err = binaryWrite(w, binary.LittleEndian, hdChainsHeader); err != nil {
+		return n + written, err
+	}
+	n += written
+
+	for i := range e.hd.chains {
+		c := &e.hd.chains[i]
+		datas := []interface{}{
+			&c.pubKey,
+			walletHash(c.pubKey[:]),
+			&c.chaincode,
+			walletHash(c.chaincode[:]),
+			c.next,
+		}
+		for _, data := range datas {
+			written, err = binaryWrite(w, binary.LittleEndian, data)
+			if err != nil {
+				return n + written, err
+			}
+			n += written
+		}
+	}
+	return n, nil
+}
+
+// ReadFrom implements io.ReaderFrom by reading the entry from r.
+func (e *hdChainsEntry) ReadFrom(r io.Reader) (n int64, err error) {
+	var read int64
+
+	for i := range e.hd.chains {
+		c := &e.hd.chains[i]
+		var chkPubKey, chkChaincode uint32
+		datas := []interface{}{
+			&c.pubKey,
+			&chkPubKey,
+			&c.chaincode,
+			&chkChaincode,
+			&c.next,
+		}
+		for _, data := range datas {
+			read, err = binaryRead(r, binary.LittleEndian, data)
+			if err != nil {
+				return n + read, err
+			}
+			n += read
+		}
+
+		if err = verifyAndFix(c.pubKey[:], chkPubKey); err != nil {
+			return n, err
+		}
+		if err = verifyAndFix(c.chaincode[:], chkChaincode); err != nil {
+			return n, err
+		}
+		if _, err = btcec.ParsePubKey(c.pubKey[:], btcec.S256()); err != nil {
+			return n, err
+		}
+	}
+	return n, nil
+}
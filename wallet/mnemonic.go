@@ -0,0 +1,140 @@
+/*
+ * Copyright (c) 2014 Conformal Systems LLC <info@conformal.com>
+ *
+ * Permission to use, copy, modify, and distribute this software for any
+ * purpose with or without fee is hereby granted, provided that the above
+ * copyright notice and this permission notice appear in all copies.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+ * WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+ * MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ * ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+ * WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ * ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+ * OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ */
+
+package wallet
+
+import (
+	"code.google.com/p/go.crypto/pbkdf2"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/sha512"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+)
+
+// ErrInvalidMnemonic describes an error where a mnemonic contains an
+// unknown word, has an invalid number of words, or fails its checksum.
+var ErrInvalidMnemonic = errors.New("invalid mnemonic")
+
+// MnemonicEntropySize is the number of bytes of random entropy encoded by
+// a mnemonic created by GenerateMnemonic.  This results in a 24 word
+// mnemonic.
+const MnemonicEntropySize = 32
+
+// mnemonicSeedIterations is the number of PBKDF2 iterations used to derive
+// a seed from a mnemonic.
+const mnemonicSeedIterations = 2048
+
+// mnemonicWordIndex maps each word of the BIP0039 word list to its index.
+var mnemonicWordIndex = make(map[string]int, len(mnemonicWords))
+
+func init() {
+	for i, word := range mnemonicWords {
+		mnemonicWordIndex[word] = i
+	}
+}
+
+// GenerateMnemonic creates a new BIP0039 mnemonic from random entropy.
+func GenerateMnemonic() (string, error) {
+	entropy := make([]byte, MnemonicEntropySize)
+	if _, err := rand.Read(entropy); err != nil {
+		return "", err
+	}
+	defer zero(entropy)
+	return NewMnemonic(entropy)
+}
+
+// NewMnemonic encodes entropy as a BIP0039 mnemonic.  entropy must be
+// between 16 and 32 bytes long, and a multiple of 4 bytes.
+func NewMnemonic(entropy []byte) (string, error) {
+	if len(entropy) < 16 || len(entropy) > 32 || len(entropy)%4 != 0 {
+		return "", fmt.Errorf("invalid entropy length %d", len(entropy))
+	}
+
+	// The entropy is followed by one checksum bit for every 32 bits of
+	// entropy, taken from the start of the entropy's SHA256 hash.  Each
+	// 11 bit group of the result encodes one word.
+	checksumBits := uint(len(entropy) / 4)
+	checksum := sha256.Sum256(entropy)
+	b := new(big.Int).SetBytes(entropy)
+	b.Lsh(b, checksumBits)
+	b.Or(b, big.NewInt(int64(checksum[0]>>(8-checksumBits))))
+
+	nwords := (len(entropy)*8 + int(checksumBits)) / 11
+	words := make([]string, nwords)
+	mask := big.NewInt(2047)
+	for i := nwords - 1; i >= 0; i-- {
+		idx := new(big.Int).And(b, mask)
+		words[i] = mnemonicWords[idx.Int64()]
+		b.Rsh(b, 11)
+	}
+	return strings.Join(words, " "), nil
+}
+
+// MnemonicEntropy decodes the entropy of a BIP0039 mnemonic, verifying
+// its checksum.  Words may be separated by any whitespace and are not case
+// sensitive.  ErrInvalidMnemonic is returned if the mnemonic is not valid.
+func MnemonicEntropy(mnemonic string) ([]byte, error) {
+	words := strings.Fields(strings.ToLower(mnemonic))
+	switch len(words) {
+	case 12, 15, 18, 21, 24:
+	default:
+		return nil, ErrInvalidMnemonic
+	}
+
+	b := new(big.Int)
+	for _, word := range words {
+		idx, ok := mnemonicWordIndex[word]
+		if !ok {
+			return nil, ErrInvalidMnemonic
+		}
+		b.Lsh(b, 11)
+		b.Or(b, big.NewInt(int64(idx)))
+	}
+
+	checksumBits := uint(len(words) / 3)
+	entropyLen := (len(words)*11 - int(checksumBits)) / 8
+	checksum := byte(new(big.Int).And(b,
+		big.NewInt(1<<checksumBits-1)).Int64())
+	b.Rsh(b, checksumBits)
+	entropy := pad(entropyLen, b.Bytes())
+
+	sum := sha256.Sum256(entropy)
+	if sum[0]>>(8-checksumBits) != checksum {
+		return nil, ErrInvalidMnemonic
+	}
+	return entropy, nil
+}
+
+// MnemonicSeed verifies a BIP0039 mnemonic and derives the 64 byte seed it
+// encodes, using an optional password.  The seed is used to create the
+// BIP32 master key of a wallet.  The password is used as given, and should
+// be normalized by the caller if it contains non-ASCII characters.
+func MnemonicSeed(mnemonic, password string) ([]byte, error) {
+	entropy, err := MnemonicEntropy(mnemonic)
+	if err != nil {
+		return nil, err
+	}
+	zero(entropy)
+
+	// The seed is derived from the mnemonic in its normalized form, with
+	// lowercase words separated by a single space.
+	normalized := strings.Join(strings.Fields(strings.ToLower(mnemonic)), " ")
+	return pbkdf2.Key([]byte(normalized), []byte("mnemonic"+password),
+		mnemonicSeedIterations, 64, sha512.New), nil
+}
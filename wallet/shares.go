@@ -0,0 +1,286 @@
+/*
+ * Copyright (c) 2014 Conformal Systems LLC <info@conformal.com>
+ *
+ * Permission to use, copy, modify, and distribute this software for any
+ * purpose with or without fee is hereby granted, provided that the above
+ * copyright notice and this permission notice appear in all copies.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+ * WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+ * MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ * ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+ * WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ * ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+ * OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ */
+
+package wallet
+
+import (
+	"bytes"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"github.com/conformal/btcutil"
+	"github.com/conformal/btcwire"
+)
+
+// The root private key and chaincode of a wallet may be backed up as
+// shares using Shamir's secret sharing, where any threshold of the shares
+// restore the wallet, but fewer reveal nothing about the root key.  The 64
+// byte secret (the private key followed by the chaincode) is split one
+// byte at a time, with each byte the constant term of a random polynomial
+// over GF(2^8) of degree threshold-1.  A share holds the value of every
+// polynomial at the share's index.
+//
+// A share is the base58 encoding of the following bytes:
+//
+//   - 1 byte root type, either shareRootArmory or shareRootHD
+//   - 1 byte network, 0 for mainnet or 1 for testnet3
+//   - 1 byte threshold
+//   - 1 byte share index, between 1 and 255
+//   - 4 byte secret checksum, the first bytes of the double SHA256 of the
+//     secret, used to verify the secret once restored
+//   - 64 byte share of the secret
+//   - 4 byte checksum, the first bytes of the double SHA256 of all
+//     preceding bytes
+const (
+	shareRootArmory byte = 1
+	shareRootHD     byte = 2
+
+	shareSecretSize = 64
+	shareSize       = 8 + shareSecretSize + 4
+)
+
+// Possible errors when backing up or restoring shares.
+var (
+	ErrInvalidShare      = errors.New("invalid share")
+	ErrInvalidShareCount = errors.New("threshold must be between 1 and " +
+		"the share count, which may not exceed 255")
+	ErrMismatchedShares = errors.New("shares are not from the same backup")
+	ErrNotEnoughShares  = errors.New("not enough shares to restore the " +
+		"root key")
+)
+
+// gfExp and gfLog are the exponent and logarithm tables of GF(2^8) with
+// the generator 3, using the reducing polynomial x^8 + x^4 + x^3 + x + 1.
+// gfExp is doubled in length so the sum of two logarithms may be used as
+// an index without reduction.
+var (
+	gfExp [510]byte
+	gfLog [256]byte
+)
+
+func init() {
+	x := byte(1)
+	for i := 0; i < 255; i++ {
+		gfExp[i] = x
+		gfExp[i+255] = x
+		gfLog[x] = byte(i)
+
+		// Multiply x by the generator, x*2 + x.
+		x2 := x << 1
+		if x&0x80 != 0 {
+			x2 ^= 0x1b
+		}
+		x ^= x2
+	}
+}
+
+// gfMul multiplies a and b in GF(2^8).
+func gfMul(a, b byte) byte {
+	if a == 0 || b == 0 {
+		return 0
+	}
+	return gfExp[int(gfLog[a])+int(gfLog[b])]
+}
+
+// gfDiv divides a by b in GF(2^8).  b must not be zero.
+func gfDiv(a, b byte) byte {
+	if a == 0 {
+		return 0
+	}
+	return gfExp[int(gfLog[a])+255-int(gfLog[b])]
+}
+
+// splitSecret splits secret into count shares, any threshold of which
+// recover the secret with combineShares.  The share at index i is for the
+// x coordinate i+1.
+func splitSecret(secret []byte, threshold, count int) ([][]byte, error) {
+	shares := make([][]byte, count)
+	for i := range shares {
+		shares[i] = make([]byte, len(secret))
+	}
+
+	coeffs := make([]byte, threshold-1)
+	defer zero(coeffs)
+	for i, b := range secret {
+		if _, err := rand.Read(coeffs); err != nil {
+			return nil, err
+		}
+
+		// Evaluate the polynomial at each share's x coordinate using
+		// Horner's method.
+		for j := range shares {
+			x := byte(j + 1)
+			var y byte
+			for k := len(coeffs) - 1; k >= 0; k-- {
+				y = gfMul(y, x) ^ coeffs[k]
+			}
+			shares[j][i] = gfMul(y, x) ^ b
+		}
+	}
+	return shares, nil
+}
+
+// combineShares recovers a secret split by splitSecret from the shares ys
+// at the distinct, non-zero x coordinates xs, using Lagrange interpolation
+// at zero.
+func combineShares(xs []byte, ys [][]byte) []byte {
+	secret := make([]byte, len(ys[0]))
+	for i, xi := range xs {
+		// Subtraction is addition (xor) in GF(2^8), so the basis
+		// polynomial at zero is the product of xj / (xi ^ xj).
+		l := byte(1)
+		for j, xj := range xs {
+			if i != j {
+				l = gfMul(l, gfDiv(xj, xi^xj))
+			}
+		}
+		for k := range secret {
+			secret[k] ^= gfMul(ys[i][k], l)
+		}
+	}
+	return secret
+}
+
+// BackupShares splits the root private key and chaincode of the wallet
+// into count shares, any threshold of which restore the wallet with
+// NewWalletFromShares.  The wallet must be unlocked.
+func (w *Wallet) BackupShares(threshold, count int) ([]string, error) {
+	if w.flags.watchingOnly {
+		return nil, ErrWalletIsWatchingOnly
+	}
+	if threshold < 1 || threshold > count || count > 255 {
+		return nil, ErrInvalidShareCount
+	}
+	netByte, err := watchingChainNet(w.net)
+	if err != nil {
+		return nil, err
+	}
+
+	// The wallet must be unlocked to decrypt the root private key.
+	if w.IsLocked() {
+		return nil, ErrWalletLocked
+	}
+	privkey, err := w.keyGenerator.unlock(w.secret)
+	if err != nil {
+		return nil, err
+	}
+	defer zero(privkey)
+
+	secret := make([]byte, shareSecretSize)
+	defer zero(secret)
+	copy(secret, pad(32, privkey))
+	copy(secret[32:], w.keyGenerator.chaincode[:])
+
+	rootType := shareRootArmory
+	if w.hd != nil {
+		rootType = shareRootHD
+	}
+	secretChecksum := btcwire.DoubleSha256(secret)[:4]
+
+	ys, err := splitSecret(secret, threshold, count)
+	if err != nil {
+		return nil, err
+	}
+	shares := make([]string, count)
+	for i, y := range ys {
+		var buf bytes.Buffer
+		buf.Write([]byte{rootType, netByte, byte(threshold), byte(i + 1)})
+		buf.Write(secretChecksum)
+		buf.Write(y)
+		buf.Write(btcwire.DoubleSha256(buf.Bytes())[:4])
+		shares[i] = btcutil.Base58Encode(buf.Bytes())
+		zero(y)
+	}
+	return shares, nil
+}
+
+// NewWalletFromShares creates a new wallet with the root private key and
+// chaincode restored from shares created by BackupShares.  At least the
+// threshold of shares must be given.  The restored wallet derives the same
+// addresses as the backed up wallet, but is otherwise new, and is
+// encrypted with passphrase.  name's and desc's binary representation must
+// not exceed 32 and 256 bytes, respectively.
+func NewWalletFromShares(name, desc string, shares []string,
+	passphrase []byte, net btcwire.BitcoinNet,
+	createdAt *BlockStamp) (*Wallet, error) {
+
+	netByte, err := watchingChainNet(net)
+	if err != nil {
+		return nil, err
+	}
+
+	// Decode and verify each share.  Every share must have the same
+	// header, and no share index may be repeated.
+	var header []byte
+	xs := make([]byte, 0, len(shares))
+	ys := make([][]byte, 0, len(shares))
+	for _, share := range shares {
+		b := btcutil.Base58Decode(share)
+		if len(b) != shareSize {
+			return nil, ErrInvalidShare
+		}
+		payload, checksum := b[:len(b)-4], b[len(b)-4:]
+		if !bytes.Equal(btcwire.DoubleSha256(payload)[:4], checksum) {
+			return nil, ErrInvalidShare
+		}
+		if payload[1] != netByte {
+			return nil, errors.New("share is for a different network")
+		}
+		if payload[2] == 0 || payload[3] == 0 {
+			return nil, ErrInvalidShare
+		}
+
+		// The share index is excluded when comparing headers.
+		h := append([]byte{payload[0], payload[1], payload[2]},
+			payload[4:8]...)
+		if header == nil {
+			header = h
+		} else if !bytes.Equal(header, h) {
+			return nil, ErrMismatchedShares
+		}
+		if bytes.IndexByte(xs, payload[3]) != -1 {
+			return nil, fmt.Errorf("share %d is repeated", payload[3])
+		}
+		xs = append(xs, payload[3])
+		ys = append(ys, payload[8:])
+	}
+	if header == nil || len(xs) < int(header[2]) {
+		return nil, ErrNotEnoughShares
+	}
+
+	var hd bool
+	switch header[0] {
+	case shareRootArmory:
+	case shareRootHD:
+		hd = true
+	default:
+		return nil, ErrInvalidShare
+	}
+
+	// Restore the secret, verifying it matches the secret checksum.
+	// Shares from different backups of the same wallet have the same
+	// header, but do not restore the secret when combined.
+	secret := combineShares(xs, ys)
+	defer zero(secret)
+	if !bytes.Equal(btcwire.DoubleSha256(secret)[:4], header[3:]) {
+		return nil, ErrMismatchedShares
+	}
+
+	rootkey := make([]byte, 32)
+	copy(rootkey, secret[:32])
+	return newWalletFromRootKey(name, desc, rootkey, secret[32:], hd,
+		passphrase, net, createdAt, 0)
+}
@@ -57,6 +57,7 @@ var (
 	ErrAddressNotFound      = errors.New("address not found")
 	ErrAlreadyEncrypted     = errors.New("private key is already encrypted")
 	ErrChecksumMismatch     = errors.New("checksum mismatch")
+	ErrCommentTooLong       = errors.New("comment is too long")
 	ErrDuplicate            = errors.New("duplicate key or address")
 	ErrMalformedEntry       = errors.New("malformed entry")
 	ErrWalletIsWatchingOnly = errors.New("wallet is watching-only")
@@ -74,6 +75,8 @@ const (
 	txCommentHeader
 	deletedHeader
 	scriptHeader
+	hdChainsHeader
+	watchedAddrHeader
 	addrHeader entryHeader = 0
 )
 
@@ -306,20 +309,17 @@ func (v *version) WriteTo(w io.Writer) (int64, error) {
 // LT returns whether v is an earlier version than v2.
 func (v version) LT(v2 version) bool {
 	switch {
-	case v.major < v2.major:
-		return true
-
-	case v.minor < v2.minor:
-		return true
+	case v.major != v2.major:
+		return v.major < v2.major
 
-	case v.bugfix < v2.bugfix:
-		return true
+	case v.minor != v2.minor:
+		return v.minor < v2.minor
 
-	case v.autoincrement < v2.autoincrement:
-		return true
+	case v.bugfix != v2.bugfix:
+		return v.bugfix < v2.bugfix
 
 	default:
-		return false
+		return v.autoincrement < v2.autoincrement
 	}
 }
 
@@ -345,22 +345,7 @@ func (v version) EQ(v2 version) bool {
 
 // GT returns whether v is a later version than v2.
 func (v version) GT(v2 version) bool {
-	switch {
-	case v.major > v2.major:
-		return true
-
-	case v.minor > v2.minor:
-		return true
-
-	case v.bugfix > v2.bugfix:
-		return true
-
-	case v.autoincrement > v2.autoincrement:
-		return true
-
-	default:
-		return false
-	}
+	return v2.LT(v)
 }
 
 // Various versions.
@@ -382,8 +367,20 @@ var (
 	// encrypt.
 	VersUnsetNeedsPrivkeyFlag = version{1, 36, 1, 0}
 
+	// VersHDChains is the version where new wallets use a BIP32 master
+	// key as the root address, and derive addresses from separate
+	// external and internal BIP32 chains saved in an appended entry.
+	// Wallets without the appended entry continue to use Armory
+	// address chaining.
+	VersHDChains = version{1, 37, 0, 0}
+
+	// VersWatchedAddrs is the version where pubkey hash addresses may be
+	// imported without a private key, saved in their own appended entry
+	// type.
+	VersWatchedAddrs = version{1, 38, 0, 0}
+
 	// VersCurrent is the current wallet file version.
-	VersCurrent = VersUnsetNeedsPrivkeyFlag
+	VersCurrent = VersWatchedAddrs
 )
 
 type varEntries struct {
@@ -442,6 +439,21 @@ func (v *varEntries) ReadFrom(r io.Reader) (n int64, err error) {
 			}
 			n += read
 			wt = &entry
+		case watchedAddrHeader:
+			var entry watchedAddrEntry
+			entry.addr.wallet = v.wallet
+			if read, err = entry.ReadFrom(r); err != nil {
+				return n + read, err
+			}
+			n += read
+			wt = &entry
+		case hdChainsHeader:
+			var entry hdChainsEntry
+			if read, err = entry.ReadFrom(r); err != nil {
+				return n + read, err
+			}
+			n += read
+			wt = &entry
 		case addrCommentHeader:
 			var entry addrCommentEntry
 			if read, err = entry.ReadFrom(r); err != nil {
@@ -511,13 +523,79 @@ type Wallet struct {
 	importedAddrs    []walletAddress
 	lastChainIdx     int64
 	missingKeysStart int64
+
+	// hd holds the BIP32 chains of an HD wallet, or is nil for wallets
+	// using Armory address chaining.  It is serialized as an appended
+	// entry.
+	hd *hdChains
 }
 
-// NewWallet creates and initializes a new Wallet.  name's and
+// NewWallet creates and initializes a new HD Wallet.  name's and
 // desc's binary representation must not exceed 32 and 256 bytes,
 // respectively.  All address private keys are encrypted with passphrase.
-// The wallet is returned locked.
+// The root address is a BIP32 master key derived from a random seed.
+// HD wallets derive addresses as they are needed, so unlike Armory
+// wallets, no keypool is filled.  The wallet is returned locked.
 func NewWallet(name, desc string, passphrase []byte, net btcwire.BitcoinNet,
+	createdAt *BlockStamp) (*Wallet, error) {
+
+	// Randomly-generate seed.
+	seed := make([]byte, 32)
+	if _, err := rand.Read(seed); err != nil {
+		return nil, err
+	}
+	defer zero(seed)
+
+	return NewWalletFromSeed(name, desc, seed, passphrase, net, createdAt)
+}
+
+// NewWalletFromSeed creates and initializes a new HD Wallet with the
+// BIP32 master key derived from seed, such as the seed of a mnemonic
+// returned by MnemonicSeed.  Wallets created from the same seed derive the
+// same addresses.  Otherwise, this is the same as NewWallet.
+func NewWalletFromSeed(name, desc string, seed, passphrase []byte,
+	net btcwire.BitcoinNet, createdAt *BlockStamp) (*Wallet, error) {
+
+	// Derive the master key and chaincode from the seed.
+	rootkey, chaincode, err := NewMasterKey(seed)
+	if err != nil {
+		return nil, err
+	}
+
+	return newWalletFromRootKey(name, desc, rootkey, chaincode, true,
+		passphrase, net, createdAt, 0)
+}
+
+// newArmoryWallet creates and initializes a new Wallet using Armory
+// address chaining from a randomly-generated root key and chaincode, with
+// a keypool of keypoolSize addresses.  New wallets are always HD wallets,
+// but existing Armory wallets are still read and used, so tests create
+// them to cover Armory address chaining.
+func newArmoryWallet(name, desc string, passphrase []byte,
+	net btcwire.BitcoinNet, createdAt *BlockStamp,
+	keypoolSize uint) (*Wallet, error) {
+
+	// Randomly-generate rootkey and chaincode.
+	rootkey, chaincode := make([]byte, 32), make([]byte, 32)
+	if _, err := rand.Read(rootkey); err != nil {
+		return nil, err
+	}
+	if _, err := rand.Read(chaincode); err != nil {
+		return nil, err
+	}
+
+	return newWalletFromRootKey(name, desc, rootkey, chaincode, false,
+		passphrase, net, createdAt, keypoolSize)
+}
+
+// newWalletFromRootKey creates and initializes a new Wallet with the root
+// address private key rootkey and chaincode.  If hd is true, rootkey and
+// chaincode are used as a BIP32 master key, and addresses are derived from
+// its external and internal chains.  Otherwise, addresses are derived with
+// Armory address chaining, and the keypool is filled with keypoolSize
+// addresses.  The wallet is returned locked.
+func newWalletFromRootKey(name, desc string, rootkey, chaincode []byte,
+	hd bool, passphrase []byte, net btcwire.BitcoinNet,
 	createdAt *BlockStamp, keypoolSize uint) (*Wallet, error) {
 
 	// Check sizes of inputs.
@@ -533,13 +611,13 @@ func NewWallet(name, desc string, passphrase []byte, net btcwire.BitcoinNet,
 		return nil, errors.New("wallets must use mainnet or testnet3")
 	}
 
-	// Randomly-generate rootkey and chaincode.
-	rootkey, chaincode := make([]byte, 32), make([]byte, 32)
-	if _, err := rand.Read(rootkey); err != nil {
-		return nil, err
-	}
-	if _, err := rand.Read(chaincode); err != nil {
-		return nil, err
+	var hdc *hdChains
+	if hd {
+		var err error
+		hdc, err = newHDChains(rootkey, chaincode)
+		if err != nil {
+			return nil, err
+		}
 	}
 
 	// Compute AES key and encrypt root address.
@@ -572,6 +650,7 @@ func NewWallet(name, desc string, passphrase []byte, net btcwire.BitcoinNet,
 		chainIdxMap:    make(map[int64]btcutil.Address),
 		lastChainIdx:   rootKeyChainIdx,
 		secret:         aeskey,
+		hd:             hdc,
 	}
 	copy(w.name[:], []byte(name))
 	copy(w.desc[:], []byte(desc))
@@ -599,9 +678,11 @@ func NewWallet(name, desc string, passphrase []byte, net btcwire.BitcoinNet,
 	w.addrMap[getAddressKey(rootAddr)] = &w.keyGenerator
 	w.chainIdxMap[rootKeyChainIdx] = rootAddr
 
-	// Fill keypool.
-	if err := w.extendKeypool(keypoolSize, createdAt); err != nil {
-		return nil, err
+	// Fill keypool of Armory wallets.
+	if hdc == nil {
+		if err := w.extendKeypool(keypoolSize, createdAt); err != nil {
+			return nil, err
+		}
 	}
 
 	// Wallet must be returned locked.
@@ -634,6 +715,7 @@ func (w *Wallet) ReadFrom(r io.Reader) (n int64, err error) {
 	w.addrCommentMap = make(map[addressKey]comment)
 	w.chainIdxMap = make(map[int64]btcutil.Address)
 	w.txCommentMap = make(map[transactionHashKey]comment)
+	w.hd = nil
 
 	var id [8]byte
 	appendedEntries := varEntries{wallet: w}
@@ -718,6 +800,12 @@ func (w *Wallet) ReadFrom(r io.Reader) (n int64, err error) {
 			// script are always imported.
 			w.importedAddrs = append(w.importedAddrs, &e.script)
 
+		case *watchedAddrEntry:
+			addr := e.addr.Address()
+			w.addrMap[getAddressKey(addr)] = &e.addr
+			// watched addresses are always imported.
+			w.importedAddrs = append(w.importedAddrs, &e.addr)
+
 		case *addrCommentEntry:
 			addr, err := e.address(w.net)
 			if err != nil {
@@ -730,6 +818,10 @@ func (w *Wallet) ReadFrom(r io.Reader) (n int64, err error) {
 			txKey := transactionHashKey(e.txHash[:])
 			w.txCommentMap[txKey] = comment(e.comment)
 
+		case *hdChainsEntry:
+			hd := e.hd
+			w.hd = &hd
+
 		default:
 			return n, errors.New("unknown appended entry")
 		}
@@ -767,9 +859,21 @@ func (w *Wallet) WriteTo(wtr io.Writer) (n int64, err error) {
 			copy(e.scriptHash160[:], btcAddr.AddrHash())
 			// scripts are always imported
 			importedAddrs = append(importedAddrs, e)
+
+		case *watchedAddress:
+			e := &watchedAddrEntry{
+				addr: *btcAddr,
+			}
+			copy(e.pubKeyHash160[:], btcAddr.AddrHash())
+			// watched addresses are always imported
+			importedAddrs = append(importedAddrs, e)
 		}
 	}
-	wts = append(chainedAddrs, importedAddrs...)
+	if w.hd != nil {
+		wts = append(wts, &hdChainsEntry{hd: *w.hd})
+	}
+	wts = append(wts, chainedAddrs...)
+	wts = append(wts, importedAddrs...)
 	for addr, comment := range w.addrCommentMap {
 		e := &addrCommentEntry{
 			comment: []byte(comment),
@@ -939,13 +1043,27 @@ func (w *Wallet) IsLocked() bool {
 	return len(w.secret) != 32
 }
 
+// WatchingOnly returns whether a wallet is a watching-only wallet, which
+// holds no private keys and can not be locked or unlocked.
+func (w *Wallet) WatchingOnly() bool {
+	return w.flags.watchingOnly
+}
+
 // NextChainedAddress attempts to get the next chained address.
 // If there are addresses available in the keypool, the next address
 // is used.  If not and the wallet is unlocked, the keypool is extended.
 // If locked, a new address's pubkey is chained off the last pubkey
 // and added to the wallet.
+//
+// HD wallets instead derive the next address of the external chain.
 func (w *Wallet) NextChainedAddress(bs *BlockStamp, keypoolSize uint) (btcutil.Address, error) {
-	addr, err := w.nextChainedAddress(bs, keypoolSize)
+	var addr *btcAddress
+	var err error
+	if w.hd != nil {
+		addr, err = w.nextChainedHDAddress(hdExternalChain, bs)
+	} else {
+		addr, err = w.nextChainedAddress(bs, keypoolSize)
+	}
 	if err != nil {
 		return nil, err
 	}
@@ -954,8 +1072,17 @@ func (w *Wallet) NextChainedAddress(bs *BlockStamp, keypoolSize uint) (btcutil.A
 	return addr.Address(), nil
 }
 
+// ChangeAddress returns the next chained address to be used for
+// transaction change.  HD wallets derive change addresses from the
+// internal chain.
 func (w *Wallet) ChangeAddress(bs *BlockStamp, keypoolSize uint) (btcutil.Address, error) {
-	addr, err := w.nextChainedAddress(bs, keypoolSize)
+	var addr *btcAddress
+	var err error
+	if w.hd != nil {
+		addr, err = w.nextChainedHDAddress(hdInternalChain, bs)
+	} else {
+		addr, err = w.nextChainedAddress(bs, keypoolSize)
+	}
 	if err != nil {
 		return nil, err
 	}
@@ -1111,6 +1238,10 @@ func (w *Wallet) extendLockedWallet(bs *BlockStamp) error {
 }
 
 func (w *Wallet) createMissingPrivateKeys() error {
+	if w.hd != nil {
+		return w.createMissingHDPrivateKeys()
+	}
+
 	idx := w.missingKeysStart
 	if idx == 0 {
 		return nil
@@ -1187,6 +1318,43 @@ func (w *Wallet) Address(a btcutil.Address) (WalletAddress, error) {
 	return btcaddr, nil
 }
 
+// AddressComment returns the comment saved for a wallet address, and
+// whether any comment has been saved.
+func (w *Wallet) AddressComment(a btcutil.Address) (string, bool) {
+	c, ok := w.addrCommentMap[getAddressKey(a)]
+	return string(c), ok
+}
+
+// CheckComment returns ErrCommentTooLong if c is too long to be saved as
+// an address comment.
+func CheckComment(c string) error {
+	if len(c) > maxCommentLen {
+		return ErrCommentTooLong
+	}
+	return nil
+}
+
+// SetAddressComment saves a comment for a wallet address, replacing any
+// previous comment.  An empty comment removes the address's comment.
+// ErrAddressNotFound is returned if the address is not in the wallet, and
+// ErrCommentTooLong if the comment is too long to be saved.
+func (w *Wallet) SetAddressComment(a btcutil.Address, c string) error {
+	key := getAddressKey(a)
+	if _, ok := w.addrMap[key]; !ok {
+		return ErrAddressNotFound
+	}
+	if err := CheckComment(c); err != nil {
+		return err
+	}
+
+	if c == "" {
+		delete(w.addrCommentMap, key)
+		return nil
+	}
+	w.addrCommentMap[key] = comment(c)
+	return nil
+}
+
 // Net returns the bitcoin network identifier for this wallet.
 func (w *Wallet) Net() btcwire.BitcoinNet {
 	return w.net
@@ -1449,6 +1617,11 @@ func (w *Wallet) ExportWatchingWallet() (*Wallet, error) {
 		lastChainIdx: w.lastChainIdx,
 	}
 
+	if w.hd != nil {
+		hd := *w.hd
+		ww.hd = &hd
+	}
+
 	kgwc := w.keyGenerator.watchingCopy(ww)
 	ww.keyGenerator = *(kgwc.(*btcAddress))
 	if len(w.recent.hashes) != 0 {
@@ -1598,6 +1771,37 @@ func (w *Wallet) ExtendActiveAddresses(n int, keypoolSize uint) ([]btcutil.Addre
 	return addrs, nil
 }
 
+// ExtendActiveChangeAddresses creates the next n change addresses and
+// marks each as active.  This is used with ExtendActiveAddresses to recover
+// the change addresses of an HD wallet, which are derived from a separate
+// chain.  Wallets using Armory address chaining derive change addresses
+// from the same chain as all other addresses, so no addresses are created
+// for these wallets.
+//
+// A slice is returned with the btcutil.Address of each new address.
+// The blockchain must be rescanned for these addresses.
+func (w *Wallet) ExtendActiveChangeAddresses(n int, keypoolSize uint) ([]btcutil.Address, error) {
+	if n <= 0 {
+		return nil, errors.New("n is not positive")
+	}
+	if w.hd == nil {
+		return nil, nil
+	}
+
+	last := w.addrMap[getAddressKey(w.chainIdxMap[w.highestUsed])]
+	bs := &BlockStamp{Height: last.FirstBlock()}
+
+	addrs := make([]btcutil.Address, 0, n)
+	for i := 0; i < n; i++ {
+		addr, err := w.ChangeAddress(bs, keypoolSize)
+		if err != nil {
+			return nil, err
+		}
+		addrs = append(addrs, addr)
+	}
+	return addrs, nil
+}
+
 type walletFlags struct {
 	useEncryption bool
 	watchingOnly  bool
@@ -1975,7 +2179,7 @@ type btcAddress struct {
 	flags             addrFlags
 	chaincode         [32]byte
 	chainIndex        int64
-	chainDepth        int64 // unused
+	chainDepth        int64 // HD chain and child index, unused by Armory
 	initVector        [16]byte
 	privKey           [32]byte
 	pubKey            *btcec.PublicKey
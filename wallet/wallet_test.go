@@ -20,6 +20,7 @@ import (
 	"bytes"
 	"crypto/ecdsa"
 	"crypto/rand"
+	"encoding/hex"
 	"github.com/conformal/btcec"
 	"github.com/conformal/btcscript"
 	"github.com/conformal/btcutil"
@@ -117,7 +118,7 @@ func TestScriptAddressSerializer(t *testing.T) {
 
 func TestWalletCreationSerialization(t *testing.T) {
 	createdAt := &BlockStamp{}
-	w1, err := NewWallet("banana wallet", "A wallet for testing.",
+	w1, err := newArmoryWallet("banana wallet", "A wallet for testing.",
 		[]byte("banana"), btcwire.MainNet, createdAt, 100)
 	if err != nil {
 		t.Error("Error creating new wallet: " + err.Error())
@@ -158,6 +159,72 @@ func TestWalletCreationSerialization(t *testing.T) {
 	//	}
 }
 
+func TestAddressComments(t *testing.T) {
+	createdAt := &BlockStamp{}
+	w1, err := NewWallet("banana wallet", "A wallet for testing.",
+		[]byte("banana"), btcwire.MainNet, createdAt)
+	if err != nil {
+		t.Fatal("Error creating new wallet: " + err.Error())
+	}
+	addr, err := w1.NextChainedAddress(createdAt, 100)
+	if err != nil {
+		t.Fatal("Error getting next address: " + err.Error())
+	}
+
+	if _, ok := w1.AddressComment(addr); ok {
+		t.Error("New address unexpectedly has a comment")
+	}
+	if err := w1.SetAddressComment(addr, "coffee"); err != nil {
+		t.Fatal("Error setting address comment: " + err.Error())
+	}
+
+	// Comments for addresses not in the wallet must not be saved.
+	other, err := btcutil.NewAddressPubKeyHash(make([]byte, 20),
+		btcwire.MainNet)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := w1.SetAddressComment(other, "tea"); err != ErrAddressNotFound {
+		t.Errorf("Setting comment for unknown address: got error %v, "+
+			"want %v", err, ErrAddressNotFound)
+	}
+
+	// Comments too long to be saved must be rejected, both when checked
+	// before creating an address and when set.
+	long := string(make([]byte, maxCommentLen+1))
+	if err := CheckComment(long); err != ErrCommentTooLong {
+		t.Errorf("Checking long comment: got error %v, want %v", err,
+			ErrCommentTooLong)
+	}
+	if err := w1.SetAddressComment(addr, long); err != ErrCommentTooLong {
+		t.Errorf("Setting long comment: got error %v, want %v", err,
+			ErrCommentTooLong)
+	}
+
+	buf := new(bytes.Buffer)
+	if _, err := w1.WriteTo(buf); err != nil {
+		t.Fatal("Error writing wallet: " + err.Error())
+	}
+	w2 := new(Wallet)
+	if _, err := w2.ReadFrom(buf); err != nil {
+		t.Fatal("Error reading written wallet: " + err.Error())
+	}
+
+	c, ok := w2.AddressComment(addr)
+	if !ok || c != "coffee" {
+		t.Errorf("Read address comment %q (saved %v), want %q", c, ok,
+			"coffee")
+	}
+
+	// Empty comments remove the comment.
+	if err := w2.SetAddressComment(addr, ""); err != nil {
+		t.Fatal("Error removing address comment: " + err.Error())
+	}
+	if _, ok := w2.AddressComment(addr); ok {
+		t.Error("Removed address comment is still saved")
+	}
+}
+
 func TestChaining(t *testing.T) {
 	tests := []struct {
 		name                       string
@@ -329,7 +396,7 @@ func TestWalletPubkeyChaining(t *testing.T) {
 	// Set a reasonable keypool size that isn't too big nor too small for testing.
 	const keypoolSize = 5
 
-	w, err := NewWallet("banana wallet", "A wallet for testing.",
+	w, err := newArmoryWallet("banana wallet", "A wallet for testing.",
 		[]byte("banana"), btcwire.MainNet, &BlockStamp{}, keypoolSize)
 	if err != nil {
 		t.Error("Error creating new wallet: " + err.Error())
@@ -505,7 +572,7 @@ func TestWalletPubkeyChaining(t *testing.T) {
 func TestWatchingWalletExport(t *testing.T) {
 	const keypoolSize = 10
 	createdAt := &BlockStamp{}
-	w, err := NewWallet("banana wallet", "A wallet for testing.",
+	w, err := newArmoryWallet("banana wallet", "A wallet for testing.",
 		[]byte("banana"), btcwire.MainNet, createdAt, keypoolSize)
 	if err != nil {
 		t.Error("Error creating new wallet: " + err.Error())
@@ -724,7 +791,7 @@ func TestImportPrivateKey(t *testing.T) {
 	const keypoolSize = 10
 	createHeight := int32(100)
 	createdAt := &BlockStamp{Height: createHeight}
-	w, err := NewWallet("banana wallet", "A wallet for testing.",
+	w, err := newArmoryWallet("banana wallet", "A wallet for testing.",
 		[]byte("banana"), btcwire.MainNet, createdAt, keypoolSize)
 	if err != nil {
 		t.Error("Error creating new wallet: " + err.Error())
@@ -915,7 +982,7 @@ func TestImportScript(t *testing.T) {
 	const keypoolSize = 10
 	createHeight := int32(100)
 	createdAt := &BlockStamp{Height: createHeight}
-	w, err := NewWallet("banana wallet", "A wallet for testing.",
+	w, err := newArmoryWallet("banana wallet", "A wallet for testing.",
 		[]byte("banana"), btcwire.MainNet, createdAt, keypoolSize)
 	if err != nil {
 		t.Error("Error creating new wallet: " + err.Error())
@@ -1186,7 +1253,7 @@ func TestImportScript(t *testing.T) {
 func TestChangePassphrase(t *testing.T) {
 	const keypoolSize = 10
 	createdAt := &BlockStamp{}
-	w, err := NewWallet("banana wallet", "A wallet for testing.",
+	w, err := newArmoryWallet("banana wallet", "A wallet for testing.",
 		[]byte("banana"), btcwire.MainNet, createdAt, keypoolSize)
 	if err != nil {
 		t.Error("Error creating new wallet: " + err.Error())
@@ -1272,3 +1339,702 @@ func TestChangePassphrase(t *testing.T) {
 		return
 	}
 }
+
+func TestBIP32Vectors(t *testing.T) {
+	// Test vector 1 from BIP0032, deriving m/0'/1.
+	seed, _ := hex.DecodeString("000102030405060708090a0b0c0d0e0f")
+	tests := []struct {
+		name      string
+		index     uint32
+		privkey   string
+		chaincode string
+		pubkey    string
+	}{
+		{
+			name:      "m",
+			privkey:   "e8f32e723decf4051aefac8e2c93c9c5b214313817cdb01a1494b917c8436b35",
+			chaincode: "873dff81c02f525623fd1fe5167eac3a55a049de3d314bb42ee227ffed37d508",
+			pubkey:    "0339a36013301597daef41fbe593a02cc513d0b55527ec2df1050e2e8ff49c85c2",
+		},
+		{
+			name:      "m/0'",
+			index:     HardenedKeyStart + 0,
+			privkey:   "edb2e14f9ee77d26dd93b4ecede8d16ed408ce149b6cd80b0715a2d911a0afea",
+			chaincode: "47fdacbd0f1097043b78c63c20c34ef4ed9a111d980047ad16282c7ae6236141",
+			pubkey:    "035a784662a4a20a65bf6aab9ae98a6c068a81c52e4b032c0fb5400c706cfccc56",
+		},
+		{
+			name:      "m/0'/1",
+			index:     1,
+			privkey:   "3c6cb8d0f6a264c91ea8b5030fadaa8e538b020f0a387421a12de9319dc93368",
+			chaincode: "2a7857631386ba23dacac34180dd1983734e444fdbf774041578e9b6adb37c19",
+			pubkey:    "03501e454bf00751f24b1b489aa925215d66af2234e3891c3b21a52bedb3cd711c",
+		},
+	}
+
+	var privkey, chaincode, pubkey []byte
+	for i, test := range tests {
+		var err error
+		if i == 0 {
+			privkey, chaincode, err = NewMasterKey(seed)
+			if err != nil {
+				t.Errorf("%s: cannot create master key: %v", test.name, err)
+				return
+			}
+		} else {
+			parentPrivkey, parentChaincode := privkey, chaincode
+			privkey, chaincode, err = ChildPrivKey(parentPrivkey,
+				parentChaincode, test.index)
+			if err != nil {
+				t.Errorf("%s: cannot derive child privkey: %v", test.name, err)
+				return
+			}
+
+			// Non-hardened children must also be derivable from the
+			// parent public key.
+			if test.index < HardenedKeyStart {
+				childPubkey, childChaincode, err := ChildPubKey(pubkey,
+					parentChaincode, test.index)
+				if err != nil {
+					t.Errorf("%s: cannot derive child pubkey: %v", test.name, err)
+					return
+				}
+				if hex.EncodeToString(childPubkey) != test.pubkey {
+					t.Errorf("%s: public derivation pubkey mismatch", test.name)
+					return
+				}
+				if !bytes.Equal(childChaincode, chaincode) {
+					t.Errorf("%s: public derivation chaincode mismatch", test.name)
+					return
+				}
+			}
+		}
+		pubkey = pubkeyFromPrivkey(privkey, true)
+
+		if hex.EncodeToString(privkey) != test.privkey {
+			t.Errorf("%s: privkey mismatch: got %x want %s", test.name,
+				privkey, test.privkey)
+			return
+		}
+		if hex.EncodeToString(chaincode) != test.chaincode {
+			t.Errorf("%s: chaincode mismatch: got %x want %s", test.name,
+				chaincode, test.chaincode)
+			return
+		}
+		if hex.EncodeToString(pubkey) != test.pubkey {
+			t.Errorf("%s: pubkey mismatch: got %x want %s", test.name,
+				pubkey, test.pubkey)
+			return
+		}
+	}
+
+	if _, _, err := ChildPubKey(pubkey, chaincode, HardenedKeyStart); err == nil {
+		t.Errorf("Derived a hardened child from a public key.")
+	}
+}
+
+func TestHDChains(t *testing.T) {
+	createdAt := &BlockStamp{}
+	w, err := NewWallet("banana wallet", "A wallet for testing.",
+		[]byte("banana"), btcwire.MainNet, createdAt)
+	if err != nil {
+		t.Error("Error creating new wallet: " + err.Error())
+		return
+	}
+	if w.hd == nil {
+		t.Error("New wallet does not use HD chains.")
+		return
+	}
+
+	// Derive external and change addresses while locked.
+	addr, err := w.NextChainedAddress(createdAt, 0)
+	if err != nil {
+		t.Errorf("Cannot get next chained address: %v", err)
+		return
+	}
+	changeAddr, err := w.ChangeAddress(createdAt, 0)
+	if err != nil {
+		t.Errorf("Cannot get change address: %v", err)
+		return
+	}
+
+	// The addresses must be the first children of the external and
+	// internal chains derived from the master key.
+	if err := w.Unlock([]byte("banana")); err != nil {
+		t.Errorf("Cannot unlock wallet: %v", err)
+		return
+	}
+	master, err := w.keyGenerator.unlock(w.secret)
+	if err != nil {
+		t.Errorf("Cannot unlock master key: %v", err)
+		return
+	}
+	for _, test := range []struct {
+		branch uint32
+		addr   btcutil.Address
+		change bool
+	}{
+		{hdExternalChain, addr, false},
+		{hdInternalChain, changeAddr, true},
+	} {
+		chainKey, chainCode, err := hdChainPrivKey(master,
+			w.keyGenerator.chaincode[:], test.branch)
+		if err != nil {
+			t.Errorf("Cannot derive chain %d: %v", test.branch, err)
+			return
+		}
+		privkey, _, err := ChildPrivKey(chainKey, chainCode, 0)
+		if err != nil {
+			t.Errorf("Cannot derive chain %d child: %v", test.branch, err)
+			return
+		}
+
+		info, err := w.Address(test.addr)
+		if err != nil {
+			t.Errorf("Cannot find chain %d address: %v", test.branch, err)
+			return
+		}
+		if info.Change() != test.change {
+			t.Errorf("Chain %d address has wrong change flag.", test.branch)
+			return
+		}
+		key, err := info.(PubKeyAddress).PrivKey()
+		if err != nil {
+			t.Errorf("Private key for chain %d address was not created: %v",
+				test.branch, err)
+			return
+		}
+		if !bytes.Equal(pad(32, key.D.Bytes()), privkey) {
+			t.Errorf("Chain %d address has the wrong private key.", test.branch)
+			return
+		}
+	}
+
+	// Chains must survive (de)serialization.
+	buf := new(bytes.Buffer)
+	if _, err := w.WriteTo(buf); err != nil {
+		t.Errorf("Cannot write wallet: %v", err)
+		return
+	}
+	w2 := new(Wallet)
+	if _, err := w2.ReadFrom(buf); err != nil {
+		t.Errorf("Cannot read wallet: %v", err)
+		return
+	}
+	if !reflect.DeepEqual(w.hd, w2.hd) {
+		t.Error("HD chains do not match after (de)serialization.")
+		return
+	}
+	addr, err = w.NextChainedAddress(createdAt, 0)
+	if err != nil {
+		t.Errorf("Cannot get next chained address: %v", err)
+		return
+	}
+	addr2, err := w2.NextChainedAddress(createdAt, 0)
+	if err != nil {
+		t.Errorf("Cannot get next chained address of re-read wallet: %v", err)
+		return
+	}
+	if addr.EncodeAddress() != addr2.EncodeAddress() {
+		t.Error("Next addresses of original and re-read wallets differ.")
+		return
+	}
+}
+
+func TestMnemonic(t *testing.T) {
+	// Test vectors from BIP0039, using the password "TREZOR".
+	tests := []struct {
+		entropy  string
+		mnemonic string
+		seed     string
+	}{
+		{
+			entropy:  "00000000000000000000000000000000",
+			mnemonic: "abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon about",
+			seed:     "c55257c360c07c72029aebc1b53c05ed0362ada38ead3e3e9efa3708e53495531f09a6987599d18264c1e1c92f2cf141630c7a3c4ab7c81b2f001698e7463b04",
+		},
+		{
+			entropy:  "7f7f7f7f7f7f7f7f7f7f7f7f7f7f7f7f",
+			mnemonic: "legal winner thank year wave sausage worth useful legal winner thank yellow",
+			seed:     "2e8905819b8723fe2c1d161860e5ee1830318dbf49a83bd451cfb8440c28bd6fa457fe1296106559a3c80937a1c1069be3a3a5bd381ee6260e8d9739fce1f607",
+		},
+		{
+			entropy:  "9e885d952ad362caeb4efe34a8e91bd2",
+			mnemonic: "ozone drill grab fiber curtain grace pudding thank cruise elder eight picnic",
+			seed:     "274ddc525802f7c828d8ef7ddbcdc5304e87ac3535913611fbbfa986d0c9e5476c91689f9c8a54fd55bd38606aa6a8595ad213d4c9c9f9aca3fb217069a41028",
+		},
+		{
+			entropy:  "ffffffffffffffffffffffffffffffffffffffffffffffff",
+			mnemonic: "zoo zoo zoo zoo zoo zoo zoo zoo zoo zoo zoo zoo zoo zoo zoo zoo zoo when",
+			seed:     "0cd6e5d827bb62eb8fc1e262254223817fd068a74b5b449cc2f667c3f1f985a76379b43348d952e2265b4cd129090758b3e3c2c49103b5051aac2eaeb890a528",
+		},
+		{
+			entropy:  "0000000000000000000000000000000000000000000000000000000000000000",
+			mnemonic: "abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon art",
+			seed:     "bda85446c68413707090a52022edd26a1c9462295029f2e60cd7c4f2bbd3097170af7a4d73245cafa9c3cca8d561a7c3de6f5d4a10be8ed2a5e608d68f92fcc8",
+		},
+	}
+
+	for i, test := range tests {
+		entropy, _ := hex.DecodeString(test.entropy)
+		mnemonic, err := NewMnemonic(entropy)
+		if err != nil {
+			t.Errorf("#%d: cannot create mnemonic: %v", i, err)
+			continue
+		}
+		if mnemonic != test.mnemonic {
+			t.Errorf("#%d: mnemonic mismatch: got %q want %q", i,
+				mnemonic, test.mnemonic)
+			continue
+		}
+
+		decoded, err := MnemonicEntropy(mnemonic)
+		if err != nil {
+			t.Errorf("#%d: cannot decode mnemonic: %v", i, err)
+			continue
+		}
+		if !bytes.Equal(decoded, entropy) {
+			t.Errorf("#%d: decoded entropy mismatch: got %x want %x", i,
+				decoded, entropy)
+			continue
+		}
+
+		seed, err := MnemonicSeed(mnemonic, "TREZOR")
+		if err != nil {
+			t.Errorf("#%d: cannot derive seed: %v", i, err)
+			continue
+		}
+		if hex.EncodeToString(seed) != test.seed {
+			t.Errorf("#%d: seed mismatch: got %x want %s", i, seed,
+				test.seed)
+			continue
+		}
+	}
+
+	// Mnemonics with a bad checksum or unknown words must be rejected.
+	invalid := []string{
+		"abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon",
+		"abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon banana",
+		"abandon abandon abandon",
+	}
+	for i, mnemonic := range invalid {
+		if _, err := MnemonicSeed(mnemonic, ""); err != ErrInvalidMnemonic {
+			t.Errorf("invalid #%d: unexpected error %v", i, err)
+		}
+	}
+}
+
+func TestWalletFromSeed(t *testing.T) {
+	mnemonic, err := GenerateMnemonic()
+	if err != nil {
+		t.Errorf("Cannot generate mnemonic: %v", err)
+		return
+	}
+	seed, err := MnemonicSeed(mnemonic, "")
+	if err != nil {
+		t.Errorf("Cannot derive seed from generated mnemonic: %v", err)
+		return
+	}
+
+	// Wallets restored from the same seed must derive the same addresses,
+	// even when encrypted with different passphrases.
+	createdAt := &BlockStamp{}
+	w1, err := NewWalletFromSeed("banana wallet", "A wallet for testing.",
+		seed, []byte("banana"), btcwire.MainNet, createdAt)
+	if err != nil {
+		t.Errorf("Cannot create wallet from seed: %v", err)
+		return
+	}
+	w2, err := NewWalletFromSeed("banana wallet", "A wallet for testing.",
+		seed, []byte("potato"), btcwire.MainNet, createdAt)
+	if err != nil {
+		t.Errorf("Cannot create wallet from seed: %v", err)
+		return
+	}
+
+	addrs1, err := w1.ExtendActiveAddresses(5, 0)
+	if err != nil {
+		t.Errorf("Cannot extend active addresses: %v", err)
+		return
+	}
+	changeAddrs1, err := w1.ExtendActiveChangeAddresses(5, 0)
+	if err != nil {
+		t.Errorf("Cannot extend active change addresses: %v", err)
+		return
+	}
+	addrs2, err := w2.ExtendActiveAddresses(5, 0)
+	if err != nil {
+		t.Errorf("Cannot extend active addresses: %v", err)
+		return
+	}
+	changeAddrs2, err := w2.ExtendActiveChangeAddresses(5, 0)
+	if err != nil {
+		t.Errorf("Cannot extend active change addresses: %v", err)
+		return
+	}
+	addrs1 = append(addrs1, changeAddrs1...)
+	addrs2 = append(addrs2, changeAddrs2...)
+	for i := range addrs1 {
+		if addrs1[i].EncodeAddress() != addrs2[i].EncodeAddress() {
+			t.Errorf("Address %d of wallets from the same seed differ.", i)
+			return
+		}
+	}
+}
+
+func TestWatchingChain(t *testing.T) {
+	createdAt := &BlockStamp{}
+	hdWallet, err := NewWallet("banana wallet", "A wallet for testing.",
+		[]byte("banana"), btcwire.MainNet, createdAt)
+	if err != nil {
+		t.Error("Error creating new wallet: " + err.Error())
+		return
+	}
+
+	armoryWallet, err := newArmoryWallet("banana wallet",
+		"A wallet for testing.", []byte("banana"), btcwire.MainNet,
+		createdAt, 0)
+	if err != nil {
+		t.Error("Error creating new wallet: " + err.Error())
+		return
+	}
+
+	for _, w := range []*Wallet{hdWallet, armoryWallet} {
+		chain, err := w.ExportWatchingChain()
+		if err != nil {
+			t.Errorf("Cannot export watching chain: %v", err)
+			return
+		}
+
+		// Watching chains must only be imported on the same network.
+		_, err = NewWatchingWallet("shop", "", chain, btcwire.TestNet3,
+			createdAt)
+		if err == nil {
+			t.Errorf("Imported watching chain for the wrong network.")
+			return
+		}
+
+		// Corrupted watching chains must fail the checksum.
+		corrupted := []byte(chain)
+		if corrupted[5] == '2' {
+			corrupted[5] = '3'
+		} else {
+			corrupted[5] = '2'
+		}
+		_, err = NewWatchingWallet("shop", "", string(corrupted),
+			btcwire.MainNet, createdAt)
+		if err != ErrInvalidWatchingChain {
+			t.Errorf("Corrupted watching chain returned wrong error: %v", err)
+			return
+		}
+
+		ww, err := NewWatchingWallet("shop", "", chain, btcwire.MainNet,
+			createdAt)
+		if err != nil {
+			t.Errorf("Cannot import watching chain: %v", err)
+			return
+		}
+		if !ww.WatchingOnly() {
+			t.Errorf("Imported wallet is not watching-only.")
+			return
+		}
+		if ww.Name() != "shop" {
+			t.Errorf("Imported wallet has wrong name %q.", ww.Name())
+			return
+		}
+
+		// Addresses derived by the watching wallet must match the
+		// original wallet's.
+		for i := 0; i < 5; i++ {
+			addr, err := w.NextChainedAddress(createdAt, 0)
+			if err != nil {
+				t.Errorf("Cannot get next chained address: %v", err)
+				return
+			}
+			waddr, err := ww.NextChainedAddress(createdAt, 0)
+			if err != nil {
+				t.Errorf("Cannot get next watching address: %v", err)
+				return
+			}
+			if addr.EncodeAddress() != waddr.EncodeAddress() {
+				t.Errorf("Next addresses for each wallet do not match.")
+				return
+			}
+		}
+
+		// The watching wallet must survive (de)serialization.
+		buf := new(bytes.Buffer)
+		if _, err := ww.WriteTo(buf); err != nil {
+			t.Errorf("Cannot write watching wallet: %v", err)
+			return
+		}
+		ww2 := new(Wallet)
+		if _, err := ww2.ReadFrom(buf); err != nil {
+			t.Errorf("Cannot read watching wallet: %v", err)
+			return
+		}
+		if !reflect.DeepEqual(ww, ww2) {
+			t.Errorf("Imported and read-in watching wallets do not match.")
+			return
+		}
+	}
+}
+
+func TestImportWatched(t *testing.T) {
+	createHeight := int32(100)
+	createdAt := &BlockStamp{Height: createHeight}
+	w, err := NewWallet("banana wallet", "A wallet for testing.",
+		[]byte("banana"), btcwire.MainNet, createdAt)
+	if err != nil {
+		t.Error("Error creating new wallet: " + err.Error())
+		return
+	}
+
+	importHeight := int32(50)
+	stamp := &BlockStamp{Height: importHeight}
+
+	// Import a public key.
+	pubkeyHex := "0339a36013301597daef41fbe593a02cc513d0b55527ec2df1050e2e8ff49c85c2"
+	pubkey, _ := hex.DecodeString(pubkeyHex)
+	pkAddr, err := w.ImportWatchedPubKey(pubkey, stamp)
+	if err != nil {
+		t.Errorf("Cannot import public key: %v", err)
+		return
+	}
+	if _, err := w.ImportWatchedPubKey(pubkey, stamp); err != ErrDuplicate {
+		t.Errorf("Importing duplicate public key returned wrong error: %v", err)
+		return
+	}
+
+	// Import a pubkey hash address without its public key.
+	hash := make([]byte, 20)
+	if _, err := rand.Read(hash); err != nil {
+		t.Error(err)
+		return
+	}
+	apkh, err := btcutil.NewAddressPubKeyHash(hash, btcwire.MainNet)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	pkhAddr, err := w.ImportWatchedAddress(apkh, stamp)
+	if err != nil {
+		t.Errorf("Cannot import address: %v", err)
+		return
+	}
+	testnetAddr, err := btcutil.NewAddressPubKeyHash(hash, btcwire.TestNet3)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if _, err := w.ImportWatchedAddress(testnetAddr, stamp); err == nil {
+		t.Errorf("Imported address for the wrong network.")
+		return
+	}
+
+	// Imports below the synced height must be rescanned.
+	if h := w.SyncHeight(); h != importHeight {
+		t.Errorf("Sync height %v does not match expected %v.", h, importHeight)
+		return
+	}
+
+	// The wallet must survive (de)serialization with both addresses.
+	buf := new(bytes.Buffer)
+	if _, err := w.WriteTo(buf); err != nil {
+		t.Errorf("Cannot write wallet: %v", err)
+		return
+	}
+	w2 := new(Wallet)
+	if _, err := w2.ReadFrom(buf); err != nil {
+		t.Errorf("Cannot read wallet: %v", err)
+		return
+	}
+
+	tests := []struct {
+		addr       btcutil.Address
+		pubkey     string
+		compressed bool
+	}{
+		{pkAddr, pubkeyHex, true},
+		{pkhAddr, "", false},
+	}
+	for _, wallet := range []*Wallet{w, w2} {
+		for _, test := range tests {
+			if !wallet.IsWatchOnly(test.addr) {
+				t.Errorf("%v is not watch-only.", test.addr)
+				return
+			}
+			ainfo, err := wallet.Address(test.addr)
+			if err != nil {
+				t.Errorf("Cannot look up %v: %v", test.addr, err)
+				return
+			}
+			pka := ainfo.(PubKeyAddress)
+			if pka.Address().EncodeAddress() != test.addr.EncodeAddress() {
+				t.Errorf("Looked up address %v does not match %v.",
+					pka.Address(), test.addr)
+				return
+			}
+			if s := pka.ExportPubKey(); s != test.pubkey {
+				t.Errorf("Public key %q does not match expected %q.",
+					s, test.pubkey)
+				return
+			}
+			if (pka.PubKey() != nil) != (test.pubkey != "") {
+				t.Errorf("Unexpected public key for %v.", test.addr)
+				return
+			}
+			if pka.Compressed() != test.compressed {
+				t.Errorf("Wrong compressed flag for %v.", test.addr)
+				return
+			}
+			if !pka.Imported() || pka.FirstBlock() != importHeight {
+				t.Errorf("Wrong import details for %v.", test.addr)
+				return
+			}
+			if _, err := pka.PrivKey(); err != ErrWatchOnlyAddress {
+				t.Errorf("PrivKey returned wrong error: %v", err)
+				return
+			}
+			if _, err := pka.ExportPrivKey(); err != ErrWatchOnlyAddress {
+				t.Errorf("ExportPrivKey returned wrong error: %v", err)
+				return
+			}
+		}
+	}
+
+	// Wallet addresses are not watch-only.
+	addr, err := w.NextChainedAddress(createdAt, 0)
+	if err != nil {
+		t.Errorf("Cannot get next chained address: %v", err)
+		return
+	}
+	if w.IsWatchOnly(addr) {
+		t.Errorf("Chained address is watch-only.")
+		return
+	}
+}
+
+func TestBackupShares(t *testing.T) {
+	createdAt := &BlockStamp{}
+	hdWallet, err := NewWallet("banana wallet", "A wallet for testing.",
+		[]byte("banana"), btcwire.MainNet, createdAt)
+	if err != nil {
+		t.Error("Error creating new wallet: " + err.Error())
+		return
+	}
+
+	armoryWallet, err := newArmoryWallet("banana wallet",
+		"A wallet for testing.", []byte("banana"), btcwire.MainNet,
+		createdAt, 0)
+	if err != nil {
+		t.Error("Error creating new wallet: " + err.Error())
+		return
+	}
+
+	for _, w := range []*Wallet{hdWallet, armoryWallet} {
+		// Shares may only be created while unlocked.
+		if _, err := w.BackupShares(2, 3); err != ErrWalletLocked {
+			t.Errorf("Backing up locked wallet returned wrong error: %v", err)
+			return
+		}
+		if err := w.Unlock([]byte("banana")); err != nil {
+			t.Errorf("Can't unlock wallet: %v", err)
+			return
+		}
+		if _, err := w.BackupShares(4, 3); err != ErrInvalidShareCount {
+			t.Errorf("Invalid threshold returned wrong error: %v", err)
+			return
+		}
+		shares, err := w.BackupShares(2, 3)
+		if err != nil {
+			t.Errorf("Cannot back up shares: %v", err)
+			return
+		}
+		if len(shares) != 3 {
+			t.Errorf("Got %d shares, expected 3.", len(shares))
+			return
+		}
+		otherShares, err := w.BackupShares(2, 3)
+		if err != nil {
+			t.Errorf("Cannot back up shares: %v", err)
+			return
+		}
+		if err := w.Lock(); err != nil {
+			t.Errorf("Cannot lock wallet: %v", err)
+			return
+		}
+
+		restore := func(shares ...string) (*Wallet, error) {
+			return NewWalletFromShares("restored", "", shares,
+				[]byte("apple"), btcwire.MainNet, createdAt)
+		}
+		if _, err := restore(shares[0]); err != ErrNotEnoughShares {
+			t.Errorf("Restoring from one share returned wrong error: %v", err)
+			return
+		}
+		if _, err := restore(shares[0], otherShares[1]); err != ErrMismatchedShares {
+			t.Errorf("Restoring from different backups returned wrong error: %v", err)
+			return
+		}
+		corrupted := []byte(shares[1])
+		if corrupted[5] == '2' {
+			corrupted[5] = '3'
+		} else {
+			corrupted[5] = '2'
+		}
+		if _, err := restore(shares[0], string(corrupted)); err != ErrInvalidShare {
+			t.Errorf("Corrupted share returned wrong error: %v", err)
+			return
+		}
+		_, err = NewWalletFromShares("restored", "", shares[:2],
+			[]byte("apple"), btcwire.TestNet3, createdAt)
+		if err == nil {
+			t.Errorf("Restored shares for the wrong network.")
+			return
+		}
+
+		var want []string
+		for i := 0; i < 3; i++ {
+			addr, err := w.NextChainedAddress(createdAt, 0)
+			if err != nil {
+				t.Errorf("Cannot get next chained address: %v", err)
+				return
+			}
+			want = append(want, addr.EncodeAddress())
+		}
+
+		// Any two shares must restore a wallet with the same addresses.
+		for _, pair := range [][]string{shares[:2], shares[1:], {shares[2], shares[0]}} {
+			rw, err := restore(pair...)
+			if err != nil {
+				t.Errorf("Cannot restore from shares: %v", err)
+				return
+			}
+			if (rw.hd == nil) != (w.hd == nil) {
+				t.Errorf("Restored wallet uses the wrong address chaining.")
+				return
+			}
+			if rw.keyGenerator.Address().EncodeAddress() !=
+				w.keyGenerator.Address().EncodeAddress() {
+				t.Errorf("Restored root address does not match.")
+				return
+			}
+			if err := rw.Unlock([]byte("apple")); err != nil {
+				t.Errorf("Can't unlock restored wallet: %v", err)
+				return
+			}
+			for _, addr := range want {
+				raddr, err := rw.NextChainedAddress(createdAt, 0)
+				if err != nil {
+					t.Errorf("Cannot get next restored address: %v", err)
+					return
+				}
+				if raddr.EncodeAddress() != addr {
+					t.Errorf("Next addresses for each wallet do not match.")
+					return
+				}
+			}
+		}
+	}
+}
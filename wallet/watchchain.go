@@ -0,0 +1,209 @@
+/*
+ * Copyright (c) 2014 Conformal Systems LLC <info@conformal.com>
+ *
+ * Permission to use, copy, modify, and distribute this software for any
+ * purpose with or without fee is hereby granted, provided that the above
+ * copyright notice and this permission notice appear in all copies.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+ * WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+ * MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ * ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+ * WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ * ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+ * OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ */
+
+package wallet
+
+import (
+	"bytes"
+	"errors"
+	"github.com/conformal/btcec"
+	"github.com/conformal/btcutil"
+	"github.com/conformal/btcwire"
+	"time"
+)
+
+// A watching chain is the text encoding of the public keys and chaincodes
+// needed to derive the same addresses as a wallet, without any private
+// keys.  It is the base58 encoding of the following bytes:
+//
+//   - 1 byte chain type, either watchingChainArmory or watchingChainHD
+//   - 1 byte network, 0 for mainnet or 1 for testnet3
+//   - the chain type's public keys and chaincodes (described below)
+//   - 4 byte checksum, the first bytes of the double SHA256 of all
+//     preceding bytes
+//
+// For Armory wallets, this is the serialized root public key (33 bytes if
+// compressed, or 65 bytes if uncompressed), followed by the 32 byte root
+// chaincode.  Addresses are derived with ChainedPubKey.
+//
+// For HD wallets, this is the 33 byte compressed public key and 32 byte
+// chaincode of the BIP32 external chain, followed by the public key and
+// chaincode of the internal chain.  Addresses are derived with ChildPubKey.
+// The root (master) key is not included, as the chains are derived from
+// it with hardened derivation.
+const (
+	watchingChainArmory byte = 1
+	watchingChainHD     byte = 2
+)
+
+// ErrInvalidWatchingChain describes an error where a watching chain can
+// not be decoded.
+var ErrInvalidWatchingChain = errors.New("invalid watching chain")
+
+// watchingChainNet returns the network byte of a watching chain for net.
+func watchingChainNet(net btcwire.BitcoinNet) (byte, error) {
+	switch net {
+	case btcwire.MainNet:
+		return 0, nil
+	case btcwire.TestNet3:
+		return 1, nil
+	default:
+		return 0, errors.New("unsupported network")
+	}
+}
+
+// ExportWatchingChain returns the watching chain text encoding of the
+// public keys and chaincodes a watching-only wallet created by
+// NewWatchingWallet needs to derive the same addresses as w.
+func (w *Wallet) ExportWatchingChain() (string, error) {
+	netByte, err := watchingChainNet(w.net)
+	if err != nil {
+		return "", err
+	}
+
+	var buf bytes.Buffer
+	if w.hd != nil {
+		buf.WriteByte(watchingChainHD)
+		buf.WriteByte(netByte)
+		for i := range w.hd.chains {
+			c := &w.hd.chains[i]
+			buf.Write(c.pubKey[:])
+			buf.Write(c.chaincode[:])
+		}
+	} else {
+		buf.WriteByte(watchingChainArmory)
+		buf.WriteByte(netByte)
+		buf.Write(w.keyGenerator.pubKeyBytes())
+		buf.Write(w.keyGenerator.chaincode[:])
+	}
+	buf.Write(btcwire.DoubleSha256(buf.Bytes())[:4])
+	return btcutil.Base58Encode(buf.Bytes()), nil
+}
+
+// NewWatchingWallet creates a new watching-only wallet deriving the same
+// addresses as the wallet which exported the watching chain.  The watching
+// chain must be for the network net.  name's and desc's binary
+// representation must not exceed 32 and 256 bytes, respectively.
+func NewWatchingWallet(name, desc, chain string, net btcwire.BitcoinNet,
+	createdAt *BlockStamp) (*Wallet, error) {
+
+	// Check sizes of inputs.
+	if len([]byte(name)) > 32 {
+		return nil, errors.New("name exceeds 32 byte maximum size")
+	}
+	if len([]byte(desc)) > 256 {
+		return nil, errors.New("desc exceeds 256 byte maximum size")
+	}
+
+	// Decode and verify the watching chain.
+	b := btcutil.Base58Decode(chain)
+	if len(b) < 6 {
+		return nil, ErrInvalidWatchingChain
+	}
+	payload, checksum := b[:len(b)-4], b[len(b)-4:]
+	if !bytes.Equal(btcwire.DoubleSha256(payload)[:4], checksum) {
+		return nil, ErrInvalidWatchingChain
+	}
+	netByte, err := watchingChainNet(net)
+	if err != nil {
+		return nil, err
+	}
+	if payload[1] != netByte {
+		return nil, errors.New("watching chain is for a different network")
+	}
+
+	// Read the root public key and chaincode.  For HD wallets, the
+	// external chain is used as the root address, as the master key is
+	// not exported.
+	var hd *hdChains
+	var rootPubKey, rootChaincode []byte
+	keys := payload[2:]
+	switch payload[0] {
+	case watchingChainArmory:
+		switch len(keys) {
+		case 33 + 32, 65 + 32:
+		default:
+			return nil, ErrInvalidWatchingChain
+		}
+		rootPubKey = keys[:len(keys)-32]
+		rootChaincode = keys[len(keys)-32:]
+
+	case watchingChainHD:
+		hd = new(hdChains)
+		if len(keys) != len(hd.chains)*(33+32) {
+			return nil, ErrInvalidWatchingChain
+		}
+		for i := range hd.chains {
+			c := &hd.chains[i]
+			copy(c.pubKey[:], keys[:33])
+			copy(c.chaincode[:], keys[33:65])
+			keys = keys[65:]
+			_, err := btcec.ParsePubKey(c.pubKey[:], btcec.S256())
+			if err != nil {
+				return nil, ErrInvalidWatchingChain
+			}
+		}
+		rootPubKey = hd.chains[hdExternalChain].pubKey[:]
+		rootChaincode = hd.chains[hdExternalChain].chaincode[:]
+
+	default:
+		return nil, ErrInvalidWatchingChain
+	}
+
+	// Create and fill wallet.
+	w := &Wallet{
+		vers: VersCurrent,
+		net:  net,
+		flags: walletFlags{
+			useEncryption: false,
+			watchingOnly:  true,
+		},
+		createDate:  time.Now().Unix(),
+		highestUsed: rootKeyChainIdx,
+		recent: recentBlocks{
+			lastHeight: createdAt.Height,
+			hashes: []*btcwire.ShaHash{
+				&createdAt.Hash,
+			},
+		},
+		addrMap:        make(map[addressKey]walletAddress),
+		addrCommentMap: make(map[addressKey]comment),
+		txCommentMap:   make(map[transactionHashKey]comment),
+		chainIdxMap:    make(map[int64]btcutil.Address),
+		lastChainIdx:   rootKeyChainIdx,
+		hd:             hd,
+	}
+	copy(w.name[:], []byte(name))
+	copy(w.desc[:], []byte(desc))
+
+	// Create the root address without a private key.  As with the root
+	// of an exported watching wallet, the private key is never created.
+	root, err := newBtcAddressWithoutPrivkey(w, rootPubKey, nil, createdAt)
+	if err != nil {
+		return nil, ErrInvalidWatchingChain
+	}
+	root.flags.createPrivKeyNextUnlock = false
+	copy(root.chaincode[:], rootChaincode)
+	root.chainIndex = rootKeyChainIdx
+	w.keyGenerator = *root
+
+	// Add root address to maps.
+	rootAddr := w.keyGenerator.Address()
+	w.addrMap[getAddressKey(rootAddr)] = &w.keyGenerator
+	w.chainIdxMap[rootKeyChainIdx] = rootAddr
+
+	return w, nil
+}
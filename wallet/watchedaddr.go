@@ -0,0 +1,450 @@
+/*
+ * Copyright (c) 2014 Conformal Systems LLC <info@conformal.com>
+ *
+ * Permission to use, copy, modify, and distribute this software for any
+ * purpose with or without fee is hereby granted, provided that the above
+ * copyright notice and this permission notice appear in all copies.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+ * WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+ * MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ * ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+ * WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ * ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+ * OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ */
+
+package wallet
+
+import (
+	"bytes"
+	"code.google.com/p/go.crypto/ripemd160"
+	"crypto/ecdsa"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"github.com/conformal/btcec"
+	"github.com/conformal/btcutil"
+	"io"
+	"time"
+)
+
+// ErrWatchOnlyAddress describes an error where the private key of a
+// watch-only address was requested.  Watch-only addresses are imported
+// without a private key, and their outputs can not be spent by the wallet.
+var ErrWatchOnlyAddress = errors.New("address is watch-only")
+
+// watchedAddress is a pay-to-pubkey-hash address imported without a
+// private key.  Transactions paying to and spending from the address are
+// tracked like any other wallet address, but the wallet is unable to sign
+// for it.  The public key is only known if it was imported with the
+// address.
+type watchedAddress struct {
+	wallet            *Wallet
+	address           *btcutil.AddressPubKeyHash
+	flags             watchedFlags
+	pubKey            publicKey // nil unless flags.hasPubKey
+	firstSeen         int64
+	lastSeen          int64
+	firstBlock        int32
+	partialSyncHeight int32
+}
+
+// newWatchedAddress initializes and returns a new watch-only address.
+// pubkey is the serialized public key of the address, or nil if it is not
+// known.
+func newWatchedAddress(wallet *Wallet, address *btcutil.AddressPubKeyHash,
+	pubkey []byte, bs *BlockStamp) *watchedAddress {
+
+	return &watchedAddress{
+		wallet:  wallet,
+		address: address,
+		flags: watchedFlags{
+			hasPubKey: pubkey != nil,
+		},
+		pubKey:     pubkey,
+		firstSeen:  time.Now().Unix(),
+		firstBlock: bs.Height,
+	}
+}
+
+// ImportWatchedAddress imports a pay-to-pubkey-hash address without a
+// private key to the wallet.  Balances and history of the address are
+// tracked, but the wallet can not spend its outputs.  ErrDuplicate is
+// returned if the address is already in the wallet.
+func (w *Wallet) ImportWatchedAddress(addr btcutil.Address, bs *BlockStamp) (btcutil.Address, error) {
+	apkh, ok := addr.(*btcutil.AddressPubKeyHash)
+	if !ok {
+		return nil, errors.New("address is not a pubkey hash address")
+	}
+	if !apkh.IsForNet(w.net) {
+		return nil, errors.New("address is for a different network")
+	}
+	return w.importWatched(apkh, nil, bs)
+}
+
+// ImportWatchedPubKey imports the pay-to-pubkey-hash address of a
+// serialized public key to the wallet, without a private key.  The public
+// key is saved with the address, and may be used to create multisig
+// scripts.  ErrDuplicate is returned if the address is already in the
+// wallet.
+func (w *Wallet) ImportWatchedPubKey(pubkey []byte, bs *BlockStamp) (btcutil.Address, error) {
+	pk, err := btcec.ParsePubKey(pubkey, btcec.S256())
+	if err != nil {
+		return nil, err
+	}
+
+	// Reserialize the key, which also copies it, so hybrid encodings are
+	// saved in the same format as other wallet public keys.
+	var serialized []byte
+	if len(pubkey) == 33 {
+		serialized = pk.SerializeCompressed()
+	} else {
+		serialized = pk.SerializeUncompressed()
+	}
+	apkh, err := btcutil.NewAddressPubKeyHash(btcutil.Hash160(serialized),
+		w.net)
+	if err != nil {
+		return nil, err
+	}
+	return w.importWatched(apkh, serialized, bs)
+}
+
+// importWatched adds a watch-only address to the wallet.
+func (w *Wallet) importWatched(apkh *btcutil.AddressPubKeyHash,
+	pubkey []byte, bs *BlockStamp) (btcutil.Address, error) {
+
+	if _, ok := w.addrMap[getAddressKey(apkh)]; ok {
+		return nil, ErrDuplicate
+	}
+
+	waddr := newWatchedAddress(w, apkh, pubkey, bs)
+
+	// Mark as unsynced if import height is below currently-synced
+	// height.
+	if len(w.recent.hashes) != 0 && bs.Height < w.recent.lastHeight {
+		waddr.flags.unsynced = true
+	}
+
+	// Add address to wallet's bookkeeping structures.  Adding to
+	// the map will result in the imported address being serialized
+	// on the next WriteTo call.
+	w.addrMap[getAddressKey(apkh)] = waddr
+	w.importedAddrs = append(w.importedAddrs, waddr)
+
+	return apkh, nil
+}
+
+// IsWatchOnly returns whether addr is a watch-only address of the wallet,
+// imported without a private key.
+func (w *Wallet) IsWatchOnly(addr btcutil.Address) bool {
+	_, ok := w.addrMap[getAddressKey(addr)].(*watchedAddress)
+	return ok
+}
+
+type watchedFlags struct {
+	hasPubKey   bool
+	change      bool
+	unsynced    bool
+	partialSync bool
+}
+
+// ReadFrom implements the io.ReaderFrom interface by reading from r into wf.
+func (wf *watchedFlags) ReadFrom(r io.Reader) (int64, error) {
+	var b [8]byte
+	n, err := io.ReadFull(r, b[:])
+	if err != nil {
+		return int64(n), err
+	}
+
+	// Bits match those of addrFlags for the same fields.
+	wf.hasPubKey = b[0]&(1<<1) != 0
+	wf.change = b[0]&(1<<5) != 0
+	wf.unsynced = b[0]&(1<<6) != 0
+	wf.partialSync = b[0]&(1<<7) != 0
+
+	return int64(n), nil
+}
+
+// WriteTo implements the io.WriteTo interface by writing wf into w.
+func (wf *watchedFlags) WriteTo(w io.Writer) (int64, error) {
+	var b [8]byte
+	if wf.hasPubKey {
+		b[0] |= 1 << 1
+	}
+	if wf.change {
+		b[0] |= 1 << 5
+	}
+	if wf.unsynced {
+		b[0] |= 1 << 6
+	}
+	if wf.partialSync {
+		b[0] |= 1 << 7
+	}
+
+	n, err := w.Write(b[:])
+	return int64(n), err
+}
+
+// ReadFrom reads a watch-only address from an io.Reader.  The public key
+// is only read if the flags show it is present.
+func (a *watchedAddress) ReadFrom(r io.Reader) (n int64, err error) {
+	var read int64
+
+	// Checksums
+	var chkPubKeyHash uint32
+	var chkPubKey uint32
+	var pubKeyHash [ripemd160.Size]byte
+
+	// Read serialized wallet into addr fields and checksums.
+	datas := []interface{}{
+		&pubKeyHash,
+		&chkPubKeyHash,
+		make([]byte, 4), // version
+		&a.flags,
+	}
+	for _, data := range datas {
+		if rf, ok := data.(io.ReaderFrom); ok {
+			read, err = rf.ReadFrom(r)
+		} else {
+			read, err = binaryRead(r, binary.LittleEndian, data)
+		}
+		if err != nil {
+			return n + read, err
+		}
+		n += read
+	}
+	if err = verifyAndFix(pubKeyHash[:], chkPubKeyHash); err != nil {
+		return n, err
+	}
+
+	a.pubKey = nil
+	if a.flags.hasPubKey {
+		if read, err = a.pubKey.ReadFrom(r); err != nil {
+			return n + read, err
+		}
+		n += read
+		read, err = binaryRead(r, binary.LittleEndian, &chkPubKey)
+		if err != nil {
+			return n + read, err
+		}
+		n += read
+		if err = verifyAndFix(a.pubKey, chkPubKey); err != nil {
+			return n, err
+		}
+		if !bytes.Equal(btcutil.Hash160(a.pubKey), pubKeyHash[:]) {
+			return n, errors.New("watched public key does not " +
+				"match address")
+		}
+	}
+
+	datas = []interface{}{
+		&a.firstSeen,
+		&a.lastSeen,
+		&a.firstBlock,
+		&a.partialSyncHeight,
+	}
+	for _, data := range datas {
+		read, err = binaryRead(r, binary.LittleEndian, data)
+		if err != nil {
+			return n + read, err
+		}
+		n += read
+	}
+
+	a.address, err = btcutil.NewAddressPubKeyHash(pubKeyHash[:],
+		a.wallet.Net())
+	return n, err
+}
+
+// WriteTo implements io.WriterTo by writing the watchedAddress to w.
+func (a *watchedAddress) WriteTo(w io.Writer) (n int64, err error) {
+	var written int64
+
+	hash := a.address.ScriptAddress()
+	datas := []interface{}{
+		&hash,
+		walletHash(hash),
+		make([]byte, 4), // version
+		&a.flags,
+	}
+	if a.flags.hasPubKey {
+		datas = append(datas, &a.pubKey, walletHash(a.pubKey))
+	}
+	datas = append(datas,
+		&a.firstSeen,
+		&a.lastSeen,
+		&a.firstBlock,
+		&a.partialSyncHeight,
+	)
+	for _, data := range datas {
+		if wt, ok := data.(io.WriterTo); ok {
+			written, err = wt.WriteTo(w)
+		} else {
+			written, err = binaryWrite(w, binary.LittleEndian, data)
+		}
+		if err != nil {
+			return n + written, err
+		}
+		n += written
+	}
+	return n, nil
+}
+
+// Address returns the btcutil.AddressPubKeyHash of the watched address.
+func (a *watchedAddress) Address() btcutil.Address {
+	return a.address
+}
+
+// AddrHash returns the pubkey hash, implementing WalletAddress.
+func (a *watchedAddress) AddrHash() string {
+	return string(a.address.ScriptAddress())
+}
+
+// FirstBlock returns the first blockheight the address is known at.
+func (a *watchedAddress) FirstBlock() int32 {
+	return a.firstBlock
+}
+
+// Imported always returns true since watched addresses are never part of
+// an address chain.
+func (a *watchedAddress) Imported() bool {
+	return true
+}
+
+// Change returns true if the address was created as a change address.
+func (a *watchedAddress) Change() bool {
+	return a.flags.change
+}
+
+// Compressed returns whether the public key of the address is compressed.
+// False is returned if the public key is not known.
+// Implements WalletAddress.
+func (a *watchedAddress) Compressed() bool {
+	return a.flags.hasPubKey && len(a.pubKey) == 33
+}
+
+// SyncStatus returns a SyncStatus type for how the address is currently
+// synced.  For an Unsynced type, the value is the recorded first seen
+// block height of the address.
+// Implements WalletAddress.
+func (a *watchedAddress) SyncStatus() SyncStatus {
+	switch {
+	case a.flags.unsynced && !a.flags.partialSync:
+		return Unsynced(a.firstBlock)
+	case a.flags.unsynced && a.flags.partialSync:
+		return PartialSync(a.partialSyncHeight)
+	default:
+		return FullSync{}
+	}
+}
+
+// PubKey returns the public key of the address, or nil if the address was
+// imported without one.  Implements PubKeyAddress.
+func (a *watchedAddress) PubKey() *btcec.PublicKey {
+	if !a.flags.hasPubKey {
+		return nil
+	}
+	pk, err := btcec.ParsePubKey(a.pubKey, btcec.S256())
+	if err != nil {
+		return nil
+	}
+	return pk
+}
+
+// ExportPubKey returns the public key of the address serialised as a hex
+// encoded string, or an empty string if the address was imported without
+// one.  Implements PubKeyAddress.
+func (a *watchedAddress) ExportPubKey() string {
+	return hex.EncodeToString(a.pubKey)
+}
+
+// PrivKey always returns ErrWatchOnlyAddress since the wallet does not
+// have the private key of a watched address.  Implements PubKeyAddress.
+func (a *watchedAddress) PrivKey() (*ecdsa.PrivateKey, error) {
+	return nil, ErrWatchOnlyAddress
+}
+
+// ExportPrivKey always returns ErrWatchOnlyAddress since the wallet does
+// not have the private key of a watched address.  Implements
+// PubKeyAddress.
+func (a *watchedAddress) ExportPrivKey() (string, error) {
+	return "", ErrWatchOnlyAddress
+}
+
+// setSyncStatus sets the address flags and possibly the partial sync height
+// depending on the type of s.
+func (a *watchedAddress) setSyncStatus(s SyncStatus) {
+	switch e := s.(type) {
+	case Unsynced:
+		a.flags.unsynced = true
+		a.flags.partialSync = false
+		a.partialSyncHeight = 0
+
+	case PartialSync:
+		a.flags.unsynced = true
+		a.flags.partialSync = true
+		a.partialSyncHeight = int32(e)
+
+	case FullSync:
+		a.flags.unsynced = false
+		a.flags.partialSync = false
+		a.partialSyncHeight = 0
+	}
+}
+
+// watchingCopy creates a copy of a watched address for a watching wallet.
+// Watched addresses never have a private key, so all fields are copied.
+func (a *watchedAddress) watchingCopy(wallet *Wallet) walletAddress {
+	return &watchedAddress{
+		wallet:            wallet,
+		address:           a.address,
+		flags:             a.flags,
+		pubKey:            a.pubKey,
+		firstSeen:         a.firstSeen,
+		lastSeen:          a.lastSeen,
+		firstBlock:        a.firstBlock,
+		partialSyncHeight: a.partialSyncHeight,
+	}
+}
+
+// watchedAddrEntry is the entry type for a watch-only address.
+type watchedAddrEntry struct {
+	pubKeyHash160 [ripemd160.Size]byte
+	addr          watchedAddress
+}
+
+// WriteTo implements io.WriterTo by writing the entry to w.
+func (e *watchedAddrEntry) WriteTo(w io.Writer) (n int64, err error) {
+	var written int64
+
+	// Write header
+	if written, err = binaryWrite(w, binary.LittleEndian, watchedAddrHeader); err != nil {
+		return n + written, err
+	}
+	n += written
+
+	// Write hash
+	if written, err = binaryWrite(w, binary.LittleEndian, &e.pubKeyHash160); err != nil {
+		return n + written, err
+	}
+	n += written
+
+	// Write watchedAddress
+	written, err = e.addr.WriteTo(w)
+	n += written
+	return n, err
+}
+
+// ReadFrom implements io.ReaderFrom by reading the entry from r.
+func (e *watchedAddrEntry) ReadFrom(r io.Reader) (n int64, err error) {
+	var read int64
+
+	if read, err = binaryRead(r, binary.LittleEndian, &e.pubKeyHash160); err != nil {
+		return n + read, err
+	}
+	n += read
+
+	read, err = e.addr.ReadFrom(r)
+	return n + read, err
+}